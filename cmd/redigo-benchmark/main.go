@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultAddr = "localhost:6380"
+
+// prompt is written by the server before it reads each command, so it also
+// marks the end of every reply.
+var prompt = []byte("> ")
+
+type config struct {
+	addr     string
+	clients  int
+	requests int
+	pipeline int
+	keyspace int
+	dataSize int
+	quiet    bool
+}
+
+func main() {
+	var cfg config
+	var tests string
+	flag.StringVar(&cfg.addr, "addr", defaultAddr, "server address")
+	flag.IntVar(&cfg.clients, "c", 50, "number of parallel connections")
+	flag.IntVar(&cfg.requests, "n", 100000, "total number of requests per test")
+	flag.IntVar(&cfg.pipeline, "P", 1, "pipeline <n> requests per round trip")
+	flag.IntVar(&cfg.keyspace, "r", 100000, "use random keys from a key space of this size (0 uses a single key)")
+	flag.IntVar(&cfg.dataSize, "d", 3, "value size in bytes for SET")
+	flag.StringVar(&tests, "t", "set,get,incr", "comma separated list of tests to run")
+	flag.BoolVar(&cfg.quiet, "q", false, "quiet: only show throughput")
+	flag.Parse()
+
+	if cfg.clients < 1 || cfg.requests < 1 || cfg.pipeline < 1 || cfg.dataSize < 1 {
+		log.Fatalf("-c, -n, -P and -d must be positive")
+	}
+
+	for _, name := range strings.Split(tests, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		gen, ok := generators[name]
+		if !ok {
+			log.Fatalf("unknown test %q (want SET, GET or INCR)", name)
+		}
+		before, memErr := memCounters(cfg.addr)
+		res, err := run(cfg, gen)
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		if after, err := memCounters(cfg.addr); memErr == nil && err == nil {
+			res.allocs = after["mem_allocations"] - before["mem_allocations"]
+			res.gcs = after["mem_gc_cycles"] - before["mem_gc_cycles"]
+			res.memStats = true
+		}
+		res.report(os.Stdout, name, cfg)
+	}
+}
+
+// generator builds the command line for the i-th request of a client.
+type generator func(cfg config, rng *rand.Rand, value string) string
+
+var generators = map[string]generator{
+	"SET": func(cfg config, rng *rand.Rand, value string) string {
+		return "SET " + key(cfg, rng) + " " + value
+	},
+	"GET": func(cfg config, rng *rand.Rand, _ string) string {
+		return "GET " + key(cfg, rng)
+	},
+	"INCR": func(cfg config, rng *rand.Rand, _ string) string {
+		return "INCR counter:" + key(cfg, rng)
+	},
+}
+
+func key(cfg config, rng *rand.Rand) string {
+	if cfg.keyspace <= 0 {
+		return "key:__rand_int__"
+	}
+	return fmt.Sprintf("key:%012d", rng.Intn(cfg.keyspace))
+}
+
+type result struct {
+	elapsed   time.Duration
+	latencies []time.Duration
+	errors    int64
+
+	// Server heap allocations and GC cycles during the test, taken from
+	// INFO memory; memStats is false if the server doesn't report them.
+	allocs, gcs int64
+	memStats    bool
+}
+
+// run opens cfg.clients connections and splits cfg.requests between them.
+// Each client sends cfg.pipeline commands per round trip; every command in
+// a batch is charged the latency of the whole round trip.
+func run(cfg config, gen generator) (*result, error) {
+	value := strings.Repeat("x", cfg.dataSize)
+	conns := make([]*benchConn, cfg.clients)
+	for i := range conns {
+		c, err := dial(cfg.addr)
+		if err != nil {
+			for _, c := range conns[:i] {
+				c.Close()
+			}
+			return nil, err
+		}
+		conns[i] = c
+	}
+
+	var (
+		remaining = int64(cfg.requests)
+		errs      atomic.Int64
+		mu        sync.Mutex
+		all       = make([]time.Duration, 0, cfg.requests)
+		wg        sync.WaitGroup
+		firstErr  error
+	)
+	start := time.Now()
+	for i, c := range conns {
+		wg.Add(1)
+		go func(seed int64, c *benchConn) {
+			defer wg.Done()
+			defer c.Close()
+			rng := rand.New(rand.NewSource(seed))
+			local := make([]time.Duration, 0, cfg.requests/cfg.clients+cfg.pipeline)
+			for {
+				n := cfg.pipeline
+				left := atomic.AddInt64(&remaining, -int64(n))
+				if left < 0 {
+					n += int(left)
+				}
+				if n <= 0 {
+					break
+				}
+				for j := 0; j < n; j++ {
+					c.w.WriteString(gen(cfg, rng, value))
+					c.w.WriteString("\r\n")
+				}
+				sent := time.Now()
+				if err := c.w.Flush(); err != nil {
+					mu.Lock()
+					firstErr = err
+					mu.Unlock()
+					return
+				}
+				for j := 0; j < n; j++ {
+					failed, err := c.readReply()
+					if err != nil {
+						mu.Lock()
+						firstErr = err
+						mu.Unlock()
+						return
+					}
+					if failed {
+						errs.Add(1)
+					}
+				}
+				rtt := time.Since(sent)
+				for j := 0; j < n; j++ {
+					local = append(local, rtt)
+				}
+			}
+			mu.Lock()
+			all = append(all, local...)
+			mu.Unlock()
+		}(time.Now().UnixNano()+int64(i), c)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	return &result{elapsed: time.Since(start), latencies: all, errors: errs.Load()}, nil
+}
+
+func (r *result) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.latencies)-1) * p / 100)
+	return r.latencies[i]
+}
+
+func (r *result) report(w io.Writer, name string, cfg config) {
+	rps := float64(len(r.latencies)) / r.elapsed.Seconds()
+	if cfg.quiet {
+		fmt.Fprintf(w, "%s: %.2f requests per second, p50=%.3f msec\n", name, rps, ms(r.percentile(50)))
+		return
+	}
+	fmt.Fprintf(w, "====== %s ======\n", name)
+	fmt.Fprintf(w, "  %d requests completed in %.2f seconds\n", len(r.latencies), r.elapsed.Seconds())
+	fmt.Fprintf(w, "  %d parallel clients\n", cfg.clients)
+	fmt.Fprintf(w, "  %d bytes payload\n", cfg.dataSize)
+	fmt.Fprintf(w, "  pipeline depth %d, key space %d\n", cfg.pipeline, cfg.keyspace)
+	if r.errors > 0 {
+		fmt.Fprintf(w, "  %d requests returned an error\n", r.errors)
+	}
+	fmt.Fprintf(w, "\n  latency (msec): avg=%.3f min=%.3f p50=%.3f p95=%.3f p99=%.3f max=%.3f\n",
+		ms(r.avg()), ms(r.percentile(0)), ms(r.percentile(50)), ms(r.percentile(95)), ms(r.percentile(99)), ms(r.percentile(100)))
+	fmt.Fprintf(w, "  throughput: %.2f requests per second\n", rps)
+	if r.memStats {
+		fmt.Fprintf(w, "  server allocations: %.2f per request, %d GC cycles\n",
+			float64(r.allocs)/float64(len(r.latencies)), r.gcs)
+	}
+	fmt.Fprintln(w)
+}
+
+func (r *result) avg() time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range r.latencies {
+		sum += d
+	}
+	return sum / time.Duration(len(r.latencies))
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type benchConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// dial connects to the server and consumes the greeting banner.
+func dial(addr string) (*benchConn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &benchConn{Conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	if _, err := c.readReply(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read banner: %w", err)
+	}
+	return c, nil
+}
+
+// memCounters returns the numeric fields of the server's INFO memory
+// section. The counters are process-wide, so the figures derived from
+// them include whatever else the server is doing.
+func memCounters(addr string) (map[string]int64, error) {
+	c, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("INFO memory\r\n")); err != nil {
+		return nil, err
+	}
+	res := make(map[string]int64)
+	for {
+		if p, err := c.r.Peek(len(prompt)); err == nil && bytes.Equal(p, prompt) {
+			break
+		}
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		name, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			res[name] = n
+		}
+	}
+	if _, ok := res["mem_allocations"]; !ok {
+		return nil, fmt.Errorf("server does not report allocations")
+	}
+	return res, nil
+}
+
+// readReply consumes one reply, up to and including the next prompt, and
+// reports whether it was an error reply.
+func (c *benchConn) readReply() (bool, error) {
+	failed := false
+	first := true
+	for {
+		if p, err := c.r.Peek(len(prompt)); err == nil && bytes.Equal(p, prompt) {
+			c.r.Discard(len(prompt))
+			return failed, nil
+		}
+		line, err := c.r.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull {
+			return failed, err
+		}
+		if first && len(line) > 0 && line[0] == '-' {
+			failed = true
+		}
+		first = err == nil
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const maxHistory = 1000
+
+// errInterrupt is returned by readLine when the user presses Ctrl-C.
+var errInterrupt = errors.New("interrupted")
+
+// editor is a small readline replacement: cursor movement, history and
+// tab completion with the usual emacs-style keys.
+type editor struct {
+	fd  int
+	in  *bufio.Reader
+	out io.Writer
+
+	history []string
+
+	// complete returns candidates for the last of words, the words before
+	// the cursor (the last one possibly empty).
+	complete func(words []string) []string
+}
+
+func newEditor(fd int, in io.Reader, out io.Writer) *editor {
+	return &editor{fd: fd, in: bufio.NewReader(in), out: out}
+}
+
+func (ed *editor) loadHistory(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			ed.addHistory(line)
+		}
+	}
+}
+
+func (ed *editor) addHistory(line string) {
+	if n := len(ed.history); n > 0 && ed.history[n-1] == line {
+		return
+	}
+	ed.history = append(ed.history, line)
+	if len(ed.history) > maxHistory {
+		ed.history = ed.history[len(ed.history)-maxHistory:]
+	}
+}
+
+func (ed *editor) appendHistory(path, line string) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// readLine shows prompt and returns the line typed. It returns io.EOF on
+// Ctrl-D at an empty line and errInterrupt on Ctrl-C.
+func (ed *editor) readLine(prompt string) (string, error) {
+	st, err := makeRaw(ed.fd)
+	if err != nil {
+		return ed.readPlain(prompt)
+	}
+	defer restoreTerm(ed.fd, st)
+
+	l := &lineState{ed: ed, prompt: prompt, hist: len(ed.history)}
+	l.refresh()
+	for {
+		r, _, err := ed.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if r != '\t' {
+			l.lastTab = false
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(ed.out, "\r\n")
+			return string(l.buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(ed.out, "^C\r\n")
+			return "", errInterrupt
+		case 4: // Ctrl-D
+			if len(l.buf) == 0 {
+				return "", io.EOF
+			}
+			l.deleteAt(l.pos)
+		case 127, 8: // Backspace, Ctrl-H
+			if l.pos > 0 {
+				l.pos--
+				l.deleteAt(l.pos)
+			}
+		case 1: // Ctrl-A
+			l.pos = 0
+		case 5: // Ctrl-E
+			l.pos = len(l.buf)
+		case 2: // Ctrl-B
+			l.move(-1)
+		case 6: // Ctrl-F
+			l.move(1)
+		case 11: // Ctrl-K
+			l.buf = l.buf[:l.pos]
+		case 21: // Ctrl-U
+			l.buf = append(l.buf[:0], l.buf[l.pos:]...)
+			l.pos = 0
+		case 23: // Ctrl-W
+			start := l.pos
+			for start > 0 && unicode.IsSpace(l.buf[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(l.buf[start-1]) {
+				start--
+			}
+			l.buf = append(l.buf[:start], l.buf[l.pos:]...)
+			l.pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(ed.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			l.historyStep(-1)
+		case 14: // Ctrl-N
+			l.historyStep(1)
+		case '\t':
+			l.completeWord()
+		case 27: // escape sequence
+			l.escape()
+		default:
+			if unicode.IsPrint(r) {
+				l.insert(r)
+			}
+		}
+		l.refresh()
+	}
+}
+
+// readPlain is used when the terminal cannot be put into raw mode.
+func (ed *editor) readPlain(prompt string) (string, error) {
+	fmt.Fprint(ed.out, prompt)
+	line, err := ed.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// lineState is the line being edited.
+type lineState struct {
+	ed     *editor
+	prompt string
+	buf    []rune
+	pos    int
+
+	hist  int    // index into history; len(history) is the new line
+	saved []rune // the new line, while browsing history
+
+	lastTab bool // the previous key was a Tab with several candidates
+}
+
+func (l *lineState) refresh() {
+	fmt.Fprintf(l.ed.out, "\r%s%s\x1b[K", l.prompt, string(l.buf))
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(l.ed.out, "\x1b[%dD", back)
+	}
+}
+
+func (l *lineState) insert(r rune) {
+	l.buf = append(l.buf, 0)
+	copy(l.buf[l.pos+1:], l.buf[l.pos:])
+	l.buf[l.pos] = r
+	l.pos++
+}
+
+func (l *lineState) deleteAt(i int) {
+	if i < len(l.buf) {
+		l.buf = append(l.buf[:i], l.buf[i+1:]...)
+	}
+}
+
+func (l *lineState) move(d int) {
+	l.pos = max(0, min(len(l.buf), l.pos+d))
+}
+
+func (l *lineState) historyStep(d int) {
+	h := l.ed.history
+	next := l.hist + d
+	if next < 0 || next > len(h) {
+		return
+	}
+	if l.hist == len(h) {
+		l.saved = append(l.saved[:0], l.buf...)
+	}
+	l.hist = next
+	if next == len(h) {
+		l.buf = append(l.buf[:0], l.saved...)
+	} else {
+		l.buf = []rune(h[next])
+	}
+	l.pos = len(l.buf)
+}
+
+// escape handles the arrow, Home, End and Delete key sequences.
+func (l *lineState) escape() {
+	in := l.ed.in
+	b, err := in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return
+	}
+	c, err := in.ReadByte()
+	if err != nil {
+		return
+	}
+	switch c {
+	case 'A':
+		l.historyStep(-1)
+	case 'B':
+		l.historyStep(1)
+	case 'C':
+		l.move(1)
+	case 'D':
+		l.move(-1)
+	case 'H':
+		l.pos = 0
+	case 'F':
+		l.pos = len(l.buf)
+	case '1', '3', '4', '7', '8':
+		// ESC [ n ~
+		if t, err := in.ReadByte(); err != nil || t != '~' {
+			return
+		}
+		switch c {
+		case '1', '7':
+			l.pos = 0
+		case '4', '8':
+			l.pos = len(l.buf)
+		case '3':
+			l.deleteAt(l.pos)
+		}
+	}
+}
+
+// completeWord completes the word before the cursor. A unique match is
+// completed with a trailing space; otherwise the common prefix is filled
+// in, and a second Tab lists the candidates.
+func (l *lineState) completeWord() {
+	listed := l.lastTab
+	l.lastTab = false
+	if l.ed.complete == nil {
+		return
+	}
+	before := string(l.buf[:l.pos])
+	words := strings.Fields(before)
+	if len(words) == 0 || strings.HasSuffix(before, " ") {
+		words = append(words, "")
+	}
+	cands := l.ed.complete(words)
+	if len(cands) == 0 {
+		return
+	}
+	word := []rune(words[len(words)-1])
+	fill := cands[0]
+	if len(cands) == 1 {
+		fill += " "
+	} else {
+		for _, c := range cands[1:] {
+			fill = commonPrefix(fill, c)
+		}
+	}
+	if f := []rune(fill); len(f) > len(word) {
+		start := l.pos - len(word)
+		rest := append(f, l.buf[l.pos:]...)
+		l.buf = append(l.buf[:start], rest...)
+		l.pos = start + len(f)
+		return
+	}
+	if listed {
+		fmt.Fprintf(l.ed.out, "\r\n%s\r\n", strings.Join(cands, "  "))
+		return
+	}
+	l.lastTab = true
+}
+
+func commonPrefix(a, b string) string {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/DakshBaxi/RediGo/pkg/client"
+)
+
+// printReply writes r the way redis-cli does: type hints for errors and
+// integers, OK without the '+', and quoted values. In raw mode values are
+// unquoted and integers and errors are printed bare, which suits scripts.
+func printReply(w io.Writer, r *client.Reply, raw bool) {
+	if len(r.Lines) == 0 {
+		if !raw {
+			fmt.Fprintln(w, "(empty)")
+		}
+		return
+	}
+	if len(r.Lines) > 1 {
+		for _, line := range r.Lines {
+			fmt.Fprintln(w, line)
+		}
+		return
+	}
+	line := r.Lines[0]
+	switch {
+	case strings.HasPrefix(line, "-"):
+		if raw {
+			fmt.Fprintln(w, line[1:])
+		} else {
+			fmt.Fprintf(w, "(error) %s\n", line[1:])
+		}
+	case strings.HasPrefix(line, ":"):
+		if raw {
+			fmt.Fprintln(w, line[1:])
+		} else {
+			fmt.Fprintf(w, "(integer) %s\n", line[1:])
+		}
+	case strings.HasPrefix(line, "+"):
+		fmt.Fprintln(w, line[1:])
+	case raw && line == "(nil)":
+		fmt.Fprintln(w)
+	case raw && len(line) >= 2 && line[0] == '"' && line[len(line)-1] == '"':
+		fmt.Fprintln(w, line[1:len(line)-1])
+	default:
+		fmt.Fprintln(w, line)
+	}
+}
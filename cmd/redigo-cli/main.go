@@ -0,0 +1,312 @@
+// Command redigo-cli is an interactive client for RediGo.
+//
+//	redigo-cli                          # interactive prompt
+//	redigo-cli SET greeting hello       # run one command
+//	redigo-cli -eval "GET greeting"     # same, as a single string
+//	redigo-cli -scan -pattern 'user:*'  # list matching keys
+//	redigo-cli -hotkeys -count 20       # most frequently accessed keys
+//	redigo-cli -bigkeys                 # largest keys
+//	redigo-cli < commands.txt           # run one command per line
+//
+// The prompt supports line editing, history (saved in ~/.redigo_history)
+// and tab completion of command names, which are fetched from the server
+// with COMMAND DOCS.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/client"
+)
+
+const historyFile = ".redigo_history"
+
+func main() {
+	addr := flag.String("addr", "localhost:6380", "server address")
+	eval := flag.String("eval", "", "run this command and exit")
+	raw := flag.Bool("raw", false, "print replies as sent by the server, without quotes or type hints")
+	scan := flag.Bool("scan", false, "list the keys matching -pattern and exit")
+	pattern := flag.String("pattern", "*", "glob pattern for -scan")
+	hotkeys := flag.Bool("hotkeys", false, "report the most frequently accessed keys and exit")
+	bigkeys := flag.Bool("bigkeys", false, "report the largest keys and exit")
+	count := flag.Int("count", 10, "number of keys reported by -hotkeys and -bigkeys")
+	timeout := flag.Duration("timeout", 10*time.Second, "per-command timeout (0 = none)")
+	flag.Parse()
+
+	cli := &cli{
+		addr:    *addr,
+		raw:     *raw,
+		timeout: *timeout,
+		c:       client.New(client.Options{Addr: *addr, PoolSize: 1, Name: "redigo-cli"}),
+	}
+	defer cli.c.Close()
+
+	switch {
+	case *scan:
+		os.Exit(cli.scan(*pattern))
+	case *hotkeys || *bigkeys:
+		os.Exit(cli.keyReport(*hotkeys, *bigkeys, *count))
+	case *eval != "":
+		os.Exit(cli.run(*eval))
+	case flag.NArg() > 0:
+		os.Exit(cli.run(strings.Join(flag.Args(), " ")))
+	case !isTerminal(int(os.Stdin.Fd())):
+		os.Exit(cli.batch(os.Stdin))
+	}
+	cli.interactive()
+}
+
+type cli struct {
+	addr    string
+	raw     bool
+	timeout time.Duration
+	c       *client.Client
+}
+
+func (cli *cli) context() (context.Context, context.CancelFunc) {
+	if cli.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), cli.timeout)
+}
+
+// run sends one command line and prints the reply. It returns the process
+// exit code: 1 for errors, 0 otherwise.
+func (cli *cli) run(line string) int {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return 0
+	}
+	if strings.EqualFold(args[0], "MONITOR") {
+		return cli.monitor()
+	}
+	ctx, cancel := cli.context()
+	defer cancel()
+	r, err := cli.c.Do(ctx, args...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not talk to RediGo at %s: %v\n", cli.addr, err)
+		return 1
+	}
+	printReply(os.Stdout, r, cli.raw)
+	if r.Err() != nil {
+		return 1
+	}
+	return 0
+}
+
+func (cli *cli) batch(in io.Reader) int {
+	code := 0
+	sc := bufio.NewScanner(in)
+	for sc.Scan() {
+		if c := cli.run(sc.Text()); c != 0 {
+			code = c
+		}
+	}
+	return code
+}
+
+// scan prints the keys matching pattern, walking the keyspace with SCAN so
+// the server is never asked for every key at once.
+func (cli *cli) scan(pattern string) int {
+	if _, err := path.Match(pattern, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid pattern %q: %v\n", pattern, err)
+		return 2
+	}
+	var cursor uint64
+	for {
+		ctx, cancel := cli.context()
+		keys, next, err := cli.c.Scan(ctx, cursor, pattern, 1000)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not list keys: %v\n", err)
+			return 1
+		}
+		for _, k := range keys {
+			fmt.Println(k)
+		}
+		if next == 0 {
+			return 0
+		}
+		cursor = next
+	}
+}
+
+// keyReport prints the hottest and/or largest keys as a table.
+func (cli *cli) keyReport(hot, big bool, n int) int {
+	ctx, cancel := cli.context()
+	defer cancel()
+	report := func(title string, fetch func(context.Context, int) ([]client.KeyStat, error)) error {
+		stats, err := fetch(ctx, n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# %s\n", title)
+		if len(stats) == 0 {
+			fmt.Println("(no keys)")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tFREQ\tBYTES")
+		for _, st := range stats {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", st.Key, st.Freq, st.Bytes)
+		}
+		return w.Flush()
+	}
+	if hot {
+		if err := report("Hot keys (by LFU access frequency)", cli.c.HotKeys); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not get hot keys: %v\n", err)
+			return 1
+		}
+	}
+	if big {
+		if hot {
+			fmt.Println()
+		}
+		if err := report("Big keys (by estimated memory)", cli.c.BigKeys); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not get big keys: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// monitor streams MONITOR output until the connection closes or the user
+// presses Ctrl-C. It uses its own connection, since MONITOR never returns
+// to the prompt.
+func (cli *cli) monitor() int {
+	nc, err := net.Dial("tcp", cli.addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not connect to RediGo at %s: %v\n", cli.addr, err)
+		return 1
+	}
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	if err := skipBanner(r); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not connect to RediGo at %s: %v\n", cli.addr, err)
+		return 1
+	}
+	fmt.Fprintf(nc, "MONITOR\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return 0
+		}
+		fmt.Print(strings.TrimRight(line, "\r\n"), "\n")
+	}
+}
+
+// skipBanner reads up to the first prompt.
+func skipBanner(r *bufio.Reader) error {
+	for {
+		if p, err := r.Peek(2); err != nil {
+			return err
+		} else if string(p) == "> " {
+			_, err := r.Discard(2)
+			return err
+		}
+		if _, err := r.ReadString('\n'); err != nil {
+			return err
+		}
+	}
+}
+
+func (cli *cli) interactive() {
+	ed := newEditor(int(os.Stdin.Fd()), os.Stdin, os.Stdout)
+	ed.complete = cli.completer()
+	histPath := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		histPath = filepath.Join(home, historyFile)
+		ed.loadHistory(histPath)
+	}
+	prompt := cli.addr + "> "
+	for {
+		line, err := ed.readLine(prompt)
+		if errors.Is(err, errInterrupt) {
+			continue
+		}
+		if err != nil {
+			fmt.Println()
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		ed.addHistory(line)
+		if histPath != "" {
+			ed.appendHistory(histPath, line)
+		}
+		switch strings.ToUpper(line) {
+		case "QUIT", "EXIT":
+			return
+		case "CLEAR":
+			fmt.Print("\x1b[H\x1b[2J")
+			continue
+		}
+		cli.run(line)
+	}
+}
+
+// subcommands completes the second word of container commands.
+var subcommands = map[string][]string{
+	"CLIENT":  {"GETNAME", "ID", "KILL", "LIST", "PAUSE", "SETNAME", "UNPAUSE"},
+	"COMMAND": {"COUNT", "DOCS", "INFO"},
+	"CONFIG":  {"GET", "RESETSTAT", "REWRITE", "SET"},
+	"DEBUG":   {"JMAP", "OBJECT", "SET-ACTIVE-EXPIRE", "SLEEP"},
+	"HOTKEYS": {"COUNT", "FREQ", "SIZE"},
+	"INFO":    {"ALL", "CLIENTS", "COMMANDSTATS", "KEYSPACE", "MEMORY", "PERSISTENCE", "REPLICATION", "SERVER", "STATS"},
+	"MEMORY":  {"STATS", "USAGE"},
+}
+
+// completer returns candidates for the word being typed. Command names
+// come from COMMAND DOCS, fetched on first use.
+func (cli *cli) completer() func(words []string) []string {
+	var names []string
+	return func(words []string) []string {
+		if len(words) > 2 {
+			return nil
+		}
+		if names == nil {
+			names = cli.commandNames()
+		}
+		cands := names
+		if len(words) == 2 {
+			cands = subcommands[strings.ToUpper(words[0])]
+		}
+		prefix := strings.ToUpper(words[len(words)-1])
+		var res []string
+		for _, c := range cands {
+			if strings.HasPrefix(c, prefix) {
+				res = append(res, c)
+			}
+		}
+		return res
+	}
+}
+
+func (cli *cli) commandNames() []string {
+	ctx, cancel := cli.context()
+	defer cancel()
+	r, err := cli.c.Do(ctx, "COMMAND", "DOCS")
+	if err != nil || r.Err() != nil {
+		return []string{}
+	}
+	names := make([]string, 0, len(r.Lines))
+	for _, line := range r.Lines {
+		if name, _, ok := strings.Cut(line, ":"); ok {
+			names = append(names, strings.ToUpper(name))
+		}
+	}
+	return names
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// Line editing needs raw terminal mode, which is only implemented for
+// Linux; elsewhere the prompt falls back to plain line input.
+
+type termState struct{}
+
+var errNoRawMode = errors.New("raw terminal mode is not supported on this platform")
+
+func isTerminal(fd int) bool { return true }
+
+func makeRaw(fd int) (*termState, error) { return nil, errNoRawMode }
+
+func restoreTerm(fd int, st *termState) error { return nil }
@@ -2,66 +2,214 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/DakshBaxi/RediGo/internal/store"
+	"github.com/DakshBaxi/RediGo/internal/config"
+	"github.com/DakshBaxi/RediGo/internal/discovery"
+	"github.com/DakshBaxi/RediGo/internal/promtext"
+	"github.com/DakshBaxi/RediGo/pkg/server"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+	"github.com/DakshBaxi/RediGo/pkg/tracing"
 )
 
-const defaultPrimary = "localhost:6380"
+const (
+	defaultPrimary      = "localhost:6380"
+	defaultAddr         = ":6381"
+	defaultSyncInterval = 5 * time.Second
+
+	// syncDialTimeout bounds connecting to each primary candidate, so an
+	// unreachable seed doesn't hold up the next one.
+	syncDialTimeout = 5 * time.Second
+)
+
+// tracer records a span per sync; nil when tracing is disabled.
+var tracer *tracing.Tracer
+
+// lastSync is the unix nano time of the last successfully applied snapshot.
+var lastSync atomic.Int64
+
+// syncStatus is what INFO replication reports; syncMu guards it.
+var (
+	syncMu     sync.Mutex
+	syncStatus server.ReplicaStatus
+)
 
 func main() {
+	configFile := flag.String("config", "", "load replicaof, bind and port from this redigo.conf file")
+	replicaOf := flag.String("replicaof", "", "primary to follow as host:port, a comma-separated seed list or srv:<DNS SRV name>, re-resolved on every sync (default "+defaultPrimary+")")
+	listenAddr := flag.String("addr", "", "serve clients on this host:port (default "+defaultAddr+", or bind and port from -config)")
+	syncInterval := flag.Duration("sync-interval", defaultSyncInterval, "how often to copy the primary's data")
+	allowCommands := flag.String("allow-commands", "", "comma-separated commands clients may run, e.g. GET,EXISTS,TTL,INFO (empty = every read command)")
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics at http://<addr>/metrics (empty = disabled)")
+	healthAddr := flag.String("health-addr", "", "serve /healthz and /readyz probes on this address (empty = disabled)")
+	maxLag := flag.Duration("max-lag", 30*time.Second, "report not ready once the last applied snapshot is older than this")
+	snapshotPath := flag.String("snapshot", "", "keep the synced data in this snapshot file and load it on start, so a restarted replica serves reads before its first sync (empty = memory only)")
+	snapshotInterval := flag.Duration("snapshot-interval", time.Minute, "write the snapshot after a successful sync at most this often")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "export sync spans to this OTLP/HTTP collector, e.g. http://localhost:4318 (empty = disabled)")
+	flag.Parse()
+	// Every flag can also be set as REDIGO_<NAME>, e.g. REDIGO_REPLICAOF.
+	if err := config.SetFlagsFromEnv(flag.CommandLine, "REDIGO_"); err != nil {
+		slog.Error("invalid environment", "err", err)
+		os.Exit(2)
+	}
+	if *otlpEndpoint != "" {
+		tracer = tracing.New(tracing.Config{Endpoint: *otlpEndpoint, ServiceName: "redigo-replica"})
+	}
 	primaryAddr := defaultPrimary
-	if len(os.Args) > 1 {
-		primaryAddr = os.Args[1]
+	addr := defaultAddr
+	if *configFile != "" {
+		cfg, err := config.Load(*configFile)
+		if err != nil {
+			slog.Error("invalid config file", "err", err)
+			os.Exit(2)
+		}
+		if cfg.ReplicaOf != "" {
+			primaryAddr = cfg.ReplicaOf
+		}
+		// a replica usually runs next to its primary, so only take bind
+		// and port from the file when they are set explicitly
+		host, port, _ := net.SplitHostPort(defaultAddr)
+		if cfg.IsSet("bind") {
+			host = cfg.Bind
+		}
+		if cfg.IsSet("port") {
+			port = strconv.Itoa(cfg.Port)
+		}
+		addr = net.JoinHostPort(host, port)
+	}
+	if *replicaOf != "" {
+		primaryAddr = *replicaOf
+	}
+	if *listenAddr != "" {
+		addr = *listenAddr
+	}
+	if *syncInterval <= 0 {
+		slog.Error("invalid -sync-interval", "value", *syncInterval)
+		os.Exit(2)
+	}
+	var allowed []string
+	if *allowCommands != "" {
+		allowed = strings.Split(*allowCommands, ",")
+	}
+	if flag.NArg() > 0 {
+		primaryAddr = flag.Arg(0)
 	}
 
 	s := store.New()
-		// Simple periodic sync loop
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			var buf bytes.Buffer
+			writeMetrics(promtext.NewWriter(&buf), s)
+			w.Header().Set("Content-Type", promtext.ContentType)
+			w.Write(buf.Bytes())
+		})
+		go func() {
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				slog.Error("metrics listener failed", "addr", *metricsAddr, "err", err)
+			}
+		}()
+	}
+	if *healthAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintf(w, "status:ok\r\n")
+		})
+		mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			status, detail := primaryLinkStatus(*maxLag)
+			if status != "ok" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			fmt.Fprintf(w, "status:%s\r\nprimary_link:%s (%s)\r\n", status, status, detail)
+		})
+		go func() {
+			if err := http.ListenAndServe(*healthAddr, mux); err != nil {
+				slog.Error("health listener failed", "addr", *healthAddr, "err", err)
+			}
+		}()
+	}
+	// Serve clients on a different port, e.g. 6381, with the primary's
+	// commands; as a replica the server refuses writes. New loads the
+	// snapshot, so reads are served from it until the first sync.
+	srv, err := server.New(server.Options{
+		Addr:            addr,
+		Store:           s,
+		SnapshotPath:    *snapshotPath,
+		ReplicaOf:       primaryAddr,
+		AllowedCommands: allowed,
+		ReplicaStatus: func() server.ReplicaStatus {
+			syncMu.Lock()
+			defer syncMu.Unlock()
+			return syncStatus
+		},
+	})
+	if err != nil {
+		slog.Error("failed to start", "err", err)
+		os.Exit(1)
+	}
+	if *snapshotPath != "" && s.Len() > 0 {
+		slog.Info("loaded snapshot", "path", *snapshotPath, "keys", s.Len())
+	}
+	// Simple periodic sync loop
 	go func() {
+		var lastSave time.Time
 		for {
 			if err := syncOnce(primaryAddr, s); err != nil {
-				log.Printf("sync error: %v", err)
+				slog.Error("sync failed", "primary", primaryAddr, "err", err)
+			} else if *snapshotPath != "" && time.Since(lastSave) >= *snapshotInterval {
+				if err := srv.Save(); err != nil {
+					slog.Error("saving snapshot failed", "path", *snapshotPath, "err", err)
+				}
+				lastSave = time.Now()
 			}
-			time.Sleep(5 * time.Second)
+			time.Sleep(*syncInterval)
 		}
 	}()
-	// Start a read-only server for clients on a different port, e.g. 6381
-	addr := ":6381"
-	log.Printf("RediGo replica listening on %s (primary=%s)...", addr, primaryAddr)
-	ln, err := net.Listen("tcp", addr)
-	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+	slog.Info("RediGo replica listening", "addr", addr, "primary", primaryAddr)
+	if err := srv.ListenAndServe(); err != nil {
+		slog.Error("failed to listen", "addr", addr, "err", err)
+		os.Exit(1)
 	}
-	defer ln.Close()
+}
 
-	for {
-		conn, err := ln.Accept()
+func syncOnce(primaryAddr string, s *store.Store) (err error) {
+	start := time.Now()
+	sp := tracer.Start("replica.sync", tracing.KindClient)
+	sp.SetString("server.address", primaryAddr)
+	defer func() {
 		if err != nil {
-			log.Printf("accept error: %v", err)
-			continue
+			sp.SetError(err.Error())
 		}
-		log.Printf("new client connection from %s", conn.RemoteAddr())
-		go handleReplicaClient(conn, s)
-	}
-}
-
-func syncOnce(primaryAddr string, s *store.Store) error {
-	log.Printf("sync: connecting to primary %s ...", primaryAddr)
-	conn, err := net.Dial("tcp", primaryAddr)
+		sp.End()
+	}()
+	slog.Info("sync: connecting to primary", "primary", primaryAddr)
+	conn, err := discovery.Dial(context.Background(), &net.Dialer{Timeout: syncDialTimeout}, primaryAddr)
 	if err != nil {
 		return fmt.Errorf("dial primary: %w", err)
 	}
 	defer conn.Close()
+	sp.SetString("network.peer.address", conn.RemoteAddr().String())
 
 	// Send DUMPALL
 	fmt.Fprintf(conn, "DUMPALL\r\n")
 
-	reader := bufio.NewReader(conn)
+	received := &countingReader{r: conn}
+	reader := bufio.NewReader(received)
 
 	var lines []string
 	for {
@@ -69,14 +217,16 @@ func syncOnce(primaryAddr string, s *store.Store) error {
 		if err != nil {
 			return fmt.Errorf("read from primary: %w", err)
 		}
-		line = strings.TrimSpace(line)
+		// the primary's prompt has no line break of its own, so it starts
+		// the first line of the reply
+		line = strings.TrimPrefix(strings.TrimSpace(line), "> ")
 		if line == "." {
 			break
 		}
 		if line == "" {
 			continue
 		}
-		
+
 		if strings.HasPrefix(line, "+OK") || strings.HasPrefix(line, "Supports ") || strings.HasPrefix(line, "Type HELP") || line == ">" {
 			continue
 		}
@@ -84,21 +234,44 @@ func syncOnce(primaryAddr string, s *store.Store) error {
 	}
 
 	// Apply snapshot to local store
-	log.Printf("sync: received %d commands", len(lines))
-
+	slog.Info("sync: received snapshot", "commands", len(lines))
+	sp.SetInt("redigo.commands", int64(len(lines)))
 
 	newStore := store.New()
 	for _, cmdLine := range lines {
 		applySnapshotCommand(newStore, cmdLine)
 	}
-
+
 	replaceStoreData(s, newStore)
 
-	log.Printf("sync: applied snapshot")
+	now := time.Now()
+	lastSync.Store(now.UnixNano())
+	syncMu.Lock()
+	syncStatus.Primary = conn.RemoteAddr().String()
+	syncStatus.LastSync = now
+	syncStatus.SyncDuration = now.Sub(start)
+	syncStatus.Syncs++
+	syncStatus.BytesReceived += received.n
+	syncStatus.CommandsApplied += int64(len(lines))
+	syncMu.Unlock()
+	slog.Info("sync: applied snapshot", "duration", now.Sub(start), "bytes", received.n)
 	return nil
 }
 
-// applySnapshotCommand parses a single replay line like: "SET k v", "SETEX k ttl v", "RPUSH k v1 v2"
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// applySnapshotCommand parses a single replay line like: "SET k v",
+// "EXPIREAT k unixtime", or "SETEX k ttl v" from older primaries
 func applySnapshotCommand(s *store.Store, line string) {
 	parts := strings.Fields(line)
 	if len(parts) == 0 {
@@ -128,6 +301,15 @@ func applySnapshotCommand(s *store.Store, line string) {
 			return
 		}
 		s.Setwithttl(key, value, ttl)
+	case "EXPIREAT":
+		if len(args) != 2 {
+			return
+		}
+		at, err := parseInt64(args[1])
+		if err != nil {
+			return
+		}
+		s.ExpireAt(args[0], at)
 	}
 }
 
@@ -137,55 +319,46 @@ func parseInt64(sval string) (int64, error) {
 	return n, err
 }
 
-// replaceStoreData copies contents from src to dst
-func replaceStoreData(dst, src *store.Store) {
+// replaceStoreData copies contents from src to dst, and drops the keys
+// src doesn't have, such as ones loaded from a snapshot that the primary
+// deleted since.
+func replaceStoreData(dst, src *store.Store) {
 	cmds := src.DumpCommands()
 	for _, line := range cmds {
 		applySnapshotCommand(dst, line)
 	}
+	for _, key := range dst.Keys() {
+		if !src.Exists(key) {
+			dst.Del(key)
+		}
+	}
 }
-// handleReplicaClient: like primary, but READ ONLY.
-func handleReplicaClient(conn net.Conn, s *store.Store) {
-	defer conn.Close()
-	fmt.Fprintf(conn, "+OK RediGo Replica (read-only)\r\n")
 
-	reader := bufio.NewScanner(conn)
-	for {
-		fmt.Fprint(conn, "> ")
-		if !reader.Scan() {
-			return
-		}
-		line := strings.TrimSpace(reader.Text())
-		if line == "" {
-			continue
-		}
-		parts := strings.Fields(line)
-		if len(parts) == 0 {
-			continue
-		}
-		cmd := strings.ToUpper(parts[0])
-		args := parts[1:]
-
-		switch cmd {
-		case "GET":
-			// reuse same logic but only for reads
-			val, ok := s.Get(args[0])
-			if ok {
-				fmt.Fprintf(conn, "\"%s\"\r\n", val)
-			} else {
-				fmt.Fprintf(conn, "(nil)\r\n")
-			}
-		case "INFO":
-			stats := s.Stats()
-			fmt.Fprintf(conn, "# Replica\r\n")
-			fmt.Fprintf(conn, "keys:%d\r\n", stats.Keys)
-			fmt.Fprintf(conn, "max_keys:%d\r\n", stats.MaxKeys)
-			fmt.Fprintf(conn, "evictions:%d\r\n", stats.Evictions)
-		case "QUIT":
-			fmt.Fprintf(conn, "+OK bye\r\n")
-			return
-		default:
-			fmt.Fprintf(conn, "-ERR READONLY replica: only GET/INFO/QUIT allowed for now\r\n")
-		}
+// primaryLinkStatus reports whether the data served is fresh: "ok" while
+// the last applied snapshot is at most maxLag old, "degraded" after that,
+// and "failed" before the first successful sync.
+func primaryLinkStatus(maxLag time.Duration) (status, detail string) {
+	last := lastSync.Load()
+	if last == 0 {
+		return "failed", "never synced"
+	}
+	lag := time.Since(time.Unix(0, last)).Round(time.Millisecond)
+	if lag > maxLag {
+		return "degraded", "last sync " + lag.String() + " ago"
 	}
-}
\ No newline at end of file
+	return "ok", "last sync " + lag.String() + " ago"
+}
+
+// writeMetrics exports the replica's dataset size and how far it lags the
+// primary, i.e. the age of the last applied snapshot.
+func writeMetrics(p *promtext.Writer, s *store.Store) {
+	stats := s.Stats()
+	p.Gauge("redigo_keys", "Number of keys in the dataset.", float64(stats.Keys))
+	p.Gauge("redigo_memory_dataset_bytes", "Approximate size of the dataset.", float64(stats.UsedMemory))
+	last := lastSync.Load()
+	if last == 0 {
+		return // no successful sync yet
+	}
+	p.Gauge("redigo_replication_last_sync_timestamp_seconds", "Unix time of the last applied snapshot.", float64(last)/1e9)
+	p.Gauge("redigo_replication_lag_seconds", "Seconds since the last applied snapshot.", time.Since(time.Unix(0, last)).Seconds())
+}
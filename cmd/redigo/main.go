@@ -1,141 +1,619 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"expvar"
+	"flag"
 	"fmt"
-	"log"
-	"net"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
-	"github.com/DakshBaxi/RediGo/internal/store"
+	"github.com/DakshBaxi/RediGo/internal/config"
+	"github.com/DakshBaxi/RediGo/internal/encryption"
+	"github.com/DakshBaxi/RediGo/internal/logging"
+	"github.com/DakshBaxi/RediGo/pkg/backup"
+	"github.com/DakshBaxi/RediGo/pkg/cdc"
+	"github.com/DakshBaxi/RediGo/pkg/server"
+	"github.com/DakshBaxi/RediGo/pkg/statsd"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+	"github.com/DakshBaxi/RediGo/pkg/tracing"
 )
 
-const (
-	defaultAddr = ":6380" //redis default is 6379; we use 6380 for safety
-)
+// shutdownTimeout bounds how long a signal-triggered shutdown waits for
+// connections to drain before closing them forcefully.
+const shutdownTimeout = 10 * time.Second
 
-var (
-	aofFile *os.File
-	aofMu 	sync.Mutex
-)
+func main() {
+	configFile := flag.String("config", "", "load settings from this redigo.conf file")
+	// These override the config file; see redigo.conf for their meaning.
+	flag.String("bind", "", "interface to listen on (empty = all)")
+	flag.Int("port", 6380, "port to listen on")
+	var listens []string
+	flag.Func("listen", "serve on `addr` as well as -port: host:port or unix:/path, optionally followed by tls and/or readonly, e.g. \"unix:/tmp/redigo.sock readonly\"; repeatable, or comma-separated", func(v string) error {
+		for _, spec := range strings.Split(v, ",") {
+			listens = append(listens, strings.TrimSpace(spec))
+		}
+		return nil
+	})
+	flag.String("dir", ".", "directory holding the AOF and snapshot")
+	flag.String("aof", "redigo.aof", "AOF file name in -dir (empty = disable the AOF)")
+	flag.String("appendfsync", "everysec", "when to fsync the AOF: always, everysec or no")
+	flag.Int("maxkeys", 0, "maximum number of keys (0 = unlimited)")
+	flag.String("maxmemory", "0", "maximum dataset size, e.g. 256mb (0 = unlimited)")
+	flag.String("maxmemory-policy", "allkeys-lru", "eviction policy when a limit is reached")
+	flag.Int("max-key-length", 0, "reject keys longer than this many bytes (0 = unlimited)")
+	flag.String("max-value-size", "0", "reject values larger than this, e.g. 1mb (0 = unlimited)")
+	flag.String("compress-threshold", "0", "store string values of at least this size compressed, e.g. 4kb (0 = off)")
+	flag.Int("timeout", 0, "close clients idle for this many seconds (0 = never)")
+	flag.Int("max-result-size", 0, "refuse KEYS and TS.RANGE replies with more items than this (0 = unlimited)")
+	flag.String("tls-cert-file", "", "TLS certificate (PEM)")
+	flag.String("tls-key-file", "", "TLS private key (PEM)")
+	flag.String("encryption-key", "", "encrypt the AOF and snapshots with this 32-byte key, as 64 hex digits or base64 (empty = no encryption)")
+	flag.String("encryption-key-command", "", "run this command, e.g. a KMS client, and use the key it prints as -encryption-key")
+	flag.String("save", "", `BGSAVE after <seconds> if at least <changes> writes happened, e.g. "900 1 300 10" (empty = no automatic snapshots)`)
+	workers := flag.Int("workers", 0, "run command handlers on a pool of this many goroutines (0 = one per connection)")
+	workerQueue := flag.Int("worker-queue", 1024, "commands that may wait for a free worker before readers block")
+	replayWorkers := flag.Int("replay-workers", 0, "load the snapshot and AOF at startup on this many goroutines (0 = one per CPU, 1 = serially)")
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn or error (debug logs every command)")
+	logFormat := flag.String("log-format", "text", "log format: text or json")
+	logFile := flag.String("log-file", "", "write logs to this file instead of stderr")
+	logMaxSize := flag.Int64("log-max-size", 100, "rotate the log file once it exceeds this many megabytes (0 = never)")
+	logMaxBackups := flag.Int("log-max-backups", 5, "rotated log files to keep")
+	auditLog := flag.String("audit-log", "", "record every successful write command as JSON lines in this file, rotated like -log-file (empty = disabled)")
+	statsdAddr := flag.String("statsd-addr", "", "push metrics to this StatsD agent, e.g. localhost:8125 (empty = disabled)")
+	statsdTags := flag.Bool("statsd-tags", false, "add DogStatsD tags (for Datadog agents)")
+	statsdInterval := flag.Duration("statsd-interval", 10*time.Second, "how often to push StatsD metrics")
+	cdcFile := flag.String("cdc-file", "", "export every change (key, op, value, time) to this file as NDJSON (empty = disabled)")
+	cdcWebhook := flag.String("cdc-webhook", "", "POST batches of changes as NDJSON to this URL (empty = disabled)")
+	healthAddr := flag.String("health-addr", "", "serve /healthz and /readyz probes on this address (empty = disabled)")
+	memcacheAddr := flag.String("memcache-addr", "", "also serve the memcached text protocol on this address, e.g. :11211 (empty = disabled)")
+	wsAddr := flag.String("ws-addr", "", "tunnel the command protocol over WebSockets at ws://<addr>/ws (empty = disabled)")
+	wsOrigins := flag.String("ws-origins", "", "comma-separated origins allowed to open WebSockets, or * (empty = same origin only)")
+	wsToken := flag.String("ws-token", "", "require this token in the ?token= query or a Bearer header for WebSockets")
+	debugAddr := flag.String("debug-addr", "", "serve expvar at /debug/vars and pprof at /debug/pprof/ on this address (empty = disabled)")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "export command spans to this OTLP/HTTP collector, e.g. http://localhost:4318 (empty = disabled)")
+	traceSampleRatio := flag.Float64("trace-sample-ratio", 1, "fraction of commands to trace")
+	backupURL := flag.String("backup-url", "", "upload every saved snapshot to this s3://bucket/prefix/ or gs://bucket/prefix/, with credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY (empty = disabled)")
+	backupEndpoint := flag.String("backup-endpoint", "", "object storage endpoint, e.g. http://localhost:9000 for MinIO (empty = AWS S3 or GCS, by URL scheme)")
+	backupRegion := flag.String("backup-region", "", "signing region of -backup-endpoint (empty = us-east-1 for s3, auto for gs)")
+	backupKeep := flag.Int("backup-keep", 7, "snapshots to keep in the bucket (0 = all)")
+	backupMaxAge := flag.Duration("backup-max-age", 0, "delete uploaded snapshots older than this, always keeping the newest (0 = never)")
+	restoreFrom := flag.String("restore-from-url", "", "when there is no local snapshot or AOF, start from the snapshot at this http(s) URL or s3:// or gs:// object, or the newest under a prefix ending in /")
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics at http://<addr>/metrics (empty = disabled)")
+	checkPersistence := flag.Bool("check-persistence", false, "validate every record of the snapshot and AOF, print statistics and exit without serving (status 1 if any record is invalid)")
+	dryRun := flag.Bool("dry-run", false, "like -check-persistence, but load under the configured maxkeys, maxmemory and policy, to show which records they would refuse")
+	showVersion := flag.Bool("version", false, "print the version and build information and exit")
+	flag.Parse()
+	if *showVersion {
+		fmt.Println(server.VersionString())
+		return
+	}
+	// Every flag can also be set as REDIGO_<NAME>, e.g. REDIGO_PORT or
+	// REDIGO_LOG_LEVEL; command-line flags take precedence.
+	if err := config.SetFlagsFromEnv(flag.CommandLine, "REDIGO_"); err != nil {
+		slog.Error("invalid environment", "err", err)
+		os.Exit(2)
+	}
 
-// CommandFunc is the function signature for a RediGo command.
-type CommandFunc func(conn net.Conn, s *store.Store, args []string)
-
-// Global command registry.
-var commands = map[string]CommandFunc{
-	"SET":    cmdSET,
-	"SETEX":  cmdSETEX,
-	"GET":    cmdGET,
-	"DEL":    cmdDEL,
-	"KEYS":   cmdKEYS,
-	"PING":   cmdPING,
-	"EXISTS": cmdEXISTS,
-	"TTL":    cmdTTL,
-	"EXPIRE": cmdEXPIRE,
-	"INCR":   cmdINCR,
-    "DECR":   cmdDECR,
-	"CONFIG": cmdCONFIG,
-	"INFO":   cmdINFO,
-	"DUMPALL": cmdDUMPALL,
-	"HELP":   cmdHELP,
-	"QUIT":   cmdQUIT,
-}
+	logger, logCloser, err := logging.New(logging.Options{
+		Level:      *logLevel,
+		Format:     *logFormat,
+		File:       *logFile,
+		MaxSize:    *logMaxSize << 20,
+		MaxBackups: *logMaxBackups,
+	})
+	if err != nil {
+		slog.Error("invalid logging options", "err", err)
+		os.Exit(2)
+	}
+	defer logCloser.Close()
+	slog.SetDefault(logger)
 
-func main() {
-	// Create the in-memory store instance shared by all connections.
-	s := store.New()
-// cleanupexpired
-	go func() {
-	for {
-		time.Sleep(5 * time.Second)
-		n := s.CleanupExpired()
-		if n > 0 {
-			log.Printf("Cleaned up %d expired keys\n", n)
+	var audit *slog.Logger
+	if *auditLog != "" {
+		var auditCloser io.Closer
+		audit, auditCloser, err = logging.New(logging.Options{
+			Format:     "json",
+			File:       *auditLog,
+			MaxSize:    *logMaxSize << 20,
+			MaxBackups: *logMaxBackups,
+		})
+		if err != nil {
+			logger.Error("cannot open audit log", "err", err)
+			os.Exit(2)
+		}
+		defer auditCloser.Close()
+	}
+
+	cfg := config.Default()
+	if *configFile != "" {
+		if cfg, err = config.Load(*configFile); err != nil {
+			logger.Error("invalid config file", "err", err)
+			os.Exit(2)
+		}
+	}
+	if err := applyFlags(cfg, listens); err != nil {
+		logger.Error("invalid option", "err", err)
+		os.Exit(2)
+	}
+	key, err := encryptionKey(cfg)
+	if err != nil {
+		logger.Error("cannot get the encryption key", "err", err)
+		os.Exit(2)
+	}
+	st := store.New()
+	st.SetLFULogFactor(cfg.LFULogFactor)
+	st.SetLFUDecayTime(cfg.LFUDecayTime)
+	st.SetDefragThreshold(cfg.DefragThreshold)
+	st.SetKeyIndex(cfg.KeyIndex)
+	st.SetCompressThreshold(cfg.CompressThreshold)
+
+	if *checkPersistence || *dryRun {
+		// -check-persistence checks the files alone; -dry-run also shows
+		// which records the configured limits would refuse
+		if *dryRun {
+			st.SetEvictionPolicy(cfg.MaxMemoryPolicy)
+			st.SetMaxKeys(cfg.MaxKeys)
+			st.SetMaxMemory(cfg.MaxMemory)
+		} else {
+			st = store.New()
+		}
+		r, err := server.CheckPersistence(st, cfg.SnapshotPath(), cfg.AOFPath(), cfg.AppendDirname, key)
+		if err != nil {
+			logger.Error("checking persistence failed", "err", err)
+			os.Exit(1)
+		}
+		printCheckReport(os.Stdout, r)
+		if !r.OK() {
+			os.Exit(1)
 		}
+		return
 	}
-}()
 
-	// open aof file in append mode(create if not exists)
-	f,err:=os.OpenFile("./redigo.aof",os.O_CREATE|os.O_APPEND|os.O_WRONLY,0644)
-	if err != nil{
-		log.Fatalf("failed to open AOF file: %v", err)
+	if *restoreFrom != "" {
+		if err := restoreSnapshot(cfg, *restoreFrom, *backupEndpoint, *backupRegion, logger); err != nil {
+			logger.Error("restoring snapshot failed", "url", *restoreFrom, "err", err)
+			os.Exit(1)
+		}
 	}
-	aofFile = f
-	defer f.Close()
 
-	// replay existing aof to restore state
-	if err :=replayAOF(s,"./redigo.aof");err != nil {
-        log.Printf("error replaying AOF: %v", err)
-    }
+	var tracer *tracing.Tracer
+	if *otlpEndpoint != "" {
+		tracer = tracing.New(tracing.Config{Endpoint: *otlpEndpoint, ServiceName: "redigo", SampleRatio: *traceSampleRatio})
+	}
 
-	// Start listening on TCP port.
-	log.Printf("RediGo listening on %s ...", defaultAddr)
-	ln,err := net.Listen("tcp",defaultAddr)
+	srv, err := server.New(server.Options{
+		Addr:              cfg.Addr(),
+		Listeners:         listeners(cfg),
+		Store:             st,
+		AOFPath:           cfg.AOFPath(),
+		AOFDirName:        cfg.AppendDirname,
+		AOFLoadTruncated:  cfg.AOFLoadTruncated,
+		AOFFsync:          cfg.AppendFsync,
+		SnapshotPath:      cfg.SnapshotPath(),
+		EncryptionKey:     key,
+		TLSCertFile:       cfg.TLSCertFile,
+		TLSKeyFile:        cfg.TLSKeyFile,
+		IdleTimeout:       time.Duration(cfg.Timeout) * time.Second,
+		MaxResultSize:     cfg.MaxResultSize,
+		SlowlogSlowerThan: slowlogThreshold(cfg.SlowlogLogSlowerThan),
+		SlowlogMaxLen:     cfg.SlowlogMaxLen,
+		ConfigFile:        cfg.File,
+		Logger:            logger,
+		AuditLog:          audit,
+		DebugFaults:       cfg.DebugFault,
+		Tracer:            tracer,
+		Workers:           *workers,
+		WorkerQueue:       *workerQueue,
+		ReplayWorkers:     *replayWorkers,
+		TCPKeepAlive:      keepAlive(cfg.TCPKeepAlive),
+		TCPNagle:          !cfg.TCPNoDelay,
+		TCPBacklog:        cfg.TCPBacklog,
+		ReadThrough:       readThrough(cfg.ReadThrough),
+		ReadThroughTTL:    time.Duration(cfg.ReadThroughTTL) * time.Second,
+		SavePoints:        savePoints(cfg.SavePoints),
+		MonitorOutputLimit: server.OutputLimit{
+			Hard:        cfg.MonitorOutputLimit.Hard,
+			Soft:        cfg.MonitorOutputLimit.Soft,
+			SoftSeconds: cfg.MonitorOutputLimit.SoftSeconds,
+		},
+		AOFRewritePercentage: cfg.AutoAOFRewritePercentage,
+		AOFRewriteMinSize:    cfg.AutoAOFRewriteMinSize,
+	})
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		logger.Error("failed to start", "err", err)
+		os.Exit(1)
 	}
-	defer ln.Close()
-	for {
-		conn, err := ln.Accept()
+	// Limits and quotas only apply to new writes, so they are set once the
+	// snapshot and AOF are loaded: data already on disk is kept, and a
+	// maxmemory below its size evicts under the policy from here.
+	st.SetEvictionPolicy(cfg.MaxMemoryPolicy)
+	st.SetMaxKeys(cfg.MaxKeys)
+	st.SetMaxMemory(cfg.MaxMemory)
+	st.SetMaxKeyLength(cfg.MaxKeyLength)
+	st.SetMaxValueSize(cfg.MaxValueSize)
+	for _, q := range cfg.Quotas {
+		st.SetQuota(q.Namespace, store.Quota{MaxKeys: q.MaxKeys, MaxMemory: q.MaxMemory})
+	}
+
+	var uploader *backup.Uploader
+	if *backupURL != "" {
+		if cfg.SnapshotPath() == "" {
+			logger.Error("-backup-url needs snapshots enabled")
+			os.Exit(2)
+		}
+		b, prefix, err := openBucket(*backupURL, *backupEndpoint, *backupRegion)
 		if err != nil {
-			log.Printf("accept error: %v", err)
-			continue
+			logger.Error("invalid backup location", "url", *backupURL, "err", err)
+			os.Exit(2)
+		}
+		uploader = backup.Start(b, prefix, backup.Options{Keep: *backupKeep, MaxAge: *backupMaxAge, Logger: logger})
+		srv.OnSave(uploader.Notify)
+	}
+
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", srv.MetricsHandler())
+		go func() {
+			logger.Info("metrics listening", "addr", *metricsAddr)
+			if err := http.ListenAndServe(*metricsAddr, mux); err != nil {
+				logger.Error("metrics listener failed", "addr", *metricsAddr, "err", err)
+			}
+		}()
+	}
+
+	if *healthAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", srv.LivenessHandler())
+		mux.Handle("/readyz", srv.ReadinessHandler())
+		go func() {
+			logger.Info("health probes listening", "addr", *healthAddr)
+			if err := http.ListenAndServe(*healthAddr, mux); err != nil {
+				logger.Error("health listener failed", "addr", *healthAddr, "err", err)
+			}
+		}()
+	}
+
+	if *memcacheAddr != "" {
+		go func() {
+			if err := srv.ListenAndServeMemcache(*memcacheAddr); err != nil && err != server.ErrServerClosed {
+				logger.Error("memcached listener failed", "addr", *memcacheAddr, "err", err)
+			}
+		}()
+	}
+
+	if *wsAddr != "" {
+		var origins []string
+		if *wsOrigins != "" {
+			origins = strings.Split(*wsOrigins, ",")
 		}
-		log.Printf("new connection from %s", conn.RemoteAddr())
+		mux := http.NewServeMux()
+		mux.Handle("/ws", srv.WebSocketHandler(server.WebSocketOptions{AllowedOrigins: origins, Token: *wsToken}))
+		go func() {
+			logger.Info("websocket listening", "addr", *wsAddr)
+			if err := http.ListenAndServe(*wsAddr, mux); err != nil {
+				logger.Error("websocket listener failed", "addr", *wsAddr, "err", err)
+			}
+		}()
+	}
 
-		// Handle each client in a separate goroutine.
-		go handleConn(conn, s)
+	if *statsdAddr != "" {
+		sd, err := statsd.New(statsd.Options{Addr: *statsdAddr, Prefix: "redigo.", Tags: *statsdTags})
+		if err != nil {
+			logger.Error("statsd setup failed", "addr", *statsdAddr, "err", err)
+			os.Exit(1)
+		}
+		defer sd.Close()
+		go srv.ReportMetrics(context.Background(), sd, *statsdInterval)
 	}
-}
-func handleConn(conn net.Conn,s *store.Store){
-	defer func() {
-		log.Printf("closing connection from %s", conn.RemoteAddr())
-		conn.Close()
-	}()
-		// Send a welcome banner (purely for dev friendliness).
-	fmt.Fprintf(conn, "+OK RediGo Simple Text Server\r\n")
-	fmt.Fprintf(conn, "Supports simple text commands.\r\n")
-	fmt.Fprintf(conn, "Type HELP for commands.\r\n")
-
-	reader := bufio.NewScanner(conn)
-	for {
-		// Prompt
-		fmt.Fprint(conn,"> ")
-			if !reader.Scan() {
-			// Client closed or error
-			if err := reader.Err(); err != nil {
-				log.Printf("read error from %s: %v", conn.RemoteAddr(), err)
+
+	// Change data capture starts once the dataset is loaded, so only new
+	// writes are exported.
+	var exporters []*cdc.Exporter
+	if *cdcFile != "" {
+		sink, err := cdc.OpenFileSink(*cdcFile)
+		if err != nil {
+			logger.Error("cdc setup failed", "file", *cdcFile, "err", err)
+			os.Exit(1)
+		}
+		exporters = append(exporters, cdc.Start(st, sink, cdc.Options{Logger: logger}))
+	}
+	if *cdcWebhook != "" {
+		exporters = append(exporters, cdc.Start(st, &cdc.WebhookSink{URL: *cdcWebhook}, cdc.Options{Logger: logger}))
+	}
+
+	if *debugAddr != "" {
+		expvar.Publish("redigo", expvar.Func(func() any { return srv.Vars() }))
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		go func() {
+			logger.Info("debug listening", "addr", *debugAddr)
+			if err := http.ListenAndServe(*debugAddr, mux); err != nil {
+				logger.Error("debug listener failed", "addr", *debugAddr, "err", err)
 			}
-			return
+		}()
+	}
+
+	// Shut down gracefully on SIGINT/SIGTERM.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		logger.Info("shutting down", "signal", sig.String())
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Error("shutdown failed", "err", err)
 		}
-			line := strings.TrimSpace(reader.Text())
-		if line == "" {
-			continue
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != server.ErrServerClosed {
+		logger.Error("failed to listen", "addr", cfg.Addr(), "err", err)
+		os.Exit(1)
+	}
+	<-srv.Done()
+	for _, exp := range exporters {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := exp.Close(ctx); err != nil {
+			logger.Warn("closing cdc sink failed", "err", err)
+		}
+		cancel()
+	}
+	if uploader != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := uploader.Close(ctx); err != nil {
+			logger.Warn("snapshot upload did not finish", "err", err)
 		}
-			// Split on spaces for now: CMD key value
-		parts := strings.Fields(line)
-		cmd := strings.ToUpper(parts[0])
-		args := parts[1:]
-				// Look up command handler.
-		handler, ok := commands[cmd]
-		if !ok {
-			// Clean error: don’t dump weird whitespace
-			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", cmd)
+		cancel()
+	}
+	if tracer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := tracer.Shutdown(ctx); err != nil {
+			logger.Warn("flushing spans failed", "err", err)
+		}
+		cancel()
+	}
+	logger.Info("RediGo stopped")
+}
+
+// openBucket parses an s3:// or gs:// location, applies the endpoint and
+// region overrides and takes the credentials from the standard
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// variables (GCS HMAC keys go in the same variables).
+func openBucket(rawURL, endpoint, region string) (*backup.Bucket, string, error) {
+	b, key, err := backup.ParseURL(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if endpoint != "" {
+		b.Endpoint = endpoint
+	}
+	if region != "" {
+		b.Region = region
+	}
+	b.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	b.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	b.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	return b, key, nil
+}
+
+// restoreSnapshot downloads the snapshot at src to the snapshot path,
+// unless there is local data to start from: a restore is for a node that
+// lost its disk, not one that restarts.
+func restoreSnapshot(cfg *config.Config, src, endpoint, region string, logger *slog.Logger) error {
+	path := cfg.SnapshotPath()
+	if path == "" {
+		return fmt.Errorf("snapshots are disabled")
+	}
+	manifest := ""
+	if cfg.AOFPath() != "" {
+		manifest = filepath.Join(cfg.AOFDir(), cfg.AppendFilename+".manifest")
+	}
+	for _, p := range []string{path, cfg.AOFPath(), manifest} {
+		if fi, err := os.Stat(p); p != "" && err == nil && fi.Size() > 0 {
+			logger.Info("local data found, not restoring", "path", p)
+			return nil
+		}
+	}
+	ctx := context.Background()
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		if err := backup.DownloadURL(ctx, src, path); err != nil {
+			return err
+		}
+		logger.Info("snapshot restored", "path", path)
+		return nil
+	}
+	b, key, err := openBucket(src, endpoint, region)
+	if err != nil {
+		return err
+	}
+	key, err = backup.Restore(ctx, b, key, path)
+	if err != nil {
+		return err
+	}
+	logger.Info("snapshot restored", "bucket", b.Name, "key", key, "path", path)
+	return nil
+}
+
+// printCheckReport writes the result of -check-persistence or -dry-run.
+func printCheckReport(w io.Writer, r *server.CheckReport) {
+	for _, f := range r.Files {
+		if f.Missing {
+			fmt.Fprintf(w, "%s: not found\n", f.Path)
 			continue
 		}
+		fmt.Fprintf(w, "%s: %d records, %d bytes, %d invalid", f.Path, f.Records, f.Bytes, f.Invalid)
+		if f.Sealed {
+			fmt.Fprintf(w, ", encrypted")
+		}
+		if f.Truncated {
+			fmt.Fprintf(w, ", last record truncated")
+		}
+		fmt.Fprintln(w)
+		if len(f.Commands) > 0 {
+			fmt.Fprintf(w, "  %s\n", formatCounts(f.Commands))
+		}
+		for _, p := range f.Problems {
+			if p.Record == "" {
+				fmt.Fprintf(w, "  line %d: %s\n", p.Line, p.Reason)
+				continue
+			}
+			fmt.Fprintf(w, "  line %d: %s: %s\n", p.Line, p.Reason, p.Record)
+		}
+		if more := f.Invalid - len(f.Problems); more > 0 {
+			fmt.Fprintf(w, "  ... and %d more\n", more)
+		}
+	}
+	fmt.Fprintf(w, "dataset: %d keys, %d with a TTL, %d bytes", r.Keys, r.Expires, r.Memory)
+	if len(r.Types) > 0 {
+		fmt.Fprintf(w, "; %s", formatCounts(r.Types))
+	}
+	if r.Evicted > 0 {
+		fmt.Fprintf(w, "; %d evicted while loading", r.Evicted)
+	}
+	fmt.Fprintf(w, "\nchecked in %s\n", r.Duration.Round(time.Microsecond))
+}
+
+// formatCounts renders counts as "name n, ..." sorted by name.
+func formatCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s %d", name, counts[name])
+	}
+	return strings.Join(names, ", ")
+}
 
-		// Execute handler
-		handler(conn, s, args)
-			// Special: QUIT closes the connection from inside handler.
-		if cmd == "QUIT" {
+// applyFlags overrides cfg with the config flags that were set on the
+// command line or through the environment. listens replaces the listen
+// directives of the file.
+func applyFlags(cfg *config.Config, listens []string) error {
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		v := f.Value.String()
+		switch f.Name {
+		case "aof":
+			if v == "" {
+				err = cfg.Set("appendonly", "no")
+				return
+			}
+			if err = cfg.Set("appendonly", "yes"); err == nil {
+				err = cfg.Set("appendfilename", v)
+			}
+		case "listen":
+			cfg.Listen = nil
+			for _, spec := range listens {
+				if err = cfg.Set("listen", strings.Fields(spec)...); err != nil {
+					break
+				}
+			}
+		case "encryption-key-command":
+			err = cfg.Set(f.Name, strings.Fields(v)...)
+		case "save":
+			cfg.SavePoints = nil
+			if v != "" {
+				err = cfg.Set("save", strings.Fields(v)...)
+			}
+		case "bind", "port", "dir", "appendfsync", "maxkeys", "maxmemory", "maxmemory-policy", "max-key-length", "max-value-size", "compress-threshold", "timeout", "max-result-size", "tls-cert-file", "tls-key-file", "encryption-key":
+			err = cfg.Set(f.Name, v)
+		default:
 			return
 		}
+		if err != nil {
+			err = fmt.Errorf("-%s: %w", f.Name, err)
+		}
+	})
+	return err
+}
+
+// encryptionKey returns the key persistence files are sealed with, running
+// encryption-key-command if it is set, or nil if encryption is off.
+func encryptionKey(cfg *config.Config) ([]byte, error) {
+	if len(cfg.EncryptionKeyCommand) > 0 {
+		return encryption.KeyFromCommand(context.Background(), cfg.EncryptionKeyCommand)
 	}
+	return cfg.EncryptionKey, nil
 }
 
+// savePoints converts the save directives to Options.SavePoints.
+func savePoints(ps []config.SavePoint) []server.SavePoint {
+	points := make([]server.SavePoint, len(ps))
+	for i, p := range ps {
+		points[i] = server.SavePoint{Seconds: p.Seconds, Changes: p.Changes}
+	}
+	return points
+}
+
+// keepAlive converts tcp-keepalive seconds, where 0 means off, to
+// Options.TCPKeepAlive, where a negative value does.
+func keepAlive(secs int) time.Duration {
+	if secs == 0 {
+		return -1
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// slowlogThreshold converts slowlog-log-slower-than microseconds, where 0
+// logs every command and -1 none, to Options.SlowlogSlowerThan, where 0
+// means the default.
+func slowlogThreshold(usec int64) time.Duration {
+	switch {
+	case usec < 0:
+		return -1
+	case usec == 0:
+		return time.Nanosecond
+	}
+	return time.Duration(usec) * time.Microsecond
+}
 
+// listeners returns bind:port followed by the endpoints of cfg's listen
+// directives, or nil to serve bind:port only. bind:port serves TLS when a
+// certificate is configured and no listen directive asks for TLS.
+func listeners(cfg *config.Config) []server.Listener {
+	if len(cfg.Listen) == 0 {
+		return nil
+	}
+	main := server.Listener{Addr: cfg.Addr(), TLS: cfg.TLS()}
+	var res []server.Listener
+	for _, l := range cfg.Listen {
+		if l.TLS {
+			main.TLS = false
+		}
+		if l.Network == "tcp" && l.Addr == main.Addr {
+			main.Addr = "" // the directive overrides bind:port
+		}
+		res = append(res, server.Listener{Network: l.Network, Addr: l.Addr, TLS: l.TLS, ReadOnly: l.ReadOnly})
+	}
+	if main.Addr != "" {
+		res = append([]server.Listener{main}, res...)
+	}
+	return res
+}
 
+func readThrough(rules []config.ReadThrough) []server.ReadThroughRule {
+	res := make([]server.ReadThroughRule, len(rules))
+	for i, r := range rules {
+		res[i] = server.ReadThroughRule{Pattern: r.Pattern, Command: r.Command}
+	}
+	return res
+}
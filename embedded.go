@@ -0,0 +1,290 @@
+// Package redigo runs RediGo inside a Go program. Embedded gives the same
+// dataset semantics as the networked server (eviction, expiry, AOF and
+// snapshot persistence) without opening a TCP port; a listener can be
+// attached later to expose the same data over the wire protocol.
+//
+// The server and client live in pkg/server and pkg/client, and the bare
+// in-memory store in pkg/store.
+package redigo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/cdc"
+	"github.com/DakshBaxi/RediGo/pkg/server"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// File names used inside Options.Dir.
+const (
+	AOFFile      = "redigo.aof"
+	SnapshotFile = "redigo.snapshot"
+)
+
+// ErrClosed is returned by writes after Close.
+var ErrClosed = errors.New("redigo: embedded instance is closed")
+
+// Options configures an Embedded instance.
+type Options struct {
+	// Dir holds the AOF and snapshot files, with the AOF parts in
+	// Dir/appendonlydir. Empty keeps the data in memory only.
+	Dir string
+
+	// EncryptionKey, if set, is the 32-byte key the files in Dir are
+	// sealed with (see server.Options.EncryptionKey).
+	EncryptionKey []byte
+
+	// Logger receives server logs; nil uses slog.Default.
+	Logger *slog.Logger
+
+	// WriteBehind, if set, receives every write and deletion
+	// asynchronously, in batches with retries, once the dataset is
+	// loaded (see cdc.WriteBehind). WriteBehindOptions tunes the batching.
+	WriteBehind        cdc.Backend
+	WriteBehindOptions cdc.Options
+
+	// Loader, if set, fills misses in GetOrLoad, typically from the same
+	// backend WriteBehind writes to. Loaded values expire after LoaderTTL
+	// (0 means never); concurrent misses on a key share one call.
+	Loader    store.Loader
+	LoaderTTL time.Duration
+}
+
+// Embedded is an in-process RediGo instance. It is safe for concurrent use.
+type Embedded struct {
+	srv *server.Server
+	s   *store.Store
+	wb  *cdc.Exporter // nil without Options.WriteBehind
+
+	mu     sync.RWMutex // write lock held by Close
+	closed bool
+}
+
+// Open restores the dataset from opts.Dir (snapshot first, then the AOF, see
+// server.Options.AOFPath) and returns a ready instance. The AOF is rewritten whenever it doubles in
+// size past 64MB, as redigo.conf does by default.
+func Open(opts Options) (*Embedded, error) {
+	so := server.Options{Logger: opts.Logger}
+	if opts.Dir != "" {
+		so.AOFPath = filepath.Join(opts.Dir, AOFFile)
+		so.SnapshotPath = filepath.Join(opts.Dir, SnapshotFile)
+		so.EncryptionKey = opts.EncryptionKey
+		so.AOFRewritePercentage = 100
+		so.AOFRewriteMinSize = 64 << 20
+	}
+	srv, err := server.New(so)
+	if err != nil {
+		return nil, err
+	}
+	e := &Embedded{srv: srv, s: srv.Store()}
+	if opts.Loader != nil {
+		e.s.SetLoader(opts.Loader, opts.LoaderTTL)
+	}
+	if opts.WriteBehind != nil {
+		wo := opts.WriteBehindOptions
+		if wo.Logger == nil {
+			wo.Logger = opts.Logger
+		}
+		e.wb = cdc.Start(e.s, cdc.WriteBehind(opts.WriteBehind), wo)
+	}
+	return e, nil
+}
+
+// Store returns the underlying store for reads and configuration (limits,
+// eviction policy, stats). Writes made directly on it are not persisted;
+// use the Embedded methods instead.
+func (e *Embedded) Store() *store.Store {
+	return e.s
+}
+
+// Get returns the value of key and whether it exists.
+func (e *Embedded) Get(key string) (string, bool) {
+	return e.s.Get(key)
+}
+
+// GetOrLoad returns the value of key, asking Options.Loader for keys that
+// don't exist and caching the result. Without a loader it is Get.
+func (e *Embedded) GetOrLoad(ctx context.Context, key string) (string, bool, error) {
+	return e.s.GetOrLoad(ctx, key)
+}
+
+// TTL returns the remaining time to live of key: -1 if it has no TTL and -2
+// if it does not exist, in seconds as reported by the TTL command.
+func (e *Embedded) TTL(key string) int64 {
+	return e.s.TTL(key)
+}
+
+// Set stores value under key without a TTL.
+func (e *Embedded) Set(key, value string) error {
+	return e.write(func() error {
+		if err := e.s.Set(key, value); err != nil {
+			return err
+		}
+		e.srv.Propagate("SET", key, value)
+		return nil
+	})
+}
+
+// SetEX stores value under key with a TTL of whole seconds (at least one).
+func (e *Embedded) SetEX(key, value string, ttl time.Duration) error {
+	secs := seconds(ttl)
+	return e.write(func() error {
+		if err := e.s.Setwithttl(key, value, secs); err != nil {
+			return err
+		}
+		e.propagateValue(key, value)
+		return nil
+	})
+}
+
+// Del removes key and reports whether it existed.
+func (e *Embedded) Del(key string) (bool, error) {
+	var ok bool
+	err := e.write(func() error {
+		if ok = e.s.Del(key); ok {
+			e.srv.Propagate("DEL", key)
+		}
+		return nil
+	})
+	return ok, err
+}
+
+// Expire sets a TTL on key and reports whether the key exists.
+func (e *Embedded) Expire(key string, ttl time.Duration) (bool, error) {
+	var ok bool
+	err := e.write(func() error {
+		at := e.s.Now().Unix() + seconds(ttl)
+		if ok = e.s.ExpireAt(key, at); ok {
+			e.srv.Propagate("EXPIREAT", key, strconv.FormatInt(at, 10))
+		}
+		return nil
+	})
+	return ok, err
+}
+
+// Persist removes the TTL of key and reports whether it had one.
+func (e *Embedded) Persist(key string) (bool, error) {
+	var ok bool
+	err := e.write(func() error {
+		if ok = e.s.Persist(key); ok {
+			e.srv.Propagate("PERSIST", key)
+		}
+		return nil
+	})
+	return ok, err
+}
+
+// IncrBy adds delta to the integer stored at key and returns the result.
+func (e *Embedded) IncrBy(key string, delta int64) (int64, error) {
+	var n int64
+	err := e.write(func() error {
+		var err error
+		if n, err = e.s.IncrBy(key, delta); err != nil {
+			return err
+		}
+		// Log the result, not the delta, so replaying the AOF over a
+		// snapshot that already includes the increment stays correct.
+		e.propagateValue(key, strconv.FormatInt(n, 10))
+		return nil
+	})
+	return n, err
+}
+
+// Update runs fn against the dataset atomically, as store.Store.Update
+// does, and logs the keys it changed to the AOF, even when fn fails after
+// writing. Write-behind sees the changes like any other write.
+func (e *Embedded) Update(fn func(tx *store.Txn) error) error {
+	return e.write(func() error {
+		var changes []store.Change
+		err := e.s.Update(func(tx *store.Txn) error {
+			err := fn(tx)
+			changes = tx.Changes()
+			return err
+		})
+		e.srv.PropagateChanges(changes)
+		return err
+	})
+}
+
+// propagateValue logs the new value of key and its expiry, as SET and an
+// absolute EXPIREAT, so a replay doesn't restart the TTL.
+func (e *Embedded) propagateValue(key, val string) {
+	e.srv.PropagateChanges([]store.Change{{Key: key, Value: val, ExpiresAt: e.s.ExpireTime(key)}})
+}
+
+// Save writes a snapshot to Options.Dir and waits for it to finish.
+func (e *Embedded) Save() error {
+	return e.srv.Save()
+}
+
+// RewriteAOF starts rewriting the AOF in the background, see
+// server.Server.RewriteAOF.
+func (e *Embedded) RewriteAOF() error {
+	return e.srv.RewriteAOF()
+}
+
+// Serve exposes the instance over the RediGo protocol on ln. It blocks
+// until Close is called and then returns server.ErrServerClosed.
+func (e *Embedded) Serve(ln net.Listener) error {
+	return e.srv.Serve(ln)
+}
+
+// ListenAndServe is Serve on a new TCP listener for addr.
+func (e *Embedded) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return e.Serve(ln)
+}
+
+// RegisterFunction makes fn callable as FCALL name by the clients of an
+// attached listener, see server.Function.
+func (e *Embedded) RegisterFunction(name string, fn server.Function) {
+	e.srv.RegisterFunction(name, fn)
+}
+
+// Close stops any attached listener, closes its connections, flushes the
+// AOF to disk and waits for pending write-behind changes to be delivered.
+func (e *Embedded) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	err := e.srv.Close()
+	if e.wb != nil {
+		if werr := e.wb.Close(context.Background()); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// write runs fn unless the instance is closed. Holding the read lock keeps
+// Close from closing the AOF between the store update and its log entry.
+func (e *Embedded) write(fn func() error) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return ErrClosed
+	}
+	return fn()
+}
+
+// seconds converts d to whole seconds, with a minimum of one.
+func seconds(d time.Duration) int64 {
+	s := int64(d / time.Second)
+	if s < 1 {
+		s = 1
+	}
+	return s
+}
@@ -0,0 +1,644 @@
+// Package config reads redigo.conf, a redis.conf-style file with one
+// directive per line:
+//
+//	# persistence
+//	port 6380
+//	dir /var/lib/redigo
+//	appendfsync everysec
+//	maxmemory 256mb
+//	maxmemory-policy allkeys-lfu
+//
+// Arguments are separated by spaces; wrap an argument in double quotes to
+// include spaces. Lines starting with '#' are comments.
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/DakshBaxi/RediGo/internal/discovery"
+	"github.com/DakshBaxi/RediGo/internal/encryption"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// Fsync policies for the AOF.
+const (
+	FsyncAlways   = "always"   // after every write
+	FsyncEverySec = "everysec" // once a second
+	FsyncNo       = "no"       // leave it to the OS
+)
+
+// Listen is an endpoint given with a listen directive:
+//
+//	listen <host:port | unix:/path> [tls] [readonly]
+type Listen struct {
+	Network  string // "tcp" or "unix"
+	Addr     string
+	TLS      bool
+	ReadOnly bool
+}
+
+func (l Listen) String() string {
+	s := l.Addr
+	if l.Network == "unix" {
+		s = "unix:" + l.Addr
+	}
+	if l.TLS {
+		s += " tls"
+	}
+	if l.ReadOnly {
+		s += " readonly"
+	}
+	return s
+}
+
+// Quota is a per-namespace limit given with a namespace-quota directive:
+//
+//	namespace-quota <namespace> <maxkeys> <maxmemory>
+//
+// The namespace of a key is the part before its first ':'. Zero disables a
+// limit.
+type Quota struct {
+	Namespace string
+	MaxKeys   int
+	MaxMemory int64
+}
+
+func (q Quota) String() string {
+	return fmt.Sprintf("%s %d %d", q.Namespace, q.MaxKeys, q.MaxMemory)
+}
+
+// ReadThrough is a read-through loader given with a read-through directive:
+//
+//	read-through <pattern> <command> [args...]
+//
+// A GET miss on a key matching pattern runs the command, with the key in
+// $REDIGO_KEY and "{key}" in its arguments replaced by the key; see
+// server.ReadThroughRule.
+type ReadThrough struct {
+	Pattern string
+	Command []string
+}
+
+func (r ReadThrough) String() string {
+	return r.Pattern + " " + strings.Join(r.Command, " ")
+}
+
+// OutputLimit is the client-output-buffer-limit for MONITOR clients:
+//
+//	client-output-buffer-limit monitor <hard> <soft> <soft-seconds>
+//
+// A monitor is disconnected once its pending output exceeds hard, or stays
+// above soft for soft-seconds. Zero disables a limit.
+type OutputLimit struct {
+	Hard        int64
+	Soft        int64
+	SoftSeconds int
+}
+
+func (l OutputLimit) String() string {
+	return fmt.Sprintf("monitor %d %d %d", l.Hard, l.Soft, l.SoftSeconds)
+}
+
+// SavePoint is a rule of the save directive: snapshot the dataset once
+// Seconds have passed and at least Changes writes were made since the last
+// snapshot.
+//
+//	save <seconds> <changes> [<seconds> <changes> ...]
+type SavePoint struct {
+	Seconds int64
+	Changes int64
+}
+
+func (p SavePoint) String() string {
+	return fmt.Sprintf("%d %d", p.Seconds, p.Changes)
+}
+
+// Config is the startup configuration of a RediGo server.
+type Config struct {
+	Bind string
+	Port int
+
+	// Listen holds extra endpoints served alongside bind and port. The
+	// directive may be repeated.
+	Listen []Listen
+
+	Dir            string
+	AppendOnly     bool
+	AppendFilename string
+	AppendDirname  string
+	DBFilename     string
+	AppendFsync    string
+
+	// AutoAOFRewritePercentage rewrites the AOF once it has grown by this
+	// percentage since the last rewrite (0 = never), provided it is at
+	// least AutoAOFRewriteMinSize bytes.
+	AutoAOFRewritePercentage int
+	AutoAOFRewriteMinSize    int64
+
+	// AOFLoadTruncated starts the server when a file ends in a record cut
+	// short by a crash, dropping the record, instead of refusing to.
+	AOFLoadTruncated bool
+
+	// SavePoints trigger a background snapshot; the first one met wins.
+	// The directive may be repeated, and save "" removes them all.
+	SavePoints []SavePoint
+
+	MaxKeys         int
+	MaxMemory       int64
+	MaxMemoryPolicy store.EvictionPolicy
+	MaxKeyLength    int   // bytes, 0 = unlimited
+	MaxValueSize    int64 // bytes, 0 = unlimited
+
+	// CompressThreshold is the size from which string values are stored
+	// compressed; 0 disables compression.
+	CompressThreshold int64
+
+	// Quotas limit the keys and memory of namespaces. The directive may be
+	// repeated.
+	Quotas []Quota
+
+	// ReadThrough loaders fill GET misses; the first matching rule wins.
+	// The directive may be repeated. Loaded keys expire after
+	// ReadThroughTTL seconds, 0 = never.
+	ReadThrough    []ReadThrough
+	ReadThroughTTL int64
+
+	LFULogFactor    int
+	LFUDecayTime    int
+	DefragThreshold int   // percent of the peak key count, 0 = off
+	KeyIndex        bool  // keep a sorted key index for prefix scans
+	DebugFault      bool  // allow DEBUG FAULT
+	Timeout         int64 // idle client timeout in seconds, 0 = none
+	MaxResultSize   int   // items in a KEYS or TS.RANGE reply, 0 = unlimited
+
+	// Commands running at least SlowlogLogSlowerThan microseconds are kept
+	// in the slowlog (-1 = off, 0 = every command), up to SlowlogMaxLen.
+	SlowlogLogSlowerThan int64
+	SlowlogMaxLen        int
+
+	// TCP tuning of client connections.
+	TCPKeepAlive int  // keep-alive probe period in seconds, 0 = off
+	TCPNoDelay   bool // disable Nagle's algorithm
+	TCPBacklog   int  // pending connection queue length, 0 = system default
+
+	MonitorOutputLimit OutputLimit
+
+	// ReplicaOf is the primary's "host:port"; used by redigo-replica.
+	ReplicaOf string
+
+	// TLS is enabled when both files are set.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// EncryptionKey seals the AOF and snapshots; EncryptionKeyCommand is
+	// run to print it instead, e.g. a KMS client. Setting one clears the
+	// other. Neither is reported by CONFIG GET.
+	EncryptionKey        []byte
+	EncryptionKeyCommand []string
+
+	// File is the path the configuration was loaded from, if any.
+	File string
+
+	set map[string]bool
+}
+
+// Default returns the configuration used when no file is given.
+func Default() *Config {
+	return &Config{
+		Port:                 6380,
+		Dir:                  ".",
+		AppendOnly:           true,
+		AppendFilename:       "redigo.aof",
+		AppendDirname:        "appendonlydir",
+		DBFilename:           "redigo.snapshot",
+		AppendFsync:          FsyncEverySec,
+		MaxMemoryPolicy:      store.PolicyAllKeysLRU,
+		LFULogFactor:         10,
+		LFUDecayTime:         1,
+		DefragThreshold:      25,
+		SlowlogLogSlowerThan: 10000,
+		SlowlogMaxLen:        128,
+		TCPKeepAlive:         300,
+		TCPNoDelay:           true,
+		TCPBacklog:           511,
+		ReadThroughTTL:       300,
+		set:                  make(map[string]bool),
+		MonitorOutputLimit: OutputLimit{
+			Hard:        32 << 20,
+			Soft:        8 << 20,
+			SoftSeconds: 60,
+		},
+		AutoAOFRewritePercentage: 100,
+		AutoAOFRewriteMinSize:    64 << 20,
+	}
+}
+
+// Load reads the file at path on top of the defaults.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := Default()
+	if err := c.Parse(f, path); err != nil {
+		return nil, err
+	}
+	if c.File, err = filepath.Abs(path); err != nil {
+		c.File = path
+	}
+	return c, nil
+}
+
+// Parse applies the directives read from r; name is used in error messages.
+func (c *Config) Parse(r io.Reader, name string) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		args, err := splitLine(sc.Text())
+		if err == nil && len(args) > 0 {
+			err = c.Set(args[0], args[1:]...)
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// Set applies one directive.
+func (c *Config) Set(directive string, args ...string) error {
+	name := strings.ToLower(directive)
+	one := func() (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("%s takes exactly one argument", name)
+		}
+		return args[0], nil
+	}
+	var err error
+	switch name {
+	case "bind":
+		c.Bind, err = one()
+	case "port":
+		c.Port, err = intArg(name, args, 0, 65535)
+	case "listen":
+		var l Listen
+		if l, err = parseListen(args); err == nil {
+			c.Listen = append(c.Listen, l)
+		}
+	case "dir":
+		c.Dir, err = one()
+	case "appendonly":
+		c.AppendOnly, err = boolArg(name, args)
+	case "appendfilename":
+		c.AppendFilename, err = one()
+	case "appenddirname":
+		c.AppendDirname, err = one()
+	case "auto-aof-rewrite-percentage":
+		c.AutoAOFRewritePercentage, err = intArg(name, args, 0, -1)
+	case "auto-aof-rewrite-min-size":
+		var v string
+		if v, err = one(); err == nil {
+			c.AutoAOFRewriteMinSize, err = ParseSize(v)
+		}
+	case "aof-load-truncated":
+		c.AOFLoadTruncated, err = boolArg(name, args)
+	case "dbfilename":
+		c.DBFilename, err = one()
+	case "save":
+		var ps []SavePoint
+		if ps, err = ParseSavePoints(args); err == nil {
+			if ps == nil {
+				c.SavePoints = nil
+			} else {
+				c.SavePoints = append(c.SavePoints, ps...)
+			}
+		}
+	case "appendfsync":
+		var v string
+		if v, err = one(); err == nil {
+			switch v = strings.ToLower(v); v {
+			case FsyncAlways, FsyncEverySec, FsyncNo:
+				c.AppendFsync = v
+			default:
+				err = fmt.Errorf("appendfsync must be always, everysec or no, got %q", v)
+			}
+		}
+	case "maxkeys":
+		c.MaxKeys, err = intArg(name, args, 0, -1)
+	case "maxmemory":
+		var v string
+		if v, err = one(); err == nil {
+			c.MaxMemory, err = ParseSize(v)
+		}
+	case "maxmemory-policy":
+		var v string
+		if v, err = one(); err == nil {
+			c.MaxMemoryPolicy, err = store.ParseEvictionPolicy(strings.ToLower(v))
+		}
+	case "max-key-length":
+		c.MaxKeyLength, err = intArg(name, args, 0, -1)
+	case "max-value-size":
+		var v string
+		if v, err = one(); err == nil {
+			c.MaxValueSize, err = ParseSize(v)
+		}
+	case "compress-threshold":
+		var v string
+		if v, err = one(); err == nil {
+			c.CompressThreshold, err = ParseSize(v)
+		}
+	case "namespace-quota":
+		var q Quota
+		if q, err = parseQuota(args); err == nil {
+			c.Quotas = append(c.Quotas, q)
+		}
+	case "read-through":
+		if len(args) < 2 {
+			err = errors.New("read-through takes a key pattern and a command")
+		} else if strings.Contains(args[1], "{key}") {
+			err = errors.New("read-through: {key} is not allowed in the program name")
+		} else {
+			c.ReadThrough = append(c.ReadThrough, ReadThrough{Pattern: args[0], Command: args[1:]})
+		}
+	case "read-through-ttl":
+		var n int
+		n, err = intArg(name, args, 0, -1)
+		c.ReadThroughTTL = int64(n)
+	case "lfu-log-factor":
+		c.LFULogFactor, err = intArg(name, args, 0, -1)
+	case "lfu-decay-time":
+		c.LFUDecayTime, err = intArg(name, args, 0, -1)
+	case "active-defrag-threshold":
+		c.DefragThreshold, err = intArg(name, args, 0, 100)
+	case "key-index":
+		c.KeyIndex, err = boolArg(name, args)
+	case "enable-debug-fault":
+		c.DebugFault, err = boolArg(name, args)
+	case "timeout":
+		var n int
+		n, err = intArg(name, args, 0, -1)
+		c.Timeout = int64(n)
+	case "max-result-size":
+		c.MaxResultSize, err = intArg(name, args, 0, -1)
+	case "slowlog-log-slower-than":
+		var n int
+		if n, err = intArg(name, args, -1, -1); err == nil {
+			c.SlowlogLogSlowerThan = int64(n)
+		}
+	case "slowlog-max-len":
+		c.SlowlogMaxLen, err = intArg(name, args, 0, -1)
+	case "client-output-buffer-limit":
+		c.MonitorOutputLimit, err = ParseOutputLimit(args)
+	case "tcp-keepalive":
+		c.TCPKeepAlive, err = intArg(name, args, 0, -1)
+	case "tcp-nodelay":
+		c.TCPNoDelay, err = boolArg(name, args)
+	case "tcp-backlog":
+		c.TCPBacklog, err = intArg(name, args, 0, 65535)
+	case "replicaof":
+		switch {
+		case len(args) == 1 && strings.EqualFold(args[0], "no"):
+			c.ReplicaOf = ""
+		case len(args) == 1 && discovery.IsSpec(args[0]):
+			c.ReplicaOf = args[0]
+		case len(args) == 2:
+			c.ReplicaOf = net.JoinHostPort(args[0], args[1])
+		default:
+			err = errors.New("replicaof takes <host> <port>, a seed list, srv:<name> or \"no\"")
+		}
+	case "tls-cert-file":
+		c.TLSCertFile, err = one()
+	case "tls-key-file":
+		c.TLSKeyFile, err = one()
+	case "encryption-key":
+		var v string
+		if v, err = one(); err == nil {
+			c.EncryptionKey, err = encryption.ParseKey(v)
+			c.EncryptionKeyCommand = nil
+		}
+	case "encryption-key-command":
+		if len(args) == 0 {
+			err = errors.New("encryption-key-command takes a command and its arguments")
+		}
+		c.EncryptionKey, c.EncryptionKeyCommand = nil, args
+	default:
+		return fmt.Errorf("unknown directive %q", directive)
+	}
+	if err != nil {
+		return err
+	}
+	c.set[name] = true
+	return nil
+}
+
+// IsSet reports whether directive was given explicitly.
+func (c *Config) IsSet(directive string) bool {
+	return c.set[strings.ToLower(directive)]
+}
+
+// Addr is the address to listen on.
+func (c *Config) Addr() string {
+	return net.JoinHostPort(c.Bind, strconv.Itoa(c.Port))
+}
+
+// AOFPath is the AOF location, or "" when appendonly is off. Its parts
+// live in AOFDir, under names starting with AppendFilename; a plain file at
+// AOFPath is a single-file AOF from before the split, imported at startup.
+func (c *Config) AOFPath() string {
+	if !c.AppendOnly || c.AppendFilename == "" {
+		return ""
+	}
+	return filepath.Join(c.Dir, c.AppendFilename)
+}
+
+// AOFDir is the directory holding the AOF parts and their manifest, or ""
+// when appendonly is off.
+func (c *Config) AOFDir() string {
+	if c.AOFPath() == "" {
+		return ""
+	}
+	return filepath.Join(c.Dir, c.AppendDirname)
+}
+
+// SnapshotPath is the snapshot location, or "" when dbfilename is empty.
+func (c *Config) SnapshotPath() string {
+	if c.DBFilename == "" {
+		return ""
+	}
+	return filepath.Join(c.Dir, c.DBFilename)
+}
+
+// TLS reports whether a certificate and key are configured.
+func (c *Config) TLS() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// ParseSize parses a byte count with an optional kb/mb/gb suffix
+// (case-insensitive), e.g. "100mb".
+func ParseSize(v string) (int64, error) {
+	mult := int64(1)
+	lower := strings.ToLower(v)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30}, {"b", 1}} {
+		if strings.HasSuffix(lower, u.suffix) {
+			lower = strings.TrimSuffix(lower, u.suffix)
+			mult = u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid memory value")
+	}
+	return n * mult, nil
+}
+
+// ParseOutputLimit parses the arguments of client-output-buffer-limit.
+// Only the monitor class is supported.
+func ParseOutputLimit(args []string) (OutputLimit, error) {
+	if len(args) != 4 {
+		return OutputLimit{}, errors.New("client-output-buffer-limit takes <class> <hard> <soft> <soft-seconds>")
+	}
+	if !strings.EqualFold(args[0], "monitor") {
+		return OutputLimit{}, fmt.Errorf("unsupported client-output-buffer-limit class %q, only monitor is supported", args[0])
+	}
+	var l OutputLimit
+	var err error
+	if l.Hard, err = ParseSize(args[1]); err != nil {
+		return OutputLimit{}, fmt.Errorf("invalid hard limit %q", args[1])
+	}
+	if l.Soft, err = ParseSize(args[2]); err != nil {
+		return OutputLimit{}, fmt.Errorf("invalid soft limit %q", args[2])
+	}
+	if l.SoftSeconds, err = strconv.Atoi(args[3]); err != nil || l.SoftSeconds < 0 {
+		return OutputLimit{}, fmt.Errorf("invalid soft limit seconds %q", args[3])
+	}
+	return l, nil
+}
+
+// ParseSavePoints parses the arguments of save: pairs of seconds and
+// changes. No arguments, or a single empty one, means no save points and
+// returns nil.
+func ParseSavePoints(args []string) ([]SavePoint, error) {
+	if len(args) == 0 || len(args) == 1 && args[0] == "" {
+		return nil, nil
+	}
+	if len(args)%2 != 0 {
+		return nil, errors.New("save takes pairs of <seconds> <changes>")
+	}
+	ps := make([]SavePoint, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		var p SavePoint
+		var err error
+		if p.Seconds, err = strconv.ParseInt(args[i], 10, 64); err != nil || p.Seconds <= 0 {
+			return nil, fmt.Errorf("invalid save seconds %q", args[i])
+		}
+		if p.Changes, err = strconv.ParseInt(args[i+1], 10, 64); err != nil || p.Changes <= 0 {
+			return nil, fmt.Errorf("invalid save changes %q", args[i+1])
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+func parseQuota(args []string) (Quota, error) {
+	if len(args) != 3 {
+		return Quota{}, errors.New("namespace-quota takes a namespace, maxkeys and maxmemory")
+	}
+	q := Quota{Namespace: args[0]}
+	var err error
+	if q.MaxKeys, err = strconv.Atoi(args[1]); err != nil || q.MaxKeys < 0 {
+		return Quota{}, fmt.Errorf("invalid quota maxkeys %q", args[1])
+	}
+	if q.MaxMemory, err = ParseSize(args[2]); err != nil {
+		return Quota{}, fmt.Errorf("invalid quota maxmemory %q", args[2])
+	}
+	return q, nil
+}
+
+func parseListen(args []string) (Listen, error) {
+	if len(args) == 0 {
+		return Listen{}, errors.New("listen takes an address and optional tls and readonly flags")
+	}
+	l := Listen{Network: "tcp", Addr: args[0]}
+	if path, ok := strings.CutPrefix(args[0], "unix:"); ok {
+		l.Network, l.Addr = "unix", path
+	} else if _, _, err := net.SplitHostPort(args[0]); err != nil {
+		return Listen{}, fmt.Errorf("invalid listen address %q: want host:port or unix:/path", args[0])
+	}
+	for _, opt := range args[1:] {
+		switch strings.ToLower(opt) {
+		case "tls":
+			l.TLS = true
+		case "readonly":
+			l.ReadOnly = true
+		default:
+			return Listen{}, fmt.Errorf("unknown listen option %q", opt)
+		}
+	}
+	return l, nil
+}
+
+// intArg parses a single integer argument in [lo, hi]; hi < 0 means no
+// upper bound.
+func intArg(name string, args []string, lo, hi int) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%s takes exactly one argument", name)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < lo || (hi >= 0 && n > hi) {
+		return 0, fmt.Errorf("invalid %s value %q", name, args[0])
+	}
+	return n, nil
+}
+
+func boolArg(name string, args []string) (bool, error) {
+	if len(args) == 1 {
+		switch strings.ToLower(args[0]) {
+		case "yes":
+			return true, nil
+		case "no":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("%s takes yes or no", name)
+}
+
+// splitLine splits a line into arguments, honouring double quotes and
+// skipping comments.
+func splitLine(line string) ([]string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return nil, nil
+	}
+	var args []string
+	for line != "" {
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated quoted argument")
+			}
+			args = append(args, line[1:end+1])
+			line = line[end+2:]
+		} else {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			args = append(args, line[:end])
+			line = line[end:]
+		}
+		line = strings.TrimLeft(line, " \t")
+	}
+	return args, nil
+}
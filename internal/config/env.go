@@ -0,0 +1,36 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvName is the environment variable that overrides flag name, e.g.
+// REDIGO_MAXMEMORY_POLICY for -maxmemory-policy.
+func EnvName(prefix, name string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// SetFlagsFromEnv sets every flag of fs that was not given on the command
+// line from its environment variable (see EnvName), so containers can be
+// configured without arguments. Command-line flags win over the
+// environment. Flags set this way count as set for fs.Visit.
+func SetFlagsFromEnv(fs *flag.FlagSet, prefix string) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || given[f.Name] {
+			return
+		}
+		env := EnvName(prefix, f.Name)
+		if v, ok := os.LookupEnv(env); ok {
+			if serr := fs.Set(f.Name, v); serr != nil {
+				err = fmt.Errorf("%s=%q: %w", env, v, serr)
+			}
+		}
+	})
+	return err
+}
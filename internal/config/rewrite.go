@@ -0,0 +1,241 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/DakshBaxi/RediGo/internal/discovery"
+)
+
+// Known reports whether directive is a redigo.conf directive.
+func Known(directive string) bool {
+	_, ok := Default().Get(directive)
+	return ok
+}
+
+// Get returns the value of directive as it would be written in a file.
+func (c *Config) Get(directive string) (string, bool) {
+	switch strings.ToLower(directive) {
+	case "bind":
+		return c.Bind, true
+	case "port":
+		return strconv.Itoa(c.Port), true
+	case "listen":
+		specs := make([]string, len(c.Listen))
+		for i, l := range c.Listen {
+			specs[i] = l.String()
+		}
+		return strings.Join(specs, ", "), true
+	case "dir":
+		return c.Dir, true
+	case "appendonly":
+		return yesNo(c.AppendOnly), true
+	case "appendfilename":
+		return c.AppendFilename, true
+	case "appenddirname":
+		return c.AppendDirname, true
+	case "auto-aof-rewrite-percentage":
+		return strconv.Itoa(c.AutoAOFRewritePercentage), true
+	case "auto-aof-rewrite-min-size":
+		return strconv.FormatInt(c.AutoAOFRewriteMinSize, 10), true
+	case "aof-load-truncated":
+		return yesNo(c.AOFLoadTruncated), true
+	case "dbfilename":
+		return c.DBFilename, true
+	case "save":
+		return FormatSavePoints(c.SavePoints), true
+	case "appendfsync":
+		return c.AppendFsync, true
+	case "maxkeys":
+		return strconv.Itoa(c.MaxKeys), true
+	case "maxmemory":
+		return strconv.FormatInt(c.MaxMemory, 10), true
+	case "maxmemory-policy":
+		return string(c.MaxMemoryPolicy), true
+	case "max-key-length":
+		return strconv.Itoa(c.MaxKeyLength), true
+	case "max-value-size":
+		return strconv.FormatInt(c.MaxValueSize, 10), true
+	case "compress-threshold":
+		return strconv.FormatInt(c.CompressThreshold, 10), true
+	case "namespace-quota":
+		specs := make([]string, len(c.Quotas))
+		for i, q := range c.Quotas {
+			specs[i] = q.String()
+		}
+		return strings.Join(specs, ", "), true
+	case "read-through":
+		specs := make([]string, len(c.ReadThrough))
+		for i, rt := range c.ReadThrough {
+			specs[i] = rt.String()
+		}
+		return strings.Join(specs, ", "), true
+	case "read-through-ttl":
+		return strconv.FormatInt(c.ReadThroughTTL, 10), true
+	case "lfu-log-factor":
+		return strconv.Itoa(c.LFULogFactor), true
+	case "lfu-decay-time":
+		return strconv.Itoa(c.LFUDecayTime), true
+	case "active-defrag-threshold":
+		return strconv.Itoa(c.DefragThreshold), true
+	case "key-index":
+		return yesNo(c.KeyIndex), true
+	case "enable-debug-fault":
+		return yesNo(c.DebugFault), true
+	case "timeout":
+		return strconv.FormatInt(c.Timeout, 10), true
+	case "max-result-size":
+		return strconv.Itoa(c.MaxResultSize), true
+	case "slowlog-log-slower-than":
+		return strconv.FormatInt(c.SlowlogLogSlowerThan, 10), true
+	case "slowlog-max-len":
+		return strconv.Itoa(c.SlowlogMaxLen), true
+	case "client-output-buffer-limit":
+		return c.MonitorOutputLimit.String(), true
+	case "tcp-keepalive":
+		return strconv.Itoa(c.TCPKeepAlive), true
+	case "tcp-nodelay":
+		return yesNo(c.TCPNoDelay), true
+	case "tcp-backlog":
+		return strconv.Itoa(c.TCPBacklog), true
+	case "replicaof":
+		if c.ReplicaOf == "" {
+			return "no", true
+		}
+		if discovery.IsSpec(c.ReplicaOf) {
+			return c.ReplicaOf, true
+		}
+		host, port, _ := strings.Cut(c.ReplicaOf, ":")
+		return host + " " + port, true
+	case "tls-cert-file":
+		return c.TLSCertFile, true
+	case "tls-key-file":
+		return c.TLSKeyFile, true
+	}
+	return "", false
+}
+
+// Directive is a directive name and its value.
+type Directive struct {
+	Name  string
+	Value string
+}
+
+// Rewrite updates the file at path with directives, keeping comments,
+// blank lines and unrelated directives as they are. The first line of an
+// existing directive is replaced in place and any repeats of it are
+// dropped; directives missing from the file are appended unless they hold
+// their default value. The file is replaced atomically.
+func Rewrite(path string, directives []Directive) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	want := make(map[string]string, len(directives))
+	for _, d := range directives {
+		want[strings.ToLower(d.Name)] = d.Value
+	}
+
+	var out bytes.Buffer
+	written := make(map[string]bool)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		args, err := splitLine(line)
+		if err != nil || len(args) == 0 {
+			out.WriteString(line + "\n")
+			continue
+		}
+		name := strings.ToLower(args[0])
+		v, ok := want[name]
+		switch {
+		case !ok:
+			out.WriteString(line + "\n")
+		case !written[name]:
+			out.WriteString(formatDirective(name, v) + "\n")
+			written[name] = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	def := Default()
+	header := false
+	for _, d := range directives {
+		name := strings.ToLower(d.Name)
+		if written[name] {
+			continue
+		}
+		if dv, _ := def.Get(name); dv == d.Value {
+			continue
+		}
+		if !header {
+			if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n\n")) {
+				out.WriteString("\n")
+			}
+			out.WriteString("# Generated by CONFIG REWRITE\n")
+			header = true
+		}
+		out.WriteString(formatDirective(name, d.Value) + "\n")
+		written[name] = true
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(path); err == nil {
+		os.Chmod(tmp.Name(), fi.Mode().Perm())
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// formatDirective renders one line, quoting a value that is empty or
+// contains spaces, except for directives that take several arguments.
+func formatDirective(name, value string) string {
+	if value == "" || strings.ContainsAny(value, " \t") && !multiArg[name] {
+		return name + ` "` + value + `"`
+	}
+	return name + " " + value
+}
+
+// FormatSavePoints renders save points as the arguments of one save
+// directive, or "" for none.
+func FormatSavePoints(ps []SavePoint) string {
+	specs := make([]string, len(ps))
+	for i, p := range ps {
+		specs[i] = p.String()
+	}
+	return strings.Join(specs, " ")
+}
+
+// multiArg holds the directives whose value is several arguments.
+var multiArg = map[string]bool{
+	"save":                       true,
+	"replicaof":                  true,
+	"client-output-buffer-limit": true,
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,76 @@
+// Package discovery finds a server from an address spec that may change
+// over time, so a replica or client keeps working after the primary moves.
+// A spec is one of:
+//
+//	host:port                       - a single address
+//	host1:port1,host2:port2         - a seed list, tried in order
+//	srv:_redigo._tcp.example.com    - a DNS SRV record
+//
+// Specs are resolved again on every dial: host names go through DNS each
+// time and SRV records are looked up afresh, so a Kubernetes service or a
+// dynamic IP is followed without a restart.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const srvPrefix = "srv:"
+
+// IsSpec reports whether spec needs resolving, i.e. is a seed list or an
+// SRV record rather than a single address.
+func IsSpec(spec string) bool {
+	return strings.HasPrefix(spec, srvPrefix) || strings.Contains(spec, ",")
+}
+
+// Resolve returns the addresses spec stands for, in the order they should
+// be tried. SRV targets come sorted by priority and shuffled by weight.
+func Resolve(ctx context.Context, spec string) ([]string, error) {
+	if name, ok := strings.CutPrefix(spec, srvPrefix); ok {
+		_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, fmt.Errorf("look up %s: %w", name, err)
+		}
+		addrs := make([]string, len(srvs))
+		for i, srv := range srvs {
+			addrs[i] = net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+		}
+		return addrs, nil
+	}
+	var addrs []string
+	for _, a := range strings.Split(spec, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("empty address")
+	}
+	return addrs, nil
+}
+
+// Dial resolves spec and connects to the first address that accepts the
+// connection.
+func Dial(ctx context.Context, d *net.Dialer, spec string) (net.Conn, error) {
+	addrs, err := Resolve(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,281 @@
+// Package encryption seals persistence files (AOF parts and snapshots)
+// with AES-256-GCM, so their contents can't be read from the disk without
+// the key.
+//
+// A sealed file starts with a header: a magic string no plain AOF record
+// can start with, an 8-byte key id and a random 32-byte salt. The file is
+// encrypted with a key derived from the master key and the salt, so every
+// file has its own key. The rest of the file is a sequence of frames, one
+// per Write: a 4-byte big-endian length, then the sealed data. The frame
+// number is the nonce, so frames that are reordered or removed fail to
+// open. Only whole frames missing at the end go unnoticed, as a torn
+// write does in a plain file.
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// KeySize is the size of a master key in bytes: AES-256.
+const KeySize = 32
+
+// FrameOverhead is how many bytes a sealed file adds to every Write: the
+// frame length and the GCM tag.
+const FrameOverhead = 4 + 16
+
+const (
+	// magic starts every sealed file. Plain files start with a command
+	// name, never a NUL byte.
+	magic = "\x00RDGENC1"
+
+	saltSize   = 32
+	keyIDSize  = 8
+	headerSize = len(magic) + keyIDSize + saltSize
+
+	// maxFrame bounds the length read from a frame header, so a corrupt
+	// one can't make a reader allocate gigabytes.
+	maxFrame = 1 << 30
+
+	// commandTimeout bounds KeyFromCommand.
+	commandTimeout = 30 * time.Second
+)
+
+// Errors returned when reading sealed files.
+var (
+	ErrNoKey     = errors.New("file is encrypted and no encryption key is set")
+	ErrWrongKey  = errors.New("file is encrypted with a different key")
+	ErrCorrupt   = errors.New("encrypted frame is corrupt or out of order")
+	ErrTruncated = errors.New("encrypted file ends in a partial frame")
+)
+
+// ParseKey decodes a master key given as 64 hex digits or as base64.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		if key, err = base64.StdEncoding.DecodeString(s); err != nil {
+			key, err = base64.RawStdEncoding.DecodeString(s)
+		}
+	}
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, as hex or base64", KeySize)
+	}
+	return key, nil
+}
+
+// KeyFromCommand runs args, such as a KMS client decrypting a data key,
+// and parses what it prints with ParseKey. The command is run directly,
+// not through a shell.
+func KeyFromCommand(ctx context.Context, args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, errors.New("empty encryption key command")
+	}
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("encryption key command: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("encryption key command: %w", err)
+	}
+	return ParseKey(stdout.String())
+}
+
+// NewWriter writes the header of a sealed file to w and returns a writer
+// that seals every Write into one frame. key must be KeySize bytes. After
+// a failed Write the file may end in a partial frame, past which nothing
+// could be read back, so every later Write fails too.
+func NewWriter(w io.Writer, key []byte) (io.Writer, error) {
+	var salt [saltSize]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return nil, err
+	}
+	aead, err := fileCipher(key, salt[:])
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, keyID(key)...)
+	header = append(header, salt[:]...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &writer{w: w, aead: aead}, nil
+}
+
+type writer struct {
+	w    io.Writer
+	aead cipher.AEAD
+	seq  uint64
+	buf  []byte
+	err  error
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n := len(p) + w.aead.Overhead()
+	if n > maxFrame {
+		return 0, fmt.Errorf("write of %d bytes is too large to encrypt", len(p))
+	}
+	w.buf = binary.BigEndian.AppendUint32(w.buf[:0], uint32(n))
+	w.buf = w.aead.Seal(w.buf, nonce(w.seq), p, nil)
+	if _, err := w.w.Write(w.buf); err != nil {
+		w.err = err
+		return 0, err
+	}
+	w.seq++
+	return len(p), nil
+}
+
+// IsSealed reports whether r, positioned at the start of a file, holds a
+// sealed file. It does not consume anything.
+func IsSealed(r *bufio.Reader) bool {
+	b, _ := r.Peek(len(magic))
+	return string(b) == magic
+}
+
+// NewReader returns r itself if it holds a plain file, or a reader of the
+// plaintext of a sealed one. key may be nil when no key is configured; a
+// sealed file then fails with ErrNoKey. The returned reader fails with
+// ErrCorrupt or ErrTruncated after the last frame it could open.
+func NewReader(r *bufio.Reader, key []byte) (io.Reader, error) {
+	if !IsSealed(r) {
+		return r, nil
+	}
+	if key == nil {
+		return nil, ErrNoKey
+	}
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrTruncated
+	}
+	if !hmac.Equal(header[len(magic):len(magic)+keyIDSize], keyID(key)) {
+		return nil, ErrWrongKey
+	}
+	aead, err := fileCipher(key, header[len(magic)+keyIDSize:])
+	if err != nil {
+		return nil, err
+	}
+	return &reader{r: r, aead: aead}, nil
+}
+
+type reader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	seq   uint64
+	frame []byte
+	plain []byte
+	rest  []byte // plaintext not returned yet
+	err   error
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.rest) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.next()
+	}
+	n := copy(p, r.rest)
+	r.rest = r.rest[n:]
+	return n, nil
+}
+
+// next opens the next frame into r.rest, or sets r.err.
+func (r *reader) next() {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r.r, hdr[:]); err != nil {
+		r.err = readErr(err)
+		return
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n < uint32(r.aead.Overhead()) || n > maxFrame {
+		r.err = ErrCorrupt
+		return
+	}
+	if cap(r.frame) < int(n) {
+		r.frame = make([]byte, n)
+	}
+	r.frame = r.frame[:n]
+	if _, err := io.ReadFull(r.r, r.frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		r.err = readErr(err)
+		return
+	}
+	plain, err := r.aead.Open(r.plain[:0], nonce(r.seq), r.frame, nil)
+	if err != nil {
+		r.err = ErrCorrupt
+		return
+	}
+	r.seq++
+	r.plain = plain
+	r.rest = plain
+}
+
+// readErr maps an error reading a frame: EOF between frames is the end of
+// the file, EOF inside one a torn write.
+func readErr(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return err
+}
+
+// fileCipher returns the AES-GCM cipher of the file with the given salt.
+func fileCipher(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes", KeySize)
+	}
+	block, err := aes.NewCipher(mac(key, salt))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyID identifies key in file headers without revealing it, so a file
+// sealed with another key fails with ErrWrongKey rather than ErrCorrupt.
+func keyID(key []byte) []byte {
+	return mac(key, []byte("redigo key id"))[:keyIDSize]
+}
+
+func mac(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// nonce returns the GCM nonce of frame seq.
+func nonce(seq uint64) []byte {
+	var n [12]byte
+	binary.BigEndian.PutUint64(n[4:], seq)
+	return n[:]
+}
@@ -0,0 +1,134 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+var records = []string{"SET a 1\n", "SET b 2\n", "DEL a\n"}
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+// seal returns records sealed with key, one frame each, and the offset at
+// which every frame starts.
+func seal(t *testing.T, key []byte) ([]byte, []int) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offsets []int
+	for _, rec := range records {
+		offsets = append(offsets, buf.Len())
+		if _, err := io.WriteString(w, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes(), offsets
+}
+
+// open reads the plaintext of file back and returns what it could read
+// before the error.
+func open(file, key []byte) (string, error) {
+	r, err := NewReader(bufio.NewReader(bytes.NewReader(file)), key)
+	if err != nil {
+		return "", err
+	}
+	got, err := io.ReadAll(r)
+	return string(got), err
+}
+
+func TestRoundTrip(t *testing.T) {
+	key := testKey(1)
+	file, _ := seal(t, key)
+	if !IsSealed(bufio.NewReader(bytes.NewReader(file))) {
+		t.Fatal("sealed file not recognised")
+	}
+	if bytes.Contains(file, []byte("SET")) {
+		t.Error("sealed file contains plaintext")
+	}
+	want := records[0] + records[1] + records[2]
+	if got, err := open(file, key); err != nil || got != want {
+		t.Errorf("open = %q, %v; want %q", got, err, want)
+	}
+	if got, err := open([]byte(want), nil); err != nil || got != want {
+		t.Errorf("open plain file = %q, %v; want it unchanged", got, err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	file, _ := seal(t, testKey(1))
+	tests := []struct {
+		name string
+		key  []byte
+		err  error
+	}{
+		{"no key", nil, ErrNoKey},
+		{"wrong key", testKey(2), ErrWrongKey},
+		{"one bit off", append(testKey(1)[:KeySize-1], 0), ErrWrongKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := open(file, tt.key); !errors.Is(err, tt.err) || got != "" {
+				t.Errorf("open = %q, %v; want nothing and %v", got, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDamage(t *testing.T) {
+	key := testKey(1)
+	file, at := seal(t, key)
+	frame := func(i int) []byte {
+		end := len(file)
+		if i+1 < len(at) {
+			end = at[i+1]
+		}
+		return file[at[i]:end]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	flip := func(off int) []byte {
+		b := bytes.Clone(file)
+		b[off] ^= 0x80
+		return b
+	}
+	header := file[:at[0]]
+
+	tests := []struct {
+		name string
+		file []byte
+		want string // plaintext read before the error
+		err  error
+	}{
+		{"tampered key id", flip(len(magic)), "", ErrWrongKey},
+		{"tampered salt", flip(len(magic) + keyIDSize), "", ErrCorrupt},
+		{"tampered first frame", flip(at[0] + 6), "", ErrCorrupt},
+		{"tampered last frame", flip(len(file) - 1), records[0] + records[1], ErrCorrupt},
+		{"tampered length", flip(at[1]), records[0], ErrCorrupt},
+		{"frames swapped", join(header, frame(1), frame(0), frame(2)), "", ErrCorrupt},
+		{"frame removed", join(header, frame(0), frame(2)), records[0], ErrCorrupt},
+		{"frame repeated", join(header, frame(0), frame(0)), records[0], ErrCorrupt},
+		{"truncated header", file[:headerSize-1], "", ErrTruncated},
+		{"truncated frame length", file[:at[1]+2], records[0], ErrTruncated},
+		{"truncated frame data", file[:at[2]+FrameOverhead], records[0] + records[1], ErrTruncated},
+		{"truncated tag", file[:len(file)-1], records[0] + records[1], ErrTruncated},
+		// Whole frames missing at the end look like a shorter file.
+		{"last frame missing", file[:at[2]], records[0] + records[1], nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := open(tt.file, key)
+			if !errors.Is(err, tt.err) || got != tt.want {
+				t.Errorf("open = %q, %v; want %q, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
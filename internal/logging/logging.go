@@ -0,0 +1,70 @@
+// Package logging builds the slog.Logger used by the RediGo binaries from
+// command-line settings: level, text or JSON output, and an optional log
+// file that is rotated by size.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Options selects where and how to log.
+type Options struct {
+	Level  string // debug, info, warn or error
+	Format string // text or json
+	// File, if set, receives the logs instead of stderr. It is rotated
+	// when it grows past MaxSize bytes, keeping MaxBackups old files
+	// (File.1 being the newest). MaxSize 0 disables rotation.
+	File       string
+	MaxSize    int64
+	MaxBackups int
+}
+
+// New returns a logger for opts. The closer releases the log file, if any.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	var out io.Writer = os.Stderr
+	var closer io.Closer = nopCloser{}
+	if opts.File != "" {
+		f, err := OpenRotatingFile(opts.File, opts.MaxSize, opts.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		out, closer = f, f
+	}
+	ho := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		h = slog.NewTextHandler(out, ho)
+	case "json":
+		h = slog.NewJSONHandler(out, ho)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q (want text or json)", opts.Format)
+	}
+	return slog.New(h), closer, nil
+}
+
+// ParseLevel parses debug, info, warn or error (case-insensitive); empty
+// means info.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+	}
+	return l, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,109 @@
+// Package promtext writes metrics in the Prometheus text exposition format
+// (version 0.0.4), which is all a scraper needs, without pulling in the
+// Prometheus client library.
+package promtext
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the Content-Type of the exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Writer emits metric families. Call Family once per metric name, then
+// Sample (or Histogram) for each of its series.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a Writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Family writes the HELP and TYPE lines for name. typ is counter, gauge or
+// histogram.
+func (p *Writer) Family(name, typ, help string) {
+	fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+// Sample writes one series. labels are name/value pairs.
+func (p *Writer) Sample(name string, value float64, labels ...string) {
+	fmt.Fprintf(p.w, "%s%s %s\n", name, formatLabels(labels), formatFloat(value))
+}
+
+// Gauge writes a family with a single unlabelled series.
+func (p *Writer) Gauge(name, help string, value float64) {
+	p.Family(name, "gauge", help)
+	p.Sample(name, value)
+}
+
+// Counter writes a family with a single unlabelled series.
+func (p *Writer) Counter(name, help string, value float64) {
+	p.Family(name, "counter", help)
+	p.Sample(name, value)
+}
+
+// Summary writes the series of one summary: quantiles (between 0 and 1)
+// and their values, then the sum and count of observations.
+func (p *Writer) Summary(name string, quantiles, values []float64, sum float64, count uint64, labels ...string) {
+	for i, q := range quantiles {
+		p.Sample(name, values[i], append(labels[:len(labels):len(labels)], "quantile", formatFloat(q))...)
+	}
+	p.Sample(name+"_sum", sum, labels...)
+	p.Sample(name+"_count", float64(count), labels...)
+}
+
+// Histogram writes the series of one histogram: bounds are the bucket upper
+// bounds in increasing order and counts the cumulative count for each.
+func (p *Writer) Histogram(name string, bounds []float64, counts []uint64, sum float64, count uint64, labels ...string) {
+	for i, le := range bounds {
+		p.Sample(name+"_bucket", float64(counts[i]), append(labels[:len(labels):len(labels)], "le", formatFloat(le))...)
+	}
+	p.Sample(name+"_bucket", float64(count), append(labels[:len(labels):len(labels)], "le", "+Inf")...)
+	p.Sample(name+"_sum", sum, labels...)
+	p.Sample(name+"_count", float64(count), labels...)
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeValue(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	valueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeValue(s string) string { return valueEscaper.Replace(s) }
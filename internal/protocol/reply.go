@@ -0,0 +1,137 @@
+// Package protocol formats command replies. The primary and the replica
+// both reply through a Reply, so a command's output looks the same on
+// either server, whichever wire format the connection speaks.
+package protocol
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Reply writes the parts of a command reply. Write errors are not
+// returned; like the rest of the reply path they surface when the
+// connection is flushed or next read. A Reply is not safe for concurrent
+// use.
+type Reply interface {
+	// WriteSimple writes a status reply such as OK.
+	WriteSimple(s string)
+	// WriteError writes an error reply; msg starts with the error code,
+	// e.g. "ERR syntax error".
+	WriteError(msg string)
+	// WriteInt writes an integer reply.
+	WriteInt(n int64)
+	// WriteBulk writes a string value.
+	WriteBulk(s string)
+	// WriteArray writes a list of strings, such as key names.
+	WriteArray(items []string)
+	// WriteNil writes the reply for a missing value.
+	WriteNil()
+}
+
+// Errorf writes an error reply formatted like fmt.Sprintf.
+func Errorf(r Reply, format string, args ...any) {
+	r.WriteError(fmt.Sprintf(format, args...))
+}
+
+// NewText returns a Reply in the line-based text format the servers speak
+// to redigo-cli and telnet: +OK, -ERR ..., :1, "value", (nil), and one
+// line per array item, or (empty).
+func NewText(w io.Writer) Reply {
+	return &text{writer{w: w}}
+}
+
+type text struct {
+	writer
+}
+
+func (t *text) WriteSimple(s string)  { t.line("+", s, "") }
+func (t *text) WriteError(msg string) { t.line("-", msg, "") }
+func (t *text) WriteInt(n int64)      { t.int(':', n) }
+func (t *text) WriteBulk(s string)    { t.line("\"", s, "\"") }
+func (t *text) WriteNil()             { t.line("(nil)", "", "") }
+
+func (t *text) WriteArray(items []string) {
+	if len(items) == 0 {
+		t.line("(empty)", "", "")
+		return
+	}
+	for _, item := range items {
+		t.buf = append(append(t.buf, item...), "\r\n"...)
+	}
+	t.flush()
+}
+
+// NewRESP returns a Reply in RESP2, the Redis serialization protocol, so
+// Redis client libraries can parse the output.
+func NewRESP(w io.Writer) Reply {
+	return &resp{writer{w: w}}
+}
+
+type resp struct {
+	writer
+}
+
+func (r *resp) WriteSimple(s string)  { r.line("+", s, "") }
+func (r *resp) WriteError(msg string) { r.line("-", msg, "") }
+func (r *resp) WriteInt(n int64)      { r.int(':', n) }
+func (r *resp) WriteNil()             { r.line("$-1", "", "") }
+
+func (r *resp) WriteBulk(s string) {
+	r.bulk(s)
+	r.flush()
+}
+
+func (r *resp) WriteArray(items []string) {
+	r.buf = append(r.buf, '*')
+	r.buf = strconv.AppendInt(r.buf, int64(len(items)), 10)
+	r.buf = append(r.buf, "\r\n"...)
+	for _, item := range items {
+		r.bulk(item)
+	}
+	r.flush()
+}
+
+func (r *resp) bulk(s string) {
+	r.buf = append(r.buf, '$')
+	r.buf = strconv.AppendInt(r.buf, int64(len(s)), 10)
+	r.buf = append(r.buf, "\r\n"...)
+	r.buf = append(r.buf, s...)
+	r.buf = append(r.buf, "\r\n"...)
+}
+
+// maxBuffer is the largest reply buffer kept for the next reply.
+const maxBuffer = 64 << 10
+
+// writer builds each reply in a buffer it reuses, so replies don't
+// allocate once the buffer has grown.
+type writer struct {
+	w   io.Writer
+	buf []byte
+}
+
+// line writes prefix, s and suffix as one line.
+func (w *writer) line(prefix, s, suffix string) {
+	w.buf = append(w.buf, prefix...)
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, suffix...)
+	w.buf = append(w.buf, "\r\n"...)
+	w.flush()
+}
+
+// int writes n as a line after the type byte.
+func (w *writer) int(typ byte, n int64) {
+	w.buf = append(w.buf, typ)
+	w.buf = strconv.AppendInt(w.buf, n, 10)
+	w.buf = append(w.buf, "\r\n"...)
+	w.flush()
+}
+
+// flush hands the buffered reply to the underlying writer.
+func (w *writer) flush() {
+	w.w.Write(w.buf)
+	w.buf = w.buf[:0]
+	if cap(w.buf) > maxBuffer {
+		w.buf = nil
+	}
+}
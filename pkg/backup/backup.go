@@ -0,0 +1,237 @@
+// Package backup ships snapshots off the box, to S3-compatible object
+// storage, and fetches them back, giving a single node a disaster-recovery
+// path:
+//
+//	b, prefix, err := backup.ParseURL("s3://my-bucket/redigo/")
+//	if err != nil { ... }
+//	b.AccessKey, b.SecretKey = ...
+//	up := backup.Start(b, prefix, backup.Options{Keep: 7})
+//	srv.OnSave(up.Notify)
+//	defer up.Close(context.Background())
+//
+// Each upload is a new object named after the snapshot's time, so older
+// ones stay available until the retention policy (Options.Keep and
+// MaxAge) deletes them. Restore downloads the newest snapshot, or a given
+// one, and DownloadURL a plain URL, before the server loads its data.
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for zero Options fields.
+const (
+	DefaultMaxRetries = 5
+
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Snapshot objects are named prefix + objectPrefix + time + objectSuffix,
+// so sorting keys sorts snapshots by age.
+const (
+	objectPrefix = "redigo-"
+	objectSuffix = ".snapshot"
+	timeLayout   = "20060102T150405Z"
+)
+
+// Options tunes an Uploader. Zero fields take the defaults above.
+type Options struct {
+	// Keep is how many snapshots to keep in the bucket; older ones are
+	// deleted after each upload. 0 keeps them all.
+	Keep int
+
+	// MaxAge deletes snapshots older than this after each upload, while
+	// always keeping the newest. 0 disables it.
+	MaxAge time.Duration
+
+	// MaxRetries is how many times a failed upload is retried before it
+	// is given up; negative retries until a newer snapshot is saved.
+	MaxRetries int
+
+	// Logger receives upload results; nil uses slog.Default.
+	Logger *slog.Logger
+}
+
+// upload is a saved snapshot waiting to be uploaded.
+type upload struct {
+	path string
+	at   time.Time
+}
+
+// Uploader uploads snapshots in the background, one at a time. A snapshot
+// saved while another is uploading waits; if several wait, only the newest
+// is uploaded.
+type Uploader struct {
+	bucket *Bucket
+	prefix string
+	opts   Options
+
+	mu      sync.Mutex
+	pending *upload
+	wake    chan struct{} // signalled when pending is set
+	closed  bool
+	done    chan struct{} // closed once the upload loop has returned
+	cancel  context.CancelFunc
+}
+
+// Start returns an Uploader storing snapshots under prefix in b.
+func Start(b *Bucket, prefix string, opts Options) *Uploader {
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	u := &Uploader{
+		bucket: b,
+		prefix: prefix,
+		opts:   opts,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	go u.run(ctx)
+	return u
+}
+
+// Notify queues the snapshot at path, taken at at, for upload. It never
+// blocks, so it can be passed to server.Server.OnSave directly.
+func (u *Uploader) Notify(path string, at time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return
+	}
+	u.pending = &upload{path: path, at: at}
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close waits for the queued upload, if any, to finish, or gives it up
+// when ctx is done.
+func (u *Uploader) Close(ctx context.Context) error {
+	u.mu.Lock()
+	if !u.closed {
+		u.closed = true
+		close(u.wake)
+	}
+	u.mu.Unlock()
+	select {
+	case <-u.done:
+		return nil
+	case <-ctx.Done():
+		u.cancel()
+		<-u.done
+		return ctx.Err()
+	}
+}
+
+func (u *Uploader) run(ctx context.Context) {
+	defer close(u.done)
+	for range u.wake {
+		u.mu.Lock()
+		up := u.pending
+		u.pending = nil
+		u.mu.Unlock()
+		if up != nil {
+			u.deliver(ctx, up)
+		}
+	}
+}
+
+// deliver uploads up and applies the retention policy, retrying with
+// exponential backoff. A newer snapshot queued meanwhile replaces up.
+func (u *Uploader) deliver(ctx context.Context, up *upload) {
+	backoff := minBackoff
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		key, err := u.Upload(ctx, up.path, up.at)
+		if err == nil {
+			u.opts.Logger.Info("backup: snapshot uploaded", "bucket", u.bucket.Name, "key", key, "duration", time.Since(start))
+			return
+		}
+		if ctx.Err() != nil || (u.opts.MaxRetries >= 0 && attempt >= u.opts.MaxRetries) {
+			u.opts.Logger.Error("backup: giving up on snapshot upload", "path", up.path, "err", err)
+			return
+		}
+		u.opts.Logger.Warn("backup: snapshot upload failed, retrying", "path", up.path, "in", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		u.mu.Lock()
+		if u.pending != nil {
+			up, u.pending = u.pending, nil
+		}
+		u.mu.Unlock()
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Upload uploads the snapshot at path, taken at at, and then deletes the
+// snapshots the retention policy no longer keeps. It returns the new
+// object's key.
+func (u *Uploader) Upload(ctx context.Context, path string, at time.Time) (string, error) {
+	key := u.prefix + objectPrefix + at.UTC().Format(timeLayout) + objectSuffix
+	if err := u.bucket.Upload(ctx, key, path); err != nil {
+		return "", err
+	}
+	if u.opts.Keep <= 0 && u.opts.MaxAge <= 0 {
+		return key, nil
+	}
+	snaps, err := snapshots(ctx, u.bucket, u.prefix)
+	if err != nil {
+		u.opts.Logger.Warn("backup: listing snapshots for retention failed", "err", err)
+		return key, nil
+	}
+	// snaps is oldest first; the newest is never deleted.
+	for i, obj := range snaps[:len(snaps)-1] {
+		tooMany := u.opts.Keep > 0 && len(snaps)-i > u.opts.Keep
+		tooOld := u.opts.MaxAge > 0 && snapshotTime(obj.Key).Before(at.Add(-u.opts.MaxAge))
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := u.bucket.Delete(ctx, obj.Key); err != nil {
+			u.opts.Logger.Warn("backup: deleting old snapshot failed", "key", obj.Key, "err", err)
+		}
+	}
+	return key, nil
+}
+
+// snapshots lists the snapshots under prefix, oldest first.
+func snapshots(ctx context.Context, b *Bucket, prefix string) ([]Object, error) {
+	objs, err := b.List(ctx, prefix+objectPrefix)
+	if err != nil {
+		return nil, err
+	}
+	res := objs[:0]
+	for _, obj := range objs {
+		if !snapshotTime(obj.Key).IsZero() {
+			res = append(res, obj)
+		}
+	}
+	return res, nil
+}
+
+// snapshotTime returns the time in a snapshot object's key, or the zero
+// time for other objects.
+func snapshotTime(key string) time.Time {
+	i := strings.LastIndex(key, objectPrefix)
+	if i < 0 || !strings.HasSuffix(key, objectSuffix) {
+		return time.Time{}
+	}
+	t, err := time.Parse(timeLayout, key[i+len(objectPrefix):len(key)-len(objectSuffix)])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
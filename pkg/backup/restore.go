@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrNoSnapshot is returned by Restore when a prefix holds no snapshot.
+var ErrNoSnapshot = errors.New("backup: no snapshot found")
+
+// Restore downloads the object at key in b to path. A key ending in "/",
+// or no key, restores the newest snapshot uploaded under that prefix. It
+// returns the key restored. path is replaced only once the download is
+// complete.
+func Restore(ctx context.Context, b *Bucket, key, path string) (string, error) {
+	if key == "" || strings.HasSuffix(key, "/") {
+		snaps, err := snapshots(ctx, b, key)
+		if err != nil {
+			return "", err
+		}
+		if len(snaps) == 0 {
+			return "", ErrNoSnapshot
+		}
+		key = snaps[len(snaps)-1].Key
+	}
+	return key, b.Download(ctx, key, path)
+}
+
+// DownloadURL fetches rawURL, such as a presigned link, to path with a
+// plain GET. Like Restore, it replaces path only once the download is
+// complete.
+func DownloadURL(ctx context.Context, rawURL, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("backup: GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return writeFile(path, resp.Body)
+}
+
+// writeFile copies r to a temporary file and renames it over path.
+func writeFile(path string, r io.Reader) error {
+	tmp := path + ".download"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) // no-op after a successful rename
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
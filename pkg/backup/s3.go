@@ -0,0 +1,305 @@
+package backup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bucket is a bucket in an S3-compatible object store: AWS S3, Google
+// Cloud Storage through its XML API with HMAC keys, MinIO and the like.
+// Requests are signed with AWS Signature Version 4 and address the bucket
+// by path, so any endpoint works without DNS set up for the bucket.
+type Bucket struct {
+	Endpoint string // e.g. https://s3.us-east-1.amazonaws.com
+	Region   string // signing region, e.g. us-east-1 ("auto" for GCS)
+	Name     string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // optional, for temporary credentials
+
+	Client *http.Client // nil uses http.DefaultClient
+}
+
+// Object describes an object returned by List.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ParseURL parses a location such as s3://bucket/prefix or
+// gs://bucket/prefix into a Bucket with the store's default endpoint and
+// region, and the key or prefix after the bucket name. Credentials are
+// left for the caller to fill in.
+func ParseURL(rawURL string) (*Bucket, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("backup: %q has no bucket name", rawURL)
+	}
+	b := &Bucket{Name: u.Host}
+	switch u.Scheme {
+	case "s3":
+		b.Endpoint, b.Region = "https://s3.us-east-1.amazonaws.com", "us-east-1"
+	case "gs":
+		b.Endpoint, b.Region = "https://storage.googleapis.com", "auto"
+	default:
+		return nil, "", fmt.Errorf("backup: unsupported scheme %q, expected s3 or gs", u.Scheme)
+	}
+	return b, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// Upload stores the file at path as key. The file is read twice, once to
+// sign its checksum and once to send it.
+func (b *Bucket) Upload(ctx context.Context, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	req, err := b.request(ctx, http.MethodPut, key, nil, io.NopCloser(f))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := b.do(req, hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Download writes the object at key to path, through a temporary file
+// renamed over path, so a failed download leaves path untouched.
+func (b *Bucket) Download(ctx context.Context, key, path string) error {
+	req, err := b.request(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(req, emptyHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return writeFile(path, resp.Body)
+}
+
+// Delete removes the object at key.
+func (b *Bucket) Delete(ctx context.Context, key string) error {
+	req, err := b.request(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(req, emptyHash)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// List returns the objects whose keys start with prefix, sorted by key.
+func (b *Bucket) List(ctx context.Context, prefix string) ([]Object, error) {
+	var res []Object
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		req, err := b.request(ctx, http.MethodGet, "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := b.do(req, emptyHash)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("backup: listing %s: %w", b.Name, err)
+		}
+		for _, c := range page.Contents {
+			res = append(res, Object{Key: c.Key, Size: c.Size, LastModified: c.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res, nil
+}
+
+// emptyHash is the SHA-256 of an empty payload.
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// request builds a request for key in the bucket, or for the bucket itself
+// when key is empty.
+func (b *Bucket) request(ctx context.Context, method, key string, query url.Values, body io.ReadCloser) (*http.Request, error) {
+	u, err := url.Parse(strings.TrimSuffix(b.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	u.Path += "/" + b.Name
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	return req, nil
+}
+
+// do signs and sends req, whose payload has the given SHA-256, and turns
+// any status other than 2xx into an error.
+func (b *Bucket) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	b.sign(req, payloadHash, time.Now())
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("backup: %s %s: %s %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to req, covering the host
+// and every header already set.
+func (b *Bucket) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.SessionToken)
+	}
+
+	names := []string{"host"}
+	headers := map[string]string{"host": req.URL.Host}
+	for name, vals := range req.Header {
+		lower := strings.ToLower(name)
+		names = append(names, lower)
+		headers[lower] = strings.TrimSpace(strings.Join(vals, ","))
+	}
+	sort.Strings(names)
+	var canon strings.Builder
+	for _, name := range names {
+		canon.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canon.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + b.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+b.SecretKey), day)
+	key = hmacSHA256(key, b.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+b.AccessKey+"/"+scope+", SignedHeaders="+signed+", Signature="+sig)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// escapePath escapes every byte of p except unreserved characters and
+// slashes, as SigV4 canonical URIs require.
+func escapePath(p string) string {
+	return strings.ReplaceAll(escape(p), "%2F", "/")
+}
+
+// canonicalQuery encodes q sorted by name, with SigV4 escaping.
+func canonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(q))
+	for name := range q {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, v := range q[name] {
+			parts = append(parts, escape(name)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escape percent-encodes everything but the unreserved characters
+// A-Z a-z 0-9 - _ . ~, unlike url.QueryEscape, which writes spaces as +.
+func escape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
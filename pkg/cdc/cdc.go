@@ -0,0 +1,292 @@
+// Package cdc exports the changes made to a store (change data capture) to
+// a pluggable Sink, so downstream systems can mirror or index the data:
+//
+//	sink, err := cdc.OpenFileSink("changes.ndjson")
+//	if err != nil { ... }
+//	exp := cdc.Start(st, sink, cdc.Options{})
+//	defer exp.Close(context.Background())
+//
+// WriteBehind adapts a Backend, such as an SQL table, into a Sink, so the
+// store can act as a write-behind cache in front of a system of record.
+//
+// Changes come from the store's mutation hooks (see store.OnSet), so every
+// write is exported whichever front end made it. Delivery is asynchronous
+// and at least once: a batch that fails is retried with backoff. Changes
+// are lost when the store's event queue overflows (a hook falling behind a
+// burst of writes), when the exporter's buffer fills up or when a batch
+// runs out of retries. The sink is then out of sync, so the exporter
+// stops: it logs an error, drops everything from then on and Err reports
+// ErrGap until the sink has been rebuilt and a new exporter started.
+package cdc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// Defaults for zero Options fields.
+const (
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = time.Second
+	DefaultBufferSize    = 10000
+	DefaultMaxRetries    = 5
+
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 10 * time.Second
+)
+
+// ErrGap is reported by an Exporter that has lost changes and stopped.
+var ErrGap = errors.New("cdc: changes were lost, the sink is out of sync")
+
+// Change is one exported mutation. Op is "set", "delete", "expire",
+// "evict" or "flush" (every key was removed, Key is empty); Value and
+// ExpiresAt are the new entry's for "set" and the removed entry's
+// otherwise.
+type Change struct {
+	Op        string    `json:"op"`
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	ExpiresAt int64     `json:"expires_at,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Sink receives batches of changes, in the order they happened. Write is
+// called from a single goroutine; an error makes the exporter retry the
+// same batch. A Kafka producer, for example, implements Sink by sending
+// one message per change keyed by Change.Key.
+type Sink interface {
+	Write(ctx context.Context, changes []Change) error
+	Close() error
+}
+
+// Options tunes an Exporter. Zero fields take the defaults above.
+type Options struct {
+	// BatchSize is the most changes passed to one Sink.Write.
+	BatchSize int
+
+	// FlushInterval is how long a partial batch may wait for more changes.
+	FlushInterval time.Duration
+
+	// BufferSize is how many changes may wait for the sink; more are
+	// dropped, stopping the exporter, rather than slowing down writers.
+	BufferSize int
+
+	// MaxRetries is how many times a failed batch is retried before it is
+	// dropped, stopping the exporter; negative retries forever.
+	MaxRetries int
+
+	// Logger receives delivery failures; nil uses slog.Default.
+	Logger *slog.Logger
+}
+
+// Stats counts the changes an Exporter has handled.
+type Stats struct {
+	Exported int64 // written to the sink
+	Dropped  int64 // lost to a full buffer, a batch out of retries or a stop
+	Failures int64 // failed Sink.Write calls
+
+	// EventsDropped counts the store events lost before they reached the
+	// exporter (see store.Stats.EventsDropped).
+	EventsDropped int64
+}
+
+// Exporter delivers a store's changes to a Sink until closed.
+type Exporter struct {
+	store *store.Store
+	sink  Sink
+	opts  Options
+
+	mu     sync.RWMutex // guards closed against queue sends
+	closed bool
+	queue  chan Change
+	done   chan struct{} // closed once the delivery loop has returned
+	cancel context.CancelFunc
+
+	exported atomic.Int64
+	dropped  atomic.Int64
+	failures atomic.Int64
+
+	eventsDropped atomic.Int64
+	failed        atomic.Bool // set once changes were lost; see fail
+}
+
+// Start registers the exporter's hooks on s and starts delivering changes
+// to sink.
+func Start(s *store.Store, sink Sink, opts Options) *Exporter {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Exporter{
+		store:  s,
+		sink:   sink,
+		opts:   opts,
+		queue:  make(chan Change, opts.BufferSize),
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+	s.OnSet(e.add)
+	s.OnDelete(e.add)
+	s.OnExpire(e.add)
+	s.OnEvict(e.add)
+	s.OnFlush(e.add)
+	s.OnGap(e.gap)
+	go e.run(ctx)
+	return e
+}
+
+// add queues ev; it runs on the store's event goroutine and never blocks.
+// Store hooks cannot be removed, so after Close it does nothing.
+func (e *Exporter) add(ev store.Event) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return
+	}
+	if e.failed.Load() {
+		e.dropped.Add(1)
+		return
+	}
+	c := Change{Op: ev.Type.String(), Key: ev.Key, Value: ev.Value, ExpiresAt: ev.ExpiresAt, Time: ev.Time}
+	select {
+	case e.queue <- c:
+	default:
+		e.dropped.Add(1)
+		e.fail("buffer full")
+	}
+}
+
+// gap is the store's report of events that never reached add.
+func (e *Exporter) gap(ev store.Event) {
+	e.eventsDropped.Add(ev.Dropped)
+	e.fail("store events dropped")
+}
+
+// fail stops the exporter after changes were lost: exporting past the gap
+// would leave the sink silently diverged from the store.
+func (e *Exporter) fail(reason string) {
+	if e.failed.CompareAndSwap(false, true) {
+		e.opts.Logger.Error("cdc: changes lost, exporter stopped; the sink must be resynced", "reason", reason)
+	}
+}
+
+// Err returns ErrGap once the exporter has lost changes and stopped, and
+// nil while the sink is in sync.
+func (e *Exporter) Err() error {
+	if e.failed.Load() {
+		return ErrGap
+	}
+	return nil
+}
+
+func (e *Exporter) run(ctx context.Context) {
+	defer close(e.done)
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]Change, 0, e.opts.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			if e.failed.Load() {
+				e.dropped.Add(int64(len(batch)))
+			} else {
+				e.deliver(ctx, batch)
+			}
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case c, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, c)
+			if len(batch) == e.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// deliver writes batch to the sink, retrying with exponential backoff.
+func (e *Exporter) deliver(ctx context.Context, batch []Change) {
+	backoff := minBackoff
+	for attempt := 0; ; attempt++ {
+		err := e.sink.Write(ctx, batch)
+		if err == nil {
+			e.exported.Add(int64(len(batch)))
+			return
+		}
+		e.failures.Add(1)
+		if ctx.Err() != nil || (e.opts.MaxRetries >= 0 && attempt >= e.opts.MaxRetries) {
+			e.opts.Logger.Error("cdc: dropping changes", "count", len(batch), "err", err)
+			e.dropped.Add(int64(len(batch)))
+			e.fail("sink write failed")
+			return
+		}
+		e.opts.Logger.Warn("cdc: sink write failed, retrying", "count", len(batch), "in", backoff, "err", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Stats returns the exporter's counters.
+func (e *Exporter) Stats() Stats {
+	return Stats{
+		Exported:      e.exported.Load(),
+		Dropped:       e.dropped.Load(),
+		Failures:      e.failures.Load(),
+		EventsDropped: e.eventsDropped.Load(),
+	}
+}
+
+// Close stops exporting, delivers the changes made so far (giving up on a
+// failing sink once ctx is done) and closes the sink. It returns ErrGap if
+// changes were lost and the sink's own error otherwise.
+func (e *Exporter) Close(ctx context.Context) error {
+	e.store.FlushEvents()
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return errors.New("cdc: exporter already closed")
+	}
+	e.closed = true
+	close(e.queue)
+	e.mu.Unlock()
+
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		e.cancel()
+		<-e.done
+	}
+	e.cancel()
+	if err := e.sink.Close(); err != nil {
+		return err
+	}
+	return e.Err()
+}
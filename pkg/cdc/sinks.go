@@ -0,0 +1,98 @@
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// encode renders changes as newline-delimited JSON.
+func encode(changes []Change) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, c := range changes {
+		if err := enc.Encode(c); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// FileSink appends changes to a file as newline-delimited JSON, one object
+// per change.
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFileSink opens path for appending, creating it if needed.
+func OpenFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f}, nil
+}
+
+// Write appends changes in a single write, so a batch is never interleaved
+// with another writer's output.
+func (s *FileSink) Write(_ context.Context, changes []Change) error {
+	data, err := encode(changes)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(data)
+	return err
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// WebhookSink POSTs each batch to a URL as newline-delimited JSON
+// (Content-Type application/x-ndjson). Any status other than 2xx fails
+// the batch, so it is retried.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client // nil uses http.DefaultClient
+}
+
+// Write posts changes to s.URL.
+func (s *WebhookSink) Write(ctx context.Context, changes []Change) error {
+	data, err := encode(changes)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+// Close does nothing; it is part of the Sink interface.
+func (s *WebhookSink) Close() error { return nil }
@@ -0,0 +1,119 @@
+package cdc
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Backend is a system of record kept up to date behind the store, making
+// RediGo a write-behind cache in front of it. Put and Delete must be
+// idempotent: a batch that fails part way is retried from the start.
+type Backend interface {
+	Put(ctx context.Context, key, value string, expiresAt int64) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Flusher is implemented by a Backend that can remove every key at once,
+// which WriteBehind uses to mirror a FLUSHALL. For a Backend without it the
+// flush stays in the cache, as if every key had been evicted.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// WriteBehind returns a Sink that applies changes to b. Only writes,
+// deletions and flushes reach the backend: keys leaving the cache because
+// they expired or were evicted are still valid in the system of record.
+// Within a batch only the last change to each key is applied.
+func WriteBehind(b Backend) Sink {
+	return writeBehind{b}
+}
+
+type writeBehind struct {
+	b Backend
+}
+
+func (w writeBehind) Write(ctx context.Context, changes []Change) error {
+	for _, c := range coalesce(changes) {
+		var err error
+		switch c.Op {
+		case "set":
+			err = w.b.Put(ctx, c.Key, c.Value, c.ExpiresAt)
+		case "delete":
+			err = w.b.Delete(ctx, c.Key)
+		case "flush":
+			if f, ok := w.b.(Flusher); ok {
+				err = f.Flush(ctx)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w writeBehind) Close() error { return nil }
+
+// coalesce keeps the last set or delete of each key, in the order of those
+// last changes. A flush makes everything before it moot, so the last flush
+// comes first and only what follows it is kept.
+func coalesce(changes []Change) []Change {
+	var res []Change
+	for i := len(changes) - 1; i >= 0; i-- {
+		if changes[i].Op == "flush" {
+			res = append(res, changes[i])
+			changes = changes[i+1:]
+			break
+		}
+	}
+	last := make(map[string]int, len(changes))
+	for i, c := range changes {
+		if c.Op == "set" || c.Op == "delete" {
+			last[c.Key] = i
+		}
+	}
+	for i, c := range changes {
+		if j, ok := last[c.Key]; ok && j == i {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// SQLBackend writes changes to a database/sql table with the statements
+// given, so any driver and dialect can be used. PutSQL takes the key, the
+// value and the expiry (unix seconds, 0 for none) and must upsert, e.g.
+// for PostgreSQL or SQLite:
+//
+//	INSERT INTO kv (k, v, expires_at) VALUES ($1, $2, $3)
+//	ON CONFLICT (k) DO UPDATE SET v = excluded.v, expires_at = excluded.expires_at
+//
+// DeleteSQL takes the key, e.g. DELETE FROM kv WHERE k = $1. FlushSQL, if
+// set, takes no arguments and mirrors FLUSHALL, e.g. DELETE FROM kv.
+type SQLBackend struct {
+	DB        *sql.DB
+	PutSQL    string
+	DeleteSQL string
+	FlushSQL  string
+}
+
+// Put runs PutSQL.
+func (b *SQLBackend) Put(ctx context.Context, key, value string, expiresAt int64) error {
+	_, err := b.DB.ExecContext(ctx, b.PutSQL, key, value, expiresAt)
+	return err
+}
+
+// Delete runs DeleteSQL.
+func (b *SQLBackend) Delete(ctx context.Context, key string) error {
+	_, err := b.DB.ExecContext(ctx, b.DeleteSQL, key)
+	return err
+}
+
+// Flush runs FlushSQL, if set.
+func (b *SQLBackend) Flush(ctx context.Context) error {
+	if b.FlushSQL == "" {
+		return nil
+	}
+	_, err := b.DB.ExecContext(ctx, b.FlushSQL)
+	return err
+}
@@ -0,0 +1,279 @@
+// Package client is a Go client for the RediGo text protocol.
+//
+// A Client is safe for concurrent use. It keeps a pool of connections,
+// dials new ones on demand (reconnecting transparently after the server
+// restarts), honours context deadlines and cancellation, and supports
+// pipelining:
+//
+//	c := client.New(client.Options{Addr: "localhost:6380"})
+//	defer c.Close()
+//	if err := c.Set(ctx, "greeting", "hello"); err != nil { ... }
+//	v, err := c.Get(ctx, "greeting") // err == client.Nil if missing
+//
+// The protocol is line based: keys may not contain whitespace and values may
+// not contain line breaks or leading/trailing spaces.
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/discovery"
+)
+
+const (
+	defaultAddr        = "localhost:6380"
+	defaultPoolSize    = 10
+	defaultDialTimeout = 5 * time.Second
+)
+
+// ErrClosed is returned by calls made after Close.
+var ErrClosed = errors.New("redigo: client is closed")
+
+// Options configures a Client. Zero fields take the documented defaults.
+type Options struct {
+	// Addr is the server address; default "localhost:6380". It may also
+	// be a comma-separated seed list or "srv:" followed by a DNS SRV name,
+	// resolved again whenever a connection is dialled.
+	Addr string
+
+	// PoolSize caps the number of open connections; calls wait for a free
+	// one when all are busy. Default 10.
+	PoolSize int
+
+	// DialTimeout bounds connecting to the server; default 5s.
+	DialTimeout time.Duration
+
+	// Dial, if set, opens connections instead of dialling Addr, e.g. to
+	// reach an in-process server over net.Pipe (see pkg/redigotest).
+	Dial func(ctx context.Context) (net.Conn, error)
+
+	// MaxRetries is how many times a call is retried on a fresh connection
+	// when a pooled connection turns out to be broken before any reply was
+	// read (e.g. after a server restart). Default 1; negative disables.
+	// Only calls made up of read-only commands are retried: the server may
+	// have applied a write before the connection broke, and running it
+	// again could apply it twice (an INCR, say).
+	MaxRetries int
+
+	// Name, if set, is assigned to every connection with CLIENT SETNAME.
+	Name string
+
+	// Replicas are addresses of redigo-replica servers. When set, Get is
+	// load-balanced across the replicas whose last sync with the primary
+	// is at most MaxReplicaLag old (default 10s); other commands, and Get
+	// when no replica is fresh enough, go to Addr. Replicas only serve GET,
+	// so that is the only command routed to them.
+	Replicas      []string
+	MaxReplicaLag time.Duration
+}
+
+// Client is a pool of connections to one RediGo server.
+type Client struct {
+	opts Options
+
+	sem  chan struct{} // one token per open or opening connection
+	idle chan *conn
+
+	replicas *replicaSet // nil without Options.Replicas
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// New returns a client for opts.Addr. Connections are dialled lazily.
+func New(opts Options) *Client {
+	if opts.Addr == "" {
+		opts.Addr = defaultAddr
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = defaultPoolSize
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = defaultDialTimeout
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 1
+	}
+	if opts.MaxReplicaLag <= 0 {
+		opts.MaxReplicaLag = defaultMaxReplicaLag
+	}
+	c := &Client{
+		opts: opts,
+		sem:  make(chan struct{}, opts.PoolSize),
+		idle: make(chan *conn, opts.PoolSize),
+	}
+	if len(opts.Replicas) > 0 {
+		c.replicas = newReplicaSet(opts)
+	}
+	return c
+}
+
+// Close closes every idle connection; connections in use are closed when
+// their call returns. Later calls fail with ErrClosed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.replicas.close()
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// Do sends one command and returns its reply. Error replies from the server
+// are returned as the reply's Err, not as err; err reports transport and
+// argument problems.
+func (c *Client) Do(ctx context.Context, args ...string) (*Reply, error) {
+	replies, err := c.do(ctx, [][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// do sends cmds in a single round trip and reads one reply per command.
+func (c *Client) do(ctx context.Context, cmds [][]string) ([]*Reply, error) {
+	for _, args := range cmds {
+		if err := checkArgs(args); err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		cn, err := c.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		replies, err := cn.roundTrip(ctx, cmds)
+		c.put(cn, err == nil)
+		if err != nil && cn.reused && !cn.readAny && attempt < c.opts.MaxRetries && isBrokenConn(err) && retryable(cmds) {
+			continue
+		}
+		return replies, err
+	}
+}
+
+// readOnly lists the commands that are safe to send twice.
+var readOnly = map[string]bool{
+	"PING": true, "INFO": true, "COMMAND": true, "LASTSAVE": true, "HEALTHCHECK": true,
+	"GET": true, "EXISTS": true, "TTL": true, "KEYS": true, "SCAN": true, "DUMPALL": true,
+	"MEMORY": true, "HOTKEYS": true, "LCS": true, "WAITKEY": true, "JSON.GET": true,
+	"BF.EXISTS": true, "BF.MEXISTS": true, "CF.EXISTS": true, "CF.MEXISTS": true,
+	"CMS.QUERY": true, "TOPK.LIST": true, "TOPK.QUERY": true, "TS.GET": true, "TS.RANGE": true,
+}
+
+// retryable reports whether every command in cmds is read-only.
+func retryable(cmds [][]string) bool {
+	for _, args := range cmds {
+		if !readOnly[strings.ToUpper(args[0])] {
+			return false
+		}
+	}
+	return true
+}
+
+// get takes an idle connection or dials a new one.
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case c.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		<-c.sem
+		return nil, ErrClosed
+	}
+
+	select {
+	case cn := <-c.idle:
+		cn.reused = true
+		return cn, nil
+	default:
+	}
+	cn, err := c.dial(ctx)
+	if err != nil {
+		<-c.sem
+		return nil, err
+	}
+	return cn, nil
+}
+
+// put returns cn to the pool, or closes it if it is no longer usable.
+func (c *Client) put(cn *conn, ok bool) {
+	defer func() { <-c.sem }()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !ok || c.closed {
+		cn.Close()
+		return
+	}
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	var nc net.Conn
+	var err error
+	if c.opts.Dial != nil {
+		ctx, cancel := context.WithTimeout(ctx, c.opts.DialTimeout)
+		nc, err = c.opts.Dial(ctx)
+		cancel()
+	} else {
+		d := net.Dialer{Timeout: c.opts.DialTimeout}
+		nc, err = discovery.Dial(ctx, &d, c.opts.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cn := newConn(nc)
+	// The server greets every connection with a banner.
+	if _, err := cn.roundTrip(ctx, nil); err != nil {
+		cn.Close()
+		return nil, err
+	}
+	// Servers that predate CLIENT FRAMING answer with an error; replies
+	// are then read up to the next prompt.
+	replies, err := cn.roundTrip(ctx, [][]string{{"CLIENT", "FRAMING", "ON"}})
+	if err != nil {
+		cn.Close()
+		return nil, err
+	}
+	cn.framed = replies[0].Err() == nil
+	if c.opts.Name != "" {
+		replies, err := cn.roundTrip(ctx, [][]string{{"CLIENT", "SETNAME", c.opts.Name}})
+		if err == nil {
+			err = replies[0].Err()
+		}
+		if err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// isBrokenConn reports whether err means the connection was already dead
+// when it was used, so the command never reached the server.
+func isBrokenConn(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
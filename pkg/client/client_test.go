@@ -0,0 +1,76 @@
+package client_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/DakshBaxi/RediGo/pkg/client"
+	"github.com/DakshBaxi/RediGo/pkg/redigotest"
+)
+
+// A reply line starting with the prompt must not end the reply early.
+func TestPromptInReply(t *testing.T) {
+	srv := redigotest.Start(t, redigotest.Options{})
+	ctx := context.Background()
+	c := srv.Client()
+	for _, key := range []string{">", "a", ">"} {
+		if err := c.Set(ctx, key, "v"); err != nil {
+			t.Fatal(err)
+		}
+		c.Get(ctx, key)
+	}
+	stats, err := c.HotKeys(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0].Key != ">" && stats[1].Key != ">" {
+		t.Fatalf("HOTKEYS = %+v, want > and a", stats)
+	}
+	// the next reply is still in step
+	if v, err := c.Get(ctx, "a"); err != nil || v != "v" {
+		t.Fatalf("GET a = %q, %v", v, err)
+	}
+}
+
+// Only read-only calls are retried after their pooled connection was
+// closed by the server.
+func TestRetry(t *testing.T) {
+	srv := redigotest.Start(t, redigotest.Options{})
+	redigotest.AssertOK(t, srv.Do("SET", "n", "1"))
+
+	tests := []struct {
+		cmds  [][]string
+		retry bool
+	}{
+		{cmds: [][]string{{"GET", "n"}}, retry: true},
+		{cmds: [][]string{{"get", "n"}, {"EXISTS", "n"}}, retry: true},
+		{cmds: [][]string{{"INCR", "n"}}},
+		{cmds: [][]string{{"GET", "n"}, {"SET", "n", "1"}}},
+	}
+	for _, tt := range tests {
+		c := client.New(client.Options{Dial: srv.Dial})
+		ctx := context.Background()
+		id, err := c.Do(ctx, "CLIENT", "ID")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := id.Int()
+		redigotest.AssertInt(t, srv.Do("CLIENT", "KILL", "ID", strconv.FormatInt(n, 10)), 1)
+
+		p := c.Pipeline()
+		for _, args := range tt.cmds {
+			p.Do(args...)
+		}
+		_, err = p.Exec(ctx)
+		if tt.retry && err != nil {
+			t.Errorf("%v: %v, want a retry", tt.cmds, err)
+		}
+		if !tt.retry && err == nil {
+			t.Errorf("%v: retried, want the connection error", tt.cmds)
+		}
+		c.Close()
+	}
+	// the write was sent at most once
+	redigotest.AssertString(t, srv.Do("GET", "n"), "1")
+}
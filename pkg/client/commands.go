@@ -0,0 +1,371 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ping checks the connection; it returns "PONG".
+func (c *Client) Ping(ctx context.Context) (string, error) {
+	return c.str(ctx, "PING")
+}
+
+// Set stores value under key without a TTL.
+func (c *Client) Set(ctx context.Context, key, value string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	return c.ok(ctx, "SET", key, value)
+}
+
+// SetEX stores value under key with a TTL, rounded down to whole seconds.
+func (c *Client) SetEX(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	return c.ok(ctx, "SETEX", key, seconds(ttl), value)
+}
+
+// Get returns the value of key, or Nil if it does not exist. With
+// Options.Replicas it may be served by a replica.
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return c.readString(ctx, "GET", key)
+}
+
+// WaitKey blocks until key is written and returns its new value, or Nil
+// once timeout has passed (0 waits forever). If existing is set and key
+// already exists, its value is returned at once.
+func (c *Client) WaitKey(ctx context.Context, key string, timeout time.Duration, existing bool) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	args := []string{"WAITKEY", key, strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64)}
+	if existing {
+		args = append(args, "GET")
+	}
+	return c.str(ctx, args...)
+}
+
+// Del removes key and reports whether it existed.
+func (c *Client) Del(ctx context.Context, key string) (bool, error) {
+	return c.keyBool(ctx, "DEL", key)
+}
+
+// Exists reports whether key exists.
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	return c.keyBool(ctx, "EXISTS", key)
+}
+
+// TTL returns the remaining time to live of key: -1s if it has no TTL and
+// -2s if it does not exist, as in Redis.
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := checkKey(key); err != nil {
+		return 0, err
+	}
+	n, err := c.integer(ctx, "TTL", key)
+	return time.Duration(n) * time.Second, err
+}
+
+// Expire sets a TTL on key and reports whether the key exists.
+func (c *Client) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if err := checkKey(key); err != nil {
+		return false, err
+	}
+	n, err := c.integer(ctx, "EXPIRE", key, seconds(ttl))
+	return n == 1, err
+}
+
+// Persist removes the TTL of key and reports whether it had one.
+func (c *Client) Persist(ctx context.Context, key string) (bool, error) {
+	return c.keyBool(ctx, "PERSIST", key)
+}
+
+// Incr increments the integer stored at key and returns the new value.
+func (c *Client) Incr(ctx context.Context, key string) (int64, error) {
+	if err := checkKey(key); err != nil {
+		return 0, err
+	}
+	return c.integer(ctx, "INCR", key)
+}
+
+// Decr decrements the integer stored at key and returns the new value.
+func (c *Client) Decr(ctx context.Context, key string) (int64, error) {
+	if err := checkKey(key); err != nil {
+		return 0, err
+	}
+	return c.integer(ctx, "DECR", key)
+}
+
+// Keys returns every key.
+func (c *Client) Keys(ctx context.Context) ([]string, error) {
+	r, err := c.Do(ctx, "KEYS")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	if len(r.Lines) == 1 && r.Lines[0] == "(empty)" {
+		return nil, nil
+	}
+	return r.Lines, nil
+}
+
+// Scan returns a batch of the keys matching the glob pattern (empty
+// matches all) and the cursor of the next batch, visiting about count keys
+// per call. Start with cursor 0 and stop when the returned cursor is 0.
+func (c *Client) Scan(ctx context.Context, cursor uint64, match string, count int) ([]string, uint64, error) {
+	args := []string{"SCAN", strconv.FormatUint(cursor, 10)}
+	if match != "" {
+		args = append(args, "MATCH", match)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(count))
+	}
+	r, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := r.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(r.Lines) == 0 {
+		return nil, 0, fmt.Errorf("redigo: empty SCAN reply")
+	}
+	v, ok := strings.CutPrefix(r.Lines[0], "cursor:")
+	next, err := strconv.ParseUint(v, 10, 64)
+	if !ok || err != nil {
+		return nil, 0, fmt.Errorf("redigo: unexpected SCAN cursor %q", r.Lines[0])
+	}
+	return r.Lines[1:], next, nil
+}
+
+// KeyStat is one key of a HotKeys or BigKeys report.
+type KeyStat struct {
+	Key   string
+	Freq  int   // logarithmic access frequency, 0-255
+	Bytes int64 // estimated memory usage
+}
+
+// HotKeys returns up to n of the most frequently accessed keys, hottest
+// first.
+func (c *Client) HotKeys(ctx context.Context, n int) ([]KeyStat, error) {
+	return c.keyStats(ctx, "FREQ", n)
+}
+
+// BigKeys returns up to n of the largest keys, largest first.
+func (c *Client) BigKeys(ctx context.Context, n int) ([]KeyStat, error) {
+	return c.keyStats(ctx, "SIZE", n)
+}
+
+func (c *Client) keyStats(ctx context.Context, by string, n int) ([]KeyStat, error) {
+	r, err := c.Do(ctx, "HOTKEYS", by, "COUNT", strconv.Itoa(n))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	if len(r.Lines) == 1 && r.Lines[0] == "(empty)" {
+		return nil, nil
+	}
+	stats := make([]KeyStat, 0, len(r.Lines))
+	for _, line := range r.Lines {
+		var st KeyStat
+		if _, err := fmt.Sscanf(line, "%s freq=%d bytes=%d", &st.Key, &st.Freq, &st.Bytes); err != nil {
+			return nil, fmt.Errorf("redigo: unexpected HOTKEYS line %q", line)
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
+
+// Info returns the fields of an INFO section ("" for the default set).
+func (c *Client) Info(ctx context.Context, section string) (map[string]string, error) {
+	args := []string{"INFO"}
+	if section != "" {
+		args = append(args, section)
+	}
+	r, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return r.Fields()
+}
+
+// InfoJSON decodes the server's INFO JSON document, the counters of every
+// INFO section and per-command statistics, into v.
+func (c *Client) InfoJSON(ctx context.Context, v any) error {
+	s, err := c.str(ctx, "INFO", "JSON")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
+// ConfigSet changes a runtime setting, e.g. ConfigSet(ctx, "maxmemory", "100mb").
+func (c *Client) ConfigSet(ctx context.Context, name, value string) error {
+	return c.ok(ctx, "CONFIG", "SET", name, value)
+}
+
+// ConfigGet returns the configuration parameters matching the glob
+// pattern.
+func (c *Client) ConfigGet(ctx context.Context, pattern string) (map[string]string, error) {
+	r, err := c.Do(ctx, "CONFIG", "GET", pattern)
+	if err != nil {
+		return nil, err
+	}
+	return r.Fields()
+}
+
+// ConfigRewrite saves runtime configuration changes to the server's
+// config file.
+func (c *Client) ConfigRewrite(ctx context.Context) error {
+	return c.ok(ctx, "CONFIG", "REWRITE")
+}
+
+// ConfigResetStat zeroes the server's statistics.
+func (c *Client) ConfigResetStat(ctx context.Context) error {
+	return c.ok(ctx, "CONFIG", "RESETSTAT")
+}
+
+// MemoryUsage returns the approximate number of bytes used by key, or Nil
+// if it does not exist.
+func (c *Client) MemoryUsage(ctx context.Context, key string) (int64, error) {
+	if err := checkKey(key); err != nil {
+		return 0, err
+	}
+	return c.integer(ctx, "MEMORY", "USAGE", key)
+}
+
+// MemoryStats returns the MEMORY STATS breakdown.
+func (c *Client) MemoryStats(ctx context.Context) (map[string]string, error) {
+	r, err := c.Do(ctx, "MEMORY", "STATS")
+	if err != nil {
+		return nil, err
+	}
+	return r.Fields()
+}
+
+// DumpAll returns the dataset as replayable commands.
+func (c *Client) DumpAll(ctx context.Context) ([]string, error) {
+	r, err := c.Do(ctx, "DUMPALL")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	lines := r.Lines
+	if n := len(lines); n > 0 && lines[n-1] == "." {
+		lines = lines[:n-1]
+	}
+	return lines, nil
+}
+
+// BGSave starts a background snapshot on the server.
+func (c *Client) BGSave(ctx context.Context) error {
+	r, err := c.Do(ctx, "BGSAVE")
+	if err != nil {
+		return err
+	}
+	_, err = r.String()
+	return err
+}
+
+// BGRewriteAOF starts a background rewrite of the server's AOF.
+func (c *Client) BGRewriteAOF(ctx context.Context) error {
+	r, err := c.Do(ctx, "BGREWRITEAOF")
+	if err != nil {
+		return err
+	}
+	_, err = r.String()
+	return err
+}
+
+// LastSave returns the time of the last successful snapshot, or the zero
+// time if there has been none.
+func (c *Client) LastSave(ctx context.Context) (time.Time, error) {
+	n, err := c.integer(ctx, "LASTSAVE")
+	if err != nil || n == 0 {
+		return time.Time{}, err
+	}
+	return time.Unix(n, 0), nil
+}
+
+// ClientList returns one line per connected client.
+func (c *Client) ClientList(ctx context.Context) ([]string, error) {
+	r, err := c.Do(ctx, "CLIENT", "LIST")
+	if err != nil {
+		return nil, err
+	}
+	return r.Lines, r.Err()
+}
+
+// ClientKill closes the client connection with the given id.
+func (c *Client) ClientKill(ctx context.Context, id int64) (bool, error) {
+	n, err := c.integer(ctx, "CLIENT", "KILL", "ID", strconv.FormatInt(id, 10))
+	return n > 0, err
+}
+
+// ClientPause holds all commands (or only writes) for d.
+func (c *Client) ClientPause(ctx context.Context, d time.Duration, writeOnly bool) error {
+	mode := "ALL"
+	if writeOnly {
+		mode = "WRITE"
+	}
+	return c.ok(ctx, "CLIENT", "PAUSE", strconv.FormatInt(d.Milliseconds(), 10), mode)
+}
+
+// ClientUnpause lifts a CLIENT PAUSE.
+func (c *Client) ClientUnpause(ctx context.Context) error {
+	return c.ok(ctx, "CLIENT", "UNPAUSE")
+}
+
+func (c *Client) ok(ctx context.Context, args ...string) error {
+	r, err := c.Do(ctx, args...)
+	if err != nil {
+		return err
+	}
+	return r.OK()
+}
+
+func (c *Client) str(ctx context.Context, args ...string) (string, error) {
+	r, err := c.Do(ctx, args...)
+	if err != nil {
+		return "", err
+	}
+	return r.String()
+}
+
+func (c *Client) integer(ctx context.Context, args ...string) (int64, error) {
+	r, err := c.Do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	return r.Int()
+}
+
+func (c *Client) keyBool(ctx context.Context, cmd, key string) (bool, error) {
+	if err := checkKey(key); err != nil {
+		return false, err
+	}
+	n, err := c.integer(ctx, cmd, key)
+	return n == 1, err
+}
+
+// seconds formats d as whole seconds, with a minimum of one.
+func seconds(d time.Duration) string {
+	s := int64(d / time.Second)
+	if s < 1 {
+		s = 1
+	}
+	return strconv.FormatInt(s, 10)
+}
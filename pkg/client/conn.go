@@ -0,0 +1,186 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prompt is written by the server before it reads each command, so it
+// follows every reply. A reply line may start with the same two bytes,
+// e.g. HOTKEYS listing a key named ">", so connections ask the server to
+// frame replies: each one is preceded by a "*N" line giving its number of
+// lines, and the prompt is only looked for after the last of them.
+var prompt = []byte("> ")
+
+// errBadFrame is returned for a framed reply that doesn't parse.
+var errBadFrame = errors.New("redigo: malformed reply frame")
+
+// ErrInvalidArg is returned for arguments the line protocol cannot carry.
+var ErrInvalidArg = errors.New("redigo: invalid argument")
+
+// conn is one pooled server connection.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+
+	reused  bool // taken from the idle pool rather than freshly dialled
+	readAny bool // some reply data was read during the last round trip
+	framed  bool // the server frames replies, see prompt
+}
+
+func newConn(nc net.Conn) *conn {
+	return &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+}
+
+// roundTrip writes cmds and reads one reply for each of them; with no
+// commands it reads a single reply (the greeting banner). On error the
+// connection must be discarded.
+func (cn *conn) roundTrip(ctx context.Context, cmds [][]string) ([]*Reply, error) {
+	cn.readAny = false
+	if d, ok := ctx.Deadline(); ok {
+		cn.SetDeadline(d)
+	} else {
+		cn.SetDeadline(time.Time{})
+	}
+	// Cancellation interrupts blocked I/O by expiring the deadline.
+	stop := context.AfterFunc(ctx, func() { cn.SetDeadline(time.Now()) })
+	defer stop()
+
+	replies, err := cn.exchange(cmds)
+	if err != nil {
+		if cerr := ctx.Err(); cerr != nil {
+			return nil, cerr
+		}
+		// The connection deadline can fire just before ctx's own timer.
+		var ne net.Error
+		if _, ok := ctx.Deadline(); ok && errors.As(err, &ne) && ne.Timeout() {
+			return nil, context.DeadlineExceeded
+		}
+	}
+	return replies, err
+}
+
+func (cn *conn) exchange(cmds [][]string) ([]*Reply, error) {
+	for _, args := range cmds {
+		cn.w.WriteString(strings.Join(args, " "))
+		cn.w.WriteString("\r\n")
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	n := len(cmds)
+	if n == 0 {
+		n = 1
+	}
+	replies := make([]*Reply, n)
+	for i := range replies {
+		r, err := cn.readReply()
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = r
+	}
+	return replies, nil
+}
+
+// readReply reads one reply and the prompt after it.
+func (cn *conn) readReply() (*Reply, error) {
+	if cn.framed {
+		return cn.readFramed()
+	}
+	r := &Reply{}
+	for {
+		if p, err := cn.r.Peek(len(prompt)); err == nil && bytes.Equal(p, prompt) {
+			cn.r.Discard(len(prompt))
+			cn.readAny = true
+			return r, nil
+		}
+		line, err := cn.r.ReadString('\n')
+		if len(line) > 0 {
+			cn.readAny = true
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.Lines = append(r.Lines, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// readFramed reads a "*N" line, the N lines of the reply and the prompt.
+// The server writes a few errors of its own, such as for an unknown
+// command, as a single unframed line.
+func (cn *conn) readFramed() (*Reply, error) {
+	head, err := cn.readLine()
+	if err != nil {
+		return nil, err
+	}
+	r := &Reply{}
+	if strings.HasPrefix(head, "-") {
+		r.Lines = []string{head}
+	} else {
+		n, err := strconv.Atoi(strings.TrimPrefix(head, "*"))
+		if !strings.HasPrefix(head, "*") || err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: %q", errBadFrame, head)
+		}
+		r.Lines = make([]string, n)
+		for i := range r.Lines {
+			if r.Lines[i], err = cn.readLine(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	p := make([]byte, len(prompt))
+	if _, err := io.ReadFull(cn.r, p); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(p, prompt) {
+		return nil, fmt.Errorf("%w: %q after the reply", errBadFrame, p)
+	}
+	return r, nil
+}
+
+// readLine reads one line, without its terminator.
+func (cn *conn) readLine() (string, error) {
+	line, err := cn.r.ReadString('\n')
+	if len(line) > 0 {
+		cn.readAny = true
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// checkArgs rejects commands the line protocol would mangle.
+func checkArgs(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: empty command", ErrInvalidArg)
+	}
+	for _, a := range args {
+		if strings.ContainsAny(a, "\r\n") {
+			return fmt.Errorf("%w: %q contains a line break", ErrInvalidArg, a)
+		}
+	}
+	return nil
+}
+
+// checkKey rejects keys that would be split into several arguments.
+func checkKey(key string) error {
+	if key == "" || strings.IndexFunc(key, isSpace) >= 0 {
+		return fmt.Errorf("%w: key %q must be non-empty without whitespace", ErrInvalidArg, key)
+	}
+	return nil
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\n' || r == '\v' || r == '\f'
+}
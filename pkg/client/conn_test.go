@@ -0,0 +1,50 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name   string
+		framed bool
+		input  string
+		want   []string
+		err    error
+	}{
+		{name: "unframed", input: "+OK\r\n> ", want: []string{"+OK"}},
+		{name: "unframed empty", input: "> ", want: nil},
+		{name: "unframed torn", input: "+OK\r\n", err: io.EOF},
+		{name: "framed", framed: true, input: "*2\r\na\r\nb\r\n> ", want: []string{"a", "b"}},
+		{name: "framed empty", framed: true, input: "*0\r\n> ", want: []string{}},
+		{name: "framed prompt-like line", framed: true, input: "*2\r\n> freq=3\r\n>\r\n> ", want: []string{"> freq=3", ">"}},
+		{name: "framed unframed error", framed: true, input: "-ERR unknown command 'X'\r\n> ", want: []string{"-ERR unknown command 'X'"}},
+		{name: "framed bad header", framed: true, input: "+OK\r\n> ", err: errBadFrame},
+		{name: "framed negative count", framed: true, input: "*-1\r\n> ", err: errBadFrame},
+		{name: "framed missing prompt", framed: true, input: "*1\r\na\r\nb\r\n", err: errBadFrame},
+		{name: "framed short", framed: true, input: "*3\r\na\r\nb\r\n", err: io.EOF},
+		{name: "framed torn prompt", framed: true, input: "*1\r\na\r\n>", err: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		cn := &conn{r: bufio.NewReader(strings.NewReader(tt.input)), framed: tt.framed}
+		r, err := cn.readReply()
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !slices.Equal(r.Lines, tt.want) {
+			t.Errorf("%s: lines = %q, want %q", tt.name, r.Lines, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package client
+
+import "context"
+
+// Pipeline queues commands and sends them in a single round trip.
+//
+//	p := c.Pipeline()
+//	p.Do("SET", "a", "1")
+//	p.Do("INCR", "a")
+//	replies, err := p.Exec(ctx)
+type Pipeline struct {
+	c    *Client
+	cmds [][]string
+}
+
+// Pipeline returns an empty pipeline on c.
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Do queues a command.
+func (p *Pipeline) Do(args ...string) {
+	p.cmds = append(p.cmds, args)
+}
+
+// Len returns the number of queued commands.
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Exec sends every queued command on one connection and returns their
+// replies in order. The pipeline is empty afterwards.
+func (p *Pipeline) Exec(ctx context.Context) ([]*Reply, error) {
+	cmds := p.cmds
+	p.cmds = nil
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+	return p.c.do(ctx, cmds)
+}
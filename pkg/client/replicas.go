@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMaxReplicaLag = 10 * time.Second
+	replicaCheckInterval = time.Second
+)
+
+// replicaSet load-balances reads across the replicas in Options.Replicas.
+// A background loop asks each replica how old its data is (INFO) and only
+// replicas within MaxReplicaLag are used; a replica that fails a call is
+// left out until the next check finds it healthy again.
+type replicaSet struct {
+	clients []*Client
+	maxLag  time.Duration
+
+	healthy atomic.Pointer[[]*Client]
+	next    atomic.Uint64
+
+	done chan struct{}
+	once sync.Once
+}
+
+func newReplicaSet(opts Options) *replicaSet {
+	rs := &replicaSet{maxLag: opts.MaxReplicaLag, done: make(chan struct{})}
+	for _, addr := range opts.Replicas {
+		// Replicas only accept reads, so no CLIENT SETNAME.
+		rs.clients = append(rs.clients, New(Options{
+			Addr:        addr,
+			PoolSize:    opts.PoolSize,
+			DialTimeout: opts.DialTimeout,
+			MaxRetries:  opts.MaxRetries,
+		}))
+	}
+	rs.healthy.Store(&[]*Client{})
+	go rs.run()
+	return rs
+}
+
+// pick returns the next healthy replica, or nil if there is none (or rs
+// is nil) and the primary must be used.
+func (rs *replicaSet) pick() *Client {
+	if rs == nil {
+		return nil
+	}
+	healthy := *rs.healthy.Load()
+	if len(healthy) == 0 {
+		return nil
+	}
+	return healthy[rs.next.Add(1)%uint64(len(healthy))]
+}
+
+// exclude stops using r until the next check.
+func (rs *replicaSet) exclude(r *Client) {
+	for {
+		old := rs.healthy.Load()
+		healthy := make([]*Client, 0, len(*old))
+		for _, c := range *old {
+			if c != r {
+				healthy = append(healthy, c)
+			}
+		}
+		if rs.healthy.CompareAndSwap(old, &healthy) {
+			return
+		}
+	}
+}
+
+func (rs *replicaSet) run() {
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+	for {
+		rs.check()
+		select {
+		case <-rs.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check asks every replica for its lag and keeps those within maxLag.
+func (rs *replicaSet) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), replicaCheckInterval)
+	defer cancel()
+	var healthy []*Client
+	for _, r := range rs.clients {
+		if lag, err := r.replicaLag(ctx); err == nil && lag <= rs.maxLag {
+			healthy = append(healthy, r)
+		}
+	}
+	rs.healthy.Store(&healthy)
+}
+
+func (rs *replicaSet) close() {
+	if rs == nil {
+		return
+	}
+	rs.once.Do(func() {
+		close(rs.done)
+		for _, r := range rs.clients {
+			r.Close()
+		}
+	})
+}
+
+// replicaLag returns how long ago a replica last synced with its primary,
+// from the master_last_io_seconds_ago line of its INFO.
+func (c *Client) replicaLag(ctx context.Context) (time.Duration, error) {
+	r, err := c.Do(ctx, "INFO")
+	if err != nil {
+		return 0, err
+	}
+	if err := r.Err(); err != nil {
+		return 0, err
+	}
+	for _, line := range r.Lines {
+		if v, ok := strings.CutPrefix(line, "master_last_io_seconds_ago:"); ok {
+			secs, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || secs < 0 {
+				return 0, errors.New("redigo: replica has not synced")
+			}
+			return time.Duration(secs) * time.Second, nil
+		}
+	}
+	return 0, errors.New("redigo: not a replica")
+}
+
+// readString runs a read command that returns a string on a replica when
+// one is healthy, falling back to the primary if the replica cannot be
+// reached.
+func (c *Client) readString(ctx context.Context, args ...string) (string, error) {
+	if r := c.replicas.pick(); r != nil {
+		v, err := r.str(ctx, args...)
+		var rerr Error
+		if err == nil || errors.Is(err, Nil) || errors.As(err, &rerr) || ctx.Err() != nil {
+			return v, err
+		}
+		c.replicas.exclude(r)
+	}
+	return c.str(ctx, args...)
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Nil is returned by typed helpers when the server replies (nil), i.e. the
+// key does not exist.
+var Nil = errors.New("redigo: nil")
+
+// Error is an error reply sent by the server, without the leading '-'.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Reply is the raw text of one reply: every line the server sent before its
+// next prompt, without line terminators.
+type Reply struct {
+	Lines []string
+}
+
+// Err returns the server error if the reply is one.
+func (r *Reply) Err() error {
+	if len(r.Lines) > 0 && strings.HasPrefix(r.Lines[0], "-") {
+		return Error(r.Lines[0][1:])
+	}
+	return nil
+}
+
+// IsNil reports whether the reply is (nil).
+func (r *Reply) IsNil() bool {
+	return len(r.Lines) == 1 && r.Lines[0] == "(nil)"
+}
+
+// String decodes a single-line reply: a quoted value, a +status or a plain
+// line. It returns Nil for (nil).
+func (r *Reply) String() (string, error) {
+	if err := r.Err(); err != nil {
+		return "", err
+	}
+	if r.IsNil() {
+		return "", Nil
+	}
+	if len(r.Lines) != 1 {
+		return "", fmt.Errorf("redigo: expected a single-line reply, got %d lines", len(r.Lines))
+	}
+	line := r.Lines[0]
+	switch {
+	case len(line) >= 2 && line[0] == '"' && line[len(line)-1] == '"':
+		return line[1 : len(line)-1], nil
+	case strings.HasPrefix(line, "+"):
+		return line[1:], nil
+	}
+	return line, nil
+}
+
+// Int decodes an integer reply (:n).
+func (r *Reply) Int() (int64, error) {
+	if err := r.Err(); err != nil {
+		return 0, err
+	}
+	if r.IsNil() {
+		return 0, Nil
+	}
+	if len(r.Lines) != 1 || !strings.HasPrefix(r.Lines[0], ":") {
+		return 0, fmt.Errorf("redigo: expected an integer reply, got %q", r.Lines)
+	}
+	return strconv.ParseInt(r.Lines[0][1:], 10, 64)
+}
+
+// OK checks for a +OK status reply.
+func (r *Reply) OK() error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if len(r.Lines) != 1 || !strings.HasPrefix(r.Lines[0], "+OK") {
+		return fmt.Errorf("redigo: expected +OK, got %q", r.Lines)
+	}
+	return nil
+}
+
+// Fields parses "name:value" lines, as sent by INFO and MEMORY STATS.
+// Section headers and blank lines are skipped.
+func (r *Reply) Fields() (map[string]string, error) {
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	res := make(map[string]string)
+	for _, line := range r.Lines {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,83 @@
+package redigotest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DakshBaxi/RediGo/pkg/client"
+)
+
+// The Assert helpers check a reply returned by Server.Do or client.Do.
+// They report a mismatch with t.Errorf, so a test carries on and reports
+// every failed check, and return whether the reply matched.
+
+// AssertOK checks for a +OK status reply.
+func AssertOK(t testing.TB, r *client.Reply) bool {
+	t.Helper()
+	if err := r.OK(); err != nil {
+		t.Errorf("reply %q: want +OK", r.Lines)
+		return false
+	}
+	return true
+}
+
+// AssertString checks for a single-line reply decoding to want, such as a
+// quoted value or a +status.
+func AssertString(t testing.TB, r *client.Reply, want string) bool {
+	t.Helper()
+	got, err := r.String()
+	if err != nil || got != want {
+		t.Errorf("reply %q: want %q", r.Lines, want)
+		return false
+	}
+	return true
+}
+
+// AssertInt checks for the integer reply :want.
+func AssertInt(t testing.TB, r *client.Reply, want int64) bool {
+	t.Helper()
+	got, err := r.Int()
+	if err != nil || got != want {
+		t.Errorf("reply %q: want :%d", r.Lines, want)
+		return false
+	}
+	return true
+}
+
+// AssertNil checks for the (nil) reply of a missing key.
+func AssertNil(t testing.TB, r *client.Reply) bool {
+	t.Helper()
+	if !r.IsNil() {
+		t.Errorf("reply %q: want (nil)", r.Lines)
+		return false
+	}
+	return true
+}
+
+// AssertError checks for an error reply starting with prefix, e.g. "ERR"
+// or "QUOTA"; an empty prefix accepts any error.
+func AssertError(t testing.TB, r *client.Reply, prefix string) bool {
+	t.Helper()
+	err := r.Err()
+	if err == nil || !strings.HasPrefix(err.Error(), prefix) {
+		t.Errorf("reply %q: want an error starting with %q", r.Lines, prefix)
+		return false
+	}
+	return true
+}
+
+// AssertLines checks a reply line by line, e.g. for KEYS or INFO output.
+func AssertLines(t testing.TB, r *client.Reply, want ...string) bool {
+	t.Helper()
+	if len(r.Lines) != len(want) {
+		t.Errorf("reply %q: want %q", r.Lines, want)
+		return false
+	}
+	for i := range want {
+		if r.Lines[i] != want[i] {
+			t.Errorf("reply %q: want %q", r.Lines, want)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package redigotest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake store clock that only moves when told to, so tests can
+// expire keys without sleeping:
+//
+//	clock := redigotest.NewClock(time.Now())
+//	srv := redigotest.Start(t, redigotest.Options{Clock: clock})
+//	srv.Do("SETEX", "session", "60", "x")
+//	clock.Advance(61 * time.Second)
+//	redigotest.AssertNil(t, srv.Do("GET", "session"))
+//
+// It is safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock stopped at now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to now, which may be in the past.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
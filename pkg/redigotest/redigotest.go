@@ -0,0 +1,231 @@
+// Package redigotest starts in-process RediGo servers for integration
+// tests, so applications can test against RediGo without Docker or a
+// separately run server:
+//
+//	func TestCache(t *testing.T) {
+//		srv := redigotest.Start(t, redigotest.Options{Pipe: true})
+//		c := srv.Client()
+//		... exercise the code under test with c ...
+//		redigotest.AssertString(t, srv.Do("GET", "greeting"), "hello")
+//	}
+//
+// Servers and their clients are shut down when the test ends, and the
+// persistence directory is removed.
+package redigotest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/client"
+	"github.com/DakshBaxi/RediGo/pkg/server"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// shutdownTimeout bounds stopping a server at the end of a test; clients
+// still connected after it are disconnected.
+const shutdownTimeout = 5 * time.Second
+
+// Options configures a test server. The zero value serves an in-memory
+// dataset on an ephemeral TCP port of 127.0.0.1.
+type Options struct {
+	// Pipe serves connections over net.Pipe instead of TCP, so no port is
+	// opened. Connect with Client or Dial; Addr is empty.
+	Pipe bool
+
+	// Persist enables the AOF and snapshots in a temporary directory, kept
+	// across Restart.
+	Persist bool
+
+	// Clock, if set, is the store's clock, kept across Restart: expiry
+	// and eviction then follow it instead of real time.
+	Clock *Clock
+
+	// Logger receives the server logs; nil discards them.
+	Logger *slog.Logger
+
+	// Configure, if set, may change the server options before every start,
+	// e.g. to set read-through rules or the worker pool.
+	Configure func(*server.Options)
+}
+
+// Server is a running test server.
+type Server struct {
+	t    testing.TB
+	opts Options
+	dir  string
+
+	mu     sync.Mutex
+	srv    *server.Server
+	pipe   *pipeListener // nil when serving TCP
+	addr   string
+	served chan struct{}  // closed when Serve returns
+	client *client.Client // created by Client, closed by shutdown
+}
+
+// Start starts a server and registers its shutdown with t.Cleanup. It
+// fails the test if the server can't start.
+func Start(t testing.TB, opts Options) *Server {
+	t.Helper()
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	s := &Server{t: t, opts: opts}
+	if opts.Persist {
+		s.dir = t.TempDir()
+	}
+	s.start("127.0.0.1:0")
+	t.Cleanup(s.stop)
+	return s
+}
+
+// start creates the server, restoring the persisted dataset if any, and
+// serves it on addr or a new pipe listener.
+func (s *Server) start(addr string) {
+	s.t.Helper()
+	so := server.Options{Logger: s.opts.Logger}
+	if s.opts.Clock != nil {
+		so.Store = store.New()
+		so.Store.SetClock(s.opts.Clock)
+	}
+	if s.dir != "" {
+		so.AOFPath = filepath.Join(s.dir, "redigo.aof")
+		so.SnapshotPath = filepath.Join(s.dir, "redigo.snapshot")
+	}
+	if s.opts.Configure != nil {
+		s.opts.Configure(&so)
+	}
+	srv, err := server.New(so)
+	if err != nil {
+		s.t.Fatalf("redigotest: start server: %v", err)
+	}
+
+	var ln net.Listener
+	var pipe *pipeListener
+	if s.opts.Pipe {
+		pipe = newPipeListener()
+		ln = pipe
+	} else if ln, err = net.Listen("tcp", addr); err != nil {
+		s.t.Fatalf("redigotest: listen: %v", err)
+	}
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		srv.Serve(ln)
+	}()
+
+	s.mu.Lock()
+	s.srv, s.pipe, s.served = srv, pipe, served
+	if pipe == nil {
+		s.addr = ln.Addr().String()
+	}
+	s.mu.Unlock()
+}
+
+// stop shuts the server down at the end of the test.
+func (s *Server) stop() {
+	if err := s.shutdown(); err != nil {
+		s.t.Errorf("redigotest: shutdown: %v", err)
+	}
+}
+
+// shutdown closes the client and stops the server, waiting for other
+// connections to finish their commands for at most shutdownTimeout.
+// The client goes first: its pooled connections don't read, and a pipe
+// has no buffer to take the server's goodbye message.
+func (s *Server) shutdown() error {
+	s.mu.Lock()
+	srv, served, c := s.srv, s.served, s.client
+	s.client = nil
+	s.mu.Unlock()
+	if c != nil {
+		c.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	<-served
+	if err == context.DeadlineExceeded {
+		err = nil
+	}
+	return err
+}
+
+// Restart shuts the server down and starts a new one on the same address.
+// With Options.Persist the new server reloads the dataset from the
+// snapshot and AOF; otherwise it starts empty. The client returned by
+// Client is closed; call Client again for one connected to the new server.
+// Connections opened with Dial must be closed first.
+func (s *Server) Restart() {
+	s.t.Helper()
+	if err := s.shutdown(); err != nil {
+		s.t.Fatalf("redigotest: shutdown: %v", err)
+	}
+	s.start(s.Addr())
+}
+
+// Server returns the running server.
+func (s *Server) Server() *server.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.srv
+}
+
+// Store returns the dataset of the running server, to set up fixtures or
+// inspect state directly. Writes made on it bypass the AOF.
+func (s *Server) Store() *store.Store {
+	return s.Server().Store()
+}
+
+// Addr returns the TCP address of the server, or "" when it serves pipes.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
+}
+
+// Dir returns the persistence directory, or "" without Options.Persist.
+func (s *Server) Dir() string {
+	return s.dir
+}
+
+// Dial opens a raw connection to the server, including its greeting
+// banner, for tests of the wire protocol itself.
+func (s *Server) Dial(ctx context.Context) (net.Conn, error) {
+	s.mu.Lock()
+	pipe, addr := s.pipe, s.addr
+	s.mu.Unlock()
+	if pipe != nil {
+		return pipe.dial(ctx)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// Client returns a client connected to the server. It is created on first
+// use and closed by Restart and when the test ends.
+func (s *Server) Client() *client.Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		s.client = client.New(client.Options{Dial: s.Dial})
+	}
+	return s.client
+}
+
+// Do sends one command with Client and returns the reply. A transport
+// error fails the test; error replies are returned for the Assert helpers.
+func (s *Server) Do(args ...string) *client.Reply {
+	s.t.Helper()
+	r, err := s.Client().Do(context.Background(), args...)
+	if err != nil {
+		s.t.Fatalf("redigotest: %v: %v", args, err)
+	}
+	return r
+}
@@ -0,0 +1,356 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/config"
+	"github.com/DakshBaxi/RediGo/internal/encryption"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// aofLog is the append-only file every write command is logged to. Writes
+// go to the newest incremental file listed in the manifest, see manifest.go.
+type aofLog struct {
+	path   string // Options.AOFPath, "" when the AOF is disabled
+	layout aofLayout
+	fsync  string // config.FsyncAlways, FsyncEverySec or FsyncNo
+	log    *slog.Logger
+	mu     sync.Mutex
+	f      *os.File // nil when the AOF is disabled or closed
+	incr   string   // path of f
+	err    error    // error of the last write, nil once a write succeeds
+	dirty  bool     // written since the last fsync
+	buf    []byte   // record being written, reused between appends
+	stop   chan struct{}
+
+	// w is f, or an encryption writer over it when key, the
+	// Options.EncryptionKey, is set.
+	w   io.Writer
+	key []byte
+
+	manifest *aofManifest
+	size     int64 // bytes in every part
+	rewrite  rewriteState
+
+	// db is the store whose evicted and expired keys are logged as DEL
+	// ahead of the next write, see trackRemovals; nil when not tracking.
+	db      *store.Store
+	removed []string
+
+	faults *faultSet // injected write errors and fsync delays
+}
+
+// openAOF opens the AOF configured at path, with its parts in dirName
+// next to it, for appending to its newest incremental file. The directory,
+// the manifest and a first incremental file are created if needed. An
+// empty path returns a disabled log that drops every append. fsync is the
+// appendfsync policy; empty means everysec. With a key, writes are sealed
+// with it, see openPart.
+func openAOF(path, dirName, fsync string, key []byte, logger *slog.Logger, faults *faultSet) (*aofLog, error) {
+	if fsync == "" {
+		fsync = config.FsyncEverySec
+	}
+	a := &aofLog{path: path, fsync: fsync, key: key, log: logger, faults: faults}
+	if path == "" {
+		return a, nil
+	}
+	a.layout = newAOFLayout(path, dirName)
+	if err := os.MkdirAll(a.layout.dir, 0755); err != nil {
+		return nil, err
+	}
+	m, err := readManifest(a.layout)
+	if err != nil {
+		return nil, err
+	}
+	// A sealed part is only ever written by the log that created it, so
+	// its frame numbers, which are its nonces, never repeat; appending to
+	// the last part is only possible in the clear, onto a plain part.
+	if n := len(m.incrs); n == 0 || key != nil || !appendable(a.layout.path(m.incrs[n-1])) {
+		m.incrs = append(m.incrs, a.layout.part(m.nextSeq(aofTypeIncr), aofTypeIncr))
+	}
+	a.manifest = m
+	a.incr = a.layout.path(m.incrs[len(m.incrs)-1])
+	f, w, err := a.openPart(a.incr)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.write(a.layout); err != nil {
+		f.Close()
+		return nil, err
+	}
+	a.f, a.w = f, w
+	a.size = a.partsSize()
+	a.rewrite.baseSize = a.size
+	if fsync == config.FsyncEverySec {
+		a.stop = make(chan struct{})
+		go a.syncLoop(a.stop)
+	}
+	return a, nil
+}
+
+// openPart opens the AOF part at path for appending, creating it if
+// needed. With a key, the part must be new: the returned writer starts it
+// with the header of a sealed file and seals every write.
+func (a *aofLog) openPart(path string) (*os.File, io.Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.key == nil {
+		return f, f, nil
+	}
+	w, err := encryption.NewWriter(f, a.key)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, w, nil
+}
+
+// appendable reports whether records can be appended to the file at path:
+// it is missing, or plain and doesn't end in a record cut short by a crash,
+// which AOFLoadTruncated dropped on load.
+func appendable(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	if encryption.IsSealed(bufio.NewReader(f)) {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 {
+		return err == nil
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, fi.Size()-1); err != nil {
+		return false
+	}
+	return last[0] == '\n'
+}
+
+// syncLoop flushes the AOF to disk once a second for appendfsync everysec.
+func (a *aofLog) syncLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		a.mu.Lock()
+		if a.f != nil && a.dirty {
+			a.faults.delay(&a.faults.fsyncDelay)
+			if err := a.f.Sync(); err != nil {
+				a.log.Error("AOF fsync failed", "path", a.incr, "err", err)
+				a.err = err
+			}
+			a.dirty = false
+		}
+		a.mu.Unlock()
+	}
+}
+
+// append("SET", key, value...)
+// append("SETEX", key, ttl, value...)
+// append("DEL", key)
+// append("EXPIREAT", key, unixtime)
+func (a *aofLog) append(parts ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return
+	}
+	a.buf = a.appendRemovals(a.buf[:0])
+	a.buf = appendCommand(a.buf, parts...)
+	a.writeLocked(a.buf)
+	if cap(a.buf) > maxPooledBuffer {
+		a.buf = nil
+	}
+}
+
+// write logs records, one or more lines built with appendCommand, after a
+// DEL for every key the store dropped by itself since the last write.
+// Taking the removals under a.mu keeps each DEL ahead of any write made
+// after the key was dropped. records may be empty.
+func (a *aofLog) write(records []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return
+	}
+	if a.db != nil && a.db.HasRemoved() {
+		a.buf = append(a.appendRemovals(a.buf[:0]), records...)
+		records = a.buf
+	}
+	if len(records) > 0 {
+		a.writeLocked(records)
+	}
+	if cap(a.buf) > maxPooledBuffer {
+		a.buf = nil
+	}
+}
+
+// trackRemovals makes the log record the keys s evicts or expires from now
+// on, which no command reports. It does nothing when the AOF is disabled.
+func (a *aofLog) trackRemovals(s *store.Store) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return
+	}
+	a.db = s
+	s.TrackRemovals(true)
+}
+
+// appendRemovals appends a DEL record to buf for every key the store has
+// evicted or expired since the last call. It is called with a.mu held.
+func (a *aofLog) appendRemovals(buf []byte) []byte {
+	if a.db == nil || !a.db.HasRemoved() {
+		return buf
+	}
+	a.removed = a.db.TakeRemoved(a.removed[:0])
+	for _, key := range a.removed {
+		buf = appendCommand(buf, "DEL", key)
+	}
+	clear(a.removed)
+	return buf
+}
+
+func (a *aofLog) writeLocked(records []byte) {
+	var err error
+	if a.faults.hit(&a.faults.aofWriteError) {
+		err = errInjectedWrite
+	} else {
+		_, err = a.w.Write(records)
+	}
+	if err == nil {
+		a.size += int64(len(records))
+		if a.key != nil {
+			a.size += encryption.FrameOverhead
+		}
+	}
+	if err == nil && a.fsync == config.FsyncAlways {
+		a.faults.delay(&a.faults.fsyncDelay)
+		err = a.f.Sync()
+	}
+	if err != nil {
+		a.log.Error("AOF write failed", "path", a.incr, "err", err)
+	}
+	a.err = err
+	a.dirty = true
+}
+
+// enabled reports whether writes are currently being logged.
+func (a *aofLog) enabled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.f != nil
+}
+
+func (a *aofLog) fsyncPolicy() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.fsync
+}
+
+// setFsync switches the appendfsync policy, starting or stopping the
+// once-a-second sync as needed.
+func (a *aofLog) setFsync(policy string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.fsync == policy {
+		return
+	}
+	a.fsync = policy
+	if a.f == nil {
+		return
+	}
+	if a.stop != nil {
+		close(a.stop)
+		a.stop = nil
+	}
+	if a.dirty {
+		a.f.Sync()
+		a.dirty = false
+	}
+	if policy == config.FsyncEverySec {
+		a.stop = make(chan struct{})
+		go a.syncLoop(a.stop)
+	}
+}
+
+// check reports why the AOF cannot take writes: the last write failed or
+// the file was removed or replaced, so appends would be lost on restart.
+// It returns nil when the AOF is disabled.
+func (a *aofLog) check() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return nil
+	}
+	if a.err != nil {
+		return a.err
+	}
+	fi, err := a.f.Stat()
+	if err != nil {
+		return err
+	}
+	onDisk, err := os.Stat(a.incr)
+	if err != nil || !os.SameFile(fi, onDisk) {
+		return errors.New("AOF file was removed or replaced")
+	}
+	return nil
+}
+
+// close syncs the AOF to disk and closes it; later appends are dropped.
+func (a *aofLog) close() error {
+	// A rewrite still writing its base goes on to change the manifest and
+	// remove the parts it replaces: let it finish first.
+	a.mu.Lock()
+	a.rewrite.closing = true
+	a.mu.Unlock()
+	a.rewrite.running.Wait()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil {
+		return nil
+	}
+	if a.stop != nil {
+		close(a.stop)
+		a.stop = nil
+	}
+	if removals := a.appendRemovals(a.buf[:0]); len(removals) > 0 {
+		a.writeLocked(removals)
+	}
+	err := a.f.Sync()
+	if cerr := a.f.Close(); err == nil {
+		err = cerr
+	}
+	a.f = nil
+	return err
+}
+
+// Propagate logs a write that was applied to the store directly, outside
+// the command path, so it survives a restart. args is the command that
+// replays it, e.g. Propagate("SET", key, value).
+func (srv *Server) Propagate(args ...string) {
+	srv.aof.append(args...)
+}
+
+// PropagateChanges logs the changes of a store Update made outside the
+// command path. The records go out in a single write.
+func (srv *Server) PropagateChanges(changes []store.Change) {
+	if len(changes) > 0 {
+		srv.aof.write(appendChanges(nil, changes))
+	}
+}
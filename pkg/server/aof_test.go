@@ -0,0 +1,262 @@
+package server_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/encryption"
+	"github.com/DakshBaxi/RediGo/pkg/redigotest"
+	"github.com/DakshBaxi/RediGo/pkg/server"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// rewrite rewrites the AOF of srv and waits for the rewrite to complete.
+func rewrite(t *testing.T, srv *redigotest.Server) {
+	t.Helper()
+	if err := srv.Server().RewriteAOF(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		info, err := srv.Do("INFO").Fields()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info["aof_rewrite_in_progress"] == "0" && info["aof_rewrites"] != "0" {
+			if info["aof_last_bgrewrite_status"] != "ok" {
+				t.Fatalf("AOF rewrite status %q", info["aof_last_bgrewrite_status"])
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("AOF rewrite did not complete")
+}
+
+// aofParts returns the files in the AOF directory of srv.
+func aofParts(t *testing.T, srv *redigotest.Server) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(srv.Dir(), server.DefaultAOFDirName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestAOFRewriteAndRestart(t *testing.T) {
+	srv := redigotest.Start(t, redigotest.Options{Persist: true})
+	redigotest.AssertOK(t, srv.Do("SET", "a", "1"))
+	redigotest.AssertOK(t, srv.Do("SET", "b", "2"))
+	rewrite(t, srv)
+	// written to the incremental file after the new base
+	redigotest.AssertOK(t, srv.Do("SET", "a", "3"))
+	redigotest.AssertInt(t, srv.Do("DEL", "b"), 1)
+	redigotest.AssertOK(t, srv.Do("SETEX", "c", "100", "4"))
+
+	want := []string{"redigo.aof.1.base.aof", "redigo.aof.2.incr.aof", "redigo.aof.manifest"}
+	if got := aofParts(t, srv); !slices.Equal(got, want) {
+		t.Errorf("AOF directory holds %q, want %q", got, want)
+	}
+
+	srv.Restart()
+	redigotest.AssertString(t, srv.Do("GET", "a"), "3")
+	redigotest.AssertNil(t, srv.Do("GET", "b"))
+	redigotest.AssertString(t, srv.Do("GET", "c"), "4")
+	if ttl, err := srv.Do("TTL", "c").Int(); err != nil || ttl <= 0 || ttl > 100 {
+		t.Errorf("TTL c = %d, %v after restart", ttl, err)
+	}
+
+	// A second rewrite replaces the base and drops the parts it covers.
+	rewrite(t, srv)
+	for _, name := range aofParts(t, srv) {
+		if name == "redigo.aof.1.base.aof" || name == "redigo.aof.2.incr.aof" {
+			t.Errorf("%s was not removed by the rewrite", name)
+		}
+	}
+	srv.Restart()
+	redigotest.AssertString(t, srv.Do("GET", "a"), "3")
+}
+
+func TestEncryptedAOF(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, encryption.KeySize)
+	srv := redigotest.Start(t, redigotest.Options{
+		Persist:   true,
+		Configure: func(o *server.Options) { o.EncryptionKey = key },
+	})
+	redigotest.AssertOK(t, srv.Do("SET", "secret", "hunter2"))
+	rewrite(t, srv)
+	redigotest.AssertOK(t, srv.Do("SET", "other", "swordfish"))
+	srv.Restart()
+	redigotest.AssertString(t, srv.Do("GET", "secret"), "hunter2")
+	redigotest.AssertString(t, srv.Do("GET", "other"), "swordfish")
+
+	dir := filepath.Join(srv.Dir(), server.DefaultAOFDirName)
+	for _, name := range aofParts(t, srv) {
+		if !strings.HasSuffix(name, ".aof") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte("hunter2")) || bytes.Contains(data, []byte("swordfish")) {
+			t.Errorf("%s holds a value in the clear", name)
+		}
+	}
+}
+
+// TestAOFLoadFailures checks that a server refuses to start on data it
+// can't load in full, and leaves the files as they were.
+func TestAOFLoadFailures(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, encryption.KeySize)
+	srv := redigotest.Start(t, redigotest.Options{
+		Persist:   true,
+		Configure: func(o *server.Options) { o.EncryptionKey = key },
+	})
+	redigotest.AssertOK(t, srv.Do("SET", "a", "1"))
+	srv.Restart() // the first incremental file is now sealed and complete
+	dir := srv.Dir()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts := func(key []byte) server.Options {
+		return server.Options{
+			AOFPath:       filepath.Join(dir, "redigo.aof"),
+			EncryptionKey: key,
+			Logger:        logger,
+		}
+	}
+	before := aofParts(t, srv)
+	for _, tc := range []struct {
+		name string
+		key  []byte
+		want error
+	}{
+		{"no key", nil, encryption.ErrNoKey},
+		{"wrong key", bytes.Repeat([]byte{2}, encryption.KeySize), encryption.ErrWrongKey},
+	} {
+		if _, err := server.New(opts(tc.key)); !errors.Is(err, tc.want) {
+			t.Errorf("%s: New = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	if after := aofParts(t, srv); !slices.Equal(after, before) {
+		t.Errorf("failed starts changed the AOF directory from %q to %q", before, after)
+	}
+}
+
+func TestTornAOF(t *testing.T) {
+	// a single-file AOF whose last write never finished
+	dir := t.TempDir()
+	path := filepath.Join(dir, "redigo.aof")
+	if err := os.WriteFile(path, []byte("SET a 1\nSET b 2\nSET c par"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts := server.Options{AOFPath: path, Logger: logger}
+	if _, err := server.New(opts); err == nil {
+		t.Fatal("New loaded an AOF with a truncated record")
+	}
+	if _, err := os.Stat(filepath.Join(dir, server.DefaultAOFDirName)); err == nil {
+		t.Error("a failed start created the AOF directory")
+	}
+	opts.AOFLoadTruncated = true
+	s, err := server.New(opts)
+	if err != nil {
+		t.Fatalf("New with AOFLoadTruncated: %v", err)
+	}
+	defer s.Close()
+	if v, _ := s.Store().Get("b"); v != "2" {
+		t.Errorf("b = %q, want 2", v)
+	}
+	if _, ok := s.Store().Get("c"); ok {
+		t.Error("the truncated record was applied")
+	}
+}
+
+// TestLegacyAOFOverLimit loads a single-file AOF into a store that can't
+// hold it: the start must fail rather than import part of it as the base
+// and delete the file.
+func TestLegacyAOFOverLimit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "redigo.aof")
+	data := []byte("SET a 1\nSET b 2\nSET c 3\n")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	st := store.New()
+	st.SetEvictionPolicy(store.PolicyNoEviction)
+	st.SetMaxKeys(2)
+	if _, err := server.New(server.Options{AOFPath: path, Store: st, Logger: logger}); !errors.Is(err, store.ErrOOM) {
+		t.Fatalf("New = %v, want %v", err, store.ErrOOM)
+	}
+	if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("a failed start changed the AOF to %q, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, server.DefaultAOFDirName)); err == nil {
+		t.Error("a failed start created the AOF directory")
+	}
+
+	// limits set after the load only apply to new writes
+	st = store.New()
+	s, err := server.New(server.Options{AOFPath: path, Store: st, Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	st.SetEvictionPolicy(store.PolicyNoEviction)
+	st.SetMaxKeys(2)
+	if n := st.Len(); n != 3 {
+		t.Errorf("loaded %d keys, want 3", n)
+	}
+	if err := st.Set("d", "4"); !errors.Is(err, store.ErrOOM) {
+		t.Errorf("Set over the limit = %v, want %v", err, store.ErrOOM)
+	}
+}
+
+// TestReplayUnderEviction loads more keys than the store may hold with
+// several replay workers: the keys evicted must be the ones a serial
+// replay evicts, not whichever a worker happened to write first.
+func TestReplayUnderEviction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redigo.aof")
+	var b strings.Builder
+	var want []string
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&b, "SET k%d v\n", i)
+		if i >= 1950 {
+			want = append(want, fmt.Sprintf("k%d", i))
+		}
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	st := store.New()
+	st.SetEvictionPolicy(store.PolicyAllKeysLRU)
+	st.SetMaxKeys(50)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := server.New(server.Options{Store: st, SnapshotPath: path, ReplayWorkers: 8, Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	keys := st.Keys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, want) {
+		t.Errorf("kept %q, want the last 50 keys written", keys)
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// Errors returned by RewriteAOF.
+var (
+	ErrAOFDisabled       = errors.New("redigo: the AOF is disabled")
+	ErrRewriteInProgress = errors.New("redigo: AOF rewrite already in progress")
+)
+
+// rewriteState tracks AOF rewrites for BGREWRITEAOF, the automatic
+// rewrite and INFO. It is guarded by aofLog.mu.
+type rewriteState struct {
+	inProgress bool
+	keep       int // index in manifest.incrs of the first part to keep
+	lastTry    time.Time
+	lastErr    error
+	rewrites   int64
+	baseSize   int64 // AOF size after the last rewrite, or at startup
+
+	closing bool           // set by aofLog.close: no rewrite may start
+	running sync.WaitGroup // the goroutine writing the base, if any
+}
+
+// startRewrite switches writes to a new incremental file and returns the
+// base part the rewrite is to write. Every record logged before the switch
+// is in the store already, so a snapshot of the store taken after it,
+// written as the new base, makes every earlier part redundant.
+func (a *aofLog) startRewrite() (aofPart, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.f == nil || a.rewrite.closing {
+		return aofPart{}, ErrAOFDisabled
+	}
+	if a.rewrite.inProgress {
+		return aofPart{}, ErrRewriteInProgress
+	}
+	a.rewrite.lastTry = time.Now()
+	m := a.manifest
+	next := a.layout.part(m.nextSeq(aofTypeIncr), aofTypeIncr)
+	path := a.layout.path(next)
+	f, w, err := a.openPart(path)
+	if err != nil {
+		a.rewrite.lastErr = err
+		return aofPart{}, err
+	}
+	m.incrs = append(m.incrs, next)
+	if err := m.write(a.layout); err != nil {
+		m.incrs = m.incrs[:len(m.incrs)-1]
+		f.Close()
+		os.Remove(path)
+		a.rewrite.lastErr = err
+		return aofPart{}, err
+	}
+	if err := a.f.Sync(); err != nil {
+		a.log.Error("AOF fsync failed", "path", a.incr, "err", err)
+	}
+	a.f.Close()
+	a.f, a.w, a.incr, a.dirty = f, w, path, false
+	a.rewrite.inProgress = true
+	a.rewrite.running.Add(1)
+	a.rewrite.keep = len(m.incrs) - 1
+	return a.layout.part(m.nextSeq(aofTypeBase), aofTypeBase), nil
+}
+
+// finishRewrite records the outcome of writing base. On success the
+// manifest is replaced by one listing base and the incremental files
+// started since startRewrite, and the parts it drops are deleted, along
+// with a single-file AOF the first base imported.
+func (a *aofLog) finishRewrite(base aofPart, err error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rewrite.inProgress = false
+	if err == nil {
+		next := &aofManifest{base: &base, incrs: append([]aofPart(nil), a.manifest.incrs[a.rewrite.keep:]...)}
+		if err = next.write(a.layout); err == nil {
+			old := a.manifest.parts()
+			if a.manifest.base != nil {
+				old = old[:1+a.rewrite.keep]
+			} else {
+				old = old[:a.rewrite.keep]
+			}
+			a.manifest = next
+			for _, p := range old {
+				if rerr := os.Remove(a.layout.path(p)); rerr != nil {
+					a.log.Warn("removing old AOF part failed", "path", a.layout.path(p), "err", rerr)
+				}
+			}
+			if rerr := os.Remove(a.layout.legacy); rerr == nil {
+				a.log.Info("single-file AOF imported and removed", "path", a.layout.legacy)
+			}
+			a.size = a.partsSize()
+			a.rewrite.baseSize = a.size
+			a.rewrite.rewrites++
+		}
+	}
+	if err != nil {
+		os.Remove(a.layout.path(base))
+	}
+	a.rewrite.lastErr = err
+	return err
+}
+
+// partsSize returns the bytes in every part listed in the manifest. It is
+// called with a.mu held.
+func (a *aofLog) partsSize() int64 {
+	var n int64
+	for _, p := range a.manifest.parts() {
+		if fi, err := os.Stat(a.layout.path(p)); err == nil {
+			n += fi.Size()
+		}
+	}
+	return n
+}
+
+// sizes returns the bytes in the AOF and what they were after the last
+// rewrite.
+func (a *aofLog) sizes() (size, base int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.size, a.rewrite.baseSize
+}
+
+// RewriteAOF starts a background rewrite of the AOF: writes go to a new
+// incremental file while the dataset, as of the switch, is written as the
+// new base. The parts it replaces are deleted once it is complete.
+func (srv *Server) RewriteAOF() error {
+	base, err := srv.aof.startRewrite()
+	if err != nil {
+		return err
+	}
+	snap := srv.store.Snapshot()
+	go func() {
+		defer srv.aof.rewrite.running.Done()
+		defer snap.Close()
+		start := time.Now()
+		path := srv.aof.layout.path(base)
+		keys, err := writeAOFBase(snap, path, srv.opts.EncryptionKey)
+		if err = srv.aof.finishRewrite(base, err); err != nil {
+			srv.log.Error("AOF rewrite failed", "path", path, "err", err)
+			return
+		}
+		srv.log.Info("AOF rewrite done", "path", path, "keys", keys, "duration", time.Since(start))
+	}()
+	return nil
+}
+
+// aofRewriteDue reports whether the AOF has grown enough since the last
+// rewrite for an automatic one: by auto-aof-rewrite-percentage, and to at
+// least auto-aof-rewrite-min-size. None is due while a rewrite is running,
+// or for saveRetryDelay after one failed.
+func (srv *Server) aofRewriteDue(now time.Time) bool {
+	pct := srv.aofRewritePercentage.Load()
+	if pct == 0 || srv.opts.AOFPath == "" {
+		return false
+	}
+	a := srv.aof
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st := &a.rewrite
+	if a.f == nil || st.inProgress || (st.lastErr != nil && now.Sub(st.lastTry) < saveRetryDelay) {
+		return false
+	}
+	if a.size < srv.aofRewriteMinSize.Load() {
+		return false
+	}
+	base := max(st.baseSize, 1)
+	return (a.size-base)*100/base >= pct
+}
+
+// writeAOFBase writes snap to path as an AOF base file. With a key the
+// file is sealed.
+func writeAOFBase(snap *store.Snapshot, path string, key []byte) (int, error) {
+	return writeDataset(snap, path, key, appendEntry)
+}
+
+// appendEntry appends the records that recreate key, holding e, to buf: a
+// SET, and an EXPIREAT if it has a TTL. Snapshots and AOF base files both
+// hold these records.
+func appendEntry(buf []byte, key string, e store.Entry) []byte {
+	buf = appendCommand(buf, "SET", key, e.Value)
+	if e.ExpiresAt > 0 {
+		buf = appendCommand(buf, "EXPIREAT", key, strconv.FormatInt(e.ExpiresAt, 10))
+	}
+	return buf
+}
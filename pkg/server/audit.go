@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+)
+
+// auditUser is the user recorded in the audit log. There is no AUTH yet,
+// so every client is Redis's "default" user.
+const auditUser = "default"
+
+// audit records a write command that c just ran successfully in the audit
+// log, if one is configured. Unlike the AOF, which only keeps what is
+// needed to rebuild the dataset, the audit log says who ran what and from
+// where, with the arguments exactly as received.
+func (srv *Server) audit(c *Session, spec *commandSpec, args []string) {
+	l := srv.opts.AuditLog
+	if l == nil || !spec.hasFlag(flagWrite) || c.replyFailed() {
+		return
+	}
+	l.LogAttrs(context.Background(), slog.LevelInfo, "write",
+		slog.String("user", auditUser),
+		slog.Int64("client_id", c.id),
+		slog.Int64("seq", c.seq),
+		slog.String("client_addr", c.RemoteAddr().String()),
+		slog.String("client_name", c.getName()),
+		slog.String("cmd", spec.name),
+		slog.Any("args", args))
+}
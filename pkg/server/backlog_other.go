@@ -0,0 +1,12 @@
+//go:build !unix
+
+package server
+
+import (
+	"errors"
+	"net"
+)
+
+func setBacklog(ln net.Listener, n int) error {
+	return errors.New("tcp-backlog is not supported on this platform")
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package server
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// setBacklog changes the accept queue length of a listening socket by
+// calling listen(2) again, which Unix kernels allow.
+func setBacklog(ln net.Listener, n int) error {
+	sc, ok := ln.(syscall.Conn)
+	if !ok {
+		return errors.New("listener does not expose its socket")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var lerr error
+	if err := raw.Control(func(fd uintptr) {
+		lerr = syscall.Listen(int(fd), n)
+	}); err != nil {
+		return err
+	}
+	return lerr
+}
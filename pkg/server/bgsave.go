@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/encryption"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// Errors returned by Save.
+var (
+	ErrSnapshotsDisabled = errors.New("redigo: snapshots are disabled")
+	ErrSaveInProgress    = errors.New("redigo: background save already in progress")
+)
+
+// saveRetryDelay is how long save points wait after a failed snapshot
+// before trying again.
+const saveRetryDelay = 5 * time.Second
+
+// SavePoint is a rule for automatic snapshots: BGSAVE once Seconds have
+// passed since the last snapshot and at least Changes writes were made.
+type SavePoint struct {
+	Seconds int64
+	Changes int64
+}
+
+// saveState tracks background saves for BGSAVE, LASTSAVE and INFO.
+type saveState struct {
+	mu         sync.Mutex
+	inProgress bool
+	lastTry    time.Time
+	lastSave   time.Time
+	lastErr    error
+	lastKeys   int
+	changes    int64                             // store.Changes when the last snapshot was taken
+	hooks      []func(path string, at time.Time) // see OnSave
+}
+
+// start marks a save as running; it returns false if one already is.
+func (st *saveState) start() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.inProgress {
+		return false
+	}
+	st.inProgress = true
+	st.lastTry = time.Now()
+	return true
+}
+
+// finish records the outcome of a save of the snapshot taken at at, when
+// the store had made changes writes.
+func (st *saveState) finish(at time.Time, keys int, changes int64, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.inProgress = false
+	st.lastErr = err
+	if err == nil {
+		st.lastSave = at
+		st.lastKeys = keys
+		st.changes = changes
+	}
+}
+
+// Save writes a snapshot of the dataset to Options.SnapshotPath and waits
+// for it to finish. Writers are not blocked meanwhile.
+func (srv *Server) Save() error {
+	if srv.opts.SnapshotPath == "" {
+		return ErrSnapshotsDisabled
+	}
+	if !srv.saves.start() {
+		return ErrSaveInProgress
+	}
+	changes := srv.store.Changes()
+	snap := srv.store.Snapshot()
+	defer snap.Close()
+	keys, err := writeSnapshot(snap, srv.opts.SnapshotPath, srv.opts.EncryptionKey)
+	srv.saves.finish(snap.Time(), keys, changes, err)
+	if err == nil {
+		srv.saved(srv.opts.SnapshotPath, snap.Time())
+	}
+	return err
+}
+
+// OnSave registers fn to be called after every successful snapshot save,
+// by SAVE, BGSAVE or Save, with the snapshot's path and the time it was
+// taken. fn runs on the saving goroutine and should return quickly.
+func (srv *Server) OnSave(fn func(path string, at time.Time)) {
+	srv.saves.mu.Lock()
+	defer srv.saves.mu.Unlock()
+	srv.saves.hooks = append(srv.saves.hooks, fn)
+}
+
+// saved calls the OnSave hooks.
+func (srv *Server) saved(path string, at time.Time) {
+	srv.saves.mu.Lock()
+	hooks := srv.saves.hooks
+	srv.saves.mu.Unlock()
+	for _, fn := range hooks {
+		fn(path, at)
+	}
+}
+
+// bgsave snapshots the store and writes it to path on a separate goroutine.
+// Writers are never blocked for the duration of the write.
+func (srv *Server) bgsave() bool {
+	if !srv.saves.start() {
+		return false
+	}
+	path := srv.opts.SnapshotPath
+	changes := srv.store.Changes()
+	snap := srv.store.Snapshot()
+	go func() {
+		defer snap.Close()
+		start := time.Now()
+		keys, err := writeSnapshot(snap, path, srv.opts.EncryptionKey)
+		srv.saves.finish(snap.Time(), keys, changes, err)
+		if err != nil {
+			srv.log.Error("BGSAVE failed", "path", path, "err", err)
+			return
+		}
+		srv.log.Info("BGSAVE done", "path", path, "keys", keys, "duration", time.Since(start))
+		srv.saved(path, snap.Time())
+	}()
+	return true
+}
+
+// autoSave runs BGSAVE whenever a save point is met, and rewrites the AOF
+// whenever it has grown enough, until the server shuts down.
+func (srv *Server) autoSave() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		if p, ok := srv.savePointMet(time.Now()); ok && srv.bgsave() {
+			srv.log.Info("save point reached, saving in the background", "seconds", p.Seconds, "changes", p.Changes)
+		}
+		if srv.aofRewriteDue(time.Now()) && srv.RewriteAOF() == nil {
+			srv.log.Info("AOF grew past auto-aof-rewrite-percentage, rewriting in the background")
+		}
+	}
+}
+
+// savePointMet returns the first save point met at now. None is met while
+// a save is running, or for saveRetryDelay after one failed.
+func (srv *Server) savePointMet(now time.Time) (SavePoint, bool) {
+	points := *srv.savePoints.Load()
+	if len(points) == 0 || srv.opts.SnapshotPath == "" {
+		return SavePoint{}, false
+	}
+	changes := srv.store.Changes()
+	st := &srv.saves
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.inProgress || (st.lastErr != nil && now.Sub(st.lastTry) < saveRetryDelay) {
+		return SavePoint{}, false
+	}
+	since := st.lastSave
+	if since.IsZero() {
+		since = srv.startTime
+	}
+	dirty := changes - st.changes
+	for _, p := range points {
+		if dirty >= p.Changes && now.Sub(since) >= time.Duration(p.Seconds)*time.Second {
+			return p, true
+		}
+	}
+	return SavePoint{}, false
+}
+
+// writeSnapshot writes snap to a temporary file and renames it over path,
+// so a crash mid-save never leaves a truncated snapshot behind. With a key
+// the snapshot is sealed. Expiries are written as absolute times, so a
+// snapshot loaded later doesn't extend them.
+func writeSnapshot(snap *store.Snapshot, path string, key []byte) (int, error) {
+	return writeDataset(snap, path, key, appendEntry)
+}
+
+// writeDataset writes the records record renders for every key of snap to
+// a temporary file, sealed with key unless it is nil, syncs it and renames
+// it over path. It returns the number of keys written.
+func writeDataset(snap *store.Snapshot, path string, key []byte, record func(buf []byte, key string, e store.Entry) []byte) (int, error) {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp) // no-op after a successful rename
+
+	var out io.Writer = f
+	if key != nil {
+		// Every write becomes a frame; buffer generously to keep them few.
+		if out, err = encryption.NewWriter(f, key); err != nil {
+			f.Close()
+			return 0, err
+		}
+	}
+	w := bufio.NewWriterSize(out, 64<<10)
+	var buf []byte
+	keys := 0
+	err = snap.ForEach(func(k string, e store.Entry) error {
+		keys++
+		buf = record(buf[:0], k, e)
+		_, err := w.Write(buf)
+		return err
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return keys, os.Rename(tmp, path)
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/encryption"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+const (
+	// maxExpireAt is the last unix time a TTL may reach,
+	// 9999-12-31T23:59:59Z; a later expiry is a mangled number rather than
+	// a real one.
+	maxExpireAt = 253402300799
+
+	// maxCheckProblems bounds the problems a FileCheck keeps; the rest are
+	// only counted.
+	maxCheckProblems = 100
+
+	// maxProblemRecord is how much of a bad record a CheckProblem quotes.
+	maxProblemRecord = 80
+)
+
+// CheckProblem is a record CheckPersistence found invalid.
+type CheckProblem struct {
+	Line   int    // 1-based line number
+	Record string // the record, shortened if long
+	Reason string
+}
+
+// FileCheck is what CheckPersistence found in one file.
+type FileCheck struct {
+	Path     string
+	Missing  bool  // the file does not exist, as before the first write
+	Sealed   bool  // the file is encrypted
+	Bytes    int64 // of records, decrypted
+	Records  int
+	Commands map[string]int // valid records by command
+	Invalid  int            // records that are invalid or can't be applied
+	Problems []CheckProblem // the first maxCheckProblems of them
+	// Truncated is set if the last record has no trailing newline, or a
+	// sealed file ends in a partial frame, as after a crash in the middle
+	// of a write. It is counted in Invalid.
+	Truncated bool
+}
+
+// CheckReport is the result of CheckPersistence.
+type CheckReport struct {
+	Files    []FileCheck
+	Keys     int            // keys in the store once every file is loaded
+	Expires  int            // of which with a TTL
+	Memory   int64          // approximate dataset size, as INFO used_memory
+	Types    map[string]int // keys by store.ValueType
+	Evicted  int64          // keys evicted while loading, under s's limits
+	Duration time.Duration
+}
+
+// OK reports whether every record of every file was valid.
+func (r *CheckReport) OK() bool {
+	for _, f := range r.Files {
+		if f.Invalid > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckPersistence loads the snapshot and the AOF parts into s, in the
+// order New does at startup (see Options.AOFPath), but validates every
+// record on the way: its arity, numbers and
+// TTLs, the encoding of probabilistic and time series values, whether the
+// store accepts it, and whether the file ends in a torn write. Startup
+// skips such records; CheckPersistence reports them. Empty paths are
+// skipped. Sealed files are opened with key; a frame that fails to open
+// is reported, and ends the check of its file. The files are only read,
+// so it is safe to run next to a live server. It fails only if a file or
+// the AOF manifest can't be read, or a sealed file without the right key.
+func CheckPersistence(s *store.Store, snapshotPath, aofPath, aofDirName string, key []byte) (*CheckReport, error) {
+	start := time.Now()
+	r := &CheckReport{}
+	files, err := loadOrder(snapshotPath, aofPath, aofDirName)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		fc, err := checkFile(s, path, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		r.Files = append(r.Files, fc)
+	}
+
+	st := s.Stats()
+	r.Keys, r.Expires, r.Memory, r.Evicted = st.Keys, st.Expires, st.UsedMemory, st.Evictions
+	r.Types = make(map[string]int)
+	snap := s.Snapshot()
+	defer snap.Close()
+	snap.ForEach(func(_ string, e store.Entry) error {
+		r.Types[store.ValueType(e.Value)]++
+		return nil
+	})
+	r.Duration = time.Since(start)
+	return r, nil
+}
+
+// checkFile checks and loads one file.
+func checkFile(s *store.Store, path string, key []byte) (FileCheck, error) {
+	fc := FileCheck{Path: path, Commands: make(map[string]int)}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fc.Missing = true
+			return fc, nil
+		}
+		return fc, err
+	}
+	defer f.Close()
+
+	rd := bufio.NewReaderSize(f, 64*1024)
+	// nothing past a frame that fails to open can be read, nor a partial
+	// record before it; startup refuses such a file unless it is only
+	// truncated and aof-load-truncated is set
+	sealedErr := func(line int, err error) (FileCheck, error) {
+		fc.Truncated = errors.Is(err, encryption.ErrTruncated)
+		fc.Invalid++
+		if len(fc.Problems) < maxCheckProblems {
+			fc.Problems = append(fc.Problems, CheckProblem{Line: line, Reason: err.Error()})
+		}
+		return fc, nil
+	}
+	if fc.Sealed = encryption.IsSealed(rd); fc.Sealed {
+		in, err := encryption.NewReader(rd, key)
+		if errors.Is(err, encryption.ErrTruncated) {
+			return sealedErr(1, err)
+		}
+		if err != nil {
+			return fc, err
+		}
+		rd = bufio.NewReaderSize(in, 64*1024)
+	}
+	for line := 1; ; line++ {
+		text, err := rd.ReadString('\n')
+		if errors.Is(err, encryption.ErrTruncated) || errors.Is(err, encryption.ErrCorrupt) {
+			return sealedErr(line, err)
+		}
+		if err != nil && err != io.EOF {
+			return fc, err
+		}
+		if len(text) > maxReplayLine {
+			return fc, fmt.Errorf("line %d: longer than %d bytes", line, maxReplayLine)
+		}
+		fc.Bytes += int64(len(text))
+		if parts := strings.Fields(text); len(parts) > 0 {
+			fc.Records++
+			var bad error
+			if err == io.EOF {
+				// the last write never finished; replay would load a
+				// partial value
+				fc.Truncated = true
+				bad = errors.New("truncated record (no trailing newline)")
+			} else if bad = checkRecord(parts, s.Now().Unix()); bad == nil {
+				bad = replayRecord(s, parts)
+			}
+			if bad == nil {
+				fc.Commands[strings.ToUpper(parts[0])]++
+			} else {
+				fc.Invalid++
+				if len(fc.Problems) < maxCheckProblems {
+					fc.Problems = append(fc.Problems, CheckProblem{Line: line, Record: shortRecord(parts), Reason: bad.Error()})
+				}
+			}
+		}
+		if err == io.EOF {
+			return fc, nil
+		}
+	}
+}
+
+// checkRecord validates what replayRecord takes on trust: that TTLs are
+// positive and expiries in range, and that encoded values decode.
+func checkRecord(parts []string, now int64) error {
+	args := parts[1:]
+	switch strings.ToUpper(parts[0]) {
+	case "SET":
+		if len(args) >= 2 {
+			return store.CheckValue(strings.Join(args[1:], " "))
+		}
+	case "SETEX":
+		if len(args) >= 3 {
+			if err := checkTTL(args[1], now); err != nil {
+				return err
+			}
+			return store.CheckValue(strings.Join(args[2:], " "))
+		}
+	case "EXPIRE":
+		// a TTL of zero or less is allowed here: it deletes the key
+		if len(args) == 2 {
+			ttl, err := strconv.ParseInt(args[1], 10, 64)
+			if err == nil && ttl > maxExpireAt-now {
+				return fmt.Errorf("TTL %d out of range", ttl)
+			}
+		}
+	case "EXPIREAT":
+		if len(args) == 2 {
+			at, err := strconv.ParseInt(args[1], 10, 64)
+			if err == nil && (at < 0 || at > maxExpireAt) {
+				return fmt.Errorf("expiry %d out of range", at)
+			}
+		}
+	}
+	return nil // arity and syntax are left to replayRecord
+}
+
+// checkTTL reports a SETEX TTL, in seconds from now, that is not positive
+// or ends past maxExpireAt. Snapshots only hold live keys, so they never
+// write one that is.
+func checkTTL(arg string, now int64) error {
+	ttl, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return nil
+	}
+	if ttl <= 0 || ttl > maxExpireAt-now {
+		return fmt.Errorf("TTL %d out of range", ttl)
+	}
+	return nil
+}
+
+// shortRecord joins a record back together for a CheckProblem, shortened
+// to maxProblemRecord bytes.
+func shortRecord(parts []string) string {
+	rec := strings.Join(parts, " ")
+	if len(rec) > maxProblemRecord {
+		rec = rec[:maxProblemRecord] + "..."
+	}
+	return rec
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/protocol"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// Session is the state of one client connection: the connection itself,
+// the dataset its commands operate on, how replies are written, and the
+// metadata reported by CLIENT LIST. Command handlers receive it in place
+// of the raw connection, so state that lives as long as the connection
+// has one home.
+type Session struct {
+	net.Conn
+	srv       *Server
+	db        *store.Store // dataset the client's commands operate on
+	id        int64
+	createdAt time.Time
+	log       *slog.Logger // server logger with the client's id and address
+
+	// seq numbers the commands read from the connection, starting at 1.
+	// Together with id it identifies a command in the logs, MONITOR and
+	// the slowlog. Only the connection's goroutine updates it.
+	seq int64
+
+	// Replies are buffered per connection and flushed by the connection's
+	// goroutine between commands. Handlers format them through reply,
+	// which writes to the client.
+	w     *bufio.Writer
+	reply protocol.Reply
+
+	// With CLIENT FRAMING ON, the reply of each command is held in frame
+	// while it runs, then written after a line giving its number of lines,
+	// so a client needn't take the prompt for the end of a reply.
+	framed    bool
+	capturing bool
+	frame     []byte
+
+	// effects holds the AOF records of the running command until the
+	// dispatcher writes them, see effects.go.
+	effects []byte
+
+	mu       sync.Mutex
+	name     string
+	lastCmd  string
+	lastSeen time.Time
+
+	// Reply tracking for the command currently being executed. Only the
+	// connection's own goroutine writes through Write, so no lock is needed.
+	replyStarted bool
+	replyErr     bool
+	replyBytes   int
+}
+
+// Write buffers p for the client, remembering whether the current reply is an
+// error so the dispatcher can count failed calls.
+func (c *Session) Write(p []byte) (int, error) {
+	if !c.replyStarted && len(p) > 0 {
+		c.replyStarted = true
+		c.replyErr = p[0] == '-'
+	}
+	c.replyBytes += len(p)
+	if c.capturing {
+		c.frame = append(c.frame, p...)
+		return len(p), nil
+	}
+	return c.w.Write(p)
+}
+
+// beginFrame starts holding back the reply of the command just read, if
+// the client asked for framed replies.
+func (c *Session) beginFrame() {
+	if c.framed {
+		c.capturing = true
+		c.frame = c.frame[:0]
+	}
+}
+
+// endFrame writes the reply held back since beginFrame, after a "*N" line
+// with its number of lines. It does nothing for an unframed reply.
+func (c *Session) endFrame() {
+	if !c.capturing {
+		return
+	}
+	c.capturing = false
+	fmt.Fprintf(c.w, "*%d\r\n", bytes.Count(c.frame, []byte("\n")))
+	c.w.Write(c.frame)
+	if cap(c.frame) > maxFrameBuffer {
+		c.frame = nil
+	}
+}
+
+// maxFrameBuffer is the largest frame buffer kept for the next reply.
+const maxFrameBuffer = 64 << 10
+
+// replyWriters holds the reply buffers of closed connections for reuse.
+var replyWriters = sync.Pool{New: func() any {
+	return bufio.NewWriter(nil)
+}}
+
+// release returns the reply buffer to the pool once the connection is
+// closed; nothing may be written to c afterwards.
+func (c *Session) release() {
+	c.w.Reset(nil)
+	replyWriters.Put(c.w)
+	c.w = nil
+}
+
+// flush sends any buffered reply data.
+func (c *Session) flush() error {
+	return c.w.Flush()
+}
+
+// beginReply resets reply tracking before a command runs.
+func (c *Session) beginReply() {
+	c.replyStarted = false
+	c.replyErr = false
+	c.replyBytes = 0
+}
+
+// replyFailed reports whether the last command replied with an error.
+func (c *Session) replyFailed() bool {
+	return c.replyErr
+}
+
+// clientInfo is a point-in-time copy of a client's metadata.
+type clientInfo struct {
+	ID      int64
+	Addr    string
+	Name    string
+	Age     time.Duration
+	Idle    time.Duration
+	LastCmd string
+}
+
+func (c *Session) setName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.name = name
+}
+
+func (c *Session) getName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.name
+}
+
+// touch records the command the client is about to run.
+func (c *Session) touch(cmd string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastCmd = cmd
+	c.lastSeen = time.Now()
+}
+
+func (c *Session) info() clientInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	return clientInfo{
+		ID:      c.id,
+		Addr:    c.RemoteAddr().String(),
+		Name:    c.name,
+		Age:     now.Sub(c.createdAt),
+		Idle:    now.Sub(c.lastSeen),
+		LastCmd: strings.ToLower(c.lastCmd),
+	}
+}
+
+// clientRegistry tracks every connected client by id.
+type clientRegistry struct {
+	mu      sync.RWMutex
+	nextID  int64
+	clients map[int64]*Session
+}
+
+// register assigns the connection a new id and starts tracking it.
+func (r *clientRegistry) register(srv *Server, conn net.Conn) *Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	now := time.Now()
+	w := replyWriters.Get().(*bufio.Writer)
+	w.Reset(conn)
+	c := &Session{Conn: conn, srv: srv, db: srv.store, id: r.nextID, createdAt: now, lastSeen: now, w: w}
+	c.reply = protocol.NewText(c)
+	c.log = srv.log.With("client_id", c.id, "client_addr", conn.RemoteAddr().String())
+	r.clients[c.id] = c
+	return c
+}
+
+func (r *clientRegistry) unregister(c *Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, c.id)
+}
+
+// list returns all connected clients ordered by id.
+func (r *clientRegistry) list() []*Session {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make([]*Session, 0, len(r.clients))
+	for _, c := range r.clients {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].id < res[j].id })
+	return res
+}
+
+// kill closes every client matching id (if non-zero) and addr (if non-empty)
+// and returns how many were closed. The owning goroutine notices the closed
+// connection on its next read and cleans up.
+func (r *clientRegistry) kill(id int64, addr string) int {
+	killed := 0
+	for _, c := range r.list() {
+		if id != 0 && c.id != id {
+			continue
+		}
+		if addr != "" && c.RemoteAddr().String() != addr {
+			continue
+		}
+		c.Close()
+		killed++
+	}
+	return killed
+}
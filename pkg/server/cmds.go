@@ -0,0 +1,1679 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/config"
+	"github.com/DakshBaxi/RediGo/internal/protocol"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+func cmdSET(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 2 {
+		r.WriteError("ERR SET requires key and value")
+		return
+	}
+	key := args[0]
+	value := strings.Join(args[1:], " ")
+	if err := c.db.Set(key, value); err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagate("SET", key, value)
+
+	r.WriteSimple("OK")
+}
+
+func cmdSETEX(c *Session, args []string) {
+	r := c.reply
+	// setexx key ttl value
+	if len(args) < 3 {
+		r.WriteError("ERR SETEX requires key, ttl, value")
+		return
+	}
+	key := args[0]
+	ttlStr := args[1]
+	ttl, err := strconv.ParseInt(ttlStr, 10, 64)
+	if err != nil {
+		r.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	if _, ok := expireAt(c.db, ttl); !ok || ttl <= 0 {
+		r.WriteError("ERR invalid expire time in 'setex' command")
+		return
+	}
+	value := strings.Join(args[2:], " ")
+	if err := c.db.Setwithttl(key, value, ttl); err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagateValue(key, value)
+	r.WriteSimple("OK")
+}
+
+func cmdTTL(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 1 {
+		r.WriteError("ERR TTL requires key")
+		return
+	}
+	key := args[0]
+	ttl := c.db.TTL(key)
+	// Redis semantics:
+	// -2: key does not exist
+	// -1: exists, no ttl
+	r.WriteInt(ttl)
+}
+
+func cmdGET(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 1 {
+		r.WriteError("ERR GET requires key")
+		return
+	}
+	key := args[0]
+	if v, ok := c.db.Get(key); ok {
+		r.WriteBulk(v)
+		return
+	}
+	if !c.db.HasLoader() {
+		r.WriteNil()
+		return
+	}
+	// Misses go to the read-through loader. The timeout context is only
+	// set up here, keeping plain reads allocation-light.
+	ctx, cancel := context.WithTimeout(context.Background(), readThroughTimeout)
+	defer cancel()
+	v, ok, err := c.db.GetOrLoad(ctx, key)
+	switch {
+	case err != nil:
+		protocol.Errorf(r, "ERR %v", err)
+	case ok:
+		r.WriteBulk(v)
+	default:
+		r.WriteNil()
+	}
+}
+
+func cmdDEL(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 1 {
+		r.WriteError("ERR DEL requires key")
+		return
+	}
+	key := args[0]
+	if c.db.Del(key) {
+		c.propagate("DEL", key)
+		r.WriteInt(1)
+	} else {
+		r.WriteInt(0)
+	}
+}
+
+// cmdFLUSHALL implements FLUSHALL and FLUSHDB [ASYNC|SYNC]: there is a
+// single database, so both delete every key. ASYNC is accepted for client
+// compatibility; the flush always completes before the reply.
+func cmdFLUSHALL(c *Session, args []string) {
+	r := c.reply
+	if len(args) > 1 || (len(args) == 1 && !strings.EqualFold(args[0], "ASYNC") && !strings.EqualFold(args[0], "SYNC")) {
+		r.WriteError("ERR syntax error, expected ASYNC or SYNC")
+		return
+	}
+	c.db.Reset()
+	c.propagate("FLUSHALL")
+	r.WriteSimple("OK")
+}
+
+func cmdKEYS(c *Session, args []string) {
+	r := c.reply
+	// KEYS [pattern]
+	if len(args) > 1 {
+		r.WriteError("ERR KEYS takes at most one pattern")
+		return
+	}
+	var keys []string
+	if len(args) == 0 {
+		keys = c.db.Keys()
+	} else {
+		pattern := args[0]
+		if _, err := path.Match(pattern, ""); err != nil {
+			protocol.Errorf(r, "ERR invalid pattern '%s'", pattern)
+			return
+		}
+		// only the keys under the literal prefix can match
+		limit := c.srv.maxResults()
+		for _, k := range c.db.KeysWithPrefix(globPrefix(pattern)) {
+			if ok, _ := path.Match(pattern, k); ok {
+				keys = append(keys, k)
+				if limit > 0 && len(keys) > limit {
+					break
+				}
+			}
+		}
+	}
+	if refuseLargeResult(c, "KEYS", len(keys), "keys", "use SCAN to iterate instead") {
+		return
+	}
+	r.WriteArray(keys)
+}
+
+func cmdPING(c *Session, args []string) {
+	r := c.reply
+	if len(args) == 0 {
+		r.WriteSimple("PONG")
+		return
+	}
+	// If a message is passed, echo it (Redis-like)
+	r.WriteBulk(strings.Join(args, " "))
+}
+
+func cmdEXISTS(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 1 {
+		r.WriteError("ERR EXISTS requires key")
+		return
+	}
+	key := args[0]
+	if _, ok := c.db.Get(key); ok {
+		r.WriteInt(1)
+	} else {
+		r.WriteInt(0)
+	}
+}
+
+func cmdHELP(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 0 {
+		r.WriteError("ERR HELP does not take arguments")
+		return
+	}
+	r.WriteArray(strings.Split(store.HelpText(), "\n"))
+}
+
+func cmdQUIT(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 0 {
+		r.WriteError("ERR QUIT does not take arguments")
+		return
+	}
+	r.WriteSimple("OK bye")
+}
+
+func cmdCLIENT(c *Session, args []string) {
+	r := c.reply
+	if len(args) == 0 {
+		r.WriteError("ERR CLIENT requires a subcommand (LIST, ID, KILL, SETNAME, GETNAME, PAUSE, UNPAUSE, FRAMING)")
+		return
+	}
+	sub := strings.ToUpper(args[0])
+	args = args[1:]
+
+	switch sub {
+	case "LIST":
+		if len(args) != 0 {
+			r.WriteError("ERR CLIENT LIST does not take arguments")
+			return
+		}
+		var lines []string
+		for _, other := range c.srv.clients.list() {
+			info := other.info()
+			lines = append(lines, fmt.Sprintf("id=%d addr=%s name=%s age=%d idle=%d omem=%d cmd=%s",
+				info.ID, info.Addr, info.Name,
+				int64(info.Age.Seconds()), int64(info.Idle.Seconds()),
+				c.srv.monitors.pendingBytes(other), info.LastCmd))
+		}
+		r.WriteArray(lines)
+	case "ID":
+		if len(args) != 0 {
+			r.WriteError("ERR CLIENT ID does not take arguments")
+			return
+		}
+		r.WriteInt(c.id)
+	case "SETNAME":
+		if len(args) != 1 {
+			r.WriteError("ERR CLIENT SETNAME requires a name without spaces")
+			return
+		}
+		c.setName(args[0])
+		r.WriteSimple("OK")
+	case "GETNAME":
+		if len(args) != 0 {
+			r.WriteError("ERR CLIENT GETNAME does not take arguments")
+			return
+		}
+		if name := c.getName(); name != "" {
+			r.WriteBulk(name)
+		} else {
+			r.WriteNil()
+		}
+	case "KILL":
+		cmdCLIENTKILL(c, args)
+	case "PAUSE":
+		cmdCLIENTPAUSE(c, args)
+	case "UNPAUSE":
+		if len(args) != 0 {
+			r.WriteError("ERR CLIENT UNPAUSE does not take arguments")
+			return
+		}
+		c.srv.pause.unpause()
+		r.WriteSimple("OK")
+	case "FRAMING":
+		// Takes effect from the next command: this reply is framed as
+		// the one before it.
+		if len(args) != 1 || (!strings.EqualFold(args[0], "ON") && !strings.EqualFold(args[0], "OFF")) {
+			r.WriteError("ERR CLIENT FRAMING requires ON or OFF")
+			return
+		}
+		c.framed = strings.EqualFold(args[0], "ON")
+		r.WriteSimple("OK")
+	default:
+		protocol.Errorf(r, "ERR unknown CLIENT subcommand '%s'", sub)
+	}
+}
+
+// cmdCLIENTKILL supports both the legacy form (CLIENT KILL addr) and the
+// filter form (CLIENT KILL ID id | CLIENT KILL ADDR addr).
+func cmdCLIENTKILL(c *Session, args []string) {
+	r := c.reply
+	switch len(args) {
+	case 1:
+		if c.srv.clients.kill(0, args[0]) == 0 {
+			r.WriteError("ERR No such client")
+			return
+		}
+		r.WriteSimple("OK")
+	case 2:
+		var id int64
+		var addr string
+		switch strings.ToUpper(args[0]) {
+		case "ID":
+			n, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil || n <= 0 {
+				protocol.Errorf(r, "ERR invalid client id '%s'", args[1])
+				return
+			}
+			id = n
+		case "ADDR":
+			addr = args[1]
+		default:
+			r.WriteError("ERR CLIENT KILL filter must be ID or ADDR")
+			return
+		}
+		r.WriteInt(int64(c.srv.clients.kill(id, addr)))
+	default:
+		r.WriteError("ERR CLIENT KILL usage: CLIENT KILL addr | CLIENT KILL ID id | CLIENT KILL ADDR addr")
+	}
+}
+
+// cmdCLIENTPAUSE handles CLIENT PAUSE timeout-ms [WRITE|ALL].
+func cmdCLIENTPAUSE(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 1 || len(args) > 2 {
+		r.WriteError("ERR CLIENT PAUSE usage: CLIENT PAUSE timeout-ms [WRITE|ALL]")
+		return
+	}
+	ms, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || ms < 0 {
+		r.WriteError("ERR timeout is not an integer or out of range")
+		return
+	}
+	writeOnly := false
+	if len(args) == 2 {
+		switch strings.ToUpper(args[1]) {
+		case "WRITE":
+			writeOnly = true
+		case "ALL":
+		default:
+			r.WriteError("ERR CLIENT PAUSE mode must be WRITE or ALL")
+			return
+		}
+	}
+	c.srv.pause.pauseFor(time.Duration(ms)*time.Millisecond, writeOnly)
+	r.WriteSimple("OK")
+}
+
+func cmdMONITOR(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 0 {
+		r.WriteError("ERR MONITOR does not take arguments")
+		return
+	}
+	r.WriteSimple("OK")
+	// The stream bypasses the reply buffer, so send +OK ahead of it.
+	c.endFrame()
+	c.flush()
+	c.srv.monitors.add(c)
+}
+
+func cmdCOMMAND(c *Session, args []string) {
+	r := c.reply
+	if len(args) == 0 {
+		var lines []string
+		for _, spec := range sortedCommands() {
+			lines = append(lines, commandInfo(spec))
+		}
+		r.WriteArray(lines)
+		return
+	}
+	sub := strings.ToUpper(args[0])
+	args = args[1:]
+
+	switch sub {
+	case "COUNT":
+		if len(args) != 0 {
+			r.WriteError("ERR COMMAND COUNT does not take arguments")
+			return
+		}
+		r.WriteInt(int64(len(commands)))
+	case "INFO":
+		specs := sortedCommands()
+		if len(args) != 0 {
+			specs = specs[:0]
+			for _, name := range args {
+				specs = append(specs, commands[strings.ToUpper(name)])
+			}
+		}
+		lines := make([]string, len(specs))
+		for i, spec := range specs {
+			if spec == nil {
+				lines[i] = "(nil)"
+				continue
+			}
+			lines[i] = commandInfo(spec)
+		}
+		r.WriteArray(lines)
+	case "DOCS":
+		specs := sortedCommands()
+		if len(args) != 0 {
+			specs = specs[:0]
+			for _, name := range args {
+				if spec, ok := commands[strings.ToUpper(name)]; ok {
+					specs = append(specs, spec)
+				}
+			}
+		}
+		lines := make([]string, len(specs))
+		for i, spec := range specs {
+			lines[i] = strings.ToLower(spec.name) + ": " + spec.summary
+		}
+		r.WriteArray(lines)
+	default:
+		protocol.Errorf(r, "ERR unknown COMMAND subcommand '%s'", sub)
+	}
+}
+
+// expireAt turns a TTL in seconds into the unix time it ends at, by the
+// store's clock. It reports false if that doesn't fit in an int64.
+func expireAt(s *store.Store, ttl int64) (int64, bool) {
+	now := s.Now().Unix()
+	if (ttl > 0 && ttl > math.MaxInt64-now) || (ttl < 0 && ttl < math.MinInt64+now) {
+		return 0, false
+	}
+	return now + ttl, true
+}
+
+// EXPIRE key ttl sets a TTL in seconds on key, replying :1, or :0 if the
+// key doesn't exist. A TTL of 0 or less deletes the key.
+func cmdEXPIRE(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 2 {
+		r.WriteError("ERR EXPIRE requires key and ttl")
+		return
+	}
+	ttl, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		r.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	at, ok := expireAt(c.db, ttl)
+	if !ok {
+		r.WriteError("ERR invalid expire time in 'expire' command")
+		return
+	}
+	expireKey(c, args[0], at)
+}
+
+// EXPIREAT key timestamp makes key expire at a unix time in seconds,
+// replying like EXPIRE. A time in the past deletes the key.
+func cmdEXPIREAT(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 2 {
+		r.WriteError("ERR EXPIREAT requires key and timestamp")
+		return
+	}
+	at, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		r.WriteError("ERR value is not an integer or out of range")
+		return
+	}
+	expireKey(c, args[0], at)
+}
+
+// expireKey applies EXPIRE and EXPIREAT. The AOF records the absolute
+// time, so replaying the log later doesn't extend the TTL, or a DEL when
+// the key was deleted.
+func expireKey(c *Session, key string, at int64) {
+	r := c.reply
+	deletes := at <= c.db.Now().Unix()
+	if !c.db.ExpireAt(key, at) {
+		r.WriteInt(0)
+		return
+	}
+	if deletes {
+		c.propagate("DEL", key)
+	} else {
+		c.propagate("EXPIREAT", key, strconv.FormatInt(at, 10))
+	}
+	r.WriteInt(1)
+}
+
+// PERSIST key removes the TTL of key, replying :1, or :0 if the key
+// doesn't exist or has no TTL.
+func cmdPERSIST(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 1 {
+		r.WriteError("ERR PERSIST requires key")
+		return
+	}
+	if !c.db.Persist(args[0]) {
+		r.WriteInt(0)
+		return
+	}
+	c.propagate("PERSIST", args[0])
+	r.WriteInt(1)
+}
+
+func cmdINCR(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 1 {
+		r.WriteError("ERR INCR requires key")
+		return
+	}
+	incrBy(c, args[0], 1)
+}
+
+func cmdDECR(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 1 {
+		r.WriteError("ERR DECR requires key")
+		return
+	}
+	incrBy(c, args[0], -1)
+}
+
+// incrBy implements INCR and DECR. A missing key counts as 0.
+func incrBy(c *Session, key string, delta int64) {
+	r := c.reply
+	num, err := c.db.IncrBy(key, delta)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagateValue(key, strconv.FormatInt(num, 10))
+
+	// Redis returns the new value as integer reply
+	r.WriteInt(num)
+}
+
+func cmdCONFIG(c *Session, args []string) {
+	r := c.reply
+	// CONFIG GET pattern | CONFIG SET name value | CONFIG REWRITE |
+	// CONFIG RESETSTAT, plus the older CONFIG name value shorthand for SET
+	srv := c.srv
+	if len(args) == 1 && strings.ToUpper(args[0]) == "RESETSTAT" {
+		srv.resetStats()
+		r.WriteSimple("OK")
+		return
+	}
+	if len(args) == 1 && strings.ToUpper(args[0]) == "REWRITE" {
+		if err := srv.ConfigRewrite(); err != nil {
+			r.WriteError("ERR " + err.Error())
+			return
+		}
+		r.WriteSimple("OK")
+		return
+	}
+	if len(args) == 2 && strings.ToUpper(args[0]) == "GET" {
+		r.WriteArray(srv.ConfigGet(args[1]))
+		return
+	}
+	if len(args) >= 3 && strings.ToUpper(args[0]) == "SET" {
+		// values of several words, such as client-output-buffer-limit
+		args = []string{args[1], strings.Join(args[2:], " ")}
+	}
+	if len(args) != 2 {
+		r.WriteError("ERR CONFIG usage: CONFIG GET pattern | CONFIG SET name value | CONFIG REWRITE | CONFIG RESETSTAT")
+		return
+	}
+	if err := srv.ConfigSet(args[0], args[1]); err != nil {
+		r.WriteError("ERR " + err.Error())
+		return
+	}
+	r.WriteSimple("OK")
+}
+
+func cmdBGSAVE(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 0 {
+		r.WriteError("ERR BGSAVE does not take arguments")
+		return
+	}
+	srv := c.srv
+	if srv.opts.SnapshotPath == "" {
+		r.WriteError("ERR snapshots are disabled")
+		return
+	}
+	if !srv.bgsave() {
+		r.WriteError("ERR Background save already in progress")
+		return
+	}
+	r.WriteSimple("Background saving started")
+}
+
+func cmdBGREWRITEAOF(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 0 {
+		r.WriteError("ERR BGREWRITEAOF does not take arguments")
+		return
+	}
+	switch err := c.srv.RewriteAOF(); err {
+	case nil:
+		r.WriteSimple("Background append only file rewriting started")
+	case ErrAOFDisabled:
+		r.WriteError("ERR the AOF is disabled")
+	case ErrRewriteInProgress:
+		r.WriteError("ERR Background append only file rewriting already in progress")
+	default:
+		r.WriteError("ERR " + err.Error())
+	}
+}
+
+func cmdSAVE(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 0 {
+		r.WriteError("ERR SAVE does not take arguments")
+		return
+	}
+	switch err := c.srv.Save(); err {
+	case nil:
+		r.WriteSimple("OK")
+	case ErrSnapshotsDisabled:
+		r.WriteError("ERR snapshots are disabled")
+	case ErrSaveInProgress:
+		r.WriteError("ERR Background save already in progress")
+	default:
+		r.WriteError("ERR " + err.Error())
+	}
+}
+
+func cmdLASTSAVE(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 0 {
+		r.WriteError("ERR LASTSAVE does not take arguments")
+		return
+	}
+	srv := c.srv
+	srv.saves.mu.Lock()
+	last := srv.saves.lastSave
+	srv.saves.mu.Unlock()
+	var ts int64
+	if !last.IsZero() {
+		ts = last.Unix()
+	}
+	r.WriteInt(ts)
+}
+
+func cmdMEMORY(c *Session, args []string) {
+	r := c.reply
+	if len(args) == 0 {
+		r.WriteError("ERR MEMORY requires a subcommand (USAGE, STATS, PURGE)")
+		return
+	}
+	switch strings.ToUpper(args[0]) {
+	case "USAGE":
+		if len(args) != 2 && (len(args) != 3 || !strings.EqualFold(args[2], "DETAIL")) {
+			r.WriteError("ERR MEMORY USAGE requires key and an optional DETAIL")
+			return
+		}
+		m, ok := c.db.KeyMemory(args[1])
+		switch {
+		case !ok:
+			r.WriteNil()
+		case len(args) == 3:
+			r.WriteArray([]string{
+				fmt.Sprintf("bytes:%d", m.Bytes),
+				fmt.Sprintf("raw_bytes:%d", m.RawBytes),
+				fmt.Sprintf("compressed:%d", boolInt(m.Compressed)),
+			})
+		default:
+			r.WriteInt(m.Bytes)
+		}
+	case "STATS":
+		if len(args) != 1 {
+			r.WriteError("ERR MEMORY STATS does not take arguments")
+			return
+		}
+		st := c.db.MemoryStats()
+		stats := c.db.Stats()
+		lines := []string{
+			fmt.Sprintf("keys.count:%d", st.Keys),
+			fmt.Sprintf("dataset.bytes:%d", st.DatasetBytes),
+			fmt.Sprintf("overhead.total:%d", st.OverheadBytes),
+			fmt.Sprintf("total.bytes:%d", st.TotalBytes()),
+			fmt.Sprintf("compressed.keys:%d", stats.CompressedKeys),
+			fmt.Sprintf("compressed.raw_bytes:%d", stats.CompressedRawBytes),
+			fmt.Sprintf("compressed.bytes:%d", stats.CompressedBytes),
+		}
+		names := make([]string, 0, len(st.Types))
+		for name := range st.Types {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ts := st.Types[name]
+			lines = append(lines,
+				fmt.Sprintf("type.%s.keys:%d", name, ts.Keys),
+				fmt.Sprintf("type.%s.bytes:%d", name, ts.Bytes))
+		}
+		r.WriteArray(lines)
+	case "PURGE":
+		// Rebuild the key map now, however sparse, and hand freed memory
+		// back to the OS.
+		if len(args) != 1 {
+			r.WriteError("ERR MEMORY PURGE does not take arguments")
+			return
+		}
+		n := c.db.Defrag(true)
+		debug.FreeOSMemory()
+		r.WriteInt(n)
+	default:
+		protocol.Errorf(r, "ERR unknown MEMORY subcommand '%s'", args[0])
+	}
+}
+
+// cmdQUOTA implements the namespace quota subcommands:
+//
+//	QUOTA SET namespace maxkeys maxmemory - limit a namespace (0 = no limit)
+//	QUOTA DEL namespace                   - remove a namespace's quota
+//	QUOTA LIST                            - show every quota and its usage
+func cmdQUOTA(c *Session, args []string) {
+	r := c.reply
+	if len(args) == 0 {
+		r.WriteError("ERR QUOTA requires a subcommand (SET, DEL, LIST)")
+		return
+	}
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		if len(args) != 4 {
+			r.WriteError("ERR QUOTA SET requires namespace, maxkeys and maxmemory")
+			return
+		}
+		keys, err := strconv.Atoi(args[2])
+		if err != nil || keys < 0 {
+			protocol.Errorf(r, "ERR invalid maxkeys '%s'", args[2])
+			return
+		}
+		mem, err := config.ParseSize(args[3])
+		if err != nil {
+			protocol.Errorf(r, "ERR invalid maxmemory '%s'", args[3])
+			return
+		}
+		c.db.SetQuota(args[1], store.Quota{MaxKeys: keys, MaxMemory: mem})
+		r.WriteSimple("OK")
+	case "DEL":
+		if len(args) != 2 {
+			r.WriteError("ERR QUOTA DEL requires namespace")
+			return
+		}
+		c.db.SetQuota(args[1], store.Quota{})
+		r.WriteSimple("OK")
+	case "LIST":
+		if len(args) != 1 {
+			r.WriteError("ERR QUOTA LIST does not take arguments")
+			return
+		}
+		quotas := c.db.Quotas()
+		lines := make([]string, len(quotas))
+		for i, q := range quotas {
+			lines[i] = fmt.Sprintf("%s keys=%d/%d bytes=%d/%d", q.Namespace, q.Keys, q.MaxKeys, q.Bytes, q.MaxMemory)
+		}
+		r.WriteArray(lines)
+	default:
+		protocol.Errorf(r, "ERR unknown QUOTA subcommand '%s'", args[0])
+	}
+}
+
+func cmdDUMPALL(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 0 {
+		r.WriteError("ERR DUMPALL does not take arguments")
+		return
+	}
+	// Replicas sync through DUMPALL, so this is where replication faults
+	// are injected.
+	faults := &c.srv.faults
+	faults.delay(&faults.replStall)
+	cmds := c.db.DumpCommands()
+	lines := make([]string, 0, len(cmds)+1)
+	for _, line := range cmds {
+		if faults.hit(&faults.replDrop) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	r.WriteArray(append(lines, ".")) // terminator
+}
+
+
+func cmdINFO(c *Session, args []string) {
+	r := c.reply
+	if len(args) > 1 {
+		r.WriteError("ERR INFO takes at most one section")
+		return
+	}
+	section := "default"
+	if len(args) == 1 {
+		section = strings.ToLower(args[0])
+	}
+	if section == "json" {
+		// every counter as one document, for dashboards and scripts
+		b, err := json.Marshal(c.srv.Vars())
+		if err != nil {
+			r.WriteError("ERR " + err.Error())
+			return
+		}
+		r.WriteBulk(string(b))
+		return
+	}
+	if !c.srv.writeInfo(c, section) {
+		protocol.Errorf(r, "ERR unknown INFO section '%s'", args[0])
+	}
+}
+
+func cmdHEALTHCHECK(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 0 {
+		r.WriteError("ERR HEALTHCHECK takes no arguments")
+		return
+	}
+	c.srv.Health(context.Background()).WriteTo(c)
+}
+
+// LCS key1 key2 [LEN] [IDX] [MINMATCHLEN len] [WITHMATCHLEN]
+func cmdLCS(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 2 {
+		r.WriteError("ERR LCS requires two keys")
+		return
+	}
+	var wantLen, idx, withMatchLen bool
+	minLen := 0
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "LEN":
+			wantLen = true
+		case "IDX":
+			idx = true
+		case "WITHMATCHLEN":
+			withMatchLen = true
+		case "MINMATCHLEN":
+			if i+1 == len(args) {
+				r.WriteError("ERR MINMATCHLEN requires a length")
+				return
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				protocol.Errorf(r, "ERR invalid MINMATCHLEN value '%s'", args[i+1])
+				return
+			}
+			minLen = max(n, 0)
+			i++
+		default:
+			protocol.Errorf(r, "ERR syntax error near '%s'", args[i])
+			return
+		}
+	}
+	if wantLen && idx {
+		r.WriteError("ERR LEN and IDX are mutually exclusive")
+		return
+	}
+
+	// missing keys count as empty strings
+	a, _ := c.db.Get(args[0])
+	b, _ := c.db.Get(args[1])
+	seq, matches, err := lcs(a, b, minLen)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	switch {
+	case wantLen:
+		r.WriteInt(int64(len(seq)))
+	case idx:
+		// one "match:<a range> <b range>" line per match, then the length
+		lines := make([]string, 0, len(matches)+1)
+		for _, m := range matches {
+			line := fmt.Sprintf("match:%d-%d %d-%d", m.aStart, m.aEnd, m.bStart, m.bEnd)
+			if withMatchLen {
+				line += fmt.Sprintf(" len=%d", m.len())
+			}
+			lines = append(lines, line)
+		}
+		r.WriteArray(append(lines, fmt.Sprintf("len:%d", len(seq))))
+	default:
+		r.WriteBulk(seq)
+	}
+}
+
+// JSON.SET key path value [NX|XX]
+func cmdJSONSET(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 3 {
+		r.WriteError("ERR JSON.SET requires key, path and value")
+		return
+	}
+	mode := store.JSONSetAlways
+	switch strings.ToUpper(args[len(args)-1]) {
+	case "NX":
+		mode, args = store.JSONSetNX, args[:len(args)-1]
+	case "XX":
+		mode, args = store.JSONSetXX, args[:len(args)-1]
+	}
+	if len(args) < 3 {
+		r.WriteError("ERR JSON.SET requires key, path and value")
+		return
+	}
+	key := args[0]
+	// like SET, the value is the rest of the line
+	doc, ok, err := c.db.JSONSet(key, args[1], strings.Join(args[2:], " "), mode)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if !ok {
+		r.WriteNil()
+		return
+	}
+	// log the whole document, so replay does not depend on the old one
+	c.propagateValue(key, doc)
+	r.WriteSimple("OK")
+}
+
+// JSON.GET key [path]
+func cmdJSONGET(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 1 || len(args) > 2 {
+		r.WriteError("ERR JSON.GET requires a key and an optional path")
+		return
+	}
+	path := "$"
+	if len(args) == 2 {
+		path = args[1]
+	}
+	v, ok, err := c.db.JSONGet(args[0], path)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if ok {
+		r.WriteBulk(v)
+	} else {
+		r.WriteNil()
+	}
+}
+
+// JSON.DEL key [path]
+func cmdJSONDEL(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 1 || len(args) > 2 {
+		r.WriteError("ERR JSON.DEL requires a key and an optional path")
+		return
+	}
+	key, path := args[0], "$"
+	if len(args) == 2 {
+		path = args[1]
+	}
+	doc, ok, err := c.db.JSONDel(key, path)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if !ok {
+		r.WriteInt(0)
+		return
+	}
+	if doc == "" {
+		c.propagate("DEL", key)
+	} else {
+		c.propagateValue(key, doc)
+	}
+	r.WriteInt(1)
+}
+
+// BF.RESERVE key error_rate capacity
+func cmdBFRESERVE(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 3 {
+		r.WriteError("ERR BF.RESERVE requires key, error_rate and capacity")
+		return
+	}
+	rate, err1 := strconv.ParseFloat(args[1], 64)
+	capacity, err2 := strconv.ParseUint(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		r.WriteError(store.ErrFilterParams.Error())
+		return
+	}
+	if err := c.db.BFReserve(args[0], rate, capacity); err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagate("BF.RESERVE", args[0], args[1], args[2])
+	r.WriteSimple("OK")
+}
+
+// BF.ADD key item and BF.MADD key item [item ...]. Adding an item that may
+// already be present is a no-op, so the command itself is logged.
+func cmdBFADD(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 2 {
+		r.WriteError("ERR BF.ADD requires key and item")
+		return
+	}
+	bfAdd(c, "BF.ADD", args, false)
+}
+
+func cmdBFMADD(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 2 {
+		r.WriteError("ERR BF.MADD requires key and at least one item")
+		return
+	}
+	bfAdd(c, "BF.MADD", args, true)
+}
+
+func bfAdd(c *Session, name string, args []string, multi bool) {
+	r := c.reply
+	added, err := c.db.BFAdd(args[0], args[1:]...)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if slices.Contains(added, true) {
+		c.propagate(append([]string{name}, args...)...)
+	}
+	writeFlags(c, added, multi)
+}
+
+// BF.EXISTS key item and BF.MEXISTS key item [item ...]
+func cmdBFEXISTS(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 2 {
+		r.WriteError("ERR BF.EXISTS requires key and item")
+		return
+	}
+	found, err := c.db.BFExists(args[0], args[1])
+	replyFlags(c, found, err, false)
+}
+
+func cmdBFMEXISTS(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 2 {
+		r.WriteError("ERR BF.MEXISTS requires key and at least one item")
+		return
+	}
+	found, err := c.db.BFExists(args[0], args[1:]...)
+	replyFlags(c, found, err, true)
+}
+
+// CF.RESERVE key capacity
+func cmdCFRESERVE(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 2 {
+		r.WriteError("ERR CF.RESERVE requires key and capacity")
+		return
+	}
+	capacity, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		r.WriteError(store.ErrFilterParams.Error())
+		return
+	}
+	if err := c.db.CFReserve(args[0], capacity); err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagate("CF.RESERVE", args[0], args[1])
+	r.WriteSimple("OK")
+}
+
+// CF.ADD key item. Cuckoo filters keep duplicates, so CF.ADD is not
+// idempotent, but it relocates entries deterministically: like every
+// sketch write, the command is logged and replayed.
+func cmdCFADD(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 2 {
+		r.WriteError("ERR CF.ADD requires key and item")
+		return
+	}
+	if err := c.db.CFAdd(args[0], args[1]); err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagate("CF.ADD", args[0], args[1])
+	r.WriteInt(1)
+}
+
+// CF.DEL key item
+func cmdCFDEL(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 2 {
+		r.WriteError("ERR CF.DEL requires key and item")
+		return
+	}
+	ok, err := c.db.CFDel(args[0], args[1])
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if !ok {
+		r.WriteInt(0)
+		return
+	}
+	c.propagate("CF.DEL", args[0], args[1])
+	r.WriteInt(1)
+}
+
+// CF.EXISTS key item and CF.MEXISTS key item [item ...]
+func cmdCFEXISTS(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 2 {
+		r.WriteError("ERR CF.EXISTS requires key and item")
+		return
+	}
+	found, err := c.db.CFExists(args[0], args[1])
+	replyFlags(c, found, err, false)
+}
+
+func cmdCFMEXISTS(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 2 {
+		r.WriteError("ERR CF.MEXISTS requires key and at least one item")
+		return
+	}
+	found, err := c.db.CFExists(args[0], args[1:]...)
+	replyFlags(c, found, err, true)
+}
+
+func replyFlags(c *Session, flags []bool, err error, multi bool) {
+	r := c.reply
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	writeFlags(c, flags, multi)
+}
+
+// writeFlags replies :1 or :0 per flag, one per line for the multi-item
+// forms of a command.
+func writeFlags(c *Session, flags []bool, multi bool) {
+	r := c.reply
+	if !multi {
+		flags = flags[:1]
+	}
+	for _, f := range flags {
+		if f {
+			r.WriteInt(1)
+		} else {
+			r.WriteInt(0)
+		}
+	}
+}
+
+// CMS.INITBYDIM key width depth
+func cmdCMSINITBYDIM(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 3 {
+		r.WriteError("ERR CMS.INITBYDIM requires key, width and depth")
+		return
+	}
+	width, err1 := strconv.ParseUint(args[1], 10, 64)
+	depth, err2 := strconv.ParseUint(args[2], 10, 64)
+	if err1 != nil || err2 != nil {
+		r.WriteError(store.ErrSketchParams.Error())
+		return
+	}
+	cmsInit(c, args[0], width, depth)
+}
+
+// CMS.INITBYPROB key error probability
+func cmdCMSINITBYPROB(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 3 {
+		r.WriteError("ERR CMS.INITBYPROB requires key, error and probability")
+		return
+	}
+	rate, err1 := strconv.ParseFloat(args[1], 64)
+	prob, err2 := strconv.ParseFloat(args[2], 64)
+	width, depth, ok := store.CMSDims(rate, prob)
+	if err1 != nil || err2 != nil || !ok {
+		r.WriteError("ERR error and probability must be between 0 and 1")
+		return
+	}
+	cmsInit(c, args[0], width, depth)
+}
+
+// cmsInit creates the sketch, logged as CMS.INITBYDIM whichever command
+// sized it.
+func cmsInit(c *Session, key string, width, depth uint64) {
+	r := c.reply
+	if err := c.db.CMSInit(key, width, depth); err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagate("CMS.INITBYDIM", key, strconv.FormatUint(width, 10), strconv.FormatUint(depth, 10))
+	r.WriteSimple("OK")
+}
+
+// CMS.INCRBY key item increment [item increment ...]
+func cmdCMSINCRBY(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 3 || len(args)%2 == 0 {
+		r.WriteError("ERR CMS.INCRBY requires key and item/increment pairs")
+		return
+	}
+	var items []string
+	var incrs []uint64
+	for i := 1; i < len(args); i += 2 {
+		n, err := strconv.ParseUint(args[i+1], 10, 64)
+		if err != nil {
+			protocol.Errorf(r, "ERR invalid increment '%s'", args[i+1])
+			return
+		}
+		items, incrs = append(items, args[i]), append(incrs, n)
+	}
+	counts, err := c.db.CMSIncrBy(args[0], items, incrs)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagate(append([]string{"CMS.INCRBY"}, args...)...)
+	for _, n := range counts {
+		r.WriteInt(int64(n))
+	}
+}
+
+// CMS.QUERY key item [item ...]
+func cmdCMSQUERY(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 2 {
+		r.WriteError("ERR CMS.QUERY requires key and at least one item")
+		return
+	}
+	counts, err := c.db.CMSQuery(args[0], args[1:]...)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	for _, n := range counts {
+		r.WriteInt(int64(n))
+	}
+}
+
+// TOPK.RESERVE key topk [width depth decay]
+func cmdTOPKRESERVE(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 2 && len(args) != 5 {
+		r.WriteError("ERR TOPK.RESERVE requires key and topk, optionally followed by width, depth and decay")
+		return
+	}
+	k, err := strconv.ParseUint(args[1], 10, 64)
+	width, depth, decay := uint64(store.TopKDefaultWidth), uint64(store.TopKDefaultDepth), store.TopKDefaultDecay
+	if err == nil && len(args) == 5 {
+		var err1, err2 error
+		width, err = strconv.ParseUint(args[2], 10, 64)
+		depth, err1 = strconv.ParseUint(args[3], 10, 64)
+		decay, err2 = strconv.ParseFloat(args[4], 64)
+		err = errors.Join(err, err1, err2)
+	}
+	if err != nil {
+		r.WriteError(store.ErrSketchParams.Error())
+		return
+	}
+	if err := c.db.TopKReserve(args[0], k, width, depth, decay); err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	// logged with every parameter, so the defaults can change
+	c.propagate("TOPK.RESERVE", args[0], args[1], strconv.FormatUint(width, 10),
+		strconv.FormatUint(depth, 10), strconv.FormatFloat(decay, 'g', -1, 64))
+	r.WriteSimple("OK")
+}
+
+// TOPK.ADD key item [item ...] replies, per item, the item it pushed out
+// of the list or (nil).
+func cmdTOPKADD(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 2 {
+		r.WriteError("ERR TOPK.ADD requires key and at least one item")
+		return
+	}
+	expelled, err := c.db.TopKAdd(args[0], args[1:]...)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagate(append([]string{"TOPK.ADD"}, args...)...)
+	for _, item := range expelled {
+		if item == "" {
+			r.WriteNil()
+		} else {
+			r.WriteBulk(item)
+		}
+	}
+}
+
+// TOPK.LIST key [WITHCOUNT]
+func cmdTOPKLIST(c *Session, args []string) {
+	r := c.reply
+	withCount := len(args) == 2 && strings.ToUpper(args[1]) == "WITHCOUNT"
+	if len(args) != 1 && !withCount {
+		r.WriteError("ERR TOPK.LIST requires a key and optionally WITHCOUNT")
+		return
+	}
+	items, err := c.db.TopKList(args[0])
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	lines := make([]string, len(items))
+	for i, it := range items {
+		if withCount {
+			lines[i] = it.Item + " " + strconv.FormatUint(it.Count, 10)
+		} else {
+			lines[i] = it.Item
+		}
+	}
+	r.WriteArray(lines)
+}
+
+// TOPK.QUERY key item [item ...]
+func cmdTOPKQUERY(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 2 {
+		r.WriteError("ERR TOPK.QUERY requires key and at least one item")
+		return
+	}
+	found, err := c.db.TopKQuery(args[0], args[1:]...)
+	replyFlags(c, found, err, true)
+}
+
+// TS.CREATE key [RETENTION ms]
+func cmdTSCREATE(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 1 && len(args) != 3 {
+		r.WriteError("ERR TS.CREATE requires a key and optionally RETENTION ms")
+		return
+	}
+	ret, err := parseRetention(args[1:])
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if err := c.db.TSCreate(args[0], ret); err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagate(append([]string{"TS.CREATE"}, args...)...)
+	r.WriteSimple("OK")
+}
+
+// TS.ADD key timestamp|* value [RETENTION ms]. The command is logged with
+// the timestamp it resolved to; re-adding a sample fails harmlessly on replay.
+func cmdTSADD(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 3 && len(args) != 5 {
+		r.WriteError("ERR TS.ADD requires key, timestamp and value, and optionally RETENTION ms")
+		return
+	}
+	logged := slices.Clone(args)
+	if args[1] == "*" {
+		logged[1] = strconv.FormatInt(time.Now().UnixMilli(), 10)
+	}
+	ts, v, ret, err := parseTSAdd(logged)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if err := c.db.TSAdd(args[0], ts, v, ret); err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	c.propagate(append([]string{"TS.ADD"}, logged...)...)
+	r.WriteInt(ts)
+}
+
+// TS.GET key
+func cmdTSGET(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 1 {
+		r.WriteError("ERR TS.GET requires a key")
+		return
+	}
+	smp, ok, err := c.db.TSGet(args[0])
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if !ok {
+		r.WriteArray(nil)
+		return
+	}
+	r.WriteArray([]string{formatSample(smp)})
+}
+
+// TS.RANGE key from|- to|+ [AGGREGATION avg|sum|min|max|count bucket_ms]
+func cmdTSRANGE(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 3 && len(args) != 6 {
+		r.WriteError("ERR TS.RANGE requires key, from and to, and optionally AGGREGATION type bucket")
+		return
+	}
+	from, err1 := parseRangeBound(args[1], 0)
+	to, err2 := parseRangeBound(args[2], store.MaxTSTimestamp)
+	if err1 != nil || err2 != nil {
+		r.WriteError(store.ErrTSTimestamp.Error())
+		return
+	}
+	agg, bucket := store.AggNone, int64(0)
+	if len(args) == 6 {
+		var ok bool
+		agg, ok = store.ParseAggregation(args[4])
+		b, err := strconv.ParseInt(args[5], 10, 64)
+		if strings.ToUpper(args[3]) != "AGGREGATION" || !ok || err != nil || b <= 0 {
+			r.WriteError("ERR expected AGGREGATION avg|sum|min|max|count and a positive bucket size")
+			return
+		}
+		bucket = b
+	}
+	samples, err := c.db.TSRange(args[0], from, to, agg, bucket)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if refuseLargeResult(c, "TS.RANGE", len(samples), "samples", "narrow the range or use AGGREGATION") {
+		return
+	}
+	lines := make([]string, len(samples))
+	for i, smp := range samples {
+		lines[i] = formatSample(smp)
+	}
+	r.WriteArray(lines)
+}
+
+// formatSample renders smp as a "timestamp value" line.
+func formatSample(smp store.Sample) string {
+	return strconv.FormatInt(smp.Timestamp, 10) + " " + strconv.FormatFloat(smp.Value, 'f', -1, 64)
+}
+
+// parseRangeBound parses a TS.RANGE bound, where "-" and "+" stand for
+// the oldest and newest possible timestamps.
+func parseRangeBound(arg string, open int64) (int64, error) {
+	if arg == "-" || arg == "+" {
+		return open, nil
+	}
+	return strconv.ParseInt(arg, 10, 64)
+}
+
+// THROTTLE key max_burst count period [quantity] rate limits key to count
+// requests per period seconds with bursts of max_burst. Like redis-cell it
+// replies limited (0/1), the limit, the remaining requests, and the
+// seconds until a retry is allowed (-1 if allowed) and until the limit
+// resets.
+func cmdTHROTTLE(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 4 && len(args) != 5 {
+		r.WriteError("ERR THROTTLE requires key, max_burst, count, period and an optional quantity")
+		return
+	}
+	nums := []int64{0, 0, 0, 1}
+	for i, a := range args[1:] {
+		n, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			r.WriteError("ERR value is not an integer or out of range")
+			return
+		}
+		nums[i] = n
+	}
+	if nums[2] > int64(math.MaxInt64/time.Second) {
+		r.WriteError(store.ErrThrottleParams.Error())
+		return
+	}
+	res, err := c.db.Throttle(args[0], nums[0], nums[1], time.Duration(nums[2])*time.Second, nums[3])
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if !res.Limited && res.ResetAfter > 0 {
+		c.propagateValue(args[0], strconv.FormatInt(res.TAT, 10))
+	}
+
+	limited, retry := int64(0), int64(-1)
+	if res.Limited {
+		limited = 1
+		if res.RetryAfter >= 0 {
+			retry = int64(math.Ceil(res.RetryAfter.Seconds()))
+		}
+	}
+	// five integers, one per line
+	for _, n := range []int64{limited, res.Limit, res.Remaining, retry, int64(math.Ceil(res.ResetAfter.Seconds()))} {
+		r.WriteInt(n)
+	}
+}
+
+// LOCK key token ttl, UNLOCK key token and EXTEND key token ttl. TTLs are
+// in seconds; replies are :1 on success and :0 if another token holds the
+// lock (or, for UNLOCK and EXTEND, nobody does).
+func cmdLOCK(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 3 {
+		r.WriteError("ERR LOCK requires key, token and ttl")
+		return
+	}
+	ttl, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil || ttl <= 0 {
+		protocol.Errorf(r, "ERR invalid ttl '%s'", args[2])
+		return
+	}
+	ok, err := c.db.Lock(args[0], args[1], ttl)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	if ok {
+		c.propagateValue(args[0], args[1])
+	}
+	writeFlags(c, []bool{ok}, false)
+}
+
+func cmdUNLOCK(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 2 {
+		r.WriteError("ERR UNLOCK requires key and token")
+		return
+	}
+	ok := c.db.Unlock(args[0], args[1])
+	if ok {
+		c.propagate("DEL", args[0])
+	}
+	writeFlags(c, []bool{ok}, false)
+}
+
+func cmdEXTEND(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 3 {
+		r.WriteError("ERR EXTEND requires key, token and ttl")
+		return
+	}
+	ttl, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil || ttl <= 0 {
+		protocol.Errorf(r, "ERR invalid ttl '%s'", args[2])
+		return
+	}
+	ok := c.db.ExtendLock(args[0], args[1], ttl)
+	if ok {
+		c.propagateExpiry(args[0])
+	}
+	writeFlags(c, []bool{ok}, false)
+}
+
+// CAS key expected new [EX ttl] replaces the value of key only if it is
+// expected, replying +OK, or replies the current value ((nil) if there is
+// none). Without EX the key keeps its TTL.
+func cmdCAS(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 3 && len(args) != 5 {
+		r.WriteError("ERR CAS requires key, expected and new value, and optionally EX ttl")
+		return
+	}
+	var ttl int64
+	if len(args) == 5 {
+		n, err := strconv.ParseInt(args[4], 10, 64)
+		if strings.ToUpper(args[3]) != "EX" || err != nil || n <= 0 {
+			protocol.Errorf(r, "ERR invalid expire '%s %s'", args[3], args[4])
+			return
+		}
+		ttl = n
+	}
+	key := args[0]
+	cur, exists, swapped, err := c.db.CompareAndSwap(key, args[1], args[2], ttl)
+	switch {
+	case err != nil:
+		r.WriteError(err.Error())
+	case swapped:
+		c.propagateValue(key, args[2])
+		r.WriteSimple("OK")
+	case exists:
+		r.WriteBulk(cur)
+	default:
+		r.WriteNil()
+	}
+}
+
+// HOTKEYS [FREQ|SIZE] [COUNT n] lists the most frequently accessed keys
+// (FREQ, the default) or the largest ones (SIZE), one "key freq=F bytes=B"
+// line each.
+func cmdHOTKEYS(c *Session, args []string) {
+	r := c.reply
+	bySize, count := false, 10
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "FREQ":
+			bySize = false
+		case "SIZE":
+			bySize = true
+		case "COUNT":
+			if i+1 == len(args) {
+				r.WriteError("ERR COUNT requires a number")
+				return
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				protocol.Errorf(r, "ERR invalid COUNT '%s'", args[i+1])
+				return
+			}
+			count = n
+			i++
+		default:
+			protocol.Errorf(r, "ERR syntax error near '%s'", args[i])
+			return
+		}
+	}
+	var keys []store.KeyStat
+	if bySize {
+		keys = c.db.BigKeys(count)
+	} else {
+		keys = c.db.HotKeys(count)
+	}
+	lines := make([]string, len(keys))
+	for i, k := range keys {
+		lines[i] = fmt.Sprintf("%s freq=%d bytes=%d", k.Key, k.Freq, k.Bytes)
+	}
+	r.WriteArray(lines)
+}
+
+func cmdSCAN(c *Session, args []string) {
+	r := c.reply
+	// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+	if len(args) < 1 {
+		r.WriteError("ERR wrong number of arguments for 'scan'")
+		return
+	}
+	cursor, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		r.WriteError("ERR invalid cursor")
+		return
+	}
+	pattern, typ, count := "", "", store.DefaultIterateCount
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		if i+1 == len(args) {
+			protocol.Errorf(r, "ERR %s requires an argument", opt)
+			return
+		}
+		switch opt {
+		case "MATCH":
+			pattern = args[i+1]
+			if _, err := path.Match(pattern, ""); err != nil {
+				protocol.Errorf(r, "ERR invalid pattern '%s'", pattern)
+				return
+			}
+		case "COUNT":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				protocol.Errorf(r, "ERR invalid COUNT '%s'", args[i+1])
+				return
+			}
+			count = n
+		case "TYPE":
+			typ = strings.ToLower(args[i+1])
+		default:
+			protocol.Errorf(r, "ERR syntax error near '%s'", args[i])
+			return
+		}
+		i++
+	}
+	var filter func(string, store.Entry) bool
+	if pattern != "" || typ != "" {
+		filter = func(k string, e store.Entry) bool {
+			if pattern != "" {
+				if ok, _ := path.Match(pattern, k); !ok {
+					return false
+				}
+			}
+			return typ == "" || store.ValueType(e.Value) == typ
+		}
+	}
+	next, keys, err := c.db.IteratePrefix(cursor, globPrefix(pattern), count, filter)
+	if err != nil {
+		r.WriteError(err.Error())
+		return
+	}
+	// the next cursor comes first, then one key per line
+	lines := make([]string, 0, len(keys)+1)
+	lines = append(lines, fmt.Sprintf("cursor:%d", next))
+	for _, k := range keys {
+		lines = append(lines, k.Key)
+	}
+	r.WriteArray(lines)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBuckets is the number of power-of-two microsecond buckets kept per
+// command for the Prometheus histogram; bucket i counts calls that took
+// less than 2^i µs.
+const latencyBuckets = 32
+
+// cmdStat accumulates call counts and latencies for one command. hist has
+// the fixed buckets Prometheus needs; percentiles come from the finer lat.
+type cmdStat struct {
+	calls  int64
+	failed int64
+	usec   int64
+	hist   [latencyBuckets]int64
+	lat    latencyHist
+}
+
+// cmdStatSnapshot is a copy of a cmdStat taken for reporting.
+type cmdStatSnapshot struct {
+	name string
+	cmdStat
+}
+
+// commandStats holds per-command statistics for INFO commandstats.
+type commandStats struct {
+	mu    sync.Mutex
+	stats map[string]*cmdStat
+}
+
+// record adds one call of name that took d.
+func (cs *commandStats) record(name string, d time.Duration, failed bool) {
+	usec := d.Microseconds()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	st, ok := cs.stats[name]
+	if !ok {
+		st = &cmdStat{}
+		cs.stats[name] = st
+	}
+	st.calls++
+	st.usec += usec
+	if failed {
+		st.failed++
+	}
+	b := bits.Len64(uint64(usec))
+	if b >= latencyBuckets {
+		b = latencyBuckets - 1
+	}
+	st.hist[b]++
+	st.lat.record(d.Nanoseconds())
+}
+
+// snapshot returns a copy of every command's stats ordered by name.
+func (cs *commandStats) snapshot() []cmdStatSnapshot {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	res := make([]cmdStatSnapshot, 0, len(cs.stats))
+	for name, st := range cs.stats {
+		res = append(res, cmdStatSnapshot{name: name, cmdStat: *st})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
+func (cs *commandStats) reset() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.stats = make(map[string]*cmdStat)
+}
+
+// resetStats implements CONFIG RESETSTAT.
+func (srv *Server) resetStats() {
+	srv.cmdStats.reset()
+	srv.totalConnections.Store(0)
+	srv.totalCommands.Store(0)
+	srv.outputLimitDisconnects.Store(0)
+	srv.largeResultsRefused.Store(0)
+	srv.store.ResetStats()
+}
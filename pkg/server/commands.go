@@ -0,0 +1,174 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommandFunc is the function signature for a RediGo command. It runs with
+// the session of the client that sent the command.
+type CommandFunc func(c *Session, args []string)
+
+// Command flags reported by COMMAND INFO.
+const (
+	flagWrite    = "write"
+	flagReadonly = "readonly"
+	flagDenyOOM  = "denyoom"
+	flagAdmin    = "admin"
+	flagFast     = "fast"
+	flagStale    = "stale"
+	flagBlocking = "blocking"
+)
+
+// commandSpec holds a command handler together with the metadata exposed by
+// the COMMAND family. Arity follows the Redis convention: it counts the
+// command name itself, and a negative value means "at least that many".
+// Key positions are 1-based argument indexes (0 means the command takes no
+// keys); lastKey -1 means "through the last argument".
+type commandSpec struct {
+	name     string
+	fn       CommandFunc
+	arity    int
+	flags    []string
+	firstKey int
+	lastKey  int
+	step     int
+	summary  string
+}
+
+func (c *commandSpec) hasFlag(flag string) bool {
+	for _, f := range c.flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// arityOK reports whether nargs arguments, not counting the command name,
+// satisfy the arity of c.
+func (c *commandSpec) arityOK(nargs int) bool {
+	if c.arity < 0 {
+		return nargs+1 >= -c.arity
+	}
+	return nargs+1 == c.arity
+}
+
+// keyCount returns how many of nargs arguments are keys.
+func (c *commandSpec) keyCount(nargs int) int {
+	if c.firstKey == 0 || c.step <= 0 {
+		return 0
+	}
+	last := c.lastKey
+	if last < 0 || last > nargs {
+		last = nargs
+	}
+	if last < c.firstKey {
+		return 0
+	}
+	return (last-c.firstKey)/c.step + 1
+}
+
+// Global command registry, keyed by upper-case command name.
+var commands = map[string]*commandSpec{}
+
+func register(spec *commandSpec) {
+	commands[spec.name] = spec
+}
+
+// The registry is populated in init because some handlers (COMMAND) read it.
+func init() {
+	register(&commandSpec{name: "SET", fn: cmdSET, arity: -3, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Set the string value of a key"})
+	register(&commandSpec{name: "SETEX", fn: cmdSETEX, arity: -4, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Set the value and expiration of a key"})
+	register(&commandSpec{name: "WAITKEY", fn: cmdWAITKEY, arity: -3, flags: []string{flagReadonly, flagBlocking}, firstKey: 1, lastKey: 1, step: 1, summary: "Wait until a key is written"})
+	register(&commandSpec{name: "GET", fn: cmdGET, arity: 2, flags: []string{flagReadonly, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Get the value of a key"})
+	register(&commandSpec{name: "LCS", fn: cmdLCS, arity: -3, flags: []string{flagReadonly}, firstKey: 1, lastKey: 2, step: 1, summary: "Find the longest common subsequence of two strings"})
+	register(&commandSpec{name: "JSON.SET", fn: cmdJSONSET, arity: -4, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Set the JSON value at a path in a document"})
+	register(&commandSpec{name: "JSON.GET", fn: cmdJSONGET, arity: -2, flags: []string{flagReadonly}, firstKey: 1, lastKey: 1, step: 1, summary: "Get the JSON value at a path in a document"})
+	register(&commandSpec{name: "JSON.DEL", fn: cmdJSONDEL, arity: -2, flags: []string{flagWrite}, firstKey: 1, lastKey: 1, step: 1, summary: "Delete the JSON value at a path in a document"})
+	register(&commandSpec{name: "BF.RESERVE", fn: cmdBFRESERVE, arity: 4, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Create an empty bloom filter"})
+	register(&commandSpec{name: "BF.ADD", fn: cmdBFADD, arity: 3, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Add an item to a bloom filter"})
+	register(&commandSpec{name: "BF.MADD", fn: cmdBFMADD, arity: -3, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Add items to a bloom filter"})
+	register(&commandSpec{name: "BF.EXISTS", fn: cmdBFEXISTS, arity: 3, flags: []string{flagReadonly, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Check whether an item may be in a bloom filter"})
+	register(&commandSpec{name: "BF.MEXISTS", fn: cmdBFMEXISTS, arity: -3, flags: []string{flagReadonly}, firstKey: 1, lastKey: 1, step: 1, summary: "Check whether items may be in a bloom filter"})
+	register(&commandSpec{name: "CF.RESERVE", fn: cmdCFRESERVE, arity: 3, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Create an empty cuckoo filter"})
+	register(&commandSpec{name: "CF.ADD", fn: cmdCFADD, arity: 3, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Add an item to a cuckoo filter"})
+	register(&commandSpec{name: "CF.DEL", fn: cmdCFDEL, arity: 3, flags: []string{flagWrite}, firstKey: 1, lastKey: 1, step: 1, summary: "Remove an item from a cuckoo filter"})
+	register(&commandSpec{name: "CF.EXISTS", fn: cmdCFEXISTS, arity: 3, flags: []string{flagReadonly, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Check whether an item may be in a cuckoo filter"})
+	register(&commandSpec{name: "CF.MEXISTS", fn: cmdCFMEXISTS, arity: -3, flags: []string{flagReadonly}, firstKey: 1, lastKey: 1, step: 1, summary: "Check whether items may be in a cuckoo filter"})
+	register(&commandSpec{name: "CMS.INITBYDIM", fn: cmdCMSINITBYDIM, arity: 4, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Create a count-min sketch of the given dimensions"})
+	register(&commandSpec{name: "CMS.INITBYPROB", fn: cmdCMSINITBYPROB, arity: 4, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Create a count-min sketch for an error rate and probability"})
+	register(&commandSpec{name: "CMS.INCRBY", fn: cmdCMSINCRBY, arity: -4, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Increase the counts of items in a count-min sketch"})
+	register(&commandSpec{name: "CMS.QUERY", fn: cmdCMSQUERY, arity: -3, flags: []string{flagReadonly}, firstKey: 1, lastKey: 1, step: 1, summary: "Estimate the counts of items in a count-min sketch"})
+	register(&commandSpec{name: "TOPK.RESERVE", fn: cmdTOPKRESERVE, arity: -3, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Create a Top-K list"})
+	register(&commandSpec{name: "TOPK.ADD", fn: cmdTOPKADD, arity: -3, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Count items in a Top-K list"})
+	register(&commandSpec{name: "TOPK.LIST", fn: cmdTOPKLIST, arity: -2, flags: []string{flagReadonly}, firstKey: 1, lastKey: 1, step: 1, summary: "List the items in a Top-K list"})
+	register(&commandSpec{name: "TOPK.QUERY", fn: cmdTOPKQUERY, arity: -3, flags: []string{flagReadonly}, firstKey: 1, lastKey: 1, step: 1, summary: "Check whether items are in a Top-K list"})
+	register(&commandSpec{name: "TS.CREATE", fn: cmdTSCREATE, arity: -2, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Create a time series"})
+	register(&commandSpec{name: "TS.ADD", fn: cmdTSADD, arity: -4, flags: []string{flagWrite, flagDenyOOM}, firstKey: 1, lastKey: 1, step: 1, summary: "Append a sample to a time series"})
+	register(&commandSpec{name: "TS.GET", fn: cmdTSGET, arity: 2, flags: []string{flagReadonly, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Get the newest sample of a time series"})
+	register(&commandSpec{name: "TS.RANGE", fn: cmdTSRANGE, arity: -4, flags: []string{flagReadonly}, firstKey: 1, lastKey: 1, step: 1, summary: "Query a range of a time series, optionally downsampled"})
+	register(&commandSpec{name: "THROTTLE", fn: cmdTHROTTLE, arity: -5, flags: []string{flagWrite, flagDenyOOM, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Rate limit a key with the generic cell rate algorithm"})
+	register(&commandSpec{name: "LOCK", fn: cmdLOCK, arity: 4, flags: []string{flagWrite, flagDenyOOM, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Acquire a lock with an owner token and TTL"})
+	register(&commandSpec{name: "UNLOCK", fn: cmdUNLOCK, arity: 3, flags: []string{flagWrite, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Release a lock if the token holds it"})
+	register(&commandSpec{name: "EXTEND", fn: cmdEXTEND, arity: 4, flags: []string{flagWrite, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Reset a lock's TTL if the token holds it"})
+	register(&commandSpec{name: "CAS", fn: cmdCAS, arity: -4, flags: []string{flagWrite, flagDenyOOM, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Set a key only if it holds the expected value"})
+	register(&commandSpec{name: "FCALL", fn: cmdFCALL, arity: -3, flags: []string{flagWrite, flagDenyOOM}, summary: "Run a server-side function atomically"})
+	register(&commandSpec{name: "FUNCTION", fn: cmdFUNCTION, arity: -2, flags: []string{flagReadonly, flagStale}, summary: "List the registered server-side functions"})
+	register(&commandSpec{name: "HOTKEYS", fn: cmdHOTKEYS, arity: -1, flags: []string{flagReadonly, flagAdmin}, summary: "List the most accessed or the largest keys"})
+	register(&commandSpec{name: "DEL", fn: cmdDEL, arity: 2, flags: []string{flagWrite}, firstKey: 1, lastKey: 1, step: 1, summary: "Delete a key"})
+	register(&commandSpec{name: "FLUSHALL", fn: cmdFLUSHALL, arity: -1, flags: []string{flagWrite}, summary: "Delete every key"})
+	register(&commandSpec{name: "FLUSHDB", fn: cmdFLUSHALL, arity: -1, flags: []string{flagWrite}, summary: "Delete every key of the database"})
+	register(&commandSpec{name: "SCAN", fn: cmdSCAN, arity: -2, flags: []string{flagReadonly}, summary: "Iterate over the keys a few at a time"})
+	register(&commandSpec{name: "KEYS", fn: cmdKEYS, arity: -1, flags: []string{flagReadonly}, summary: "List all keys, or those matching a pattern"})
+	register(&commandSpec{name: "PING", fn: cmdPING, arity: -1, flags: []string{flagFast, flagStale}, summary: "Ping the server or echo a message"})
+	register(&commandSpec{name: "EXISTS", fn: cmdEXISTS, arity: 2, flags: []string{flagReadonly, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Determine if a key exists"})
+	register(&commandSpec{name: "TTL", fn: cmdTTL, arity: 2, flags: []string{flagReadonly, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Get the time to live for a key in seconds"})
+	register(&commandSpec{name: "EXPIRE", fn: cmdEXPIRE, arity: 3, flags: []string{flagWrite, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Set a key's time to live in seconds"})
+	register(&commandSpec{name: "EXPIREAT", fn: cmdEXPIREAT, arity: 3, flags: []string{flagWrite, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Set the expiration of a key as a unix timestamp"})
+	register(&commandSpec{name: "PERSIST", fn: cmdPERSIST, arity: 2, flags: []string{flagWrite, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Remove the expiration from a key"})
+	register(&commandSpec{name: "INCR", fn: cmdINCR, arity: 2, flags: []string{flagWrite, flagDenyOOM, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Increment the integer value of a key by one"})
+	register(&commandSpec{name: "DECR", fn: cmdDECR, arity: 2, flags: []string{flagWrite, flagDenyOOM, flagFast}, firstKey: 1, lastKey: 1, step: 1, summary: "Decrement the integer value of a key by one"})
+	register(&commandSpec{name: "CLIENT", fn: cmdCLIENT, arity: -2, flags: []string{flagAdmin, flagStale}, summary: "Inspect and manage client connections"})
+	register(&commandSpec{name: "MONITOR", fn: cmdMONITOR, arity: 1, flags: []string{flagAdmin}, summary: "Stream every command processed by the server"})
+	register(&commandSpec{name: "CONFIG", fn: cmdCONFIG, arity: -2, flags: []string{flagAdmin}, summary: "Change runtime configuration"})
+	register(&commandSpec{name: "INFO", fn: cmdINFO, arity: -1, flags: []string{flagStale}, summary: "Show server statistics"})
+	register(&commandSpec{name: "HEALTHCHECK", fn: cmdHEALTHCHECK, arity: 1, flags: []string{flagStale}, summary: "Check that the store and persistence are healthy"})
+	register(&commandSpec{name: "DUMPALL", fn: cmdDUMPALL, arity: 1, flags: []string{flagReadonly, flagAdmin}, summary: "Dump the dataset as replayable commands"})
+	register(&commandSpec{name: "MEMORY", fn: cmdMEMORY, arity: -2, flags: []string{flagReadonly}, firstKey: 2, lastKey: 2, step: 1, summary: "Estimate memory used by a key or the dataset"})
+	register(&commandSpec{name: "QUOTA", fn: cmdQUOTA, arity: -2, flags: []string{flagAdmin}, summary: "Manage per-namespace key and memory quotas"})
+	register(&commandSpec{name: "DEBUG", fn: cmdDEBUG, arity: -2, flags: []string{flagAdmin}, summary: "Testing and introspection helpers"})
+	register(&commandSpec{name: "BGREWRITEAOF", fn: cmdBGREWRITEAOF, arity: 1, flags: []string{flagAdmin}, summary: "Asynchronously rewrite the append-only file"})
+	register(&commandSpec{name: "BGSAVE", fn: cmdBGSAVE, arity: 1, flags: []string{flagAdmin}, summary: "Asynchronously save the dataset to disk"})
+	register(&commandSpec{name: "SAVE", fn: cmdSAVE, arity: 1, flags: []string{flagAdmin}, summary: "Synchronously save the dataset to disk"})
+	register(&commandSpec{name: "LASTSAVE", fn: cmdLASTSAVE, arity: 1, flags: []string{flagFast, flagStale}, summary: "Get the time of the last successful save"})
+	register(&commandSpec{name: "SLOWLOG", fn: cmdSLOWLOG, arity: -2, flags: []string{flagAdmin, flagStale}, summary: "Inspect the commands that ran longer than slowlog-log-slower-than"})
+	register(&commandSpec{name: "LATENCY", fn: cmdLATENCY, arity: -2, flags: []string{flagStale}, summary: "Report per-command latency percentiles"})
+	register(&commandSpec{name: "COMMAND", fn: cmdCOMMAND, arity: -1, flags: []string{flagStale}, summary: "Get details about the registered commands"})
+	register(&commandSpec{name: "HELP", fn: cmdHELP, arity: 1, flags: []string{flagFast, flagStale}, summary: "Show the list of supported commands"})
+	register(&commandSpec{name: "QUIT", fn: cmdQUIT, arity: 1, flags: []string{flagFast, flagStale}, summary: "Close the connection"})
+}
+
+// isWriteCommand reports whether cmd modifies the dataset.
+func isWriteCommand(cmd string) bool {
+	spec, ok := commands[cmd]
+	return ok && spec.hasFlag(flagWrite)
+}
+
+// sortedCommands returns every registered command ordered by name.
+func sortedCommands() []*commandSpec {
+	res := make([]*commandSpec, 0, len(commands))
+	for _, spec := range commands {
+		res = append(res, spec)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
+// commandInfo returns the one-line COMMAND INFO description of spec.
+func commandInfo(spec *commandSpec) string {
+	return fmt.Sprintf("%s arity=%d flags=%s first_key=%d last_key=%d step=%d",
+		strings.ToLower(spec.name), spec.arity, strings.Join(spec.flags, ","),
+		spec.firstKey, spec.lastKey, spec.step)
+}
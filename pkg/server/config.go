@@ -0,0 +1,378 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/config"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// ErrUnknownConfig is returned by ConfigSet for a parameter that does not
+// exist.
+var ErrUnknownConfig = errors.New("unknown configuration parameter")
+
+// ErrNoConfigFile is returned by ConfigRewrite when the server was not
+// started from a configuration file.
+var ErrNoConfigFile = errors.New("the server is running without a config file")
+
+// ErrReadOnlyConfig is returned by ConfigSet for a parameter that is only
+// read at startup.
+var ErrReadOnlyConfig = errors.New("configuration parameter can only be set at startup")
+
+// configParam is a parameter of CONFIG GET and CONFIG SET, named after its
+// redigo.conf directive. set validates and applies a new value; it is nil
+// for parameters that are fixed at startup.
+type configParam struct {
+	name string
+	get  func(srv *Server) string
+	set  func(srv *Server, v string) error
+}
+
+// readOnlyParam is a parameter that cannot be changed at runtime.
+func readOnlyParam(name string, get func(srv *Server) string) configParam {
+	return configParam{name: name, get: get}
+}
+
+// intParam is a non-negative integer parameter.
+func intParam(name string, get func(srv *Server) int64, set func(srv *Server, n int64)) configParam {
+	return configParam{
+		name: name,
+		get:  func(srv *Server) string { return strconv.FormatInt(get(srv), 10) },
+		set: func(srv *Server, v string) error {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s value '%s'", strings.ToUpper(name), v)
+			}
+			set(srv, n)
+			return nil
+		},
+	}
+}
+
+// sizeParam is a byte count that accepts kb/mb/gb suffixes.
+func sizeParam(name string, get func(srv *Server) int64, set func(srv *Server, n int64)) configParam {
+	p := intParam(name, get, set)
+	p.set = func(srv *Server, v string) error {
+		n, err := config.ParseSize(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s value '%s'", strings.ToUpper(name), v)
+		}
+		set(srv, n)
+		return nil
+	}
+	return p
+}
+
+// boolParam is a yes/no parameter.
+func boolParam(name string, get func(srv *Server) bool, set func(srv *Server, b bool)) configParam {
+	return configParam{
+		name: name,
+		get:  func(srv *Server) string { return yesNo(get(srv)) },
+		set: func(srv *Server, v string) error {
+			switch strings.ToLower(v) {
+			case "yes":
+				set(srv, true)
+			case "no":
+				set(srv, false)
+			default:
+				return fmt.Errorf("invalid %s value '%s', expected yes or no", strings.ToUpper(name), v)
+			}
+			return nil
+		},
+	}
+}
+
+// configParams lists every parameter, in the order CONFIG GET reports them.
+var configParams = []configParam{
+	readOnlyParam("bind", func(srv *Server) string { host, _, _ := net.SplitHostPort(srv.opts.Addr); return host }),
+	readOnlyParam("port", func(srv *Server) string { _, port, _ := net.SplitHostPort(srv.Addr()); return port }),
+	readOnlyParam("listen", func(srv *Server) string {
+		ls := srv.opts.Listeners
+		if len(ls) == 0 {
+			ls = []Listener{{Addr: srv.Addr(), TLS: srv.tlsConfig != nil}}
+		}
+		specs := make([]string, len(ls))
+		for i, l := range ls {
+			specs[i] = l.String()
+		}
+		return strings.Join(specs, ", ")
+	}),
+	readOnlyParam("dir", func(srv *Server) string { return srv.dataDir() }),
+	readOnlyParam("appendonly", func(srv *Server) string { return yesNo(srv.opts.AOFPath != "") }),
+	readOnlyParam("appendfilename", func(srv *Server) string { return baseName(srv.opts.AOFPath) }),
+	readOnlyParam("appenddirname", func(srv *Server) string {
+		if srv.opts.AOFPath == "" {
+			return ""
+		}
+		return filepath.Base(srv.aof.layout.dir)
+	}),
+	readOnlyParam("aof-load-truncated", func(srv *Server) string { return yesNo(srv.opts.AOFLoadTruncated) }),
+	{
+		name: "appendfsync",
+		get:  func(srv *Server) string { return srv.aof.fsyncPolicy() },
+		set: func(srv *Server, v string) error {
+			v = strings.ToLower(v)
+			if v == "" || !validFsync(v) {
+				return fmt.Errorf("invalid APPENDFSYNC value '%s', expected always, everysec or no", v)
+			}
+			srv.aof.setFsync(v)
+			return nil
+		},
+	},
+	intParam("auto-aof-rewrite-percentage",
+		func(srv *Server) int64 { return srv.aofRewritePercentage.Load() },
+		func(srv *Server, n int64) { srv.aofRewritePercentage.Store(n) }),
+	sizeParam("auto-aof-rewrite-min-size",
+		func(srv *Server) int64 { return srv.aofRewriteMinSize.Load() },
+		func(srv *Server, n int64) { srv.aofRewriteMinSize.Store(n) }),
+	readOnlyParam("dbfilename", func(srv *Server) string { return baseName(srv.opts.SnapshotPath) }),
+	{
+		// Pairs of seconds and changes; "" removes every save point.
+		name: "save",
+		get: func(srv *Server) string {
+			points := *srv.savePoints.Load()
+			specs := make([]string, len(points))
+			for i, p := range points {
+				specs[i] = fmt.Sprintf("%d %d", p.Seconds, p.Changes)
+			}
+			return strings.Join(specs, " ")
+		},
+		set: func(srv *Server, v string) error {
+			if v == `""` {
+				// save "" typed on the inline protocol, which keeps quotes
+				v = ""
+			}
+			ps, err := config.ParseSavePoints(strings.Fields(v))
+			if err != nil {
+				return fmt.Errorf("invalid SAVE value '%s': %v", v, err)
+			}
+			points := make([]SavePoint, len(ps))
+			for i, p := range ps {
+				points[i] = SavePoint{Seconds: p.Seconds, Changes: p.Changes}
+			}
+			srv.savePoints.Store(&points)
+			return nil
+		},
+	},
+	intParam("maxkeys",
+		func(srv *Server) int64 { return int64(srv.store.Stats().MaxKeys) },
+		func(srv *Server, n int64) { srv.store.SetMaxKeys(int(n)) }),
+	sizeParam("maxmemory",
+		func(srv *Server) int64 { return srv.store.Stats().MaxMemory },
+		func(srv *Server, n int64) { srv.store.SetMaxMemory(n) }),
+	intParam("max-key-length",
+		func(srv *Server) int64 { return int64(srv.store.Stats().MaxKeyLength) },
+		func(srv *Server, n int64) { srv.store.SetMaxKeyLength(int(n)) }),
+	sizeParam("max-value-size",
+		func(srv *Server) int64 { return srv.store.Stats().MaxValueSize },
+		func(srv *Server, n int64) { srv.store.SetMaxValueSize(n) }),
+	sizeParam("compress-threshold",
+		func(srv *Server) int64 { return srv.store.Stats().CompressThreshold },
+		func(srv *Server, n int64) { srv.store.SetCompressThreshold(n) }),
+	{
+		name: "maxmemory-policy",
+		get:  func(srv *Server) string { return string(srv.store.Stats().Policy) },
+		set: func(srv *Server, v string) error {
+			p, err := store.ParseEvictionPolicy(strings.ToLower(v))
+			if err != nil {
+				return fmt.Errorf("invalid MAXMEMORY-POLICY value '%s'", v)
+			}
+			srv.store.SetEvictionPolicy(p)
+			return nil
+		},
+	},
+	intParam("lfu-log-factor",
+		func(srv *Server) int64 { return int64(srv.store.Stats().LFULogFactor) },
+		func(srv *Server, n int64) { srv.store.SetLFULogFactor(int(n)) }),
+	intParam("lfu-decay-time",
+		func(srv *Server) int64 { return int64(srv.store.Stats().LFUDecayTime) },
+		func(srv *Server, n int64) { srv.store.SetLFUDecayTime(int(n)) }),
+	intParam("active-defrag-threshold",
+		func(srv *Server) int64 { return int64(srv.store.DefragStats().Threshold) },
+		func(srv *Server, n int64) { srv.store.SetDefragThreshold(int(n)) }),
+	{
+		// Microseconds, as in Redis: -1 disables the slowlog and 0 logs
+		// every command.
+		name: "slowlog-log-slower-than",
+		get: func(srv *Server) string {
+			ns := srv.slowlogSlowerThan.Load()
+			if ns < 0 {
+				return "-1"
+			}
+			return strconv.FormatInt(ns/int64(time.Microsecond), 10)
+		},
+		set: func(srv *Server, v string) error {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < -1 {
+				return fmt.Errorf("invalid SLOWLOG-LOG-SLOWER-THAN value '%s'", v)
+			}
+			srv.slowlogSlowerThan.Store(max(n*int64(time.Microsecond), -1))
+			return nil
+		},
+	},
+	intParam("slowlog-max-len",
+		func(srv *Server) int64 { return srv.slowlogMaxLen.Load() },
+		func(srv *Server, n int64) { srv.slowlogMaxLen.Store(n) }),
+	intParam("timeout",
+		func(srv *Server) int64 { return srv.idleTimeout.Load() },
+		func(srv *Server, n int64) { srv.idleTimeout.Store(n) }),
+	intParam("max-result-size",
+		func(srv *Server) int64 { return srv.maxResultSize.Load() },
+		func(srv *Server, n int64) { srv.maxResultSize.Store(n) }),
+	{
+		name: "client-output-buffer-limit",
+		get:  func(srv *Server) string { return srv.monitorLimit.Load().String() },
+		set: func(srv *Server, v string) error {
+			l, err := config.ParseOutputLimit(strings.Fields(v))
+			if err != nil {
+				return fmt.Errorf("invalid CLIENT-OUTPUT-BUFFER-LIMIT value '%s': %v", v, err)
+			}
+			srv.monitorLimit.Store(&OutputLimit{Hard: l.Hard, Soft: l.Soft, SoftSeconds: l.SoftSeconds})
+			return nil
+		},
+	},
+	boolParam("key-index",
+		func(srv *Server) bool { return srv.store.Stats().KeyIndex },
+		func(srv *Server, b bool) { srv.store.SetKeyIndex(b) }),
+	boolParam("active-expire",
+		func(srv *Server) bool { return srv.activeExpire.Load() },
+		func(srv *Server, b bool) { srv.activeExpire.Store(b) }),
+	readOnlyParam("tcp-keepalive", func(srv *Server) string {
+		ka := srv.opts.TCPKeepAlive
+		switch {
+		case ka < 0:
+			ka = 0
+		case ka == 0:
+			ka = 15 * time.Second // Go's default
+		}
+		return strconv.Itoa(int(ka / time.Second))
+	}),
+	readOnlyParam("tcp-nodelay", func(srv *Server) string { return yesNo(!srv.opts.TCPNagle) }),
+	readOnlyParam("tcp-backlog", func(srv *Server) string { return strconv.Itoa(srv.opts.TCPBacklog) }),
+	readOnlyParam("tls-cert-file", func(srv *Server) string { return srv.opts.TLSCertFile }),
+	readOnlyParam("tls-key-file", func(srv *Server) string { return srv.opts.TLSKeyFile }),
+	readOnlyParam("config-file", func(srv *Server) string { return srv.opts.ConfigFile }),
+}
+
+func lookupConfigParam(name string) *configParam {
+	name = strings.ToLower(name)
+	for i := range configParams {
+		if configParams[i].name == name {
+			return &configParams[i]
+		}
+	}
+	return nil
+}
+
+// ConfigGet returns "name:value" for every parameter matching the glob
+// pattern, in a fixed order.
+func (srv *Server) ConfigGet(pattern string) []string {
+	pattern = strings.ToLower(pattern)
+	var res []string
+	for _, p := range configParams {
+		if ok, _ := path.Match(pattern, p.name); ok {
+			res = append(res, p.name+":"+p.get(srv))
+		}
+	}
+	return res
+}
+
+// ConfigSet validates and applies a runtime parameter change, then calls
+// the hooks registered with OnConfigChange.
+func (srv *Server) ConfigSet(name, value string) error {
+	p := lookupConfigParam(name)
+	switch {
+	case p == nil:
+		return fmt.Errorf("%w '%s'", ErrUnknownConfig, name)
+	case p.set == nil:
+		return fmt.Errorf("%w: '%s'", ErrReadOnlyConfig, p.name)
+	}
+	if err := p.set(srv, value); err != nil {
+		return err
+	}
+	value = p.get(srv)
+	srv.log.Info("configuration changed", "param", p.name, "value", value)
+
+	srv.configMu.Lock()
+	hooks := srv.configHooks
+	srv.configMu.Unlock()
+	for _, fn := range hooks {
+		fn(p.name, value)
+	}
+	return nil
+}
+
+// ConfigRewrite writes the current value of every runtime parameter back to
+// the configuration file, so CONFIG SET changes survive a restart.
+// Comments and other directives in the file are kept.
+func (srv *Server) ConfigRewrite() error {
+	if srv.opts.ConfigFile == "" {
+		return ErrNoConfigFile
+	}
+	var ds []config.Directive
+	for _, p := range configParams {
+		if p.set != nil && config.Known(p.name) {
+			ds = append(ds, config.Directive{Name: p.name, Value: p.get(srv)})
+		}
+	}
+	if err := config.Rewrite(srv.opts.ConfigFile, ds); err != nil {
+		return fmt.Errorf("rewriting %s: %w", srv.opts.ConfigFile, err)
+	}
+	srv.log.Info("configuration file rewritten", "path", srv.opts.ConfigFile)
+	return nil
+}
+
+// OnConfigChange registers fn to be called after every successful
+// ConfigSet (including CONFIG SET) with the parameter name and its new
+// effective value.
+func (srv *Server) OnConfigChange(fn func(name, value string)) {
+	srv.configMu.Lock()
+	defer srv.configMu.Unlock()
+	srv.configHooks = append(srv.configHooks, fn)
+}
+
+// dataDir is the directory holding the persistence files.
+func (srv *Server) dataDir() string {
+	p := srv.opts.AOFPath
+	if p == "" {
+		p = srv.opts.SnapshotPath
+	}
+	if p == "" {
+		return ""
+	}
+	dir, err := filepath.Abs(filepath.Dir(p))
+	if err != nil {
+		return filepath.Dir(p)
+	}
+	return dir
+}
+
+func baseName(p string) string {
+	if p == "" {
+		return ""
+	}
+	return filepath.Base(p)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// validFsync reports whether policy is an appendfsync value (or empty).
+func validFsync(policy string) bool {
+	switch policy {
+	case "", config.FsyncAlways, config.FsyncEverySec, config.FsyncNo:
+		return true
+	}
+	return false
+}
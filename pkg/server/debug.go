@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cmdDEBUG implements the DEBUG subcommands used by integration tests:
+//
+//	DEBUG SLEEP seconds          - stall the store for the given time
+//	DEBUG OBJECT key             - show the raw entry behind key
+//	DEBUG SET-ACTIVE-EXPIRE 0|1  - toggle the background expiry loop
+//	DEBUG JMAP                   - print Go heap statistics
+//	DEBUG FAULT LIST|SET|CLEAR   - inject faults, see fault.go
+func cmdDEBUG(c *Session, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(c, "-ERR DEBUG requires a subcommand (SLEEP, OBJECT, SET-ACTIVE-EXPIRE, JMAP, FAULT)\r\n")
+		return
+	}
+	sub := strings.ToUpper(args[0])
+	args = args[1:]
+
+	switch sub {
+	case "SLEEP":
+		if len(args) != 1 {
+			fmt.Fprintf(c, "-ERR DEBUG SLEEP requires seconds\r\n")
+			return
+		}
+		secs, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || secs < 0 {
+			fmt.Fprintf(c, "-ERR invalid seconds '%s'\r\n", args[0])
+			return
+		}
+		c.db.Stall(time.Duration(secs * float64(time.Second)))
+		fmt.Fprintf(c, "+OK\r\n")
+
+	case "OBJECT":
+		if len(args) != 1 {
+			fmt.Fprintf(c, "-ERR DEBUG OBJECT requires key\r\n")
+			return
+		}
+		e, ok := c.db.Inspect(args[0])
+		if !ok {
+			fmt.Fprintf(c, "-ERR no such key\r\n")
+			return
+		}
+		fmt.Fprintf(c, "+encoding:raw serializedlength:%d expires_at:%d last_access:%d lfu_freq:%d\r\n",
+			len(e.Value), e.ExpiresAt, e.LastAccess, e.Freq)
+
+	case "SET-ACTIVE-EXPIRE":
+		if len(args) != 1 || (args[0] != "0" && args[0] != "1") {
+			fmt.Fprintf(c, "-ERR DEBUG SET-ACTIVE-EXPIRE requires 0 or 1\r\n")
+			return
+		}
+		c.srv.activeExpire.Store(args[0] == "1")
+		fmt.Fprintf(c, "+OK\r\n")
+
+	case "JMAP":
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		fmt.Fprintf(c, "heap_alloc:%d\r\n", ms.HeapAlloc)
+		fmt.Fprintf(c, "heap_sys:%d\r\n", ms.HeapSys)
+		fmt.Fprintf(c, "heap_objects:%d\r\n", ms.HeapObjects)
+		fmt.Fprintf(c, "num_gc:%d\r\n", ms.NumGC)
+		fmt.Fprintf(c, "goroutines:%d\r\n", runtime.NumGoroutine())
+
+	case "FAULT":
+		c.srv.debugFault(c, args)
+
+	default:
+		fmt.Fprintf(c, "-ERR unknown DEBUG subcommand '%s'\r\n", sub)
+	}
+}
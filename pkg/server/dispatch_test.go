@@ -0,0 +1,52 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/DakshBaxi/RediGo/pkg/redigotest"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+func TestArity(t *testing.T) {
+	srv := redigotest.Start(t, redigotest.Options{})
+	tests := []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{"GET"}, false},
+		{[]string{"GET", "k", "x"}, false},
+		{[]string{"GET", "k"}, true},
+		{[]string{"SET", "k"}, false},
+		{[]string{"SET", "k", "v"}, true},
+		{[]string{"SET", "k", "v", "EX", "10"}, true},
+		{[]string{"INFO"}, true},
+		{[]string{"INFO", "server"}, true},
+		{[]string{"SLOWLOG"}, false},
+		{[]string{"LATENCY"}, false},
+		{[]string{"SCAN"}, false},
+		{[]string{"PING"}, true},
+		{[]string{"QUIT", "now"}, false},
+	}
+	for _, tt := range tests {
+		r := srv.Do(tt.args...)
+		if tt.ok {
+			if err := r.Err(); err != nil {
+				t.Errorf("%q: %v", tt.args, err)
+			}
+		} else {
+			redigotest.AssertError(t, r, "ERR wrong number of arguments")
+		}
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	srv := redigotest.Start(t, redigotest.Options{})
+	srv.Server().RegisterFunction("boom", func(tx *store.Txn, keys, args []string) (any, error) {
+		tx.Set(keys[0], "1", 0)
+		panic("boom")
+	})
+	redigotest.AssertError(t, srv.Do("FCALL", "boom", "1", "k"), "ERR internal error running 'fcall'")
+	// the server and the store's lock survived
+	redigotest.AssertOK(t, srv.Do("SET", "k", "2"))
+	redigotest.AssertString(t, srv.Do("GET", "k"), "2")
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"strconv"
+
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// Write commands are logged by their effect rather than as sent: the value
+// a key was left with and the unix time it expires at. Replaying effects
+// rebuilds the same dataset however long after they were logged, where
+// INCR on a missing key, or a TTL counted from when the command ran, would
+// not. Handlers record effects on their session while they run and the
+// dispatcher writes them to the AOF once the handler returns, so all
+// effects of a command go out in a single write.
+
+// appendCommand appends one AOF record, the space-separated parts and a
+// newline, to buf.
+func appendCommand(buf []byte, parts ...string) []byte {
+	for i, p := range parts {
+		if i > 0 {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, p...)
+	}
+	return append(buf, '\n')
+}
+
+// propagate records parts, a deterministic command, as an effect of the
+// running command.
+func (c *Session) propagate(parts ...string) {
+	if c.srv.aof.path == "" {
+		return
+	}
+	c.effects = appendCommand(c.effects, parts...)
+}
+
+// propagateValue records that key now holds val, along with the expiry
+// the update left it with.
+func (c *Session) propagateValue(key, val string) {
+	c.propagate("SET", key, val)
+	c.propagateExpiry(key)
+}
+
+// propagateExpiry records key's expiry as an absolute time. A key without
+// one needs no record after SET; callers removing a TTL use PERSIST.
+func (c *Session) propagateExpiry(key string) {
+	if at := c.db.ExpireTime(key); at > 0 {
+		c.propagate("EXPIREAT", key, strconv.FormatInt(at, 10))
+	}
+}
+
+// propagateChanges records the changes of a store Update.
+func (c *Session) propagateChanges(changes []store.Change) {
+	if c.srv.aof.path == "" {
+		return
+	}
+	c.effects = appendChanges(c.effects, changes)
+}
+
+// appendChanges appends the records that leave each changed key in its
+// new state to buf: SET and, for a key with a TTL, EXPIREAT, or DEL.
+func appendChanges(buf []byte, changes []store.Change) []byte {
+	for _, ch := range changes {
+		if ch.Deleted {
+			buf = appendCommand(buf, "DEL", ch.Key)
+			continue
+		}
+		buf = appendCommand(buf, "SET", ch.Key, ch.Value)
+		if ch.ExpiresAt > 0 {
+			buf = appendCommand(buf, "EXPIREAT", ch.Key, strconv.FormatInt(ch.ExpiresAt, 10))
+		}
+	}
+	return buf
+}
+
+// flushEffects writes the effects of the command that just ran to the AOF,
+// after the keys its writes evicted, if any.
+func (c *Session) flushEffects() {
+	if len(c.effects) == 0 && !c.db.HasRemoved() {
+		return
+	}
+	c.srv.aof.write(c.effects)
+	c.effects = c.effects[:0]
+	if cap(c.effects) > maxPooledBuffer {
+		c.effects = nil
+	}
+}
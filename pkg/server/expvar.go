@@ -0,0 +1,64 @@
+package server
+
+import (
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Vars returns a JSON-friendly view of the server's counters, suitable for
+// publishing with expvar:
+//
+//	expvar.Publish("redigo", expvar.Func(func() any { return srv.Vars() }))
+//
+// INFO JSON replies with the same document.
+func (srv *Server) Vars() map[string]any {
+	cmds := make(map[string]any)
+	for _, st := range srv.cmdStats.snapshot() {
+		cmds[strings.ToLower(st.name)] = map[string]int64{
+			"calls":  st.calls,
+			"failed": st.failed,
+			"usec":   st.usec,
+		}
+	}
+	return map[string]any{
+		"version":                    Version,
+		"commit":                     buildInfo().commit,
+		"uptime_seconds":             int64(time.Since(srv.startTime).Seconds()),
+		"goroutines":                 runtime.NumGoroutine(),
+		"connected_clients":          len(srv.clients.list()),
+		"monitors":                   srv.monitors.count(),
+		"blocked_clients":            srv.waiters.count(),
+		"total_connections_received": srv.totalConnections.Load(),
+		"total_commands_processed":   srv.totalCommands.Load(),
+		"client_output_buffer_limit_disconnections": srv.outputLimitDisconnects.Load(),
+		"large_results_refused":                     srv.largeResultsRefused.Load(),
+		"persistence":                               srv.persistenceVars(),
+		"store":                                     srv.store.Stats(),
+		"commands":                                  cmds,
+	}
+}
+
+// persistenceVars is the persistence section of Vars.
+func (srv *Server) persistenceVars() map[string]any {
+	changes := srv.store.Changes()
+	saves := &srv.saves
+	saves.mu.Lock()
+	defer saves.mu.Unlock()
+	status := "ok"
+	if saves.lastErr != nil {
+		status = "err"
+	}
+	var lastSave int64
+	if !saves.lastSave.IsZero() {
+		lastSave = saves.lastSave.Unix()
+	}
+	return map[string]any{
+		"aof_enabled":             srv.aof.enabled(),
+		"bgsave_in_progress":      saves.inProgress,
+		"changes_since_last_save": changes - saves.changes,
+		"last_save_time":          lastSave,
+		"last_save_keys":          saves.lastKeys,
+		"last_bgsave_status":      status,
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// faultSet holds the faults injected with DEBUG FAULT to exercise
+// durability and replication in integration tests. Every fault is off
+// until set, and DEBUG FAULT is refused unless the server runs with
+// Options.DebugFaults (enable-debug-fault yes) or was built with
+// -tags faults.
+type faultSet struct {
+	aofWriteError atomic.Uint64 // probability, as math.Float64bits
+	fsyncDelay    atomic.Int64  // ns
+	replDrop      atomic.Uint64 // probability, as math.Float64bits
+	replStall     atomic.Int64  // ns
+
+	injected atomic.Int64 // faults that fired, for INFO
+}
+
+var errInjectedWrite = errors.New("injected AOF write error (DEBUG FAULT)")
+
+// fault is one DEBUG FAULT setting: a probability or a delay.
+type fault struct {
+	name string
+	prob *atomic.Uint64
+	dly  *atomic.Int64
+}
+
+func (f *faultSet) faults() []fault {
+	return []fault{
+		{name: "aof-write-error", prob: &f.aofWriteError},
+		{name: "aof-fsync-delay", dly: &f.fsyncDelay},
+		{name: "repl-drop", prob: &f.replDrop},
+		{name: "repl-stall", dly: &f.replStall},
+	}
+}
+
+// hit reports whether a fault with probability p fires.
+func (f *faultSet) hit(p *atomic.Uint64) bool {
+	if prob := math.Float64frombits(p.Load()); prob > 0 && rand.Float64() < prob {
+		f.injected.Add(1)
+		return true
+	}
+	return false
+}
+
+// delay sleeps for the injected delay d, if any.
+func (f *faultSet) delay(d *atomic.Int64) {
+	if n := d.Load(); n > 0 {
+		f.injected.Add(1)
+		time.Sleep(time.Duration(n))
+	}
+}
+
+// set parses value for the named fault: a probability between 0 and 1,
+// or a delay in milliseconds.
+func (f *faultSet) set(name, value string) error {
+	for _, ft := range f.faults() {
+		if ft.name != strings.ToLower(name) {
+			continue
+		}
+		if ft.prob != nil {
+			p, err := strconv.ParseFloat(value, 64)
+			if err != nil || p < 0 || p > 1 {
+				return fmt.Errorf("invalid probability '%s', want 0 to 1", value)
+			}
+			ft.prob.Store(math.Float64bits(p))
+		} else {
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || ms < 0 {
+				return fmt.Errorf("invalid delay '%s', want milliseconds", value)
+			}
+			ft.dly.Store(ms * int64(time.Millisecond))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown fault '%s'", name)
+}
+
+func (f *faultSet) clear() {
+	for _, ft := range f.faults() {
+		if ft.prob != nil {
+			ft.prob.Store(0)
+		} else {
+			ft.dly.Store(0)
+		}
+	}
+}
+
+// list writes every fault and its current setting.
+func (f *faultSet) list(w io.Writer) {
+	for _, ft := range f.faults() {
+		if ft.prob != nil {
+			fmt.Fprintf(w, "%s:%g\r\n", ft.name, math.Float64frombits(ft.prob.Load()))
+		} else {
+			fmt.Fprintf(w, "%s:%dms\r\n", ft.name, time.Duration(ft.dly.Load()).Milliseconds())
+		}
+	}
+}
+
+// debugFault implements DEBUG FAULT:
+//
+//	DEBUG FAULT LIST             - show every fault
+//	DEBUG FAULT SET name value   - aof-write-error p | aof-fsync-delay ms |
+//	                               repl-drop p | repl-stall ms
+//	DEBUG FAULT CLEAR            - turn every fault off
+//
+// aof-write-error fails AOF appends, which HEALTHCHECK then reports;
+// aof-fsync-delay slows every fsync, holding up writers as a slow disk
+// would; repl-drop drops lines of the DUMPALL stream replicas sync from;
+// repl-stall delays DUMPALL, like a primary that stops answering.
+func (srv *Server) debugFault(w io.Writer, args []string) {
+	if !srv.opts.DebugFaults && !faultsBuiltIn {
+		fmt.Fprintf(w, "-ERR fault injection is disabled; set enable-debug-fault yes or build with -tags faults\r\n")
+		return
+	}
+	if len(args) == 0 {
+		fmt.Fprintf(w, "-ERR DEBUG FAULT requires LIST, SET or CLEAR\r\n")
+		return
+	}
+	switch strings.ToUpper(args[0]) {
+	case "LIST":
+		srv.faults.list(w)
+	case "SET":
+		if len(args) != 3 {
+			fmt.Fprintf(w, "-ERR DEBUG FAULT SET requires name and value\r\n")
+			return
+		}
+		if err := srv.faults.set(args[1], args[2]); err != nil {
+			fmt.Fprintf(w, "-ERR %v\r\n", err)
+			return
+		}
+		srv.log.Warn("fault injection enabled", "fault", strings.ToLower(args[1]), "value", args[2])
+		fmt.Fprintf(w, "+OK\r\n")
+	case "CLEAR":
+		srv.faults.clear()
+		fmt.Fprintf(w, "+OK\r\n")
+	default:
+		fmt.Fprintf(w, "-ERR unknown DEBUG FAULT subcommand '%s'\r\n", args[0])
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !faults
+
+package server
+
+const faultsBuiltIn = false
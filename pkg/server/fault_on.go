@@ -0,0 +1,7 @@
+//go:build faults
+
+package server
+
+// faultsBuiltIn enables DEBUG FAULT regardless of Options.DebugFaults, for
+// test builds made with -tags faults.
+const faultsBuiltIn = true
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/DakshBaxi/RediGo/internal/protocol"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// Function is a server-side function that clients run with FCALL. It gets
+// the call's keys and remaining arguments and works on the dataset through
+// tx, atomically (see store.Store.Update). Its result is the reply: nil, a
+// string, an int, int64 or bool (as 1 or 0), or a []string. An error is
+// replied as is when it starts with an error code such as ERR, and with
+// "ERR " in front otherwise.
+type Function func(tx *store.Txn, keys, args []string) (any, error)
+
+// RegisterFunction makes fn callable as FCALL name. Names are not case
+// sensitive; registering a name again replaces the earlier function.
+func (srv *Server) RegisterFunction(name string, fn Function) {
+	srv.funcMu.Lock()
+	defer srv.funcMu.Unlock()
+	if srv.functions == nil {
+		srv.functions = make(map[string]Function)
+	}
+	srv.functions[strings.ToLower(name)] = fn
+}
+
+func (srv *Server) function(name string) (Function, bool) {
+	srv.funcMu.RLock()
+	defer srv.funcMu.RUnlock()
+	fn, ok := srv.functions[strings.ToLower(name)]
+	return fn, ok
+}
+
+// cmdFCALL implements FCALL name numkeys [key ...] [arg ...]. The keys the
+// function changed are logged by their effect, like any other write.
+func cmdFCALL(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 2 {
+		r.WriteError("ERR FCALL requires function name and numkeys")
+		return
+	}
+	fn, ok := c.srv.function(args[0])
+	if !ok {
+		protocol.Errorf(r, "ERR function '%s' not found", args[0])
+		return
+	}
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys < 0 {
+		protocol.Errorf(r, "ERR invalid numkeys '%s'", args[1])
+		return
+	}
+	if numKeys > len(args)-2 {
+		r.WriteError("ERR numkeys is greater than the number of arguments")
+		return
+	}
+	keys, rest := args[2:2+numKeys], args[2+numKeys:]
+
+	var res any
+	err = c.db.Update(func(tx *store.Txn) error {
+		var err error
+		res, err = fn(tx, keys, rest)
+		c.propagateChanges(tx.Changes())
+		return err
+	})
+	if err != nil {
+		r.WriteError(functionError(err))
+		return
+	}
+	writeFunctionResult(r, res)
+}
+
+// functionError returns the error reply for err, adding the ERR code if
+// err's message doesn't start with one.
+func functionError(err error) string {
+	msg := err.Error()
+	code, _, _ := strings.Cut(msg, " ")
+	if code == "" || strings.IndexFunc(code, func(r rune) bool { return !unicode.IsUpper(r) }) >= 0 {
+		return "ERR " + msg
+	}
+	return msg
+}
+
+// writeFunctionResult replies with v, a Function's result.
+func writeFunctionResult(r protocol.Reply, v any) {
+	switch v := v.(type) {
+	case nil:
+		r.WriteNil()
+	case string:
+		r.WriteBulk(v)
+	case int:
+		r.WriteInt(int64(v))
+	case int64:
+		r.WriteInt(v)
+	case bool:
+		r.WriteInt(int64(boolInt(v)))
+	case []string:
+		r.WriteArray(v)
+	default:
+		protocol.Errorf(r, "ERR function returned unsupported type %T", v)
+	}
+}
+
+// cmdFUNCTION implements FUNCTION LIST: the names of the registered
+// functions, sorted.
+func cmdFUNCTION(c *Session, args []string) {
+	r := c.reply
+	if len(args) != 1 || !strings.EqualFold(args[0], "LIST") {
+		r.WriteError("ERR FUNCTION supports only LIST")
+		return
+	}
+	c.srv.funcMu.RLock()
+	names := make([]string, 0, len(c.srv.functions))
+	for name := range c.srv.functions {
+		names = append(names, name)
+	}
+	c.srv.funcMu.RUnlock()
+	sort.Strings(names)
+	r.WriteArray(names)
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"fmt"
+)
+
+// Commands whose reply grows with the dataset (KEYS, TS.RANGE) are refused
+// when the reply would hold more than max-result-size items, so a single
+// careless call can't stall every other client while it builds and sends
+// a huge reply. 0 disables the limit.
+
+// maxResults returns the max-result-size limit, or 0 for none.
+func (srv *Server) maxResults() int {
+	return int(srv.maxResultSize.Load())
+}
+
+// refuseLargeResult replies with an error and returns true if n items are
+// over the limit. hint tells the client what to use instead.
+func refuseLargeResult(c *Session, cmd string, n int, unit, hint string) bool {
+	srv := c.srv
+	limit := srv.maxResults()
+	if limit == 0 || n <= limit {
+		return false
+	}
+	srv.largeResultsRefused.Add(1)
+	fmt.Fprintf(c, "-ERR %s would return more than %d %s (max-result-size); %s\r\n", cmd, limit, unit, hint)
+	return true
+}
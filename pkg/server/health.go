@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Health states, from best to worst.
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded" // serving, but something needs attention
+	HealthFailed   = "failed"   // not serving
+)
+
+// healthProbeTimeout bounds how long the store may take to answer a probe.
+const healthProbeTimeout = time.Second
+
+// HealthCheck is the result of one health check.
+type HealthCheck struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+// HealthReport is the outcome of Server.Health. Status is the worst status
+// of its checks.
+type HealthReport struct {
+	Status string
+	Checks []HealthCheck
+}
+
+// Health checks that the store answers within a second, that the AOF is
+// writable and that the server is not shutting down. An AOF problem
+// degrades the server, since writes are still applied but not persisted.
+func (srv *Server) Health(ctx context.Context) HealthReport {
+	var r HealthReport
+	r.add(srv.checkStore(ctx))
+	if err := srv.aof.check(); err != nil {
+		r.add(HealthCheck{Name: "aof", Status: HealthDegraded, Detail: err.Error()})
+	} else if srv.aof.enabled() {
+		r.add(HealthCheck{Name: "aof", Status: HealthOK})
+	} else {
+		r.add(HealthCheck{Name: "aof", Status: HealthOK, Detail: "disabled"})
+	}
+	if srv.closing.Load() {
+		r.add(HealthCheck{Name: "server", Status: HealthFailed, Detail: "shutting down"})
+	} else {
+		r.add(HealthCheck{Name: "server", Status: HealthOK})
+	}
+	return r
+}
+
+func (r *HealthReport) add(c HealthCheck) {
+	r.Checks = append(r.Checks, c)
+	if healthRank(c.Status) > healthRank(r.Status) {
+		r.Status = c.Status
+	}
+}
+
+func healthRank(status string) int {
+	switch status {
+	case HealthOK:
+		return 1
+	case HealthDegraded:
+		return 2
+	case HealthFailed:
+		return 3
+	}
+	return 0
+}
+
+// checkStore times a read against the store. A store held by a long write
+// (e.g. DEBUG SLEEP) fails the check; the probe goroutine finishes once
+// the store is released.
+func (srv *Server) checkStore(ctx context.Context) HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		srv.store.Exists("")
+		close(done)
+	}()
+	select {
+	case <-done:
+		return HealthCheck{Name: "store", Status: HealthOK, Detail: time.Since(start).String()}
+	case <-ctx.Done():
+		return HealthCheck{Name: "store", Status: HealthFailed, Detail: "not responding"}
+	}
+}
+
+// WriteTo writes the report as "name:status" lines, like INFO.
+func (r HealthReport) WriteTo(w io.Writer) (int64, error) {
+	n, err := fmt.Fprintf(w, "status:%s\r\n", r.Status)
+	total := int64(n)
+	for _, c := range r.Checks {
+		if err != nil {
+			break
+		}
+		if c.Detail != "" {
+			n, err = fmt.Fprintf(w, "%s:%s (%s)\r\n", c.Name, c.Status, c.Detail)
+		} else {
+			n, err = fmt.Fprintf(w, "%s:%s\r\n", c.Name, c.Status)
+		}
+		total += int64(n)
+	}
+	return total, err
+}
+
+// LivenessHandler serves /healthz: 200 unless the server has failed, so a
+// degraded server is not restarted.
+func (srv *Server) LivenessHandler() http.Handler {
+	return srv.healthHandler(HealthDegraded)
+}
+
+// ReadinessHandler serves /readyz: 200 only when every check is ok, so
+// traffic moves away from a degraded or draining server.
+func (srv *Server) ReadinessHandler() http.Handler {
+	return srv.healthHandler(HealthOK)
+}
+
+func (srv *Server) healthHandler(worstOK string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rep := srv.Health(r.Context())
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if healthRank(rep.Status) > healthRank(worstOK) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		rep.WriteTo(w)
+	})
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// maxReplayLine bounds a single line of the AOF or a snapshot.
+const maxReplayLine = 512 << 20
+
+// errReplayArity is returned by replayRecord for a record with the wrong
+// number of arguments.
+var errReplayArity = errors.New("wrong number of arguments")
+
+// replayRecord applies one AOF or snapshot record, split into words, to s.
+// It returns why the record could not be applied, if it couldn't.
+func replayRecord(s *store.Store, parts []string) error {
+	cmd := strings.ToUpper(parts[0])
+	args := parts[1:]
+	switch cmd {
+	case "SET":
+		if len(args) < 2 {
+			return errReplayArity
+		}
+		return s.Set(args[0], strings.Join(args[1:], " "))
+
+	case "SETEX":
+		if len(args) < 3 {
+			return errReplayArity
+		}
+		ttl, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid TTL %q", args[1])
+		}
+		return s.Setwithttl(args[0], strings.Join(args[2:], " "), ttl)
+
+	case "INCRBY":
+		if len(args) != 2 {
+			return errReplayArity
+		}
+		delta, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid increment %q", args[1])
+		}
+		_, err = s.IncrBy(args[0], delta)
+		return err
+
+	case "BF.RESERVE":
+		if len(args) != 3 {
+			return errReplayArity
+		}
+		rate, err1 := strconv.ParseFloat(args[1], 64)
+		capacity, err2 := strconv.ParseUint(args[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			return errors.New("invalid error rate or capacity")
+		}
+		return s.BFReserve(args[0], rate, capacity)
+
+	case "BF.ADD", "BF.MADD":
+		if len(args) < 2 {
+			return errReplayArity
+		}
+		_, err := s.BFAdd(args[0], args[1:]...)
+		return err
+
+	case "CF.RESERVE":
+		if len(args) != 2 {
+			return errReplayArity
+		}
+		capacity, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid capacity %q", args[1])
+		}
+		return s.CFReserve(args[0], capacity)
+
+	case "CF.ADD":
+		if len(args) != 2 {
+			return errReplayArity
+		}
+		return s.CFAdd(args[0], args[1])
+
+	case "CF.DEL":
+		if len(args) != 2 {
+			return errReplayArity
+		}
+		_, err := s.CFDel(args[0], args[1])
+		return err
+
+	case "CMS.INITBYDIM":
+		if len(args) != 3 {
+			return errReplayArity
+		}
+		width, err1 := strconv.ParseUint(args[1], 10, 64)
+		depth, err2 := strconv.ParseUint(args[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			return errors.New("invalid width or depth")
+		}
+		return s.CMSInit(args[0], width, depth)
+
+	case "CMS.INCRBY":
+		if len(args) < 3 || len(args)%2 == 0 {
+			return errReplayArity
+		}
+		var items []string
+		var incrs []uint64
+		for i := 1; i < len(args); i += 2 {
+			n, err := strconv.ParseUint(args[i+1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid increment %q", args[i+1])
+			}
+			items, incrs = append(items, args[i]), append(incrs, n)
+		}
+		_, err := s.CMSIncrBy(args[0], items, incrs)
+		return err
+
+	case "TOPK.RESERVE":
+		if len(args) != 5 {
+			return errReplayArity
+		}
+		k, err1 := strconv.ParseUint(args[1], 10, 64)
+		width, err2 := strconv.ParseUint(args[2], 10, 64)
+		depth, err3 := strconv.ParseUint(args[3], 10, 64)
+		decay, err4 := strconv.ParseFloat(args[4], 64)
+		if err := errors.Join(err1, err2, err3, err4); err != nil {
+			return errors.New("invalid Top-K parameters")
+		}
+		return s.TopKReserve(args[0], k, width, depth, decay)
+
+	case "TOPK.ADD":
+		if len(args) < 2 {
+			return errReplayArity
+		}
+		_, err := s.TopKAdd(args[0], args[1:]...)
+		return err
+
+	case "TS.CREATE":
+		if len(args) == 0 {
+			return errReplayArity
+		}
+		ret, err := parseRetention(args[1:])
+		if err != nil {
+			return err
+		}
+		return s.TSCreate(args[0], ret)
+
+	case "TS.ADD":
+		ts, v, ret, err := parseTSAdd(args)
+		if err != nil {
+			return err
+		}
+		return s.TSAdd(args[0], ts, v, ret)
+
+	case "DEL":
+		if len(args) != 1 {
+			return errReplayArity
+		}
+		s.Del(args[0])
+
+	case "FLUSHALL":
+		s.Reset()
+
+	case "EXPIRE":
+		if len(args) != 2 {
+			return errReplayArity
+		}
+		ttl, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid TTL %q", args[1])
+		}
+		s.Expires(args[0], ttl)
+
+	case "EXPIREAT":
+		if len(args) != 2 {
+			return errReplayArity
+		}
+		at, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q", args[1])
+		}
+		s.ExpireAt(args[0], at)
+
+	case "PERSIST":
+		if len(args) != 1 {
+			return errReplayArity
+		}
+		s.Persist(args[0])
+
+	default:
+		return fmt.Errorf("unknown command %q", parts[0])
+	}
+	return nil
+}
+
+// parseRetention parses an optional "RETENTION ms" argument pair.
+func parseRetention(args []string) (int64, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	if len(args) != 2 || strings.ToUpper(args[0]) != "RETENTION" {
+		return 0, errors.New("ERR expected RETENTION ms")
+	}
+	ret, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || ret < 0 {
+		return 0, errors.New("ERR invalid retention '" + args[1] + "'")
+	}
+	return ret, nil
+}
+
+// parseTSAdd parses "key timestamp value [RETENTION ms]" with a numeric
+// timestamp.
+func parseTSAdd(args []string) (ts int64, v float64, ret int64, err error) {
+	if len(args) != 3 && len(args) != 5 {
+		return 0, 0, 0, errors.New("ERR wrong number of arguments for TS.ADD")
+	}
+	if ts, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+		return 0, 0, 0, store.ErrTSTimestamp
+	}
+	if v, err = strconv.ParseFloat(args[2], 64); err != nil {
+		return 0, 0, 0, errors.New("ERR invalid value '" + args[2] + "'")
+	}
+	ret, err = parseRetention(args[3:])
+	return ts, v, ret, err
+}
+
+// globPrefix returns the literal text a glob pattern starts with, before
+// any wildcard, so matching keys can be looked up by prefix.
+func globPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*', '?', '[':
+			return b.String()
+		case '\\':
+			if i+1 == len(pattern) {
+				return b.String()
+			}
+			i++
+			b.WriteByte(pattern[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// setIdleDeadline arms the read deadline for the next command according to
+// the current idle timeout. A monitor only receives, so silence is its
+// normal state and it never times out; a client blocked in WAITKEY clears
+// its deadline while it waits (see cmdWAITKEY).
+func (srv *Server) setIdleDeadline(c *Session) {
+	if secs := srv.idleTimeout.Load(); secs > 0 && !srv.monitors.isMonitor(c) {
+		c.SetReadDeadline(time.Now().Add(time.Duration(secs) * time.Second))
+		return
+	}
+	c.SetReadDeadline(time.Time{})
+}
+
+// isTimeout reports whether err is a network timeout (i.e. an expired deadline).
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
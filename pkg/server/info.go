@@ -0,0 +1,275 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// infoSection renders one "# Name" block of INFO output.
+type infoSection struct {
+	name      string
+	inDefault bool // included in plain INFO / INFO default
+	write     func(srv *Server, w io.Writer)
+}
+
+// infoSections lists every INFO section in output order.
+var infoSections = []infoSection{
+	{"server", true, infoServer},
+	{"clients", true, infoClients},
+	{"memory", true, infoMemory},
+	{"persistence", true, infoPersistence},
+	{"stats", true, infoStats},
+	{"replication", true, infoReplication},
+	{"keyspace", true, infoKeyspace},
+	{"commandstats", false, infoCommandstats},
+}
+
+// writeInfo writes the requested section ("default", "all", "everything" or
+// a single section name). It returns false if the section is unknown.
+func (srv *Server) writeInfo(w io.Writer, section string) bool {
+	found := false
+	for _, sec := range infoSections {
+		switch {
+		case section == "all" || section == "everything":
+		case section == "default" && sec.inDefault:
+		case section == sec.name:
+		default:
+			continue
+		}
+		if found {
+			fmt.Fprintf(w, "\r\n")
+		}
+		found = true
+		fmt.Fprintf(w, "# %s\r\n", strings.ToUpper(sec.name[:1])+sec.name[1:])
+		sec.write(srv, w)
+	}
+	return found
+}
+
+func infoServer(srv *Server, w io.Writer) {
+	s := srv.store
+	uptime := time.Since(srv.startTime)
+	stats := s.Stats()
+	b := buildInfo()
+	fmt.Fprintf(w, "redigo_version:%s\r\n", Version)
+	fmt.Fprintf(w, "redigo_git_sha1:%s\r\n", b.commit)
+	fmt.Fprintf(w, "redigo_git_dirty:%d\r\n", boolInt(b.modified))
+	fmt.Fprintf(w, "redigo_build_date:%s\r\n", b.date)
+	fmt.Fprintf(w, "os:%s\r\n", runtime.GOOS)
+	fmt.Fprintf(w, "arch:%s\r\n", runtime.GOARCH)
+	fmt.Fprintf(w, "go_version:%s\r\n", runtime.Version())
+	fmt.Fprintf(w, "process_id:%d\r\n", os.Getpid())
+	fmt.Fprintf(w, "tcp_addr:%s\r\n", srv.Addr())
+	_, port, _ := net.SplitHostPort(srv.Addr())
+	fmt.Fprintf(w, "tcp_port:%s\r\n", port)
+	fmt.Fprintf(w, "config_file:%s\r\n", srv.opts.ConfigFile)
+	fmt.Fprintf(w, "uptime_in_seconds:%d\r\n", int64(uptime.Seconds()))
+	fmt.Fprintf(w, "uptime_in_days:%d\r\n", int64(uptime.Hours()/24))
+	fmt.Fprintf(w, "config_maxkeys:%d\r\n", stats.MaxKeys)
+	fmt.Fprintf(w, "config_maxmemory:%d\r\n", stats.MaxMemory)
+	fmt.Fprintf(w, "config_maxmemory_policy:%s\r\n", stats.Policy)
+	fmt.Fprintf(w, "config_max_key_length:%d\r\n", stats.MaxKeyLength)
+	fmt.Fprintf(w, "config_max_value_size:%d\r\n", stats.MaxValueSize)
+	fmt.Fprintf(w, "config_compress_threshold:%d\r\n", stats.CompressThreshold)
+	fmt.Fprintf(w, "config_lfu_log_factor:%d\r\n", stats.LFULogFactor)
+	fmt.Fprintf(w, "config_lfu_decay_time:%d\r\n", stats.LFUDecayTime)
+	fmt.Fprintf(w, "config_key_index:%d\r\n", boolInt(stats.KeyIndex))
+	fmt.Fprintf(w, "config_timeout:%d\r\n", srv.idleTimeout.Load())
+	fmt.Fprintf(w, "config_max_result_size:%d\r\n", srv.maxResults())
+	fmt.Fprintf(w, "config_active_expire:%d\r\n", boolInt(srv.activeExpire.Load()))
+	fmt.Fprintf(w, "config_slowlog_log_slower_than:%d\r\n", max(srv.slowlogSlowerThan.Load()/int64(time.Microsecond), -1))
+	fmt.Fprintf(w, "config_slowlog_max_len:%d\r\n", srv.slowlogMaxLen.Load())
+	fmt.Fprintf(w, "slowlog_len:%d\r\n", srv.slowlog.len())
+	fmt.Fprintf(w, "debug_faults_enabled:%d\r\n", boolInt(srv.opts.DebugFaults || faultsBuiltIn))
+}
+
+func infoClients(srv *Server, w io.Writer) {
+	fmt.Fprintf(w, "connected_clients:%d\r\n", len(srv.clients.list()))
+	fmt.Fprintf(w, "monitors:%d\r\n", srv.monitors.count())
+	fmt.Fprintf(w, "blocked_clients:%d\r\n", srv.waiters.count())
+	fmt.Fprintf(w, "paused:%d\r\n", boolInt(srv.pause.active()))
+}
+
+func infoMemory(srv *Server, w io.Writer) {
+	s := srv.store
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	fmt.Fprintf(w, "used_memory:%d\r\n", ms.HeapAlloc)
+	fmt.Fprintf(w, "used_memory_sys:%d\r\n", ms.Sys)
+	fmt.Fprintf(w, "mem_allocations:%d\r\n", ms.Mallocs)
+	fmt.Fprintf(w, "mem_gc_cycles:%d\r\n", ms.NumGC)
+	stats := s.Stats()
+	fmt.Fprintf(w, "used_memory_dataset:%d\r\n", stats.UsedMemory)
+	fmt.Fprintf(w, "maxmemory:%d\r\n", stats.MaxMemory)
+	fmt.Fprintf(w, "maxmemory_policy:%s\r\n", stats.Policy)
+	fmt.Fprintf(w, "max_keys:%d\r\n", stats.MaxKeys)
+	fmt.Fprintf(w, "compressed_keys:%d\r\n", stats.CompressedKeys)
+	fmt.Fprintf(w, "compressed_raw_bytes:%d\r\n", stats.CompressedRawBytes)
+	fmt.Fprintf(w, "compressed_bytes:%d\r\n", stats.CompressedBytes)
+	d := s.DefragStats()
+	fmt.Fprintf(w, "keys_peak:%d\r\n", d.PeakKeys)
+	fmt.Fprintf(w, "active_defrag_threshold:%d\r\n", d.Threshold)
+	fmt.Fprintf(w, "active_defrag_runs:%d\r\n", d.Runs)
+	fmt.Fprintf(w, "active_defrag_reclaimed_bytes:%d\r\n", d.ReclaimedBytes)
+}
+
+func infoPersistence(srv *Server, w io.Writer) {
+	fmt.Fprintf(w, "aof_enabled:%d\r\n", boolInt(srv.aof.enabled()))
+	fmt.Fprintf(w, "persistence_encrypted:%d\r\n", boolInt(srv.opts.EncryptionKey != nil))
+	a := srv.aof
+	a.mu.Lock()
+	rewriteStatus := "ok"
+	if a.rewrite.lastErr != nil {
+		rewriteStatus = "err"
+	}
+	fmt.Fprintf(w, "aof_file:%s\r\n", a.incr)
+	fmt.Fprintf(w, "aof_size:%d\r\n", a.size)
+	fmt.Fprintf(w, "aof_base_size:%d\r\n", a.rewrite.baseSize)
+	fmt.Fprintf(w, "aof_rewrite_in_progress:%d\r\n", boolInt(a.rewrite.inProgress))
+	fmt.Fprintf(w, "aof_rewrites:%d\r\n", a.rewrite.rewrites)
+	fmt.Fprintf(w, "aof_last_bgrewrite_status:%s\r\n", rewriteStatus)
+	a.mu.Unlock()
+
+	changes := srv.store.Changes()
+	saves := &srv.saves
+	saves.mu.Lock()
+	defer saves.mu.Unlock()
+	status := "ok"
+	if saves.lastErr != nil {
+		status = "err"
+	}
+	var lastSave int64
+	if !saves.lastSave.IsZero() {
+		lastSave = saves.lastSave.Unix()
+	}
+	fmt.Fprintf(w, "snapshot_file:%s\r\n", srv.opts.SnapshotPath)
+	fmt.Fprintf(w, "changes_since_last_save:%d\r\n", changes-saves.changes)
+	fmt.Fprintf(w, "bgsave_in_progress:%d\r\n", boolInt(saves.inProgress))
+	fmt.Fprintf(w, "last_save_time:%d\r\n", lastSave)
+	fmt.Fprintf(w, "last_save_keys:%d\r\n", saves.lastKeys)
+	fmt.Fprintf(w, "last_bgsave_status:%s\r\n", status)
+}
+
+func infoStats(srv *Server, w io.Writer) {
+	s := srv.store
+	stats := s.Stats()
+	fmt.Fprintf(w, "total_connections_received:%d\r\n", srv.totalConnections.Load())
+	fmt.Fprintf(w, "total_commands_processed:%d\r\n", srv.totalCommands.Load())
+	fmt.Fprintf(w, "injected_faults:%d\r\n", srv.faults.injected.Load())
+	fmt.Fprintf(w, "reads:%d\r\n", stats.Reads)
+	fmt.Fprintf(w, "writes:%d\r\n", stats.Writes)
+	fmt.Fprintf(w, "keyspace_hits:%d\r\n", stats.Hits)
+	fmt.Fprintf(w, "keyspace_misses:%d\r\n", stats.Misses)
+	fmt.Fprintf(w, "evictions:%d\r\n", stats.Evictions)
+	fmt.Fprintf(w, "client_output_buffer_limit_disconnections:%d\r\n", srv.outputLimitDisconnects.Load())
+	fmt.Fprintf(w, "large_results_refused:%d\r\n", srv.largeResultsRefused.Load())
+	fmt.Fprintf(w, "events_dropped:%d\r\n", stats.EventsDropped)
+}
+
+// ReplicaStatus describes how a replica syncs with its primary.
+type ReplicaStatus struct {
+	Primary         string        // address of the primary last synced from, host:port
+	LastSync        time.Time     // when the last snapshot was applied; zero before the first
+	SyncDuration    time.Duration // how long the last successful sync took
+	Syncs           int64         // successful syncs
+	BytesReceived   int64         // bytes read from the primary by successful syncs
+	CommandsApplied int64         // snapshot commands applied by successful syncs
+}
+
+func infoReplication(srv *Server, w io.Writer) {
+	if srv.opts.ReplicaOf == "" {
+		fmt.Fprintf(w, "role:master\r\n")
+		return
+	}
+	var st ReplicaStatus
+	if srv.opts.ReplicaStatus != nil {
+		st = srv.opts.ReplicaStatus()
+	}
+	// before the first sync, fall back to the configured primary, which
+	// may be a seed list or an SRV name
+	host, port, err := net.SplitHostPort(st.Primary)
+	if err != nil {
+		host, port = srv.opts.ReplicaOf, ""
+	}
+	fmt.Fprintf(w, "role:slave\r\n")
+	fmt.Fprintf(w, "master_host:%s\r\n", host)
+	fmt.Fprintf(w, "master_port:%s\r\n", port)
+	// -1 until the first sync, as in Redis; clients use it to skip stale
+	// replicas
+	age, last := int64(-1), int64(0)
+	if !st.LastSync.IsZero() {
+		age = int64(time.Since(st.LastSync).Seconds())
+		last = st.LastSync.Unix()
+	}
+	fmt.Fprintf(w, "master_last_io_seconds_ago:%d\r\n", age)
+	fmt.Fprintf(w, "master_last_sync_time:%d\r\n", last)
+	fmt.Fprintf(w, "master_last_sync_duration_usec:%d\r\n", st.SyncDuration.Microseconds())
+	fmt.Fprintf(w, "master_syncs:%d\r\n", st.Syncs)
+	fmt.Fprintf(w, "master_sync_received_bytes:%d\r\n", st.BytesReceived)
+	fmt.Fprintf(w, "master_sync_applied_commands:%d\r\n", st.CommandsApplied)
+}
+
+// keyspaceSampleSize is how many keys INFO keyspace samples for its TTL
+// histogram and per-type sizes.
+const keyspaceSampleSize = 1000
+
+func infoKeyspace(srv *Server, w io.Writer) {
+	s := srv.store
+	stats := s.Stats()
+	fmt.Fprintf(w, "keys:%d\r\n", stats.Keys)
+	if stats.Keys > 0 {
+		fmt.Fprintf(w, "db0:keys=%d,expires=%d\r\n", stats.Keys, stats.Expires)
+	}
+
+	// estimates from a sample, labelled as such
+	ks := s.SampleKeyspace(keyspaceSampleSize)
+	fmt.Fprintf(w, "sampled_keys:%d\r\n", ks.Sampled)
+	fmt.Fprintf(w, "sampled_volatile:%d\r\n", ks.Volatile)
+	fmt.Fprintf(w, "sampled_persistent:%d\r\n", ks.Persistent)
+	for i, n := range ks.TTLHistogram {
+		if i < len(store.TTLHistogramBounds) {
+			fmt.Fprintf(w, "sampled_ttl_le_%d:%d\r\n", int64(store.TTLHistogramBounds[i].Seconds()), n)
+		} else {
+			fmt.Fprintf(w, "sampled_ttl_gt_%d:%d\r\n", int64(store.TTLHistogramBounds[i-1].Seconds()), n)
+		}
+	}
+	for _, q := range s.Quotas() {
+		fmt.Fprintf(w, "namespace_%s:keys=%d,bytes=%d,max_keys=%d,max_memory=%d\r\n",
+			q.Namespace, q.Keys, q.Bytes, q.MaxKeys, q.MaxMemory)
+	}
+	types := make([]string, 0, len(ks.Types))
+	for t := range ks.Types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Fprintf(w, "sampled_type_%s:keys=%d,avg_bytes=%d\r\n", t, ks.Types[t].Keys, ks.Types[t].AvgBytes())
+	}
+}
+
+func infoCommandstats(srv *Server, w io.Writer) {
+	for _, snap := range srv.cmdStats.snapshot() {
+		perCall := 0.0
+		if snap.calls > 0 {
+			perCall = float64(snap.usec) / float64(snap.calls)
+		}
+		fmt.Fprintf(w, "cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,failed_calls=%d,p50_usec=%.3f,p99_usec=%.3f,p999_usec=%.3f\r\n",
+			strings.ToLower(snap.name), snap.calls, snap.usec, perCall, snap.failed,
+			usec(snap.lat.percentile(50)), usec(snap.lat.percentile(99)), usec(snap.lat.percentile(99.9)))
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+)
+
+// latencyHist is an HDR-style (log-linear) histogram of command latencies
+// in nanoseconds. Values below latencySub are counted exactly; above that,
+// each power-of-two range is split into latencySub linear sub-buckets, so
+// a percentile is reported within 1/latencySub (6.25%) of the true value
+// across the whole range, which reaches beyond two hours.
+type latencyHist struct {
+	counts [latencyHistBuckets]int64
+	total  int64
+	max    int64 // slowest call, ns
+}
+
+const (
+	latencySubBits     = 4
+	latencySub         = 1 << latencySubBits
+	latencyMaxBits     = 43 // values of 2^43 ns and more share the last bucket
+	latencyHistBuckets = (latencyMaxBits - latencySubBits + 1) * latencySub
+)
+
+// latencyIndex returns the bucket counting a latency of v nanoseconds.
+func latencyIndex(v int64) int {
+	if v < latencySub {
+		return int(max(v, 0))
+	}
+	if v >= 1<<latencyMaxBits {
+		return latencyHistBuckets - 1
+	}
+	shift := bits.Len64(uint64(v)) - 1 - latencySubBits
+	return (shift+1)*latencySub + int(v>>shift) - latencySub
+}
+
+// latencyUpper returns the highest latency counted by bucket i.
+func latencyUpper(i int) int64 {
+	if i < latencySub {
+		return int64(i)
+	}
+	shift := i/latencySub - 1
+	lower := int64(latencySub+i%latencySub) << shift
+	return lower + int64(1)<<shift - 1
+}
+
+func (h *latencyHist) record(ns int64) {
+	h.counts[latencyIndex(ns)]++
+	h.total++
+	h.max = max(h.max, ns)
+}
+
+// percentile returns the p-th percentile latency in nanoseconds: the
+// upper bound of the bucket holding it, capped at the slowest call.
+func (h *latencyHist) percentile(p float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+	rank := max(int64(float64(h.total)*p/100+0.5), 1)
+	var seen int64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			return min(latencyUpper(i), h.max)
+		}
+	}
+	return h.max
+}
+
+// latencyPercentiles are the percentiles reported by LATENCY PERCENTILES
+// and the Prometheus summary.
+var latencyPercentiles = []float64{50, 99, 99.9}
+
+// writeLatencyPercentiles writes one line per command: its calls and the
+// reported percentiles and maximum, in microseconds.
+func writeLatencyPercentiles(w io.Writer, snap cmdStatSnapshot) {
+	fmt.Fprintf(w, "%s:calls=%d", strings.ToLower(snap.name), snap.calls)
+	for _, p := range latencyPercentiles {
+		fmt.Fprintf(w, ",p%s_usec=%.3f", strings.ReplaceAll(fmt.Sprint(p), ".", ""), usec(snap.lat.percentile(p)))
+	}
+	fmt.Fprintf(w, ",max_usec=%.3f\r\n", usec(snap.lat.max))
+}
+
+func usec(ns int64) float64 {
+	return float64(ns) / 1e3
+}
+
+// cmdLATENCY implements LATENCY PERCENTILES [command ...]: the latency
+// percentiles of every command called so far, or of the given ones.
+// CONFIG RESETSTAT clears them.
+func cmdLATENCY(c *Session, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(c, "-ERR wrong number of arguments for 'latency'\r\n")
+		return
+	}
+	if !strings.EqualFold(args[0], "PERCENTILES") {
+		fmt.Fprintf(c, "-ERR unknown LATENCY subcommand '%s'\r\n", args[0])
+		return
+	}
+	snaps := c.srv.cmdStats.snapshot()
+	if len(args) == 1 {
+		if len(snaps) == 0 {
+			fmt.Fprintf(c, "(empty)\r\n")
+		}
+		for _, snap := range snaps {
+			writeLatencyPercentiles(c, snap)
+		}
+		return
+	}
+	byName := make(map[string]cmdStatSnapshot, len(snaps))
+	for _, snap := range snaps {
+		byName[snap.name] = snap
+	}
+	for _, name := range args[1:] {
+		spec, ok := commands[strings.ToUpper(name)]
+		if !ok {
+			fmt.Fprintf(c, "-ERR unknown command '%s'\r\n", name)
+			return
+		}
+		snap, ok := byName[spec.name]
+		if !ok {
+			snap.name = spec.name
+		}
+		writeLatencyPercentiles(c, snap)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import "errors"
+
+// maxLCSCells bounds the dynamic-programming table LCS builds (one uint32
+// per pair of positions), so two large values cannot exhaust memory.
+const maxLCSCells = 1 << 26
+
+var errLCSTooLong = errors.New("ERR LCS strings are too long")
+
+// lcsMatch is a common substring found by lcsMatches: a[aStart:aEnd+1]
+// equals b[bStart:bEnd+1].
+type lcsMatch struct {
+	aStart, aEnd int
+	bStart, bEnd int
+}
+
+func (m lcsMatch) len() int { return m.aEnd - m.aStart + 1 }
+
+// lcsTable returns the LCS length table of a and b: cell (i, j) at
+// i*(len(b)+1)+j holds the LCS length of a[:i] and b[:j].
+func lcsTable(a, b string) ([]uint32, error) {
+	w := len(b) + 1
+	if (len(a)+1)*w > maxLCSCells {
+		return nil, errLCSTooLong
+	}
+	t := make([]uint32, (len(a)+1)*w)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			switch {
+			case a[i-1] == b[j-1]:
+				t[i*w+j] = t[(i-1)*w+j-1] + 1
+			case t[(i-1)*w+j] > t[i*w+j-1]:
+				t[i*w+j] = t[(i-1)*w+j]
+			default:
+				t[i*w+j] = t[i*w+j-1]
+			}
+		}
+	}
+	return t, nil
+}
+
+// lcs returns the longest common subsequence of a and b, and the ranges
+// of it that are contiguous in both strings, last match first (as Redis
+// reports them). Ranges shorter than minLen are left out.
+func lcs(a, b string, minLen int) (string, []lcsMatch, error) {
+	t, err := lcsTable(a, b)
+	if err != nil {
+		return "", nil, err
+	}
+	w := len(b) + 1
+	n := t[len(a)*w+len(b)]
+	seq := make([]byte, n)
+	var matches []lcsMatch
+	var cur *lcsMatch
+	emit := func() {
+		if cur != nil && cur.len() >= minLen {
+			matches = append(matches, *cur)
+		}
+		cur = nil
+	}
+	for i, j := len(a), len(b); i > 0 && j > 0; {
+		if a[i-1] == b[j-1] {
+			n--
+			seq[n] = a[i-1]
+			if cur != nil && cur.aStart == i && cur.bStart == j {
+				cur.aStart, cur.bStart = i-1, j-1
+			} else {
+				emit()
+				cur = &lcsMatch{aStart: i - 1, aEnd: i - 1, bStart: j - 1, bEnd: j - 1}
+			}
+			i--
+			j--
+			continue
+		}
+		emit()
+		if t[(i-1)*w+j] > t[i*w+j-1] {
+			i--
+		} else {
+			j--
+		}
+	}
+	emit()
+	return string(seq), matches, nil
+}
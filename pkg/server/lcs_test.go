@@ -0,0 +1,46 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLCS(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		minLen  int
+		want    string
+		matches []lcsMatch
+	}{
+		{a: "", b: "", want: ""},
+		{a: "abc", b: "", want: ""},
+		{a: "abc", b: "xyz", want: ""},
+		{a: "abc", b: "abc", want: "abc", matches: []lcsMatch{{0, 2, 0, 2}}},
+		// the example from the Redis LCS documentation
+		{a: "ohmytext", b: "mynewtext", want: "mytext",
+			matches: []lcsMatch{{4, 7, 5, 8}, {2, 3, 0, 1}}},
+		{a: "ohmytext", b: "mynewtext", minLen: 4, want: "mytext",
+			matches: []lcsMatch{{4, 7, 5, 8}}},
+		{a: "ohmytext", b: "mynewtext", minLen: 5, want: "mytext"},
+		{a: "axbxc", b: "abc", want: "abc",
+			matches: []lcsMatch{{4, 4, 2, 2}, {2, 2, 1, 1}, {0, 0, 0, 0}}},
+	}
+	for _, tt := range tests {
+		got, matches, err := lcs(tt.a, tt.b, tt.minLen)
+		if err != nil {
+			t.Errorf("lcs(%q, %q): %v", tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.want || !slices.Equal(matches, tt.matches) {
+			t.Errorf("lcs(%q, %q, %d) = %q %v, want %q %v", tt.a, tt.b, tt.minLen, got, matches, tt.want, tt.matches)
+		}
+	}
+}
+
+func TestLCSTooLong(t *testing.T) {
+	long := strings.Repeat("a", 1<<13)
+	if _, _, err := lcs(long, long, 0); err != errLCSTooLong {
+		t.Fatalf("err = %v, want %v", err, errLCSTooLong)
+	}
+}
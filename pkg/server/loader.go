@@ -0,0 +1,83 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// readThroughTimeout bounds a GET waiting on a read-through load, and the
+// loader command itself.
+const readThroughTimeout = 10 * time.Second
+
+// ReadThroughRule loads keys matching Pattern (a glob, as in KEYS) on a GET
+// miss by running Command. The key is in the REDIGO_KEY environment
+// variable, and each "{key}" in the arguments (not the program name) is
+// replaced with it; the command is run directly, not through a shell. Keys
+// come from clients, so a key substituted at the start of an argument
+// could pass itself off as an option: keys starting with "-" are refused
+// there, and programs that parse options should prefer REDIGO_KEY or put
+// "--" before {key}. Exit status 0 means found, with the value on stdout
+// (one trailing newline is dropped); exit status 1 means the key doesn't
+// exist. Anything else is an error.
+type ReadThroughRule struct {
+	Pattern string
+	Command []string
+}
+
+// commandLoader returns a store.Loader running the first rule whose
+// pattern matches the key. Keys matching no rule are not found.
+func commandLoader(rules []ReadThroughRule, log *slog.Logger) store.Loader {
+	return func(ctx context.Context, key string) (string, bool, error) {
+		for _, r := range rules {
+			if ok, _ := path.Match(r.Pattern, key); ok {
+				return runLoader(ctx, r, key, log)
+			}
+		}
+		return "", false, nil
+	}
+}
+
+func runLoader(ctx context.Context, r ReadThroughRule, key string, log *slog.Logger) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, readThroughTimeout)
+	defer cancel()
+	args := make([]string, len(r.Command))
+	for i, a := range r.Command {
+		switch {
+		case i == 0 && strings.Contains(a, "{key}"):
+			return "", false, errors.New("read-through loader: {key} is not allowed in the program name")
+		case strings.HasPrefix(a, "{key}") && strings.HasPrefix(key, "-"):
+			return "", false, fmt.Errorf("read-through loader: key %q would be taken as an option", key)
+		}
+		args[i] = strings.ReplaceAll(a, "{key}", key)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "REDIGO_KEY="+key)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	var exit *exec.ExitError
+	switch {
+	case err == nil:
+		v := strings.TrimSuffix(stdout.String(), "\n")
+		log.Debug("read-through load", "key", key, "found", true, "duration", time.Since(start))
+		return strings.TrimSuffix(v, "\r"), true, nil
+	case errors.As(err, &exit) && exit.ExitCode() == 1:
+		log.Debug("read-through load", "key", key, "found", false, "duration", time.Since(start))
+		return "", false, nil
+	}
+	log.Warn("read-through loader failed", "key", key, "command", args[0], "err", err, "stderr", strings.TrimSpace(stderr.String()))
+	return "", false, fmt.Errorf("read-through loader failed: %w", err)
+}
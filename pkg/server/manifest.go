@@ -0,0 +1,238 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The AOF is kept in parts in a directory of its own, as in Redis 7: a
+// base file holding the whole dataset as of the last rewrite, then
+// incremental files holding the writes made since, in order. A manifest
+// lists them. A rewrite starts a new incremental file and writes the new
+// base in the background; once the base is complete, the manifest is
+// replaced in a single rename and the parts it no longer lists are
+// deleted. The log is never copied.
+
+// DefaultAOFDirName is the directory the AOF parts are kept in when
+// Options.AOFDirName is empty.
+const DefaultAOFDirName = "appendonlydir"
+
+// Manifest part types, as written on each line of the manifest.
+const (
+	aofTypeBase = "b"
+	aofTypeIncr = "i"
+)
+
+// aofPart is one file listed in the manifest.
+type aofPart struct {
+	name string // relative to the AOF directory
+	seq  int
+	typ  string
+}
+
+// aofManifest lists the parts of the AOF, in load order.
+type aofManifest struct {
+	base  *aofPart // nil until the first rewrite
+	incrs []aofPart
+}
+
+// aofLayout locates the AOF configured at path: its parts and manifest
+// live in dir, under names starting with the base name of path. A plain
+// file at path is a single-file AOF written before the split.
+type aofLayout struct {
+	dir    string
+	prefix string
+	legacy string
+}
+
+func newAOFLayout(path, dirName string) aofLayout {
+	if dirName == "" {
+		dirName = DefaultAOFDirName
+	}
+	return aofLayout{
+		dir:    filepath.Join(filepath.Dir(path), dirName),
+		prefix: filepath.Base(path),
+		legacy: path,
+	}
+}
+
+func (l aofLayout) manifestPath() string {
+	return filepath.Join(l.dir, l.prefix+".manifest")
+}
+
+func (l aofLayout) path(p aofPart) string {
+	return filepath.Join(l.dir, p.name)
+}
+
+// part names the part of type typ numbered seq, e.g. redigo.aof.3.incr.aof.
+func (l aofLayout) part(seq int, typ string) aofPart {
+	kind := "incr"
+	if typ == aofTypeBase {
+		kind = "base"
+	}
+	return aofPart{name: fmt.Sprintf("%s.%d.%s.aof", l.prefix, seq, kind), seq: seq, typ: typ}
+}
+
+// readManifest reads the manifest of l. A missing manifest is an empty
+// one: the AOF has not been split yet.
+func readManifest(l aofLayout) (*aofManifest, error) {
+	m := &aofManifest{}
+	f, err := os.Open(l.manifestPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		p, err := parseManifestLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", l.manifestPath(), n, err)
+		}
+		switch {
+		case p.typ == aofTypeBase && m.base == nil:
+			m.base = &p
+		case p.typ == aofTypeIncr:
+			m.incrs = append(m.incrs, p)
+		default:
+			return nil, fmt.Errorf("%s:%d: more than one base file", l.manifestPath(), n)
+		}
+	}
+	return m, sc.Err()
+}
+
+// parseManifestLine parses "file <name> seq <n> type <b|i>".
+func parseManifestLine(line string) (aofPart, error) {
+	fields := strings.Fields(line)
+	if len(fields)%2 != 0 {
+		return aofPart{}, fmt.Errorf("invalid manifest line %q", line)
+	}
+	var p aofPart
+	for i := 0; i < len(fields); i += 2 {
+		switch v := fields[i+1]; fields[i] {
+		case "file":
+			p.name = v
+		case "seq":
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return aofPart{}, fmt.Errorf("invalid seq %q", v)
+			}
+			p.seq = n
+		case "type":
+			p.typ = v
+		}
+	}
+	if p.name == "" || p.seq == 0 || strings.ContainsAny(p.name, `/\`) {
+		return aofPart{}, fmt.Errorf("invalid manifest line %q", line)
+	}
+	if p.typ != aofTypeBase && p.typ != aofTypeIncr {
+		return aofPart{}, fmt.Errorf("invalid part type %q", p.typ)
+	}
+	return p, nil
+}
+
+// write replaces the manifest of l with m. The new manifest is synced and
+// renamed into place, so a crash leaves either the old one or the new one.
+func (m *aofManifest) write(l aofLayout) error {
+	var b strings.Builder
+	for _, p := range m.parts() {
+		fmt.Fprintf(&b, "file %s seq %d type %s\n", p.name, p.seq, p.typ)
+	}
+	path := l.manifestPath()
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) // no-op after a successful rename
+	_, err = f.WriteString(b.String())
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err == nil {
+		err = syncDir(l.dir)
+	}
+	return err
+}
+
+// parts returns the base, if any, and the incremental files, in load
+// order.
+func (m *aofManifest) parts() []aofPart {
+	var parts []aofPart
+	if m.base != nil {
+		parts = append(parts, *m.base)
+	}
+	return append(parts, m.incrs...)
+}
+
+// nextSeq returns the number for the next part of type typ.
+func (m *aofManifest) nextSeq(typ string) int {
+	seq := 0
+	for _, p := range m.parts() {
+		if p.typ == typ {
+			seq = max(seq, p.seq)
+		}
+	}
+	return seq + 1
+}
+
+// syncDir makes a rename or a new file in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// loadOrder returns the files that hold the dataset, in the order they
+// are loaded at startup. Once the AOF has a base, the AOF alone does.
+// Before that the snapshot is loaded first, then a single-file AOF left
+// from before the split, then the incremental files. A missing manifest
+// or AOF only leaves the snapshot. aofPath is "" when the AOF is off.
+func loadOrder(snapshotPath, aofPath, aofDirName string) ([]string, error) {
+	var files []string
+	if aofPath == "" {
+		if snapshotPath != "" {
+			files = append(files, snapshotPath)
+		}
+		return files, nil
+	}
+	l := newAOFLayout(aofPath, aofDirName)
+	m, err := readManifest(l)
+	if err != nil {
+		return nil, err
+	}
+	if m.base == nil {
+		if snapshotPath != "" {
+			files = append(files, snapshotPath)
+		}
+		if _, err := os.Stat(l.legacy); err == nil {
+			files = append(files, l.legacy)
+		}
+	}
+	for _, p := range m.parts() {
+		files = append(files, l.path(p))
+	}
+	return files, nil
+}
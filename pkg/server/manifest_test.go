@@ -0,0 +1,94 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	l := newAOFLayout(filepath.Join(dir, "redigo.aof"), "")
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	m, err := readManifest(l)
+	if err != nil || m.base != nil || len(m.incrs) != 0 {
+		t.Fatalf("missing manifest = %+v, %v; want an empty one", m, err)
+	}
+
+	base := l.part(2, aofTypeBase)
+	m = &aofManifest{base: &base, incrs: []aofPart{l.part(3, aofTypeIncr), l.part(4, aofTypeIncr)}}
+	if err := m.write(l); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readManifest(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got.parts(), m.parts()) {
+		t.Errorf("read back %+v, want %+v", got.parts(), m.parts())
+	}
+	if n := got.nextSeq(aofTypeIncr); n != 5 {
+		t.Errorf("next incr seq = %d, want 5", n)
+	}
+	if n := got.nextSeq(aofTypeBase); n != 3 {
+		t.Errorf("next base seq = %d, want 3", n)
+	}
+}
+
+func TestLoadOrder(t *testing.T) {
+	dir := t.TempDir()
+	aof := filepath.Join(dir, "redigo.aof")
+	snap := filepath.Join(dir, "redigo.snapshot")
+	l := newAOFLayout(aof, "")
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(aof, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Before the first rewrite: snapshot, the single-file AOF, then the
+	// incremental files.
+	m := &aofManifest{incrs: []aofPart{l.part(1, aofTypeIncr)}}
+	if err := m.write(l); err != nil {
+		t.Fatal(err)
+	}
+	files, err := loadOrder(snap, aof, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{snap, aof, l.path(l.part(1, aofTypeIncr))}
+	if !slices.Equal(files, want) {
+		t.Errorf("load order = %q, want %q", files, want)
+	}
+
+	// A base replaces both the snapshot and the single-file AOF.
+	base := l.part(1, aofTypeBase)
+	m = &aofManifest{base: &base, incrs: []aofPart{l.part(2, aofTypeIncr)}}
+	if err := m.write(l); err != nil {
+		t.Fatal(err)
+	}
+	if files, err = loadOrder(snap, aof, ""); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{l.path(base), l.path(l.part(2, aofTypeIncr))}
+	if !slices.Equal(files, want) {
+		t.Errorf("load order = %q, want %q", files, want)
+	}
+}
+
+func TestManifestRejectsBadLines(t *testing.T) {
+	for _, line := range []string{
+		"file redigo.aof.1.incr.aof seq 0 type i",
+		"file ../escape.aof seq 1 type i",
+		"file redigo.aof.1.incr.aof seq 1 type x",
+		"file redigo.aof.1.incr.aof seq",
+	} {
+		if _, err := parseManifestLine(line); err == nil {
+			t.Errorf("parseManifestLine(%q) succeeded", line)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// mcMaxValue is memcached's default item size limit.
+	mcMaxValue = 1 << 20
+
+	// mcMaxKey is memcached's key length limit.
+	mcMaxKey = 250
+
+	// mcRelativeLimit: memcached treats exptimes up to 30 days as relative
+	// seconds and larger ones as unix timestamps.
+	mcRelativeLimit = 30 * 24 * 60 * 60
+)
+
+// ListenAndServeMemcache listens on addr and serves the memcached text
+// protocol against the server's store until Shutdown is called.
+func (srv *Server) ListenAndServeMemcache(addr string) error {
+	if srv.closing.Load() {
+		return ErrServerClosed
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.ServeMemcache(ln)
+}
+
+// ServeMemcache accepts memcached text-protocol connections on ln, so
+// existing memcached clients can use the store unchanged. It supports
+// get, set, add, replace, append, prepend, delete, incr, decr, touch,
+// version and quit. Item flags are accepted but not stored (get returns 0),
+// and values must not contain line breaks, which the AOF cannot hold.
+// Connections show up in CLIENT LIST and are drained by Shutdown.
+func (srv *Server) ServeMemcache(ln net.Listener) error {
+	go func() {
+		<-srv.done
+		ln.Close()
+	}()
+	srv.log.Info("memcached protocol listening", "addr", ln.Addr().String())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if srv.closing.Load() {
+				return ErrServerClosed
+			}
+			srv.log.Error("accept failed", "err", err)
+			continue
+		}
+		srv.mu.Lock()
+		if srv.closing.Load() {
+			srv.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		srv.conns.Add(1)
+		srv.mu.Unlock()
+		go func() {
+			defer srv.conns.Done()
+			srv.handleMemcache(conn)
+		}()
+	}
+}
+
+func (srv *Server) handleMemcache(conn net.Conn) {
+	c := srv.clients.register(srv, conn)
+	srv.totalConnections.Add(1)
+	c.log.Info("new memcached connection")
+	defer func() {
+		c.log.Info("closing connection")
+		srv.clients.unregister(c)
+		c.flush()
+		conn.Close()
+		c.release()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		if r.Buffered() == 0 {
+			if err := c.flush(); err != nil {
+				c.log.Warn("write failed", "err", err)
+				return
+			}
+		}
+		if srv.closing.Load() {
+			fmt.Fprintf(c, "SERVER_ERROR server is shutting down\r\n")
+			return
+		}
+		srv.setIdleDeadline(c)
+		line, err := r.ReadSlice('\n')
+		if err != nil {
+			switch {
+			case err == bufio.ErrBufferFull:
+				fmt.Fprintf(c, "CLIENT_ERROR line too long\r\n")
+			case srv.closing.Load():
+				fmt.Fprintf(c, "SERVER_ERROR server is shutting down\r\n")
+			case err == io.EOF:
+			case isTimeout(err):
+				c.log.Info("closing idle connection")
+			default:
+				c.log.Warn("read failed", "err", err)
+			}
+			return
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			fmt.Fprintf(c, "ERROR\r\n")
+			continue
+		}
+		cmd := strings.ToLower(fields[0])
+		if cmd == "quit" {
+			return
+		}
+		srv.totalCommands.Add(1)
+		c.touch("mc_" + cmd)
+		ok := srv.memcacheCommand(c, r, cmd, fields[1:])
+		c.flushEffects()
+		if !ok {
+			return
+		}
+	}
+}
+
+// memcacheCommand runs one memcached command. It returns false when the
+// connection can no longer be used, e.g. after a short data block.
+func (srv *Server) memcacheCommand(c *Session, r *bufio.Reader, cmd string, args []string) bool {
+	s := srv.store
+	noreply := len(args) > 0 && args[len(args)-1] == "noreply"
+	if noreply {
+		args = args[:len(args)-1]
+	}
+	reply := func(format string, a ...any) {
+		if !noreply {
+			fmt.Fprintf(c, format, a...)
+		}
+	}
+
+	switch cmd {
+	case "get", "gets":
+		if len(args) == 0 {
+			fmt.Fprintf(c, "ERROR\r\n")
+			return true
+		}
+		for _, key := range args {
+			if v, ok := s.Get(key); ok {
+				fmt.Fprintf(c, "VALUE %s 0 %d\r\n%s\r\n", key, len(v), v)
+			}
+		}
+		fmt.Fprintf(c, "END\r\n")
+
+	case "set", "add", "replace", "append", "prepend":
+		// <cmd> <key> <flags> <exptime> <bytes> [noreply]
+		if len(args) != 4 {
+			fmt.Fprintf(c, "ERROR\r\n")
+			return true
+		}
+		key := args[0]
+		_, ferr := strconv.ParseUint(args[1], 10, 32)
+		exptime, eerr := strconv.ParseInt(args[2], 10, 64)
+		n, nerr := strconv.Atoi(args[3])
+		if ferr != nil || eerr != nil || nerr != nil || n < 0 {
+			fmt.Fprintf(c, "CLIENT_ERROR bad command line format\r\n")
+			return true
+		}
+		if n > mcMaxValue {
+			// Skip the data block so the connection stays usable.
+			if _, err := r.Discard(n + 2); err != nil {
+				return false
+			}
+			reply("SERVER_ERROR object too large for cache\r\n")
+			return true
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return false
+		}
+		if !bytes.HasSuffix(data, []byte("\r\n")) {
+			fmt.Fprintf(c, "CLIENT_ERROR bad data chunk\r\n")
+			return false
+		}
+		value := string(data[:n])
+		if !validMemcacheKey(key) {
+			reply("CLIENT_ERROR bad key\r\n")
+			return true
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			reply("SERVER_ERROR line breaks in values are not supported\r\n")
+			return true
+		}
+
+		exists := s.Exists(key)
+		switch {
+		case cmd == "add" && exists,
+			cmd != "set" && cmd != "add" && !exists:
+			reply("NOT_STORED\r\n")
+			return true
+		}
+		ttl, expired := memcacheTTL(exptime)
+		switch cmd {
+		case "append", "prepend":
+			old, _ := s.Get(key)
+			if cmd == "append" {
+				value = old + value
+			} else {
+				value += old
+			}
+			// append and prepend keep the item's expiry
+			ttl, expired = s.TTL(key), false
+			if ttl < 0 {
+				ttl = 0
+			}
+		}
+		if expired {
+			if s.Del(key) {
+				c.propagate("DEL", key)
+			}
+			reply("STORED\r\n")
+			return true
+		}
+		if err := s.Setwithttl(key, value, ttl); err != nil {
+			reply("SERVER_ERROR %s\r\n", err)
+			return true
+		}
+		c.propagateValue(key, value)
+		reply("STORED\r\n")
+
+	case "delete":
+		// delete <key> [0] [noreply]; the legacy time argument must be 0
+		if len(args) == 0 || len(args) > 2 || (len(args) == 2 && args[1] != "0") {
+			fmt.Fprintf(c, "CLIENT_ERROR bad command line format\r\n")
+			return true
+		}
+		if s.Del(args[0]) {
+			c.propagate("DEL", args[0])
+			reply("DELETED\r\n")
+		} else {
+			reply("NOT_FOUND\r\n")
+		}
+
+	case "incr", "decr":
+		if len(args) != 2 {
+			fmt.Fprintf(c, "ERROR\r\n")
+			return true
+		}
+		key := args[0]
+		delta, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || delta < 0 {
+			reply("CLIENT_ERROR invalid numeric delta argument\r\n")
+			return true
+		}
+		old, ok := s.Get(key)
+		if !ok {
+			reply("NOT_FOUND\r\n")
+			return true
+		}
+		cur, err := strconv.ParseInt(old, 10, 64)
+		if err != nil || cur < 0 {
+			reply("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return true
+		}
+		// memcached decrements stop at 0
+		if cmd == "decr" {
+			delta = -min(delta, cur)
+		}
+		num, err := s.IncrBy(key, delta)
+		if err != nil {
+			reply("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return true
+		}
+		c.propagateValue(key, strconv.FormatInt(num, 10))
+		reply("%d\r\n", num)
+
+	case "touch":
+		if len(args) != 2 {
+			fmt.Fprintf(c, "ERROR\r\n")
+			return true
+		}
+		exptime, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			fmt.Fprintf(c, "CLIENT_ERROR invalid exptime argument\r\n")
+			return true
+		}
+		key := args[0]
+		if !s.Exists(key) {
+			reply("NOT_FOUND\r\n")
+			return true
+		}
+		ttl, expired := memcacheTTL(exptime)
+		if expired {
+			if s.Del(key) {
+				c.propagate("DEL", key)
+			}
+		} else if ttl == 0 {
+			if s.Persist(key) {
+				c.propagate("PERSIST", key)
+			}
+		} else if at := s.Now().Unix() + ttl; s.ExpireAt(key, at) {
+			c.propagate("EXPIREAT", key, strconv.FormatInt(at, 10))
+		}
+		reply("TOUCHED\r\n")
+
+	case "version":
+		fmt.Fprintf(c, "VERSION %s\r\n", Version)
+
+	default:
+		fmt.Fprintf(c, "ERROR\r\n")
+	}
+	return true
+}
+
+// memcacheTTL converts a memcached exptime into a TTL in seconds (0 means
+// no expiry) and reports whether the item is already expired.
+func memcacheTTL(exptime int64) (ttl int64, expired bool) {
+	switch {
+	case exptime == 0:
+		return 0, false
+	case exptime < 0:
+		return 0, true
+	case exptime <= mcRelativeLimit:
+		return exptime, false
+	}
+	ttl = exptime - time.Now().Unix()
+	return ttl, ttl <= 0
+}
+
+func validMemcacheKey(key string) bool {
+	if len(key) == 0 || len(key) > mcMaxKey {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/promtext"
+)
+
+// Histogram buckets exported for command latency: 2^3 µs (8µs) through
+// 2^24 µs (~16.8s), a subset of the cmdStat buckets.
+const (
+	firstMetricBucket = 3
+	lastMetricBucket  = 24
+)
+
+// MetricsHandler serves the server's metrics in the Prometheus text format.
+// Mount it on a separate HTTP listener, e.g. at /metrics.
+func (srv *Server) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		srv.writeMetrics(promtext.NewWriter(&buf))
+		w.Header().Set("Content-Type", promtext.ContentType)
+		w.Write(buf.Bytes())
+	})
+}
+
+func (srv *Server) writeMetrics(p *promtext.Writer) {
+	stats := srv.store.Stats()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	p.Gauge("redigo_uptime_seconds", "Seconds since the server started.", time.Since(srv.startTime).Seconds())
+	p.Gauge("redigo_connected_clients", "Number of client connections.", float64(len(srv.clients.list())))
+	p.Gauge("redigo_monitors", "Number of clients in MONITOR mode.", float64(srv.monitors.count()))
+	p.Counter("redigo_connections_received_total", "Connections accepted since start or CONFIG RESETSTAT.", float64(srv.totalConnections.Load()))
+	p.Counter("redigo_commands_processed_total", "Commands processed since start or CONFIG RESETSTAT.", float64(srv.totalCommands.Load()))
+
+	p.Gauge("redigo_keys", "Number of keys in the dataset.", float64(stats.Keys))
+	p.Gauge("redigo_expires", "Number of keys with a TTL.", float64(stats.Expires))
+	p.Gauge("redigo_max_keys", "Key limit (0 means unlimited).", float64(stats.MaxKeys))
+	p.Gauge("redigo_memory_dataset_bytes", "Approximate size of the dataset.", float64(stats.UsedMemory))
+	p.Gauge("redigo_memory_max_bytes", "Dataset memory limit (0 means unlimited).", float64(stats.MaxMemory))
+	p.Gauge("redigo_memory_heap_bytes", "Go heap bytes in use.", float64(ms.HeapAlloc))
+	p.Gauge("redigo_memory_sys_bytes", "Bytes obtained from the OS by the Go runtime.", float64(ms.Sys))
+	p.Counter("redigo_evictions_total", "Keys evicted or expired.", float64(stats.Evictions))
+	p.Counter("redigo_reads_total", "Key reads.", float64(stats.Reads))
+	p.Counter("redigo_writes_total", "Key writes.", float64(stats.Writes))
+	p.Counter("redigo_keyspace_hits_total", "Reads of existing keys.", float64(stats.Hits))
+	p.Counter("redigo_keyspace_misses_total", "Reads of missing or expired keys.", float64(stats.Misses))
+	p.Counter("redigo_events_dropped_total", "Store events dropped because the hook queue was full.", float64(stats.EventsDropped))
+
+	p.Gauge("redigo_aof_enabled", "Whether writes are logged to the AOF.", float64(boolInt(srv.aof.enabled())))
+	aofSize, _ := srv.aof.sizes()
+	p.Gauge("redigo_aof_size_bytes", "Size of the AOF on disk.", float64(aofSize))
+	srv.saves.mu.Lock()
+	lastSave, inProgress := srv.saves.lastSave, srv.saves.inProgress
+	srv.saves.mu.Unlock()
+	var lastSaveTS float64
+	if !lastSave.IsZero() {
+		lastSaveTS = float64(lastSave.Unix())
+	}
+	p.Gauge("redigo_last_save_timestamp_seconds", "Unix time of the last successful snapshot (0 if none).", lastSaveTS)
+	p.Gauge("redigo_bgsave_in_progress", "Whether a snapshot is being written.", float64(boolInt(inProgress)))
+	// The primary does not track its replicas; lag is exported by each
+	// replica as redigo_replication_lag_seconds.
+	p.Gauge("redigo_connected_replicas", "Replicas known to this server.", 0)
+
+	snaps := srv.cmdStats.snapshot()
+	p.Family("redigo_command_calls_total", "counter", "Calls per command.")
+	for _, st := range snaps {
+		p.Sample("redigo_command_calls_total", float64(st.calls), "cmd", strings.ToLower(st.name))
+	}
+	p.Family("redigo_command_failed_calls_total", "counter", "Calls per command that replied with an error.")
+	for _, st := range snaps {
+		p.Sample("redigo_command_failed_calls_total", float64(st.failed), "cmd", strings.ToLower(st.name))
+	}
+	p.Family("redigo_command_duration_seconds", "histogram", "Command execution time.")
+	bounds := make([]float64, 0, lastMetricBucket-firstMetricBucket+1)
+	for i := firstMetricBucket; i <= lastMetricBucket; i++ {
+		bounds = append(bounds, float64(int64(1)<<i)/1e6)
+	}
+	counts := make([]uint64, len(bounds))
+	for _, st := range snaps {
+		var cum uint64
+		for i, n := range st.hist {
+			cum += uint64(n)
+			if i >= firstMetricBucket && i <= lastMetricBucket {
+				counts[i-firstMetricBucket] = cum
+			}
+		}
+		p.Histogram("redigo_command_duration_seconds", bounds, counts,
+			float64(st.usec)/1e6, uint64(st.calls), "cmd", strings.ToLower(st.name))
+	}
+	p.Family("redigo_command_latency_seconds", "summary", "Command execution time percentiles since start or CONFIG RESETSTAT.")
+	quantiles := make([]float64, len(latencyPercentiles))
+	for i, q := range latencyPercentiles {
+		quantiles[i] = math.Round(q*1000) / 1e5 // 0.999, not 0.9990000000000001
+	}
+	values := make([]float64, len(latencyPercentiles))
+	for _, st := range snaps {
+		for i, q := range latencyPercentiles {
+			values[i] = float64(st.lat.percentile(q)) / 1e9
+		}
+		p.Summary("redigo_command_latency_seconds", quantiles, values,
+			float64(st.usec)/1e6, uint64(st.calls), "cmd", strings.ToLower(st.name))
+	}
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutputLimit bounds the output queued for a MONITOR client that does not
+// keep up, like Redis's client-output-buffer-limit: the client is
+// disconnected as soon as its pending output exceeds Hard, or once it has
+// stayed above Soft for SoftSeconds. A zero limit is disabled.
+type OutputLimit struct {
+	Hard        int64
+	Soft        int64
+	SoftSeconds int
+}
+
+func (l OutputLimit) String() string {
+	return fmt.Sprintf("monitor %d %d %d", l.Hard, l.Soft, l.SoftSeconds)
+}
+
+// exceeded reports whether pending bytes break the limit, given when the
+// output first went over the soft limit (zero if it has not).
+func (l OutputLimit) exceeded(pending int64, overSoftSince, now time.Time) bool {
+	if l.Hard > 0 && pending > l.Hard {
+		return true
+	}
+	return l.Soft > 0 && pending > l.Soft && !overSoftSince.IsZero() &&
+		now.Sub(overSoftSince) >= time.Duration(l.SoftSeconds)*time.Second
+}
+
+// monitor streams processed commands to a client that issued MONITOR.
+// Lines are queued and written by a dedicated goroutine so a slow monitor
+// never stalls command processing; the queue is bounded by the server's
+// OutputLimit.
+type monitor struct {
+	c *Session
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []string
+	closed  bool
+
+	// pendingBytes counts queued lines plus the batch being written.
+	pendingBytes int64
+	overSoft     time.Time // when pendingBytes went over the soft limit
+}
+
+// monitorRegistry tracks the connections currently in MONITOR mode.
+type monitorRegistry struct {
+	mu       sync.RWMutex
+	monitors map[int64]*monitor
+}
+
+// add puts c into MONITOR mode.
+func (r *monitorRegistry) add(c *Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.monitors[c.id]; ok {
+		return
+	}
+	m := &monitor{c: c}
+	m.cond = sync.NewCond(&m.mu)
+	r.monitors[c.id] = m
+	go m.run()
+}
+
+// remove stops streaming to c, if it was monitoring.
+func (r *monitorRegistry) remove(c *Session) {
+	r.mu.Lock()
+	m, ok := r.monitors[c.id]
+	delete(r.monitors, c.id)
+	r.mu.Unlock()
+	if ok {
+		m.close()
+	}
+}
+
+func (r *monitorRegistry) isMonitor(c *Session) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.monitors[c.id]
+	return ok
+}
+
+// pendingBytes returns the output queued for c, or 0 if it is not a
+// monitor.
+func (r *monitorRegistry) pendingBytes(c *Session) int64 {
+	r.mu.RLock()
+	m, ok := r.monitors[c.id]
+	r.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pendingBytes
+}
+
+// feed sends a command issued by from to every monitor except from itself,
+// then disconnects the monitors whose output went over the limit.
+func (r *monitorRegistry) feed(from *Session, cmd string, args []string) {
+	r.mu.RLock()
+	if len(r.monitors) == 0 {
+		r.mu.RUnlock()
+		return
+	}
+	now := time.Now()
+	line := formatMonitorLine(now, from, cmd, args)
+	limit := from.srv.monitorLimit.Load()
+	var slow []*monitor
+	for id, m := range r.monitors {
+		if id == from.id {
+			continue
+		}
+		if !m.push(line, limit, now) {
+			slow = append(slow, m)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, m := range slow {
+		m.c.log.Warn("closing monitor over the output buffer limit", "omem", m.queued(), "limit", limit.String())
+		from.srv.outputLimitDisconnects.Add(1)
+		r.remove(m.c)
+		m.c.Close()
+	}
+}
+
+// formatMonitorLine renders a command the way Redis MONITOR does, with the
+// client id and command sequence number added to the bracket:
+// +<unix time> [<db> <addr> id=<client id> seq=<seq>] "cmd" "arg" ...
+func formatMonitorLine(t time.Time, from *Session, cmd string, args []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "+%d.%06d [0 %s id=%d seq=%d]", t.Unix(), t.Nanosecond()/1000, from.RemoteAddr(), from.id, from.seq)
+	b.WriteString(" ")
+	b.WriteString(strconv.Quote(strings.ToLower(cmd)))
+	for _, a := range args {
+		b.WriteString(" ")
+		b.WriteString(strconv.Quote(a))
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// push queues line, unless that takes the monitor over limit; then it
+// returns false and the caller disconnects the monitor.
+func (m *monitor) push(line string, limit *OutputLimit, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return true
+	}
+	m.pendingBytes += int64(len(line))
+	switch {
+	case limit.Soft == 0 || m.pendingBytes <= limit.Soft:
+		m.overSoft = time.Time{}
+	case m.overSoft.IsZero():
+		m.overSoft = now
+	}
+	if limit.exceeded(m.pendingBytes, m.overSoft, now) {
+		m.closed = true
+		m.cond.Signal()
+		return false
+	}
+	m.pending = append(m.pending, line)
+	m.cond.Signal()
+	return true
+}
+
+func (m *monitor) queued() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pendingBytes
+}
+
+func (m *monitor) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.cond.Signal()
+}
+
+// run writes queued lines until the monitor is closed or the write fails.
+func (m *monitor) run() {
+	for {
+		m.mu.Lock()
+		for len(m.pending) == 0 && !m.closed {
+			m.cond.Wait()
+		}
+		if m.closed {
+			m.mu.Unlock()
+			return
+		}
+		batch := m.pending
+		m.pending = nil
+		m.mu.Unlock()
+
+		for _, line := range batch {
+			// Write to the raw connection: the client's own Write tracks
+			// replies of the monitor's goroutine and must not be shared.
+			if _, err := m.c.Conn.Write([]byte(line)); err != nil {
+				m.c.srv.monitors.remove(m.c)
+				return
+			}
+			m.mu.Lock()
+			m.pendingBytes -= int64(len(line))
+			m.mu.Unlock()
+		}
+	}
+}
+
+func (r *monitorRegistry) count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.monitors)
+}
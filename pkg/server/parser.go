@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// parser reads inline commands ("CMD arg arg...\r\n") from a connection and
+// reuses its buffers between commands. The hot path performs a single
+// allocation per command: the string holding the arguments, which the
+// returned args are substrings of (handlers may keep individual args, but
+// not the args slice itself, which is reused).
+type parser struct {
+	r     *bufio.Reader
+	long  []byte   // accumulates lines longer than the reader's buffer
+	upper []byte   // upper-cased command name of the current command
+	args  []string // arguments of the current command
+}
+
+// Parsers are pooled, read buffer and token slices included, so servers
+// with many short-lived clients don't allocate them for every connection.
+var parsers = sync.Pool{New: func() any {
+	return &parser{r: bufio.NewReader(nil)}
+}}
+
+// maxPooledBuffer caps the capacity of buffers kept for reuse, so a single
+// huge command doesn't pin its buffer in the pool.
+const maxPooledBuffer = 64 << 10
+
+func newParser(r io.Reader) *parser {
+	p := parsers.Get().(*parser)
+	p.r.Reset(r)
+	return p
+}
+
+// release returns p to the pool. p must not be used afterwards.
+func (p *parser) release() {
+	p.r.Reset(nil)
+	clear(p.args[:cap(p.args)]) // don't keep the last arguments alive
+	p.args = p.args[:0]
+	if cap(p.long) > maxPooledBuffer {
+		p.long = nil
+	}
+	parsers.Put(p)
+}
+
+// buffered reports how many bytes of pipelined input are already read.
+func (p *parser) buffered() int {
+	return p.r.Buffered()
+}
+
+// readCommand reads the next non-blank line and returns its upper-cased
+// command name (valid until the next call) and arguments.
+func (p *parser) readCommand() ([]byte, []string, error) {
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = trimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		end := 0
+		for end < len(line) && !isSpace(line[end]) {
+			end++
+		}
+		p.upper = p.upper[:0]
+		for _, b := range line[:end] {
+			if 'a' <= b && b <= 'z' {
+				b -= 'a' - 'A'
+			}
+			p.upper = append(p.upper, b)
+		}
+
+		p.args = p.args[:0]
+		if rest := trimSpace(line[end:]); len(rest) > 0 {
+			p.args = splitFields(p.args, string(rest))
+		}
+		return p.upper, p.args, nil
+	}
+}
+
+// readLine returns the next line without copying it when it fits in the
+// reader's buffer. The result is only valid until the next read.
+func (p *parser) readLine() ([]byte, error) {
+	line, err := p.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		p.long = append(p.long[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = p.r.ReadSlice('\n')
+			p.long = append(p.long, line...)
+		}
+		line = p.long
+	}
+	// A final line without a trailing newline still counts as a command;
+	// the error is reported by the next call.
+	if err != nil && len(line) > 0 {
+		return line, nil
+	}
+	return line, err
+}
+
+// splitFields appends the whitespace-separated fields of s to dst.
+func splitFields(dst []string, s string) []string {
+	start := -1
+	for i := 0; i < len(s); i++ {
+		if isSpace(s[i]) {
+			if start >= 0 {
+				dst = append(dst, s[start:i])
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		dst = append(dst, s[start:])
+	}
+	return dst
+}
+
+func trimSpace(b []byte) []byte {
+	for len(b) > 0 && isSpace(b[0]) {
+		b = b[1:]
+	}
+	for len(b) > 0 && isSpace(b[len(b)-1]) {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\v', '\f':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// pauseState implements CLIENT PAUSE: while active, matching commands wait
+// until the deadline passes or CLIENT UNPAUSE is called.
+type pauseState struct {
+	mu        sync.Mutex
+	until     time.Time
+	writeOnly bool
+	resume    chan struct{} // closed (and replaced) on unpause
+}
+
+// pauseFor pauses all commands (or only writes) for d.
+func (p *pauseState) pauseFor(d time.Duration, writeOnly bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.until = time.Now().Add(d)
+	p.writeOnly = writeOnly
+}
+
+// unpause lifts the pause and wakes every waiting client.
+func (p *pauseState) unpause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.until = time.Time{}
+	close(p.resume)
+	p.resume = make(chan struct{})
+}
+
+// wait blocks until cmd may run. CLIENT is never paused so that the pause
+// can always be lifted.
+func (p *pauseState) wait(cmd string) {
+	if cmd == "CLIENT" {
+		return
+	}
+	for {
+		p.mu.Lock()
+		remaining := time.Until(p.until)
+		blocked := remaining > 0 && (!p.writeOnly || isWriteCommand(cmd))
+		resume := p.resume
+		p.mu.Unlock()
+
+		if !blocked {
+			return
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-resume:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// active reports whether a pause is currently in effect.
+func (p *pauseState) active() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return time.Now().Before(p.until)
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/DakshBaxi/RediGo/internal/encryption"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// Replay reads a file on one goroutine and applies its records on several.
+// Each key is always handled by the same worker, so the records of a key
+// keep their order; a record without a key, such as FLUSHALL, waits for
+// every record before it and runs alone.
+
+const (
+	// replayBatch is how many records the reader hands a worker at once.
+	replayBatch = 512
+
+	// replayProgressInterval is how often a long replay logs its progress.
+	replayProgressInterval = 5 * time.Second
+)
+
+// errTornRecord is returned for a file whose last record has no trailing
+// newline, as after a crash in the middle of a write.
+var errTornRecord = errors.New("last record is truncated (set aof-load-truncated yes to drop it)")
+
+// replayAOF loads the records of path, an AOF or a snapshot, into s on
+// workers goroutines (0 means GOMAXPROCS), logging its progress. A sealed
+// file is opened with key. A missing file is not an error. A truncated
+// last record, or a sealed file ending in a partial frame, fails the
+// replay, or is dropped with a warning if loadTruncated is set; a frame
+// that fails to open always does, and so does a record that can't be
+// applied: skipping it would load part of the data. CheckPersistence lists
+// every such record.
+func replayAOF(s *store.Store, path string, key []byte, workers int, loadTruncated bool, log *slog.Logger) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // nothing to replay yet
+		}
+		return err
+	}
+	defer f.Close()
+	var size int64
+	if fi, err := f.Stat(); err == nil {
+		size = fi.Size()
+	}
+
+	r := newReplayer(s, workers)
+	defer r.close()
+	start := time.Now()
+	lastLog := start
+	var offset int64
+	records := 0
+	rd := bufio.NewReaderSize(f, 64*1024)
+	if encryption.IsSealed(rd) {
+		in, err := encryption.NewReader(rd, key)
+		if errors.Is(err, encryption.ErrTruncated) && loadTruncated {
+			// the crash came before the first write
+			log.Warn("ignoring a truncated encrypted file", "path", path)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rd = bufio.NewReaderSize(in, 64*1024)
+	}
+	for {
+		line, err := rd.ReadString('\n')
+		if errors.Is(err, encryption.ErrTruncated) && loadTruncated {
+			// every frame holds whole records: the torn write at the end
+			// is dropped, as a partial record in a plain file would be
+			log.Warn("dropping a partial write at the end of the file", "path", path, "records", records)
+			break
+		}
+		if errors.Is(err, encryption.ErrTruncated) {
+			return fmt.Errorf("%w (set aof-load-truncated yes to drop it)", err)
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(line) > maxReplayLine {
+			return fmt.Errorf("record at offset %d is longer than %d bytes", offset, maxReplayLine)
+		}
+		if err == io.EOF && strings.TrimSpace(line) != "" {
+			// the last write never finished: applying it would load a
+			// partial value
+			if !loadTruncated {
+				return fmt.Errorf("offset %d: %w", offset, errTornRecord)
+			}
+			log.Warn("dropping a truncated record at the end of the file", "path", path, "offset", offset)
+			break
+		}
+		offset += int64(len(line))
+		if strings.TrimSpace(line) != "" {
+			records++
+			r.add(line)
+		}
+		if err == io.EOF {
+			break
+		}
+		// checking the clock every record would slow the replay down
+		if records%4096 == 0 && time.Since(lastLog) >= replayProgressInterval {
+			lastLog = time.Now()
+			log.Info("replaying", "path", path, "records", records,
+				"percent", offset*100/max(size, 1), "records_per_sec", perSecond(records, lastLog.Sub(start)))
+		}
+	}
+	r.wait()
+	if err := r.failure(); err != nil {
+		return err
+	}
+	if records > 0 {
+		d := time.Since(start)
+		log.Info("replay done", "path", path, "records", records, "workers", len(r.queues),
+			"duration", d, "records_per_sec", perSecond(records, d))
+	}
+	return nil
+}
+
+// perSecond returns the rate of n events over d.
+func perSecond(n int, d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(float64(n) / d.Seconds())
+}
+
+// replayer dispatches records to the replay workers.
+type replayer struct {
+	s       *store.Store
+	seed    maphash.Seed
+	queues  []chan []string // one per worker
+	pending [][]string      // the batch being filled for each worker
+	batches sync.WaitGroup  // batches sent but not applied yet
+
+	mu  sync.Mutex
+	err error // the first record that failed to apply
+}
+
+func newReplayer(s *store.Store, workers int) *replayer {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	r := &replayer{
+		s:       s,
+		seed:    maphash.MakeSeed(),
+		queues:  make([]chan []string, workers),
+		pending: make([][]string, workers),
+	}
+	for i := range r.queues {
+		q := make(chan []string, 4)
+		r.queues[i] = q
+		go func() {
+			for batch := range q {
+				for _, line := range batch {
+					if err := replayLine(s, line); err != nil {
+						r.fail(err)
+					}
+				}
+				r.batches.Done()
+			}
+		}()
+	}
+	return r
+}
+
+// add queues one record for its key's worker.
+func (r *replayer) add(line string) {
+	key := recordKey(line)
+	if key == "" {
+		r.wait()
+		if err := replayLine(r.s, line); err != nil {
+			r.fail(err)
+		}
+		return
+	}
+	i := maphash.String(r.seed, key) % uint64(len(r.queues))
+	r.pending[i] = append(r.pending[i], line)
+	if len(r.pending[i]) == replayBatch {
+		r.send(int(i))
+	}
+}
+
+func (r *replayer) send(i int) {
+	r.batches.Add(1)
+	r.queues[i] <- r.pending[i]
+	r.pending[i] = make([]string, 0, replayBatch)
+}
+
+// fail records err unless a record failed before.
+func (r *replayer) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// failure returns the error of the first record that failed to apply.
+func (r *replayer) failure() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// wait returns once every record added so far has been applied.
+func (r *replayer) wait() {
+	for i, batch := range r.pending {
+		if len(batch) > 0 {
+			r.send(i)
+		}
+	}
+	r.batches.Wait()
+}
+
+// close applies what is left and stops the workers.
+func (r *replayer) close() {
+	r.wait()
+	for _, q := range r.queues {
+		close(q)
+	}
+}
+
+// replayLine applies one record.
+func replayLine(s *store.Store, line string) error {
+	parts := strings.Fields(line)
+	if len(parts) == 0 {
+		return nil
+	}
+	if err := replayRecord(s, parts); err != nil {
+		return fmt.Errorf("record %q: %w (run with -check-persistence to list every bad record)", shortRecord(parts), err)
+	}
+	return nil
+}
+
+// recordKey returns the second word of a record, its key, or "" if it has
+// none. Words are split as strings.Fields splits them, so the key is the
+// one replayRecord will use.
+func recordKey(line string) string {
+	line = strings.TrimLeftFunc(line, unicode.IsSpace)
+	i := strings.IndexFunc(line, unicode.IsSpace)
+	if i < 0 {
+		return ""
+	}
+	line = strings.TrimLeftFunc(line[i:], unicode.IsSpace)
+	if i = strings.IndexFunc(line, unicode.IsSpace); i >= 0 {
+		line = line[:i]
+	}
+	return line
+}
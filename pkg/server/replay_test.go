@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DakshBaxi/RediGo/internal/encryption"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func writeFile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "redigo.aof")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestReplayKeepsOrder replays many keys on several workers: the records
+// of a key must apply in order, and FLUSHALL must wait for every record
+// before it and hold back every record after it.
+func TestReplayKeepsOrder(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&b, "SET k%d v%d\n", i%97, i)
+	}
+	b.WriteString("FLUSHALL\n")
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&b, "SET n%d v%d\n", i%31, i)
+		fmt.Fprintf(&b, "INCRBY c%d 1\n", i%7)
+	}
+	b.WriteString("DEL n0\n")
+	path := writeFile(t, b.String())
+
+	for _, workers := range []int{1, 4, 16} {
+		s := store.New()
+		if err := replayAOF(s, path, nil, workers, false, discard); err != nil {
+			t.Fatalf("workers=%d: %v", workers, err)
+		}
+		if _, ok := s.Get("k1"); ok {
+			t.Errorf("workers=%d: k1 survived FLUSHALL", workers)
+		}
+		if _, ok := s.Get("n0"); ok {
+			t.Errorf("workers=%d: n0 survived DEL", workers)
+		}
+		for i := 1; i < 31; i++ {
+			last := 2999 - (2999-i)%31
+			if v, _ := s.Get(fmt.Sprintf("n%d", i)); v != fmt.Sprintf("v%d", last) {
+				t.Errorf("workers=%d: n%d = %q, want v%d", workers, i, v, last)
+			}
+		}
+		for i := 0; i < 7; i++ {
+			want := 3000 / 7
+			if i < 3000%7 {
+				want++
+			}
+			if v, _ := s.Get(fmt.Sprintf("c%d", i)); v != fmt.Sprint(want) {
+				t.Errorf("workers=%d: c%d = %q, want %d", workers, i, v, want)
+			}
+		}
+	}
+}
+
+func TestReplayTornRecord(t *testing.T) {
+	path := writeFile(t, "SET a 1\nSET b 2\nSET c par")
+
+	err := replayAOF(store.New(), path, nil, 2, false, discard)
+	if !errors.Is(err, errTornRecord) {
+		t.Fatalf("replay = %v, want %v", err, errTornRecord)
+	}
+
+	s := store.New()
+	if err := replayAOF(s, path, nil, 2, true, discard); err != nil {
+		t.Fatalf("replay with load-truncated: %v", err)
+	}
+	if v, _ := s.Get("b"); v != "2" {
+		t.Errorf("b = %q, want 2", v)
+	}
+	if _, ok := s.Get("c"); ok {
+		t.Error("the truncated record was applied")
+	}
+}
+
+func sealed(t *testing.T, key []byte, writes ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := encryption.NewWriter(&buf, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range writes {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestReplayEncrypted(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, encryption.KeySize)
+	data := sealed(t, key, "SET a 1\nSET b 2\n", "FLUSHALL\nSET c 3\n", "INCRBY c 4\n")
+	path := writeFile(t, string(data))
+	if bytes.Contains(data, []byte("SET")) {
+		t.Fatal("the sealed file holds records in the clear")
+	}
+
+	s := store.New()
+	if err := replayAOF(s, path, key, 4, false, discard); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Get("a"); ok {
+		t.Error("a survived FLUSHALL")
+	}
+	if v, _ := s.Get("c"); v != "7" {
+		t.Errorf("c = %q, want 7", v)
+	}
+
+	other := bytes.Repeat([]byte{8}, encryption.KeySize)
+	if err := replayAOF(store.New(), path, other, 4, false, discard); !errors.Is(err, encryption.ErrWrongKey) {
+		t.Errorf("replay with another key = %v, want %v", err, encryption.ErrWrongKey)
+	}
+	if err := replayAOF(store.New(), path, nil, 4, false, discard); !errors.Is(err, encryption.ErrNoKey) {
+		t.Errorf("replay without a key = %v, want %v", err, encryption.ErrNoKey)
+	}
+}
+
+func TestReplayEncryptedDamage(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, encryption.KeySize)
+	data := sealed(t, key, "SET a 1\n", "SET b 2\n")
+
+	torn := writeFile(t, string(data[:len(data)-3]))
+	if err := replayAOF(store.New(), torn, key, 2, false, discard); !errors.Is(err, encryption.ErrTruncated) {
+		t.Errorf("torn file: replay = %v, want %v", err, encryption.ErrTruncated)
+	}
+	s := store.New()
+	if err := replayAOF(s, torn, key, 2, true, discard); err != nil {
+		t.Errorf("torn file with load-truncated: %v", err)
+	}
+	if v, _ := s.Get("a"); v != "1" {
+		t.Errorf("a = %q, want 1", v)
+	}
+
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)-1] ^= 1
+	corrupt := writeFile(t, string(flipped))
+	for _, loadTruncated := range []bool{false, true} {
+		err := replayAOF(store.New(), corrupt, key, 2, loadTruncated, discard)
+		if !errors.Is(err, encryption.ErrCorrupt) {
+			t.Errorf("corrupt file, load-truncated %v: replay = %v, want %v", loadTruncated, err, encryption.ErrCorrupt)
+		}
+	}
+}
+
+func TestReplayBadRecord(t *testing.T) {
+	limited := store.New()
+	limited.SetEvictionPolicy(store.PolicyNoEviction)
+	limited.SetMaxKeys(1)
+	tests := []struct {
+		name string
+		s    *store.Store
+		data string
+		want error
+	}{
+		{"bad number", store.New(), "SET a 1\nINCRBY a x\nSET b 2\n", nil},
+		{"unknown command", store.New(), "SET a 1\nLPUSH l x\n", nil},
+		{"over maxkeys", limited, "SET a 1\nSET b 2\n", store.ErrOOM},
+	}
+	for _, tt := range tests {
+		err := replayAOF(tt.s, writeFile(t, tt.data), nil, 4, false, discard)
+		if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("%s: replay = %v, want an error wrapping %v", tt.name, err, tt.want)
+		}
+	}
+}
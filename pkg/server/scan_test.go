@@ -0,0 +1,37 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/DakshBaxi/RediGo/pkg/redigotest"
+)
+
+func TestScanArguments(t *testing.T) {
+	srv := redigotest.Start(t, redigotest.Options{})
+	redigotest.AssertOK(t, srv.Do("SET", "a", "1"))
+
+	tests := []struct {
+		args []string
+		err  string   // prefix of the expected error, if any
+		want []string // expected lines otherwise
+	}{
+		{args: []string{"SCAN"}, err: "ERR wrong number of arguments"},
+		{args: []string{"SCAN", "x"}, err: "ERR invalid cursor"},
+		{args: []string{"SCAN", "0", "COUNT"}, err: "ERR COUNT requires an argument"},
+		{args: []string{"SCAN", "0", "COUNT", "0"}, err: "ERR invalid COUNT"},
+		{args: []string{"SCAN", "0", "MATCH", "["}, err: "ERR invalid pattern"},
+		{args: []string{"SCAN", "0", "SORT", "x"}, err: "ERR syntax error"},
+		{args: []string{"SCAN", "0"}, want: []string{"cursor:0", "a"}},
+		{args: []string{"SCAN", "0", "MATCH", "b*"}, want: []string{"cursor:0"}},
+	}
+	for _, tt := range tests {
+		r := srv.Do(tt.args...)
+		if tt.err != "" {
+			redigotest.AssertError(t, r, tt.err)
+		} else {
+			redigotest.AssertLines(t, r, tt.want...)
+		}
+	}
+	// the connection survived every bad call
+	redigotest.AssertString(t, srv.Do("GET", "a"), "1")
+}
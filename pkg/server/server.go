@@ -0,0 +1,859 @@
+// Package server implements the RediGo text-protocol server. A Server owns
+// its connections, statistics and persistence files, so several servers can
+// run in one process, e.g. on ephemeral ports in tests.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/encryption"
+	"github.com/DakshBaxi/RediGo/internal/protocol"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+	"github.com/DakshBaxi/RediGo/pkg/tracing"
+)
+
+// DefaultAddr is used when Options.Addr is empty. Redis defaults to 6379;
+// we use 6380 for safety.
+const DefaultAddr = ":6380"
+
+// ErrServerClosed is returned by Serve and ListenAndServe once Shutdown or
+// Close has been called.
+var ErrServerClosed = errors.New("redigo: server closed")
+
+// Listener is one endpoint ListenAndServe accepts clients on.
+type Listener struct {
+	// Network is "tcp" (the default) or "unix".
+	Network string
+
+	// Addr is a TCP address or a Unix socket path. A stale socket file
+	// is removed before listening.
+	Addr string
+
+	// TLS serves TLS on this endpoint, using Options.TLSCertFile and
+	// TLSKeyFile.
+	TLS bool
+
+	// ReadOnly rejects write commands from clients of this endpoint.
+	ReadOnly bool
+}
+
+func (l Listener) String() string {
+	network := l.Network
+	if network == "" {
+		network = "tcp"
+	}
+	s := network + " " + l.Addr
+	if l.TLS {
+		s += " tls"
+	}
+	if l.ReadOnly {
+		s += " readonly"
+	}
+	return s
+}
+
+// Options configures a Server. The zero value serves a fresh in-memory store
+// on DefaultAddr without persistence.
+type Options struct {
+	// Addr is the TCP address ListenAndServe listens on. Use ":0" to pick an
+	// ephemeral port and Addr to find out which one was chosen.
+	Addr string
+
+	// Listeners, if set, replaces Addr with several endpoints served at
+	// once (plain TCP, TLS and Unix sockets), all sharing the store.
+	Listeners []Listener
+
+	// Store is the dataset to serve; nil creates an empty store.
+	Store *store.Store
+
+	// AOFPath is the append-only file writes are logged to and replayed
+	// from on startup. Empty disables the AOF. The AOF is kept in parts,
+	// listed by a manifest, in the directory AOFDirName next to AOFPath
+	// (DefaultAOFDirName if empty), under names starting with the base
+	// name of AOFPath. A plain file at AOFPath, from before the split, is
+	// replayed and then imported into the first base.
+	AOFPath    string
+	AOFDirName string
+
+	// AOFLoadTruncated lets New drop a record cut short at the end of a
+	// file, as a crash in the middle of a write leaves it, with a warning.
+	// Otherwise New fails on it, as on any other error loading the data,
+	// so that files holding data it couldn't load are never rewritten.
+	AOFLoadTruncated bool
+
+	// AOFRewritePercentage rewrites the AOF automatically once it has grown
+	// by this percentage since the last rewrite (0 = never), provided it
+	// is at least AOFRewriteMinSize bytes. Both can be changed at runtime
+	// with CONFIG SET auto-aof-rewrite-percentage and
+	// auto-aof-rewrite-min-size.
+	AOFRewritePercentage int
+	AOFRewriteMinSize    int64
+
+	// AOFFsync is the appendfsync policy: "always", "everysec" (the
+	// default) or "no".
+	AOFFsync string
+
+	// SnapshotPath is where BGSAVE writes the dataset. It is loaded on
+	// startup before the AOF is replayed, unless the AOF has a base file,
+	// which holds the dataset itself. Empty disables snapshots.
+	SnapshotPath string
+
+	// EncryptionKey, if set, is the 32-byte key that AOF parts and
+	// snapshots are sealed with (AES-256-GCM). Files already written in
+	// the clear are still loaded; sealed files can't be loaded without it.
+	// New fails on a sealed file that was tampered with, and on one that
+	// ends in a partial frame unless AOFLoadTruncated is set.
+	EncryptionKey []byte
+
+	// SavePoints run BGSAVE automatically once one of them is met. They
+	// can be changed at runtime with CONFIG SET save.
+	SavePoints []SavePoint
+
+	// TLSCertFile and TLSKeyFile, when both set, make ListenAndServe
+	// accept TLS connections only on Addr. With Listeners, they are used
+	// by the endpoints that have TLS set.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// IdleTimeout closes clients that stay silent this long; 0 disables
+	// it. It can be changed at runtime with CONFIG SET timeout.
+	IdleTimeout time.Duration
+
+	// TCPKeepAlive is the keep-alive period of accepted TCP connections:
+	// 0 keeps Go's default (15s) and a negative value disables keep-alives.
+	TCPKeepAlive time.Duration
+
+	// TCPNagle enables Nagle's algorithm (clears TCP_NODELAY) on accepted
+	// TCP connections, trading reply latency for fewer packets.
+	TCPNagle bool
+
+	// TCPBacklog sets the accept queue length of the listeners; 0 keeps
+	// the system default. It is only supported on Unix systems.
+	TCPBacklog int
+
+	// MonitorOutputLimit disconnects MONITOR clients that fall behind; the
+	// zero value lets their output grow without bound. It can be changed
+	// at runtime with CONFIG SET client-output-buffer-limit.
+	MonitorOutputLimit OutputLimit
+
+	// MaxResultSize refuses KEYS and TS.RANGE calls that would return more
+	// than this many items, pointing clients to SCAN or a narrower range
+	// instead; 0 disables the limit. It can be changed at runtime with
+	// CONFIG SET max-result-size.
+	MaxResultSize int
+
+	// SlowlogSlowerThan records commands that run at least this long in
+	// the slowlog; 0 uses DefaultSlowlogSlowerThan and a negative value
+	// disables the slowlog. SlowlogMaxLen is the number of entries kept,
+	// 0 meaning DefaultSlowlogMaxLen. Both can be changed at runtime with
+	// CONFIG SET slowlog-log-slower-than and slowlog-max-len.
+	SlowlogSlowerThan time.Duration
+	SlowlogMaxLen     int
+
+	// ConfigFile is the configuration file the options came from, if any;
+	// it is reported by INFO and CONFIG GET.
+	ConfigFile string
+
+	// Logger receives server logs; nil uses slog.Default. Connection logs
+	// carry client_id and client_addr; commands are logged at debug level
+	// with their duration.
+	Logger *slog.Logger
+
+	// AuditLog, if set, receives a record for every write command that
+	// succeeds: the user, client id, address and name, and the command
+	// with its arguments. It is meant for compliance and forensics; the
+	// AOF remains the way to restore data.
+	AuditLog *slog.Logger
+
+	// DebugFaults allows DEBUG FAULT to inject AOF write errors, slow
+	// fsyncs and replication faults. Never set it in production.
+	DebugFaults bool
+
+	// ReadThrough, if set, loads keys missing on GET by running the
+	// command of the first matching rule; loaded values are cached for
+	// ReadThroughTTL (0 means no expiry). Concurrent misses on a key share
+	// one load.
+	ReadThrough    []ReadThroughRule
+	ReadThroughTTL time.Duration
+
+	// Tracer, if set, records a span for every command.
+	Tracer *tracing.Tracer
+
+	// Workers, when positive, runs command handlers on a pool of that many
+	// goroutines; WorkerQueue commands may wait for a free worker before
+	// connection readers block. Blocking commands such as WAITKEY run on
+	// their connection's goroutine instead.
+	Workers     int
+	WorkerQueue int
+
+	// ReplayWorkers is how many goroutines load the snapshot and AOF at
+	// startup, each applying the records of its share of the keys. 0 means
+	// GOMAXPROCS; 1 replays serially, as does a Store with maxkeys or
+	// maxmemory set.
+	ReplayWorkers int
+
+	// ReplicaOf makes the server a replica of this primary, whose data
+	// something else copies into Store: every endpoint is read-only and
+	// INFO reports the primary. ReplicaStatus, if set, supplies the sync
+	// statistics of INFO replication.
+	ReplicaOf     string
+	ReplicaStatus func() ReplicaStatus
+
+	// AllowedCommands, if set, limits clients to these commands; others
+	// are refused with a NOPERM error. QUIT is always allowed.
+	AllowedCommands []string
+}
+
+// Server accepts client connections and serves commands against a store.
+type Server struct {
+	opts  Options
+	store *store.Store
+	log   *slog.Logger
+
+	clients  *clientRegistry
+	monitors *monitorRegistry
+	pause    *pauseState
+	waiters  *keyWaiters
+	cmdStats *commandStats
+	pool     *workerPool // nil: handlers run on the connection goroutine
+	aof      *aofLog
+	saves    saveState
+
+	savePoints atomic.Pointer[[]SavePoint] // see autoSave
+
+	// aofRewritePercentage and aofRewriteMinSize trigger automatic AOF
+	// rewrites, see aofRewriteDue.
+	aofRewritePercentage atomic.Int64
+	aofRewriteMinSize    atomic.Int64
+
+	// idleTimeout closes client connections that stay silent for this many
+	// seconds. 0 disables the timeout. Set at runtime via CONFIG SET timeout.
+	idleTimeout atomic.Int64
+
+	// activeExpire enables the background expired-key cleanup loop. It can
+	// be switched off with DEBUG SET-ACTIVE-EXPIRE 0 so tests can observe
+	// lazy expiry.
+	activeExpire atomic.Bool
+
+	// monitorLimit is the output buffer limit of MONITOR clients.
+	monitorLimit atomic.Pointer[OutputLimit]
+
+	// maxResultSize caps the reply of O(N) commands, see guard.go.
+	maxResultSize atomic.Int64
+
+	// Commands slower than slowlogSlowerThan (ns, negative = off) are kept
+	// in slowlog, which holds at most slowlogMaxLen entries.
+	slowlog           slowLog
+	slowlogSlowerThan atomic.Int64
+	slowlogMaxLen     atomic.Int64
+
+	faults faultSet // injected with DEBUG FAULT, see fault.go
+
+	// Counters reported by INFO.
+	startTime        time.Time
+	totalConnections atomic.Int64
+	totalCommands    atomic.Int64
+
+	outputLimitDisconnects atomic.Int64
+	largeResultsRefused    atomic.Int64
+
+	tlsConfig *tls.Config // nil: plain TCP
+
+	allowed map[string]bool // Options.AllowedCommands; nil: every command
+
+	configMu    sync.Mutex
+	configHooks []func(name, value string) // see OnConfigChange
+
+	funcMu    sync.RWMutex
+	functions map[string]Function // lower-cased name -> fn, see RegisterFunction
+
+	mu      sync.Mutex
+	lns     []net.Listener // in the order they were added
+	closing atomic.Bool
+	conns   sync.WaitGroup // one per connection goroutine
+	done    chan struct{}  // closed when Shutdown has finished
+}
+
+// New creates a server, restoring the dataset from the snapshot and AOF
+// named in opts. It does not listen until Serve or ListenAndServe is called.
+func New(opts Options) (*Server, error) {
+	if opts.Addr == "" {
+		opts.Addr = DefaultAddr
+	}
+	if opts.Store == nil {
+		opts.Store = store.New()
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.WorkerQueue <= 0 {
+		opts.WorkerQueue = 1024
+	}
+	if !validFsync(opts.AOFFsync) {
+		return nil, fmt.Errorf("invalid AOF fsync policy %q", opts.AOFFsync)
+	}
+	if opts.EncryptionKey != nil && len(opts.EncryptionKey) != encryption.KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes", encryption.KeySize)
+	}
+	srv := &Server{
+		opts:      opts,
+		store:     opts.Store,
+		log:       opts.Logger,
+		clients:   &clientRegistry{clients: make(map[int64]*Session)},
+		monitors:  &monitorRegistry{monitors: make(map[int64]*monitor)},
+		pause:     &pauseState{resume: make(chan struct{})},
+		waiters:   newKeyWaiters(),
+		cmdStats:  &commandStats{stats: make(map[string]*cmdStat)},
+		startTime: time.Now(),
+		done:      make(chan struct{}),
+	}
+	srv.activeExpire.Store(true)
+	srv.idleTimeout.Store(int64(opts.IdleTimeout / time.Second))
+	limit := opts.MonitorOutputLimit
+	srv.monitorLimit.Store(&limit)
+	srv.maxResultSize.Store(int64(opts.MaxResultSize))
+	points := append([]SavePoint(nil), opts.SavePoints...)
+	srv.savePoints.Store(&points)
+	srv.aofRewritePercentage.Store(int64(opts.AOFRewritePercentage))
+	srv.aofRewriteMinSize.Store(opts.AOFRewriteMinSize)
+	if opts.SlowlogSlowerThan == 0 {
+		opts.SlowlogSlowerThan = DefaultSlowlogSlowerThan
+	}
+	srv.slowlogSlowerThan.Store(int64(max(opts.SlowlogSlowerThan, -1)))
+	if opts.SlowlogMaxLen == 0 {
+		opts.SlowlogMaxLen = DefaultSlowlogMaxLen
+	}
+	srv.slowlogMaxLen.Store(int64(opts.SlowlogMaxLen))
+
+	for _, r := range opts.ReadThrough {
+		if len(r.Command) == 0 {
+			return nil, fmt.Errorf("read-through rule %q has no command", r.Pattern)
+		}
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid read-through pattern %q", r.Pattern)
+		}
+	}
+	if len(opts.ReadThrough) > 0 {
+		srv.store.SetLoader(commandLoader(opts.ReadThrough, srv.log), opts.ReadThroughTTL)
+	}
+
+	for _, name := range opts.AllowedCommands {
+		name = strings.ToUpper(name)
+		if _, ok := commands[name]; !ok {
+			return nil, fmt.Errorf("unknown command %q in allowed commands", name)
+		}
+		if srv.allowed == nil {
+			srv.allowed = make(map[string]bool)
+		}
+		srv.allowed[name] = true
+	}
+
+	for _, l := range opts.Listeners {
+		if l.TLS && (opts.TLSCertFile == "" || opts.TLSKeyFile == "") {
+			return nil, fmt.Errorf("listener %s needs a TLS certificate and key", l)
+		}
+	}
+	if opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS key pair: %w", err)
+		}
+		srv.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	// load the files holding the dataset in order: the last snapshot, then
+	// the AOF on top of it, or only the AOF once it has a base (every file
+	// holds the same kind of replayable commands)
+	files, err := loadOrder(opts.SnapshotPath, opts.AOFPath, opts.AOFDirName)
+	if err != nil {
+		return nil, fmt.Errorf("read AOF manifest: %w", err)
+	}
+	// Any error is fatal, before the AOF is opened: starting with part of
+	// the data would let the next rewrite replace the files holding the
+	// rest.
+	workers := opts.ReplayWorkers
+	if srv.store.Limited() {
+		// the keys a limit evicts depend on the order of the writes, which
+		// parallel workers would change from one start to the next
+		workers = 1
+	}
+	for _, path := range files {
+		if err := replayAOF(srv.store, path, opts.EncryptionKey, workers, opts.AOFLoadTruncated, srv.log); err != nil {
+			return nil, fmt.Errorf("load %s: %w", path, err)
+		}
+	}
+	aof, err := openAOF(opts.AOFPath, opts.AOFDirName, opts.AOFFsync, opts.EncryptionKey, srv.log, &srv.faults)
+	if err != nil {
+		return nil, fmt.Errorf("open AOF file: %w", err)
+	}
+	srv.aof = aof
+	// From here on keys the store evicts or expires are logged as DEL.
+	aof.trackRemovals(srv.store)
+	// The data just loaded is on disk already; save points count changes
+	// from here.
+	srv.saves.changes = srv.store.Changes()
+	// Until the AOF has a base, the snapshot or a single-file AOF from
+	// before the split may hold data the AOF doesn't; a rewrite puts the
+	// whole dataset in a base.
+	if aof.manifest != nil && aof.manifest.base == nil && srv.store.Len() > 0 {
+		srv.log.Info("writing the loaded dataset to an AOF base file")
+		if err := srv.RewriteAOF(); err != nil {
+			srv.log.Error("AOF rewrite failed", "err", err)
+		}
+	}
+
+	if opts.Workers > 0 {
+		srv.pool = newWorkerPool(opts.Workers, opts.WorkerQueue)
+		srv.log.Info("dispatching commands on a worker pool", "workers", opts.Workers, "queue", opts.WorkerQueue)
+	}
+	go srv.cleanupExpired()
+	go srv.activeDefrag()
+	go srv.autoSave()
+	return srv, nil
+}
+
+// Store returns the dataset served by srv.
+func (srv *Server) Store() *store.Store {
+	return srv.store
+}
+
+// Addr returns the address the server is listening on (the first one, if
+// there are several), or the configured address if it is not listening
+// yet.
+func (srv *Server) Addr() string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.lns) > 0 {
+		return srv.lns[0].Addr().String()
+	}
+	if len(srv.opts.Listeners) > 0 {
+		return srv.opts.Listeners[0].Addr
+	}
+	return srv.opts.Addr
+}
+
+// Addrs returns the address of every listener.
+func (srv *Server) Addrs() []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	res := make([]string, len(srv.lns))
+	for i, ln := range srv.lns {
+		res[i] = ln.Addr().String()
+	}
+	return res
+}
+
+// cleanupExpired removes expired keys in the background until the server
+// shuts down.
+func (srv *Server) cleanupExpired() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		if !srv.activeExpire.Load() {
+			continue
+		}
+		n := srv.store.CleanupExpired()
+		if n > 0 {
+			srv.aof.write(nil) // log the removals now rather than with the next write
+			srv.log.Info("cleaned up expired keys", "count", n)
+		}
+	}
+}
+
+// activeDefrag periodically rebuilds the store's data map once mass
+// deletions have left it sparse, until the server shuts down.
+func (srv *Server) activeDefrag() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		if n := srv.store.Defrag(false); n > 0 {
+			srv.log.Info("rebuilt sparse key map", "reclaimed_bytes", n)
+		}
+	}
+}
+
+// ListenAndServe listens on the server's address (with TLS if a
+// certificate is configured), or on every endpoint in Options.Listeners,
+// and serves connections until Shutdown is called, after which it returns
+// ErrServerClosed. If any endpoint cannot be opened, none is served.
+func (srv *Server) ListenAndServe() error {
+	if srv.closing.Load() {
+		return ErrServerClosed
+	}
+	cfgs := srv.opts.Listeners
+	if len(cfgs) == 0 {
+		cfgs = []Listener{{Addr: srv.opts.Addr, TLS: srv.tlsConfig != nil}}
+	}
+	lns := make([]net.Listener, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		ln, err := srv.listen(cfg)
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return err
+		}
+		lns = append(lns, ln)
+	}
+
+	errs := make(chan error, len(lns))
+	for i, ln := range lns {
+		go func(ln net.Listener, cfg Listener) {
+			errs <- srv.serve(ln, cfg.ReadOnly)
+		}(ln, cfgs[i])
+	}
+	err := ErrServerClosed
+	for range lns {
+		if e := <-errs; e != ErrServerClosed && err == ErrServerClosed {
+			err = e
+		}
+	}
+	return err
+}
+
+// listen opens the endpoint described by cfg.
+func (srv *Server) listen(cfg Listener) (net.Listener, error) {
+	network := cfg.Network
+	if network == "" {
+		network = "tcp"
+	}
+	if network == "unix" {
+		// a socket file left behind by a crash would make Listen fail
+		if fi, err := os.Lstat(cfg.Addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(cfg.Addr)
+		}
+	}
+	ln, err := net.Listen(network, cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if n := srv.opts.TCPBacklog; n > 0 {
+		if err := setBacklog(ln, n); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("set backlog of %s: %w", cfg.Addr, err)
+		}
+	}
+	if cfg.TLS {
+		ln = tls.NewListener(ln, srv.tlsConfig)
+	}
+	return ln, nil
+}
+
+// Serve accepts connections on ln until Shutdown is called, after which it
+// returns ErrServerClosed. Serve takes ownership of ln. It may be called
+// for several listeners at once.
+func (srv *Server) Serve(ln net.Listener) error {
+	return srv.serve(ln, false)
+}
+
+func (srv *Server) serve(ln net.Listener, readOnly bool) error {
+	srv.mu.Lock()
+	if srv.closing.Load() {
+		srv.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	srv.lns = append(srv.lns, ln)
+	srv.mu.Unlock()
+
+	srv.log.Info("RediGo listening", "addr", ln.Addr().String(), "network", ln.Addr().Network(), "readonly", readOnly || srv.opts.ReplicaOf != "")
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if srv.closing.Load() {
+				return ErrServerClosed
+			}
+			srv.log.Error("accept failed", "err", err)
+			continue
+		}
+		srv.tuneConn(conn)
+		// Handle each client in a separate goroutine.
+		srv.conns.Add(1)
+		go func() {
+			defer srv.conns.Done()
+			srv.handleConn(conn, readOnly)
+		}()
+	}
+}
+
+// tuneConn applies the TCP options to an accepted connection; other
+// connections, such as Unix sockets, are left alone.
+func (srv *Server) tuneConn(conn net.Conn) {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	switch ka := srv.opts.TCPKeepAlive; {
+	case ka < 0:
+		tcp.SetKeepAlive(false)
+	case ka > 0:
+		tcp.SetKeepAlive(true)
+		tcp.SetKeepAlivePeriod(ka)
+	}
+	if srv.opts.TCPNagle {
+		tcp.SetNoDelay(false)
+	}
+}
+
+// Shutdown stops the server gracefully: it stops accepting connections,
+// lets commands that are already running complete, tells every client
+// (including monitors) that the server is going away, flushes the AOF and
+// returns. If ctx expires first, remaining connections are closed forcibly
+// and ctx's error is returned.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	if !srv.closing.CompareAndSwap(false, true) {
+		<-srv.done
+		return nil
+	}
+	srv.mu.Lock()
+	for _, ln := range srv.lns {
+		ln.Close()
+	}
+	srv.mu.Unlock()
+
+	// Clients held by CLIENT PAUSE or blocked in WAITKEY must be able to
+	// finish.
+	srv.pause.unpause()
+	srv.waiters.close()
+
+	drained := make(chan struct{})
+	go func() {
+		srv.conns.Wait()
+		close(drained)
+	}()
+
+	// Idle connections are blocked reading; an expired deadline wakes them
+	// up. Keep re-arming it, since a connection may be between commands and
+	// about to set its own idle deadline.
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	var err error
+wait:
+	for {
+		for _, c := range srv.clients.list() {
+			c.SetReadDeadline(time.Now())
+		}
+		select {
+		case <-drained:
+			break wait
+		case <-ctx.Done():
+			err = ctx.Err()
+			for _, c := range srv.clients.list() {
+				c.Close()
+			}
+			// Handlers already running still finish; wait for them so
+			// nothing writes to the AOF after it is closed.
+			<-drained
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	if srv.pool != nil {
+		srv.pool.stop()
+	}
+	if aerr := srv.aof.close(); aerr != nil && err == nil {
+		err = aerr
+	}
+	close(srv.done)
+	return err
+}
+
+// Close closes the listener and every connection immediately, without
+// waiting for clients to finish their current command batch.
+func (srv *Server) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := srv.Shutdown(ctx); err != nil && err != context.Canceled {
+		return err
+	}
+	return nil
+}
+
+// Done is closed once Shutdown has completed.
+func (srv *Server) Done() <-chan struct{} {
+	return srv.done
+}
+
+// handleConn serves one client; readOnly clients may not run write
+// commands.
+func (srv *Server) handleConn(conn net.Conn, readOnly bool) {
+	c := srv.clients.register(srv, conn)
+	srv.totalConnections.Add(1)
+	c.log.Info("new connection")
+	defer func() {
+		c.log.Info("closing connection")
+		srv.monitors.remove(c)
+		srv.clients.unregister(c)
+		c.endFrame()
+		c.flush()
+		conn.Close()
+		c.release()
+	}()
+	// Send a welcome banner (purely for dev friendliness). A replica
+	// serves the same commands, minus writes.
+	if srv.opts.ReplicaOf != "" {
+		readOnly = true
+		fmt.Fprintf(c, "+OK RediGo Replica (read-only)\r\n")
+	} else {
+		fmt.Fprintf(c, "+OK RediGo Simple Text Server\r\n")
+	}
+	fmt.Fprintf(c, "Supports simple text commands.\r\n")
+	fmt.Fprintf(c, "Type HELP for commands.\r\n")
+
+	p := newParser(conn)
+	defer p.release()
+	for {
+		c.endFrame()
+		if srv.closing.Load() {
+			fmt.Fprintf(c, "-ERR server is shutting down\r\n")
+			return
+		}
+		// Prompt (monitors receive a stream instead of prompts)
+		if !srv.monitors.isMonitor(c) {
+			fmt.Fprint(c, "> ")
+		}
+		// Replies are buffered; flush once there are no more pipelined
+		// commands waiting, so a batch of commands costs a single write.
+		if p.buffered() == 0 {
+			if err := c.flush(); err != nil {
+				c.log.Warn("write failed", "err", err)
+				return
+			}
+		}
+		srv.setIdleDeadline(c)
+		name, args, err := p.readCommand()
+		if err != nil {
+			// Client closed or error
+			switch {
+			case srv.closing.Load():
+				fmt.Fprintf(c, "\r\n-ERR server is shutting down\r\n")
+			case err == io.EOF:
+			case isTimeout(err):
+				c.log.Info("closing idle connection")
+			default:
+				c.log.Warn("read failed", "err", err)
+			}
+			return
+		}
+		c.seq++
+		c.beginFrame()
+		// Look up command handler; the []byte->string conversion in the
+		// map index does not allocate.
+		spec, ok := commands[string(name)]
+		if !ok {
+			// Clean error: don’t dump weird whitespace
+			fmt.Fprintf(c, "-ERR unknown command '%s'\r\n", name)
+			continue
+		}
+		cmd := spec.name
+		if srv.allowed != nil && !srv.allowed[cmd] && cmd != "QUIT" {
+			fmt.Fprintf(c, "-NOPERM this endpoint does not allow the '%s' command\r\n", strings.ToLower(cmd))
+			continue
+		}
+		if readOnly && spec.hasFlag(flagWrite) {
+			fmt.Fprintf(c, "-ERR READONLY this endpoint does not accept write commands\r\n")
+			continue
+		}
+		if !spec.arityOK(len(args)) {
+			fmt.Fprintf(c, "-ERR wrong number of arguments for '%s'\r\n", strings.ToLower(cmd))
+			continue
+		}
+
+		// Execute handler
+		srv.pause.wait(cmd)
+		srv.monitors.feed(c, cmd, args)
+		// A blocking command waits on the connection's own goroutine: on
+		// the pool, clients waiting for a write could take every worker
+		// and leave none to run it.
+		if srv.pool != nil && !spec.hasFlag(flagBlocking) {
+			srv.pool.do(func() { srv.execute(c, spec, args) })
+		} else {
+			srv.execute(c, spec, args)
+		}
+		// Special: QUIT closes the connection from inside handler.
+		if cmd == "QUIT" {
+			return
+		}
+	}
+}
+
+// execute runs one command for c and records its statistics.
+func (srv *Server) execute(c *Session, spec *commandSpec, args []string) {
+	srv.totalCommands.Add(1)
+	c.touch(spec.name)
+	c.beginReply()
+	sp := srv.opts.Tracer.Start(spec.name, tracing.KindServer)
+	start := time.Now()
+	srv.call(c, spec, args)
+	c.flushEffects()
+	d := time.Since(start)
+	if sp != nil {
+		traceCommand(sp, c, spec, args)
+	}
+	srv.cmdStats.record(spec.name, d, c.replyFailed())
+	srv.logSlow(c, spec, args, start, d)
+	srv.audit(c, spec, args)
+	if c.log.Enabled(context.Background(), slog.LevelDebug) {
+		c.log.Debug("command", "seq", c.seq, "cmd", spec.name, "args", len(args), "duration", d, "failed", c.replyFailed())
+	}
+}
+
+// call runs the handler of spec. A handler that panics gets an error
+// reply instead of taking the whole server down with it.
+func (srv *Server) call(c *Session, spec *commandSpec, args []string) {
+	defer func() {
+		if v := recover(); v != nil {
+			c.log.Error("command panicked", "seq", c.seq, "cmd", spec.name, "panic", v, "stack", string(debug.Stack()))
+			protocol.Errorf(c.reply, "ERR internal error running '%s'", strings.ToLower(spec.name))
+		}
+	}()
+	spec.fn(c, args)
+}
+
+// traceCommand annotates and ends the span of a command that just ran.
+func traceCommand(sp *tracing.Span, c *Session, spec *commandSpec, args []string) {
+	reqBytes := 0
+	for _, a := range args {
+		reqBytes += len(a)
+	}
+	sp.SetString("db.system", "redigo")
+	sp.SetString("db.operation", strings.ToLower(spec.name))
+	sp.SetString("client.address", c.RemoteAddr().String())
+	sp.SetInt("redigo.client_id", c.id)
+	sp.SetInt("redigo.seq", c.seq)
+	sp.SetInt("redigo.args", int64(len(args)))
+	sp.SetInt("redigo.keys", int64(spec.keyCount(len(args))))
+	sp.SetInt("redigo.request_bytes", int64(reqBytes))
+	sp.SetInt("redigo.reply_bytes", int64(c.replyBytes))
+	if c.replyFailed() {
+		sp.SetError("error reply")
+	}
+	sp.End()
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// MetricsSink receives periodic metrics pushed by ReportMetrics, for
+// monitoring systems that are not scraped (StatsD, Datadog). tags are
+// name/value pairs. pkg/statsd provides an implementation.
+type MetricsSink interface {
+	Gauge(name string, value float64, tags ...string)
+	Count(name string, delta int64, tags ...string)
+	Flush()
+}
+
+// ReportMetrics pushes the server's metrics to sink every interval until
+// ctx is done or the server shuts down. Counters are sent as the change
+// since the previous report.
+func (srv *Server) ReportMetrics(ctx context.Context, sink MetricsSink, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	r := &sinkReporter{sink: sink, prev: make(map[string]int64)}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-srv.done:
+			return
+		case <-ticker.C:
+		}
+		r.report(srv)
+	}
+}
+
+// sinkReporter remembers the last value of every counter so it can send
+// deltas.
+type sinkReporter struct {
+	sink MetricsSink
+	prev map[string]int64
+}
+
+// count sends the increase of a cumulative counter since the last report.
+// A counter that went down was reset (CONFIG RESETSTAT), so its whole
+// value is new.
+func (r *sinkReporter) count(name string, total int64, tags ...string) {
+	id := name + "|" + strings.Join(tags, ",")
+	delta := total - r.prev[id]
+	if delta < 0 {
+		delta = total
+	}
+	r.prev[id] = total
+	if delta != 0 {
+		r.sink.Count(name, delta, tags...)
+	}
+}
+
+func (r *sinkReporter) report(srv *Server) {
+	stats := srv.store.Stats()
+	r.sink.Gauge("keys", float64(stats.Keys))
+	r.sink.Gauge("expires", float64(stats.Expires))
+	r.sink.Gauge("memory.dataset_bytes", float64(stats.UsedMemory))
+	r.sink.Gauge("clients.connected", float64(len(srv.clients.list())))
+	var ratio float64
+	if stats.Reads > 0 {
+		ratio = float64(stats.Hits) / float64(stats.Reads)
+	}
+	r.sink.Gauge("keyspace.hit_ratio", ratio)
+
+	r.count("keyspace.hits", stats.Hits)
+	r.count("keyspace.misses", stats.Misses)
+	r.count("evictions", stats.Evictions)
+	r.count("connections", srv.totalConnections.Load())
+	r.count("commands", srv.totalCommands.Load())
+	for _, st := range srv.cmdStats.snapshot() {
+		cmd := strings.ToLower(st.name)
+		r.count("command.calls", st.calls, "cmd", cmd)
+		r.count("command.failed", st.failed, "cmd", cmd)
+	}
+	r.sink.Flush()
+}
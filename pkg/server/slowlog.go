@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of Options.SlowlogSlowerThan and SlowlogMaxLen, as in Redis.
+const (
+	DefaultSlowlogSlowerThan = 10 * time.Millisecond
+	DefaultSlowlogMaxLen     = 128
+)
+
+// Like Redis, entries keep at most slowlogMaxArgs arguments, each cut to
+// slowlogMaxArgLen bytes, so big values don't pin memory.
+const (
+	slowlogMaxArgs   = 32
+	slowlogMaxArgLen = 128
+)
+
+// slowlogEntry is one command that ran longer than the threshold. The
+// client id and the command's sequence number on that connection match
+// the client_id and seq of the server logs, audit log and MONITOR lines.
+type slowlogEntry struct {
+	id       int64
+	time     time.Time
+	duration time.Duration
+	clientID int64
+	seq      int64
+	addr     string
+	name     string
+	cmd      string
+	args     []string
+}
+
+// slowLog keeps the most recent slow commands, newest first.
+type slowLog struct {
+	mu      sync.Mutex
+	entries []slowlogEntry
+	nextID  int64
+}
+
+func (l *slowLog) add(e slowlogEntry, maxLen int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.id = l.nextID
+	l.nextID++
+	l.entries = append([]slowlogEntry{e}, l.entries...)
+	if len(l.entries) > maxLen {
+		l.entries = l.entries[:maxLen]
+	}
+}
+
+// get returns up to n entries, newest first; n < 0 returns all of them.
+func (l *slowLog) get(n int) []slowlogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n < 0 || n > len(l.entries) {
+		n = len(l.entries)
+	}
+	return append([]slowlogEntry(nil), l.entries[:n]...)
+}
+
+func (l *slowLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries)
+}
+
+func (l *slowLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = nil
+}
+
+// logSlow records the command c just ran in the slowlog if it took at
+// least the configured threshold.
+func (srv *Server) logSlow(c *Session, spec *commandSpec, args []string, start time.Time, d time.Duration) {
+	threshold := srv.slowlogSlowerThan.Load()
+	if threshold < 0 || int64(d) < threshold {
+		return
+	}
+	n := min(len(args), slowlogMaxArgs)
+	kept := make([]string, n)
+	for i, a := range args[:n] {
+		if len(a) > slowlogMaxArgLen {
+			a = fmt.Sprintf("%s... (%d more bytes)", a[:slowlogMaxArgLen], len(a)-slowlogMaxArgLen)
+		}
+		kept[i] = strings.Clone(a)
+	}
+	if n < len(args) {
+		kept = append(kept, fmt.Sprintf("... (%d more arguments)", len(args)-n))
+	}
+	srv.slowlog.add(slowlogEntry{
+		time:     start,
+		duration: d,
+		clientID: c.id,
+		seq:      c.seq,
+		addr:     c.RemoteAddr().String(),
+		name:     c.getName(),
+		cmd:      spec.name,
+		args:     kept,
+	}, int(srv.slowlogMaxLen.Load()))
+}
+
+// cmdSLOWLOG implements the slowlog subcommands:
+//
+//	SLOWLOG GET [count] - the newest count entries (default 10, -1 = all)
+//	SLOWLOG LEN         - number of entries
+//	SLOWLOG RESET       - drop every entry
+func cmdSLOWLOG(c *Session, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(c, "-ERR wrong number of arguments for 'slowlog'\r\n")
+		return
+	}
+	l := &c.srv.slowlog
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		n := 10
+		if len(args) > 2 {
+			fmt.Fprintf(c, "-ERR SLOWLOG GET takes an optional count\r\n")
+			return
+		}
+		if len(args) == 2 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < -1 {
+				fmt.Fprintf(c, "-ERR invalid count '%s'\r\n", args[1])
+				return
+			}
+		}
+		entries := l.get(n)
+		if len(entries) == 0 {
+			fmt.Fprintf(c, "(empty)\r\n")
+			return
+		}
+		for _, e := range entries {
+			fmt.Fprintf(c, "id=%d time=%d duration_usec=%d client_id=%d seq=%d addr=%s name=%s cmd=%s",
+				e.id, e.time.Unix(), e.duration.Microseconds(), e.clientID, e.seq, e.addr, e.name, strconv.Quote(strings.ToLower(e.cmd)))
+			for _, a := range e.args {
+				fmt.Fprintf(c, " %s", strconv.Quote(a))
+			}
+			fmt.Fprintf(c, "\r\n")
+		}
+	case "LEN":
+		fmt.Fprintf(c, ":%d\r\n", l.len())
+	case "RESET":
+		l.reset()
+		fmt.Fprintf(c, "+OK\r\n")
+	default:
+		fmt.Fprintf(c, "-ERR unknown SLOWLOG subcommand '%s'\r\n", args[0])
+	}
+}
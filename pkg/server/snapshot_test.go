@@ -0,0 +1,55 @@
+package server_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/redigotest"
+	"github.com/DakshBaxi/RediGo/pkg/server"
+)
+
+// TestSnapshotExpiry saves keys with a TTL and loads the snapshot a minute
+// later: the TTLs must have run down meanwhile, not restarted.
+func TestSnapshotExpiry(t *testing.T) {
+	clock := redigotest.NewClock(time.Unix(1_700_000_000, 0))
+	srv := redigotest.Start(t, redigotest.Options{
+		Persist:   true,
+		Clock:     clock,
+		Configure: func(o *server.Options) { o.AOFPath = "" }, // the snapshot alone
+	})
+	redigotest.AssertOK(t, srv.Do("SET", "k", "v"))
+	redigotest.AssertInt(t, srv.Do("EXPIRE", "k", "100"), 1)
+	redigotest.AssertOK(t, srv.Do("SET", "short", "v"))
+	redigotest.AssertInt(t, srv.Do("EXPIRE", "short", "30"), 1)
+	redigotest.AssertOK(t, srv.Do("SET", "forever", "v"))
+	at := clock.Now().Unix() + 100
+	dump := strings.Join(srv.Do("DUMPALL").Lines, "\n") + "\n"
+	for _, want := range []string{
+		"SET forever v\n",
+		fmt.Sprintf("SET k v\nEXPIREAT k %d\n", at),
+		fmt.Sprintf("SET short v\nEXPIREAT short %d\n", at-70),
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("DUMPALL %q does not hold %q", dump, want)
+		}
+	}
+	redigotest.AssertOK(t, srv.Do("SAVE"))
+
+	data, err := os.ReadFile(filepath.Join(srv.Dir(), "redigo.snapshot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("SET k v\nEXPIREAT k %d\n", at); !strings.Contains(string(data), want) {
+		t.Errorf("snapshot %q does not hold %q", data, want)
+	}
+
+	clock.Advance(time.Minute)
+	srv.Restart()
+	redigotest.AssertInt(t, srv.Do("TTL", "k"), 40)
+	redigotest.AssertNil(t, srv.Do("GET", "short"))
+	redigotest.AssertInt(t, srv.Do("TTL", "forever"), -1)
+}
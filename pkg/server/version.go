@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Build information, reported by INFO server. Release builds set them at
+// link time:
+//
+//	go build -ldflags "-X github.com/DakshBaxi/RediGo/pkg/server.Version=1.2.0 \
+//		-X github.com/DakshBaxi/RediGo/pkg/server.Commit=$(git rev-parse HEAD) \
+//		-X github.com/DakshBaxi/RediGo/pkg/server.BuildDate=$(date -u +%FT%TZ)" ./cmd/redigo
+//
+// Left empty, Commit and BuildDate fall back to the VCS stamp Go embeds
+// when building from a checkout.
+var (
+	Version   = "0.1.0"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// build is the resolved build information.
+type build struct {
+	commit   string
+	date     string
+	modified bool // built from a checkout with uncommitted changes
+}
+
+var buildInfo = sync.OnceValue(func() build {
+	b := build{commit: Commit, date: BuildDate}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if b.commit == "" {
+				b.commit = s.Value
+			}
+		case "vcs.time":
+			if b.date == "" {
+				b.date = s.Value
+			}
+		case "vcs.modified":
+			b.modified = Commit == "" && s.Value == "true"
+		}
+	}
+	return b
+})
+
+// VersionString describes the build on one line, as printed by
+// redigo -version.
+func VersionString() string {
+	b := buildInfo()
+	s := "RediGo " + Version
+	if b.commit != "" {
+		s += " commit " + b.commit
+		if b.modified {
+			s += "-dirty"
+		}
+	}
+	if b.date != "" {
+		s += " built " + b.date
+	}
+	return fmt.Sprintf("%s (%s %s/%s)", s, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
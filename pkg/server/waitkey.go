@@ -0,0 +1,182 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/internal/protocol"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// keyWaiters implements WAITKEY: clients blocked until a key is written.
+// Writes reach it through the store's OnSet hook, which is registered the
+// first time a client blocks so servers that never use WAITKEY don't pay
+// for keyspace events. Events the store had to drop are reported by its
+// OnGap hook, which wakes every client to look at its key again.
+type keyWaiters struct {
+	once sync.Once
+
+	mu      sync.Mutex
+	waiters map[string][]chan string // key -> channels receiving the new value
+	gap     chan struct{}            // closed, and replaced, when events were dropped
+	closed  chan struct{}            // closed on shutdown to release everyone
+	blocked int
+}
+
+func newKeyWaiters() *keyWaiters {
+	return &keyWaiters{
+		waiters: make(map[string][]chan string),
+		gap:     make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+// watch registers interest in the next write to key. The returned channel
+// receives the written value, and gap is closed if the write may have been
+// dropped; cancel must be called once done waiting.
+func (w *keyWaiters) watch(s *store.Store, key string) (ch chan string, gap <-chan struct{}, cancel func()) {
+	w.once.Do(func() {
+		s.OnSet(w.notify)
+		s.OnGap(w.notifyGap)
+	})
+	ch = make(chan string, 1)
+	w.mu.Lock()
+	w.waiters[key] = append(w.waiters[key], ch)
+	w.blocked++
+	gap = w.gap
+	w.mu.Unlock()
+	return ch, gap, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.blocked--
+		list := w.waiters[key]
+		for i, c := range list {
+			if c == ch {
+				list = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(list) == 0 {
+			delete(w.waiters, key)
+		} else {
+			w.waiters[key] = list
+		}
+	}
+}
+
+// notify wakes every client waiting on the written key. A client is woken
+// once; the channel is removed from the list so later writes skip it.
+func (w *keyWaiters) notify(ev store.Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, ch := range w.waiters[ev.Key] {
+		ch <- ev.Value
+	}
+	delete(w.waiters, ev.Key)
+}
+
+// notifyGap wakes every waiting client after events were dropped: the
+// write one is waiting for may be among them.
+func (w *keyWaiters) notifyGap(store.Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	close(w.gap)
+	w.gap = make(chan struct{})
+}
+
+// count returns the number of clients currently blocked in WAITKEY.
+func (w *keyWaiters) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.blocked
+}
+
+// close releases every blocked client; it is called when the server shuts
+// down, so Shutdown doesn't wait for their timeouts.
+func (w *keyWaiters) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case <-w.closed:
+	default:
+		close(w.closed)
+	}
+}
+
+// cmdWAITKEY implements WAITKEY key timeout [GET]: block until key is
+// written and reply with its new value, or (nil) once timeout seconds have
+// passed (0 waits forever). With GET, a key that already exists is
+// returned at once, making it a blocking GET. Deletions and expiry don't
+// wake the client. When the store drops events, the client compares the
+// key with the value it started from: a write of the same value during
+// the gap goes unnoticed. While blocked, the client holds its connection like
+// any other running command, but never a worker of the worker pool.
+func cmdWAITKEY(c *Session, args []string) {
+	r := c.reply
+	if len(args) < 2 || len(args) > 3 {
+		r.WriteError("ERR WAITKEY requires key and timeout")
+		return
+	}
+	key := args[0]
+	secs, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || secs < 0 {
+		protocol.Errorf(r, "ERR invalid timeout '%s'", args[1])
+		return
+	}
+	get := false
+	if len(args) == 3 {
+		if !strings.EqualFold(args[2], "GET") {
+			protocol.Errorf(r, "ERR syntax error near '%s'", args[2])
+			return
+		}
+		get = true
+	}
+
+	// Watch before looking at the key so a write in between isn't missed.
+	w := c.srv.waiters
+	ch, gap, cancel := w.watch(c.db, key)
+	defer func() { cancel() }()
+	start, existed := c.db.Get(key)
+	if get && existed {
+		r.WriteBulk(start)
+		return
+	}
+
+	var timeout <-chan time.Time
+	if secs > 0 {
+		timer := time.NewTimer(time.Duration(secs * float64(time.Second)))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	// The idle timeout is for clients that have gone quiet, not for one
+	// waiting on the server; the next command re-arms it.
+	c.SetReadDeadline(time.Time{})
+	for {
+		select {
+		case v := <-ch:
+			r.WriteBulk(v)
+			return
+		case <-gap:
+			select {
+			case v := <-ch: // the write came through after all
+				r.WriteBulk(v)
+				return
+			default:
+			}
+			cancel()
+			ch, gap, cancel = w.watch(c.db, key)
+			if v, ok := c.db.Get(key); ok && (!existed || v != start) {
+				r.WriteBulk(v)
+				return
+			}
+		case <-timeout:
+			r.WriteNil()
+			return
+		case <-w.closed:
+			r.WriteError("ERR server is shutting down")
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/redigotest"
+	"github.com/DakshBaxi/RediGo/pkg/server"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// waitKey sends WAITKEY key 10 on conn and returns the reply, a quoted
+// value, or the error that ended the read.
+func waitKey(conn net.Conn, key string) string {
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	fmt.Fprintf(conn, "WAITKEY %s 10\r\n", key)
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		// skip the banner and prompts
+		if line = strings.TrimPrefix(strings.TrimSpace(line), "> "); strings.HasPrefix(line, "\"") {
+			return line
+		}
+	}
+}
+
+// waitBlocked waits until n clients are blocked in WAITKEY.
+func waitBlocked(t *testing.T, srv *redigotest.Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Server().Vars()["blocked_clients"] != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%v clients blocked, want %d", srv.Server().Vars()["blocked_clients"], n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestWaitKeyWithWorkerPool blocks more clients in WAITKEY than there are
+// workers: the write that wakes them must still find a worker to run on.
+func TestWaitKeyWithWorkerPool(t *testing.T) {
+	const workers, waiters = 2, 6
+	srv := redigotest.Start(t, redigotest.Options{
+		Configure: func(o *server.Options) { o.Workers = workers },
+	})
+
+	replies := make(chan string, waiters)
+	for i := 0; i < waiters; i++ {
+		conn, err := srv.Dial(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		go func(conn net.Conn) {
+			replies <- waitKey(conn, "k")
+		}(conn)
+	}
+
+	waitBlocked(t, srv, waiters)
+	redigotest.AssertOK(t, srv.Do("SET", "k", "v"))
+	for i := 0; i < waiters; i++ {
+		select {
+		case r := <-replies:
+			if r != `"v"` {
+				t.Errorf("WAITKEY reply %q, want \"v\"", r)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d waiters woke up", i, waiters)
+		}
+	}
+}
+
+// TestWaitKeyAfterEventGap writes the key a client waits on while the
+// store is dropping events: the gap must wake the client, which finds the
+// new value.
+func TestWaitKeyAfterEventGap(t *testing.T) {
+	srv := redigotest.Start(t, redigotest.Options{})
+	conn, err := srv.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	reply := make(chan string, 1)
+	go func() { reply <- waitKey(conn, "k") }()
+	waitBlocked(t, srv, 1)
+
+	// a slow hook holds the events back until the queue overflows
+	st := srv.Store()
+	release := make(chan struct{})
+	st.OnSet(func(store.Event) { <-release })
+	for i := 0; i < 5000; i++ {
+		if err := st.Set(fmt.Sprintf("other%d", i), "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Set("k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	select {
+	case r := <-reply:
+		if r != `"v"` {
+			t.Errorf("WAITKEY reply %q, want \"v\"", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event gap didn't wake the waiting client")
+	}
+}
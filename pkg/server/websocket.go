@@ -0,0 +1,304 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// WebSocketOptions configures WebSocketHandler.
+type WebSocketOptions struct {
+	// AllowedOrigins lists the Origin values browsers may connect from,
+	// e.g. "https://dash.example.com". "*" allows any origin. When empty,
+	// only same-origin pages and non-browser clients (no Origin header)
+	// are accepted.
+	AllowedOrigins []string
+
+	// Token, if set, must be sent as the "token" query parameter or as an
+	// "Authorization: Bearer" header. Browsers cannot set headers on a
+	// WebSocket, so dashboards use the query parameter.
+	Token string
+}
+
+// wsGUID is the fixed key suffix from RFC 6455, section 1.3.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxFrame bounds the payload of a single client frame.
+const wsMaxFrame = 1 << 20
+
+// WebSocketHandler returns an HTTP handler that upgrades requests to
+// WebSockets and tunnels the text protocol over them: each text or binary
+// message from the browser is one command line, and replies (including the
+// banner, prompts and MONITOR pushes) arrive as text messages. A reply is
+// usually a single message, but large ones may be split.
+func (srv *Server) WebSocketHandler(opts WebSocketOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+			http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Sec-WebSocket-Version") != "13" {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+			http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+			return
+		}
+		key := r.Header.Get("Sec-WebSocket-Key")
+		if key == "" {
+			http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+			return
+		}
+		if !originAllowed(r, opts.AllowedOrigins) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		if opts.Token != "" && !tokenValid(r, opts.Token) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+			return
+		}
+
+		// Register the connection before Shutdown starts waiting for
+		// connections to drain, or refuse it.
+		srv.mu.Lock()
+		if srv.closing.Load() {
+			srv.mu.Unlock()
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		srv.conns.Add(1)
+		srv.mu.Unlock()
+		defer srv.conns.Done()
+
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			srv.log.Warn("websocket hijack failed", "err", err)
+			return
+		}
+		sum := sha1.Sum([]byte(key + wsGUID))
+		fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+			"Upgrade: websocket\r\n"+
+			"Connection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+		if err := rw.Flush(); err != nil {
+			conn.Close()
+			return
+		}
+		srv.handleConn(&wsConn{Conn: conn, r: rw.Reader}, false)
+	})
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func originAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(a, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func tokenValid(r *http.Request, token string) bool {
+	got := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); got == "" && strings.HasPrefix(auth, "Bearer ") {
+		got = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// WebSocket opcodes (RFC 6455, section 5.2).
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+var errWSProtocol = errors.New("websocket protocol error")
+
+// wsConn presents a WebSocket as a byte stream so handleConn can serve it
+// like a TCP connection. Reads return message payloads, each followed by a
+// newline so it ends the command line; writes become text messages.
+type wsConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	// Only the connection goroutine reads.
+	remaining int64   // unread payload bytes of the current frame
+	mask      [4]byte // masking key of the current frame
+	maskPos   int
+	fin       bool        // the current frame ends its message
+	newline   bool        // a newline is owed after the message just read
+	closed    atomic.Bool // a close frame was received
+
+	wmu sync.Mutex // writes come from the connection and control frames
+}
+
+func (ws *wsConn) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for ws.remaining == 0 {
+		if ws.newline {
+			ws.newline = false
+			p[0] = '\n'
+			return 1, nil
+		}
+		if ws.closed.Load() {
+			return 0, io.EOF
+		}
+		if err := ws.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > ws.remaining {
+		p = p[:ws.remaining]
+	}
+	n, err := ws.r.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] ^= ws.mask[ws.maskPos&3]
+		ws.maskPos++
+	}
+	ws.remaining -= int64(n)
+	if ws.remaining == 0 && ws.fin {
+		ws.newline = true
+	}
+	return n, err
+}
+
+// nextFrame reads frame headers, answering control frames, until it finds a
+// data frame.
+func (ws *wsConn) nextFrame() error {
+	var hdr [2]byte
+	if _, err := io.ReadFull(ws.r, hdr[:]); err != nil {
+		return err
+	}
+	fin := hdr[0]&0x80 != 0
+	op := hdr[0] & 0x0f
+	if hdr[1]&0x80 == 0 {
+		return errWSProtocol // client frames must be masked
+	}
+	n := int64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(ws.r, b[:]); err != nil {
+			return err
+		}
+		n = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(ws.r, b[:]); err != nil {
+			return err
+		}
+		n = int64(binary.BigEndian.Uint64(b[:]))
+	}
+	if n < 0 || n > wsMaxFrame {
+		return errWSProtocol
+	}
+	if _, err := io.ReadFull(ws.r, ws.mask[:]); err != nil {
+		return err
+	}
+	ws.maskPos = 0
+
+	switch op {
+	case wsContinuation, wsText, wsBinary:
+		ws.remaining = n
+		ws.fin = fin
+		if n == 0 && fin {
+			ws.newline = true
+		}
+		return nil
+	case wsClose, wsPing, wsPong:
+		if n > 125 {
+			return errWSProtocol
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(ws.r, payload); err != nil {
+			return err
+		}
+		for i := range payload {
+			payload[i] ^= ws.mask[i&3]
+		}
+		switch op {
+		case wsClose:
+			ws.closed.Store(true)
+			return ws.writeFrame(wsClose, payload)
+		case wsPing:
+			return ws.writeFrame(wsPong, payload)
+		}
+		return nil
+	default:
+		return errWSProtocol
+	}
+}
+
+// Write sends p as one text message.
+func (ws *wsConn) Write(p []byte) (int, error) {
+	if err := ws.writeFrame(wsText, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (ws *wsConn) writeFrame(op byte, p []byte) error {
+	hdr := make([]byte, 2, 10+len(p))
+	hdr[0] = 0x80 | op
+	switch {
+	case len(p) < 126:
+		hdr[1] = byte(len(p))
+	case len(p) <= 0xffff:
+		hdr[1] = 126
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(len(p)))
+	default:
+		hdr[1] = 127
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(len(p)))
+	}
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	_, err := ws.Conn.Write(append(hdr, p...))
+	return err
+}
+
+// Close sends a close frame, unless the client already closed, and closes
+// the connection.
+func (ws *wsConn) Close() error {
+	if !ws.closed.Load() {
+		ws.writeFrame(wsClose, []byte{0x03, 0xe8}) // 1000: normal closure
+	}
+	return ws.Conn.Close()
+}
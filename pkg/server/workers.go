@@ -0,0 +1,41 @@
+package server
+
+// workerPool runs command handlers on a fixed set of goroutines. Connection
+// readers submit a command and wait for it to finish, so commands from one
+// connection still execute in order while the number of goroutines running
+// handlers (and their stacks) stays bounded however many clients connect.
+type workerPool struct {
+	jobs chan func()
+}
+
+// newWorkerPool starts n workers fed by a queue of the given depth. When
+// the queue is full, submitting readers block, which applies backpressure.
+func newWorkerPool(n, queue int) *workerPool {
+	p := &workerPool{jobs: make(chan func(), queue)}
+	for i := 0; i < n; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) work() {
+	for job := range p.jobs {
+		job()
+	}
+}
+
+// do runs fn on a worker and waits for it to return.
+func (p *workerPool) do(fn func()) {
+	done := make(chan struct{})
+	p.jobs <- func() {
+		defer close(done)
+		fn()
+	}
+	<-done
+}
+
+// stop lets the workers exit once the queue is drained. No commands may be
+// submitted afterwards.
+func (p *workerPool) stop() {
+	close(p.jobs)
+}
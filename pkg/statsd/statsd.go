@@ -0,0 +1,113 @@
+// Package statsd is a minimal StatsD client. It sends gauges and counters
+// over UDP, packing as many metrics into each datagram as fit, and can add
+// DogStatsD-style tags for Datadog agents.
+package statsd
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxPacket keeps datagrams under a typical Ethernet MTU.
+const maxPacket = 1432
+
+// Options configures a Client.
+type Options struct {
+	// Addr is the agent address; default "localhost:8125".
+	Addr string
+
+	// Prefix is prepended to every metric name, e.g. "redigo.".
+	Prefix string
+
+	// Tags enables the DogStatsD tag extension ("|#k:v,..."). Plain StatsD
+	// servers do not understand tags, so they are dropped when disabled.
+	Tags bool
+}
+
+// Client buffers metrics and sends them on Flush, or earlier when a
+// datagram fills up. It is safe for concurrent use.
+type Client struct {
+	opts Options
+	conn net.Conn
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// New connects a UDP socket to opts.Addr. Nothing is sent until metrics are
+// recorded, and send errors are ignored, as is usual for StatsD.
+func New(opts Options) (*Client, error) {
+	if opts.Addr == "" {
+		opts.Addr = "localhost:8125"
+	}
+	conn, err := net.Dial("udp", opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{opts: opts, conn: conn}, nil
+}
+
+// Gauge records the current value of name. tags are name/value pairs.
+func (c *Client) Gauge(name string, value float64, tags ...string) {
+	c.add(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+// Count adds delta to the counter name. tags are name/value pairs.
+func (c *Client) Count(name string, delta int64, tags ...string) {
+	c.add(name, strconv.FormatInt(delta, 10), "c", tags)
+}
+
+func (c *Client) add(name, value, typ string, tags []string) {
+	var line strings.Builder
+	line.WriteString(c.opts.Prefix)
+	line.WriteString(name)
+	line.WriteByte(':')
+	line.WriteString(value)
+	line.WriteByte('|')
+	line.WriteString(typ)
+	if c.opts.Tags && len(tags) > 1 {
+		line.WriteString("|#")
+		for i := 0; i+1 < len(tags); i += 2 {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			line.WriteString(tags[i])
+			line.WriteByte(':')
+			line.WriteString(tags[i+1])
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.buf.Len() > 0 && c.buf.Len()+1+line.Len() > maxPacket {
+		c.flushLocked()
+	}
+	if c.buf.Len() > 0 {
+		c.buf.WriteByte('\n')
+	}
+	c.buf.WriteString(line.String())
+}
+
+// Flush sends any buffered metrics.
+func (c *Client) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+func (c *Client) flushLocked() {
+	if c.buf.Len() == 0 {
+		return
+	}
+	c.conn.Write(c.buf.Bytes())
+	c.buf.Reset()
+}
+
+// Close flushes and closes the socket.
+func (c *Client) Close() error {
+	c.Flush()
+	return c.conn.Close()
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// maxPendingAccesses bounds the access log; a full log is drained by the
+// reader that filled it.
+const maxPendingAccesses = 1024
+
+// access is one recorded read of an entry.
+type access struct {
+	e  *Entry
+	at time.Time
+}
+
+// accessLog batches reads so Get can run under the read lock. The LRU list
+// and LFU counters are only touched when the batch is applied, which always
+// happens with the store's write lock held.
+type accessLog struct {
+	mu      sync.Mutex
+	pending []access
+}
+
+// record queues a read of e and reports whether the log is now full.
+func (l *accessLog) record(e *Entry, at time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pending = append(l.pending, access{e: e, at: at})
+	return len(l.pending) >= maxPendingAccesses
+}
+
+// take empties the log and returns what it held.
+func (l *accessLog) take() []access {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	batch := l.pending
+	l.pending = nil
+	return batch
+}
+
+// applyAccesses replays queued reads onto the LRU list and LFU counters.
+// The caller must hold s.mu for writing. Accesses to entries that have since
+// been removed or replaced are dropped.
+func (s *Store) applyAccesses() {
+	for _, a := range s.access.take() {
+		if s.data[a.e.key] != a.e {
+			continue
+		}
+		a.e.LastAccess = a.at.Unix()
+		s.lfuTouch(a.e, a.at)
+		s.lru.moveToFront(a.e)
+	}
+}
+
+// flushAccesses applies queued reads, taking the write lock.
+func (s *Store) flushAccesses() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyAccesses()
+}
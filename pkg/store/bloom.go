@@ -0,0 +1,266 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+// A bloom filter answers "maybe present" or "definitely absent" in a few
+// bits per item. Like RedisBloom's, it scales: when the newest sub-filter
+// reaches its capacity another one is stacked on top, twice as large and
+// with half the error rate, so the overall error rate stays bounded.
+
+const (
+	bloomPrefix           = "bf:"
+	BloomDefaultErrorRate = 0.01
+	BloomDefaultCapacity  = 100
+	bloomMinErrorRate     = 1e-9
+
+	// filterMaxBytes bounds the memory of a bloom filter, all of its
+	// sub-filters included, and of a cuckoo filter. Any client can reserve
+	// one, and it is stored, logged and loaded as a single value.
+	filterMaxBytes = 32 << 20
+)
+
+var (
+	// ErrFilterExists is returned when reserving a filter on a key that
+	// already exists.
+	ErrFilterExists = errors.New("ERR item exists")
+	// ErrFilterParams is returned for an error rate outside [1e-9, 1) or a
+	// capacity of zero.
+	ErrFilterParams = errors.New("ERR error rate must be between 0.000000001 and 1 and capacity at least 1")
+	// ErrFilterTooLarge is returned when reserving a filter that would
+	// take more than filterMaxBytes.
+	ErrFilterTooLarge = errors.New("ERR filter would be larger than 32mb: lower the capacity or raise the error rate")
+)
+
+type bloomSub struct {
+	capacity, count uint64
+	k               uint64 // hash functions
+	bits            []byte
+}
+
+type bloomFilter struct {
+	errorRate float64 // of the newest sub-filter
+	subs      []*bloomSub
+}
+
+// bloomBits returns the number of bits of a sub-filter for capacity items
+// at errorRate.
+func bloomBits(capacity uint64, errorRate float64) float64 {
+	return math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+}
+
+func newBloomSub(capacity uint64, errorRate float64) *bloomSub {
+	m := bloomBits(capacity, errorRate)
+	k := max(1, math.Round(m/float64(capacity)*math.Ln2))
+	return &bloomSub{capacity: capacity, k: uint64(k), bits: make([]byte, (uint64(m)+7)/8)}
+}
+
+func newBloomFilter(capacity uint64, errorRate float64) *bloomFilter {
+	return &bloomFilter{errorRate: errorRate, subs: []*bloomSub{newBloomSub(capacity, errorRate)}}
+}
+
+// checkBloomParams validates the parameters of BF.RESERVE, sizing the
+// filter before it is allocated.
+func checkBloomParams(capacity uint64, errorRate float64) error {
+	if capacity == 0 || !(errorRate >= bloomMinErrorRate && errorRate < 1) {
+		return ErrFilterParams
+	}
+	if bloomBits(capacity, errorRate)/8 > filterMaxBytes {
+		return ErrFilterTooLarge
+	}
+	return nil
+}
+
+// size returns the bytes of every sub-filter of f.
+func (f *bloomFilter) size() int {
+	n := 0
+	for _, sub := range f.subs {
+		n += len(sub.bits)
+	}
+	return n
+}
+
+// itemHashes returns the two hashes that derive every probe position of
+// item (double hashing); the second one is odd so probes never repeat.
+func itemHashes(item string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(item))
+	var sum [16]byte
+	b := h.Sum(sum[:0])
+	return mix64(binary.BigEndian.Uint64(b[:8])), mix64(binary.BigEndian.Uint64(b[8:])) | 1
+}
+
+// mix64 is MurmurHash3's finalizer. FNV alone leaves the high bits nearly
+// identical for short inputs, and fingerprints and probe positions depend
+// on every bit.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// stateRand returns the next value of a splitmix64 sequence kept in
+// *state. Cuckoo filters and Top-K lists draw their random choices from it,
+// seeded from their own contents, so replaying a command from the AOF
+// makes the same choices and rebuilds the same value.
+func stateRand(state *uint64) uint64 {
+	*state += 0x9e3779b97f4a7c15
+	return mix64(*state)
+}
+
+func (b *bloomSub) has(h1, h2 uint64) bool {
+	m := uint64(len(b.bits)) * 8
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloomSub) set(h1, h2 uint64) {
+	m := uint64(len(b.bits)) * 8
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/8] |= 1 << (bit % 8)
+	}
+	b.count++
+}
+
+func (f *bloomFilter) exists(item string) bool {
+	h1, h2 := itemHashes(item)
+	for _, sub := range f.subs {
+		if sub.has(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds item and reports whether it was (definitely) new. Adding an
+// item that may be present changes nothing, which makes BF.ADD safe to
+// replay from the AOF. Once the sub-filter needed for a new item would
+// take the filter past filterMaxBytes, add fails with ErrFilterFull and
+// leaves f unchanged.
+func (f *bloomFilter) add(item string) (bool, error) {
+	if f.exists(item) {
+		return false, nil
+	}
+	last := f.subs[len(f.subs)-1]
+	if last.count >= last.capacity {
+		capacity, rate := last.capacity*2, f.errorRate/2
+		if float64(f.size())+bloomBits(capacity, rate)/8 > filterMaxBytes {
+			return false, ErrFilterFull
+		}
+		f.errorRate = rate
+		last = newBloomSub(capacity, rate)
+		f.subs = append(f.subs, last)
+	}
+	last.set(itemHashes(item))
+	return true, nil
+}
+
+func (f *bloomFilter) encode() string {
+	b := binary.BigEndian.AppendUint64(nil, math.Float64bits(f.errorRate))
+	b = binary.AppendUvarint(b, uint64(len(f.subs)))
+	for _, sub := range f.subs {
+		b = binary.AppendUvarint(b, sub.capacity)
+		b = binary.AppendUvarint(b, sub.count)
+		b = binary.AppendUvarint(b, sub.k)
+		b = binary.AppendUvarint(b, uint64(len(sub.bits)))
+		b = append(b, sub.bits...)
+	}
+	return encodeTagged(bloomPrefix, b)
+}
+
+func decodeBloom(v string) (*bloomFilter, error) {
+	r, err := decodeTagged(v, bloomPrefix)
+	if err != nil {
+		return nil, err
+	}
+	f := &bloomFilter{errorRate: math.Float64frombits(r.uint64())}
+	n := r.uvarint()
+	for i := uint64(0); i < n && !r.bad; i++ {
+		sub := &bloomSub{capacity: r.uvarint(), count: r.uvarint(), k: r.uvarint()}
+		sub.bits = r.bytes(r.uvarint())
+		f.subs = append(f.subs, sub)
+	}
+	if err := r.err(); err != nil || len(f.subs) == 0 {
+		return nil, ErrWrongType
+	}
+	for _, sub := range f.subs {
+		if len(sub.bits) == 0 {
+			return nil, ErrWrongType
+		}
+	}
+	return f, nil
+}
+
+// BFReserve creates an empty bloom filter at key sized for capacity items
+// at the given false positive rate. It returns ErrFilterExists if the key
+// exists.
+func (s *Store) BFReserve(key string, errorRate float64, capacity uint64) error {
+	if err := checkBloomParams(capacity, errorRate); err != nil {
+		return err
+	}
+	_, _, err := s.modify(key, func(_ string, exists bool) (string, bool, error) {
+		if exists {
+			return "", false, ErrFilterExists
+		}
+		return newBloomFilter(capacity, errorRate).encode(), true, nil
+	})
+	return err
+}
+
+// BFAdd adds items to the bloom filter at key, creating it with the
+// default capacity and error rate if the key does not exist. It reports
+// for each item whether it was new. It fails with ErrFilterFull, adding
+// none of the items, if the filter can't grow to hold them.
+func (s *Store) BFAdd(key string, items ...string) ([]bool, error) {
+	added := make([]bool, len(items))
+	_, _, err := s.modify(key, func(old string, exists bool) (string, bool, error) {
+		f := newBloomFilter(BloomDefaultCapacity, BloomDefaultErrorRate)
+		if exists {
+			var err error
+			if f, err = decodeBloom(old); err != nil {
+				return "", false, err
+			}
+		}
+		changed := !exists
+		for i, item := range items {
+			var err error
+			if added[i], err = f.add(item); err != nil {
+				return "", false, err
+			}
+			changed = changed || added[i]
+		}
+		return f.encode(), changed, nil
+	})
+	return added, err
+}
+
+// BFExists reports for each item whether it may be in the bloom filter at
+// key. A missing key holds no items.
+func (s *Store) BFExists(key string, items ...string) ([]bool, error) {
+	found := make([]bool, len(items))
+	v, ok := s.Get(key)
+	if !ok {
+		return found, nil
+	}
+	f, err := decodeBloom(v)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		found[i] = f.exists(item)
+	}
+	return found, nil
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestBFReserve(t *testing.T) {
+	s := New()
+	s.Set("plain", "v")
+	tests := []struct {
+		key      string
+		rate     float64
+		capacity uint64
+		err      error
+	}{
+		{key: "a", rate: 0.01, capacity: 100},
+		{key: "a", rate: 0.01, capacity: 100, err: ErrFilterExists},
+		{key: "plain", rate: 0.01, capacity: 100, err: ErrFilterExists},
+		{key: "b", rate: 0, capacity: 100, err: ErrFilterParams},
+		{key: "b", rate: 1, capacity: 100, err: ErrFilterParams},
+		{key: "b", rate: 1e-10, capacity: 100, err: ErrFilterParams},
+		{key: "b", rate: 0.01, capacity: 0, err: ErrFilterParams},
+		{key: "b", rate: 1e-9, capacity: 1 << 30, err: ErrFilterTooLarge},
+		{key: "b", rate: 1e-9, capacity: 1},
+	}
+	for _, tt := range tests {
+		if err := s.BFReserve(tt.key, tt.rate, tt.capacity); err != tt.err {
+			t.Errorf("BFReserve(%s, %g, %d) = %v, want %v", tt.key, tt.rate, tt.capacity, err, tt.err)
+		}
+	}
+}
+
+func TestBFAdd(t *testing.T) {
+	s := New()
+	s.Set("plain", "v")
+	tests := []struct {
+		op    string // ADD or EXISTS
+		key   string
+		items []string
+		want  []bool
+		err   error
+	}{
+		{op: "EXISTS", key: "f", items: []string{"a"}, want: []bool{false}},
+		{op: "ADD", key: "f", items: []string{"a", "b", "a"}, want: []bool{true, true, false}},
+		{op: "ADD", key: "f", items: []string{"b"}, want: []bool{false}},
+		{op: "EXISTS", key: "f", items: []string{"a", "b", "c"}, want: []bool{true, true, false}},
+		{op: "ADD", key: "plain", items: []string{"a"}, err: ErrWrongType},
+		{op: "EXISTS", key: "plain", items: []string{"a"}, err: ErrWrongType},
+	}
+	for _, tt := range tests {
+		var got []bool
+		var err error
+		if tt.op == "ADD" {
+			got, err = s.BFAdd(tt.key, tt.items...)
+		} else {
+			got, err = s.BFExists(tt.key, tt.items...)
+		}
+		if err != tt.err || err == nil && !slices.Equal(got, tt.want) {
+			t.Errorf("%s %s %v = %v, %v; want %v, %v", tt.op, tt.key, tt.items, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// A filter filled well past its capacity stacks sub-filters and keeps its
+// false positive rate near the one it was reserved with.
+func TestBFScaling(t *testing.T) {
+	s := New()
+	if err := s.BFReserve("f", 0.01, 100); err != nil {
+		t.Fatal(err)
+	}
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = fmt.Sprint("in", i)
+	}
+	if _, err := s.BFAdd("f", items...); err != nil {
+		t.Fatal(err)
+	}
+	found, _ := s.BFExists("f", items...)
+	if i := slices.Index(found, false); i >= 0 {
+		t.Fatalf("%s was added but is not found", items[i])
+	}
+	v, _ := s.Get("f")
+	if f, err := decodeBloom(v); err != nil || len(f.subs) < 3 {
+		t.Fatalf("decodeBloom = %v, %v; want at least 3 sub-filters", f, err)
+	}
+
+	others := make([]string, 10000)
+	for i := range others {
+		others[i] = fmt.Sprint("out", i)
+	}
+	found, _ = s.BFExists("f", others...)
+	fp := 0
+	for _, ok := range found {
+		if ok {
+			fp++
+		}
+	}
+	if rate := float64(fp) / float64(len(others)); rate > 0.03 {
+		t.Errorf("false positive rate %.3f, want about 0.01", rate)
+	}
+}
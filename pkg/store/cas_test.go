@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/redigotest"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	clock := redigotest.NewClock(time.Unix(1_000_000, 0))
+	s := store.New()
+	s.SetClock(clock)
+	s.Set("k", "a")
+	s.Setwithttl("t", "a", 10)
+
+	tests := []struct {
+		advance         time.Duration
+		key             string
+		expected, value string
+		ttl             int64
+		current         string
+		exists, swapped bool
+		wantTTL         int64 // of key afterwards
+	}{
+		{key: "missing", expected: "", value: "x", wantTTL: -2},
+		{key: "k", expected: "b", value: "x", current: "a", exists: true, wantTTL: -1},
+		{key: "k", expected: "a", value: "b", current: "b", exists: true, swapped: true, wantTTL: -1},
+		{key: "k", expected: "b", value: "c", ttl: 5, current: "c", exists: true, swapped: true, wantTTL: 5},
+		// a swap without a TTL keeps the key's
+		{key: "t", expected: "a", value: "b", current: "b", exists: true, swapped: true, wantTTL: 10},
+		{advance: 4 * time.Second, key: "t", expected: "b", value: "c", ttl: -1, current: "c", exists: true, swapped: true, wantTTL: 6},
+		{advance: 7 * time.Second, key: "t", expected: "c", value: "d", wantTTL: -2},
+		{key: "k", expected: "c", value: "d", wantTTL: -2},
+	}
+	for i, tt := range tests {
+		clock.Advance(tt.advance)
+		cur, exists, swapped, err := s.CompareAndSwap(tt.key, tt.expected, tt.value, tt.ttl)
+		if err != nil || cur != tt.current || exists != tt.exists || swapped != tt.swapped {
+			t.Errorf("step %d: CompareAndSwap(%s, %s, %s, %d) = %q, %v, %v, %v; want %q, %v, %v",
+				i, tt.key, tt.expected, tt.value, tt.ttl, cur, exists, swapped, err, tt.current, tt.exists, tt.swapped)
+		}
+		if ttl := s.TTL(tt.key); ttl != tt.wantTTL {
+			t.Errorf("step %d: TTL(%s) = %d, want %d", i, tt.key, ttl, tt.wantTTL)
+		}
+	}
+}
+
+func TestCompareAndSwapLimit(t *testing.T) {
+	s := store.New()
+	s.SetEvictionPolicy(store.PolicyNoEviction)
+	s.Set("k", "a")
+	s.SetMaxMemory(1)
+	if _, _, swapped, err := s.CompareAndSwap("k", "a", "a much longer value", 0); swapped || err == nil {
+		t.Fatalf("CompareAndSwap over the memory limit = %v, %v; want an error", swapped, err)
+	}
+	if v, _ := s.Get("k"); v != "a" {
+		t.Errorf("k = %q after a refused swap, want a", v)
+	}
+}
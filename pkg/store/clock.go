@@ -0,0 +1,35 @@
+package store
+
+import "time"
+
+// Clock tells the store the time. Expiry, LRU recency, LFU decay and
+// throttling all read it, so a fake clock makes them deterministic in
+// tests: advancing it expires keys without sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the default Clock.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// SetClock replaces the store's clock; nil restores real time. Call it
+// before the store is used: the clock is read without locking.
+func (s *Store) SetClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+	s.clock = c
+}
+
+// now returns the current time according to the store's clock.
+func (s *Store) now() time.Time {
+	return s.clock.Now()
+}
+
+// Now returns the current time according to the store's clock, for
+// callers that turn TTLs into expiry times the store agrees with.
+func (s *Store) Now() time.Time {
+	return s.now()
+}
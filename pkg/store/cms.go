@@ -0,0 +1,156 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// A count-min sketch estimates how often items were seen: depth rows of
+// width counters, each item incrementing one counter per row. The estimate
+// is the smallest of its counters, so it can overcount but never undercount.
+
+const (
+	cmsPrefix   = "cms:"
+	maxCMSCells = 1 << 24
+)
+
+var (
+	// ErrNoSuchKey is returned by sketch commands, which need the sketch to
+	// be created first, on a missing key.
+	ErrNoSuchKey = errors.New("ERR key does not exist")
+	// ErrSketchParams is returned for sketch dimensions that are zero or
+	// too large.
+	ErrSketchParams = errors.New("ERR invalid sketch dimensions")
+)
+
+type countMinSketch struct {
+	width, depth uint64
+	counts       []uint64 // row-major, depth rows of width counters
+}
+
+// CMSDims returns the dimensions of a sketch whose estimates overcount by
+// at most errorRate of the total count with the given probability of
+// failure, using the same formulas as RedisBloom's CMS.INITBYPROB.
+func CMSDims(errorRate, probability float64) (width, depth uint64, ok bool) {
+	if errorRate <= 0 || errorRate >= 1 || probability <= 0 || probability >= 1 {
+		return 0, 0, false
+	}
+	width = uint64(math.Ceil(2 / errorRate))
+	depth = uint64(math.Ceil(math.Log10(probability) / math.Log10(0.5)))
+	return width, depth, true
+}
+
+func validSketchDims(width, depth uint64) bool {
+	return width > 0 && depth > 0 && width <= maxCMSCells && depth <= maxCMSCells/width
+}
+
+// cell returns the index of item's counter in row, from its two hashes.
+func (c *countMinSketch) cell(row, h1, h2 uint64) uint64 {
+	return row*c.width + (h1+row*h2)%c.width
+}
+
+func (c *countMinSketch) incr(item string, n uint64) uint64 {
+	h1, h2 := itemHashes(item)
+	est := uint64(math.MaxUint64)
+	for row := uint64(0); row < c.depth; row++ {
+		i := c.cell(row, h1, h2)
+		if c.counts[i] > math.MaxUint64-n {
+			c.counts[i] = math.MaxUint64
+		} else {
+			c.counts[i] += n
+		}
+		est = min(est, c.counts[i])
+	}
+	return est
+}
+
+func (c *countMinSketch) query(item string) uint64 {
+	h1, h2 := itemHashes(item)
+	est := uint64(math.MaxUint64)
+	for row := uint64(0); row < c.depth; row++ {
+		est = min(est, c.counts[c.cell(row, h1, h2)])
+	}
+	return est
+}
+
+func (c *countMinSketch) encode() string {
+	b := binary.AppendUvarint(nil, c.width)
+	b = binary.AppendUvarint(b, c.depth)
+	for _, n := range c.counts {
+		b = binary.AppendUvarint(b, n)
+	}
+	return encodeTagged(cmsPrefix, b)
+}
+
+func decodeCMS(v string) (*countMinSketch, error) {
+	r, err := decodeTagged(v, cmsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	c := &countMinSketch{width: r.uvarint(), depth: r.uvarint()}
+	if r.bad || !validSketchDims(c.width, c.depth) {
+		return nil, ErrWrongType
+	}
+	c.counts = make([]uint64, c.width*c.depth)
+	for i := range c.counts {
+		c.counts[i] = r.uvarint()
+	}
+	return c, r.err()
+}
+
+// CMSInit creates an empty count-min sketch at key. It returns
+// ErrFilterExists if the key exists.
+func (s *Store) CMSInit(key string, width, depth uint64) error {
+	if !validSketchDims(width, depth) {
+		return ErrSketchParams
+	}
+	_, _, err := s.modify(key, func(_ string, exists bool) (string, bool, error) {
+		if exists {
+			return "", false, ErrFilterExists
+		}
+		c := &countMinSketch{width: width, depth: depth, counts: make([]uint64, width*depth)}
+		return c.encode(), true, nil
+	})
+	return err
+}
+
+// CMSIncrBy adds incrs[i] to the count of items[i] in the sketch at key and
+// returns the new estimates. The sketch must exist.
+func (s *Store) CMSIncrBy(key string, items []string, incrs []uint64) ([]uint64, error) {
+	counts := make([]uint64, len(items))
+	_, _, err := s.modify(key, func(old string, exists bool) (string, bool, error) {
+		if !exists {
+			return "", false, ErrNoSuchKey
+		}
+		c, err := decodeCMS(old)
+		if err != nil {
+			return "", false, err
+		}
+		for i, item := range items {
+			counts[i] = c.incr(item, incrs[i])
+		}
+		return c.encode(), true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// CMSQuery returns the estimated count of each item in the sketch at key.
+func (s *Store) CMSQuery(key string, items ...string) ([]uint64, error) {
+	v, ok := s.Get(key)
+	if !ok {
+		return nil, ErrNoSuchKey
+	}
+	c, err := decodeCMS(v)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]uint64, len(items))
+	for i, item := range items {
+		counts[i] = c.query(item)
+	}
+	return counts, nil
+}
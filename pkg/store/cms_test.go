@@ -0,0 +1,117 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestCMSDims(t *testing.T) {
+	tests := []struct {
+		errorRate, probability float64
+		width, depth           uint64
+		ok                     bool
+	}{
+		{errorRate: 0.001, probability: 0.01, width: 2000, depth: 7, ok: true},
+		{errorRate: 0.01, probability: 0.5, width: 200, depth: 1, ok: true},
+		{errorRate: 0, probability: 0.01},
+		{errorRate: 1, probability: 0.01},
+		{errorRate: 0.01, probability: 0},
+		{errorRate: 0.01, probability: 1},
+	}
+	for _, tt := range tests {
+		w, d, ok := CMSDims(tt.errorRate, tt.probability)
+		if w != tt.width || d != tt.depth || ok != tt.ok {
+			t.Errorf("CMSDims(%g, %g) = %d, %d, %v; want %d, %d, %v", tt.errorRate, tt.probability, w, d, ok, tt.width, tt.depth, tt.ok)
+		}
+	}
+}
+
+func TestCMSInit(t *testing.T) {
+	s := New()
+	tests := []struct {
+		key          string
+		width, depth uint64
+		err          error
+	}{
+		{key: "a", width: 100, depth: 5},
+		{key: "a", width: 100, depth: 5, err: ErrFilterExists},
+		{key: "b", width: 0, depth: 5, err: ErrSketchParams},
+		{key: "b", width: 100, depth: 0, err: ErrSketchParams},
+		{key: "b", width: maxCMSCells, depth: 2, err: ErrSketchParams},
+		{key: "b", width: maxCMSCells + 1, depth: 1, err: ErrSketchParams},
+	}
+	for _, tt := range tests {
+		if err := s.CMSInit(tt.key, tt.width, tt.depth); err != tt.err {
+			t.Errorf("CMSInit(%s, %d, %d) = %v, want %v", tt.key, tt.width, tt.depth, err, tt.err)
+		}
+	}
+}
+
+func TestCMSCounts(t *testing.T) {
+	s := New()
+	s.Set("plain", "v")
+	if err := s.CMSInit("c", 1000, 5); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		op    string // INCR or QUERY
+		key   string
+		items []string
+		incrs []uint64
+		want  []uint64
+		err   error
+	}{
+		{op: "QUERY", key: "c", items: []string{"a"}, want: []uint64{0}},
+		{op: "INCR", key: "c", items: []string{"a", "b", "a"}, incrs: []uint64{1, 5, 2}, want: []uint64{1, 5, 3}},
+		{op: "QUERY", key: "c", items: []string{"a", "b", "z"}, want: []uint64{3, 5, 0}},
+		{op: "INCR", key: "c", items: []string{"b"}, incrs: []uint64{math.MaxUint64}, want: []uint64{math.MaxUint64}},
+		{op: "INCR", key: "c", items: []string{"b"}, incrs: []uint64{1}, want: []uint64{math.MaxUint64}},
+		{op: "INCR", key: "missing", items: []string{"a"}, incrs: []uint64{1}, err: ErrNoSuchKey},
+		{op: "QUERY", key: "missing", items: []string{"a"}, err: ErrNoSuchKey},
+		{op: "INCR", key: "plain", items: []string{"a"}, incrs: []uint64{1}, err: ErrWrongType},
+		{op: "QUERY", key: "plain", items: []string{"a"}, err: ErrWrongType},
+	}
+	for _, tt := range tests {
+		var got []uint64
+		var err error
+		if tt.op == "INCR" {
+			got, err = s.CMSIncrBy(tt.key, tt.items, tt.incrs)
+		} else {
+			got, err = s.CMSQuery(tt.key, tt.items...)
+		}
+		if err != tt.err || err == nil && !slices.Equal(got, tt.want) {
+			t.Errorf("%s %s %v = %v, %v; want %v, %v", tt.op, tt.key, tt.items, got, err, tt.want, tt.err)
+		}
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Error("CMS.INCRBY created a missing key")
+	}
+}
+
+// A sketch much narrower than the number of items overcounts but never
+// undercounts.
+func TestCMSNeverUndercounts(t *testing.T) {
+	s := New()
+	if err := s.CMSInit("c", 8, 3); err != nil {
+		t.Fatal(err)
+	}
+	items := make([]string, 100)
+	incrs := make([]uint64, len(items))
+	for i := range items {
+		items[i], incrs[i] = fmt.Sprint("item", i), uint64(i+1)
+	}
+	if _, err := s.CMSIncrBy("c", items, incrs); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.CMSQuery("c", items...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range got {
+		if n < incrs[i] {
+			t.Errorf("%s: estimate %d < count %d", items[i], n, incrs[i])
+		}
+	}
+}
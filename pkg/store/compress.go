@@ -0,0 +1,90 @@
+package store
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Plain string values at least compressThreshold bytes long are stored
+// deflated (at BestSpeed) and inflated again on every read, trading CPU for
+// a larger effective cache. Typed values (filters, sketches, time series)
+// are left alone, as are values that don't shrink. Compression is internal:
+// Get, snapshots, events and the AOF all see the original value, while
+// memory accounting (maxmemory, quotas, MEMORY USAGE) counts stored bytes.
+
+// flate writers allocate hundreds of KB each, so they are reused.
+var flateWriters = sync.Pool{New: func() any {
+	w, _ := flate.NewWriter(nil, flate.BestSpeed)
+	return w
+}}
+
+var flateReaders = sync.Pool{New: func() any {
+	return flate.NewReader(nil)
+}}
+
+// SetCompressThreshold compresses plain string values of at least n bytes.
+// 0 turns compression off. Only values written afterwards are affected.
+func (s *Store) SetCompressThreshold(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compressThreshold = n
+}
+
+// compress replaces the value of e, about to be stored, with its deflated
+// form if it is over the threshold and gets smaller. Callers hold the
+// write lock.
+func (s *Store) compress(e *Entry) {
+	n := len(e.Value)
+	if s.compressThreshold <= 0 || int64(n) < s.compressThreshold || ValueType(e.Value) != "string" {
+		return
+	}
+	var b bytes.Buffer
+	w := flateWriters.Get().(*flate.Writer)
+	w.Reset(&b)
+	io.WriteString(w, e.Value)
+	w.Close()
+	flateWriters.Put(w)
+	if b.Len() >= n {
+		return
+	}
+	e.Value = b.String()
+	e.rawLen = n
+}
+
+// raw returns the original value of e.
+func (e *Entry) raw() string {
+	if e.rawLen == 0 {
+		return e.Value
+	}
+	r := flateReaders.Get().(io.ReadCloser)
+	defer flateReaders.Put(r)
+	r.(flate.Resetter).Reset(strings.NewReader(e.Value), nil)
+	var b strings.Builder
+	b.Grow(e.rawLen)
+	// The data was written by compress, so it can't be corrupt.
+	io.Copy(&b, r)
+	return b.String()
+}
+
+// valueType is ValueType of the original value; only plain strings are
+// ever compressed.
+func (e *Entry) valueType() string {
+	if e.rawLen != 0 {
+		return "string"
+	}
+	return ValueType(e.Value)
+}
+
+// trackCompression keeps the compression counters in sync as e is added
+// (sign 1) or removed (sign -1). Callers hold the write lock.
+func (s *Store) trackCompression(e *Entry, sign int64) {
+	if e.rawLen == 0 {
+		return
+	}
+	s.compressedKeys += sign
+	s.compressedRaw += sign * int64(e.rawLen)
+	s.compressedBytes += sign * int64(len(e.Value))
+}
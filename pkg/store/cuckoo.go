@@ -0,0 +1,236 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// A cuckoo filter stores a one-byte fingerprint per item in one of two
+// candidate buckets. Unlike a bloom filter it supports deletion, at the
+// cost of a fixed capacity: an insert that cannot find room after
+// relocating cuckooMaxKicks fingerprints fails with ErrFilterFull. Writes
+// are deterministic, so they are logged as commands and replayed.
+
+const (
+	cuckooPrefix          = "cf:"
+	cuckooBucketSize      = 4
+	cuckooMaxKicks        = 500
+	CuckooDefaultCapacity = 1024
+)
+
+// ErrFilterFull is returned when a cuckoo filter has no room for an item,
+// or a bloom filter can't grow to hold one.
+var ErrFilterFull = errors.New("ERR filter is full")
+
+type cuckooFilter struct {
+	count   uint64
+	buckets []byte // cuckooBucketSize fingerprints per bucket, 0 is empty
+}
+
+func newCuckooFilter(capacity uint64) *cuckooFilter {
+	// a power of two number of buckets, so the alternate bucket of an
+	// alternate bucket is the original one
+	n := max(1, (capacity+cuckooBucketSize-1)/cuckooBucketSize)
+	n = 1 << bits.Len64(n-1)
+	return &cuckooFilter{buckets: make([]byte, n*cuckooBucketSize)}
+}
+
+func (f *cuckooFilter) numBuckets() uint64 {
+	return uint64(len(f.buckets)) / cuckooBucketSize
+}
+
+// locate returns item's fingerprint and its two candidate buckets.
+func (f *cuckooFilter) locate(item string) (byte, uint64, uint64) {
+	h1, h2 := itemHashes(item)
+	fp := byte(h2 >> 56)
+	if fp == 0 {
+		fp = 1
+	}
+	i1 := h1 & (f.numBuckets() - 1)
+	return fp, i1, f.alt(i1, fp)
+}
+
+func (f *cuckooFilter) alt(i uint64, fp byte) uint64 {
+	return (i ^ uint64(fp)*0x5bd1e995) & (f.numBuckets() - 1)
+}
+
+func (f *cuckooFilter) bucket(i uint64) []byte {
+	return f.buckets[i*cuckooBucketSize : (i+1)*cuckooBucketSize]
+}
+
+func (f *cuckooFilter) insert(i uint64, fp byte) bool {
+	b := f.bucket(i)
+	for j := range b {
+		if b[j] == 0 {
+			b[j] = fp
+			return true
+		}
+	}
+	return false
+}
+
+func (f *cuckooFilter) has(i uint64, fp byte) bool {
+	for _, v := range f.bucket(i) {
+		if v == fp {
+			return true
+		}
+	}
+	return false
+}
+
+// add inserts item, relocating fingerprints if both buckets are full. On
+// ErrFilterFull the filter has been shuffled and must be discarded.
+func (f *cuckooFilter) add(item string) error {
+	fp, i1, i2 := f.locate(item)
+	if f.insert(i1, fp) || f.insert(i2, fp) {
+		f.count++
+		return nil
+	}
+	// the same filter and item always relocate the same fingerprints
+	seed := i1 ^ uint64(fp)<<56 ^ f.count*0xff51afd7ed558ccd
+	i := i1
+	if stateRand(&seed)&1 == 1 {
+		i = i2
+	}
+	for n := 0; n < cuckooMaxKicks; n++ {
+		b := f.bucket(i)
+		j := stateRand(&seed) % cuckooBucketSize
+		fp, b[j] = b[j], fp
+		i = f.alt(i, fp)
+		if f.insert(i, fp) {
+			f.count++
+			return nil
+		}
+	}
+	return ErrFilterFull
+}
+
+func (f *cuckooFilter) exists(item string) bool {
+	fp, i1, i2 := f.locate(item)
+	return f.has(i1, fp) || f.has(i2, fp)
+}
+
+// del removes one copy of item's fingerprint and reports whether there
+// was one.
+func (f *cuckooFilter) del(item string) bool {
+	fp, i1, i2 := f.locate(item)
+	for _, i := range []uint64{i1, i2} {
+		b := f.bucket(i)
+		for j := range b {
+			if b[j] == fp {
+				b[j] = 0
+				f.count--
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *cuckooFilter) encode() string {
+	b := binary.AppendUvarint(nil, f.count)
+	b = binary.AppendUvarint(b, uint64(len(f.buckets)))
+	return encodeTagged(cuckooPrefix, append(b, f.buckets...))
+}
+
+func decodeCuckoo(v string) (*cuckooFilter, error) {
+	r, err := decodeTagged(v, cuckooPrefix)
+	if err != nil {
+		return nil, err
+	}
+	f := &cuckooFilter{count: r.uvarint()}
+	f.buckets = r.bytes(r.uvarint())
+	n := f.numBuckets()
+	if err := r.err(); err != nil || n == 0 || n&(n-1) != 0 || len(f.buckets)%cuckooBucketSize != 0 {
+		return nil, ErrWrongType
+	}
+	return f, nil
+}
+
+// cuckooUpdate applies fn to the cuckoo filter at key, creating one with
+// the default capacity if create is set and the key does not exist. It
+// reports whether fn changed the filter.
+func (s *Store) cuckooUpdate(key string, create bool, fn func(f *cuckooFilter) (bool, error)) (bool, error) {
+	_, changed, err := s.modify(key, func(old string, exists bool) (string, bool, error) {
+		if !exists && !create {
+			return "", false, nil
+		}
+		f := newCuckooFilter(CuckooDefaultCapacity)
+		if exists {
+			var err error
+			if f, err = decodeCuckoo(old); err != nil {
+				return "", false, err
+			}
+		}
+		changed, err := fn(f)
+		if err != nil || !changed {
+			return "", false, err
+		}
+		return f.encode(), true, nil
+	})
+	return changed, err
+}
+
+// checkCuckooParams validates the capacity of CF.RESERVE, sizing the filter
+// before it is allocated.
+func checkCuckooParams(capacity uint64) error {
+	if capacity == 0 {
+		return ErrFilterParams
+	}
+	n := (capacity + cuckooBucketSize - 1) / cuckooBucketSize
+	if n > filterMaxBytes/cuckooBucketSize || uint64(1)<<bits.Len64(n-1)*cuckooBucketSize > filterMaxBytes {
+		return ErrFilterTooLarge
+	}
+	return nil
+}
+
+// CFReserve creates an empty cuckoo filter at key with room for about
+// capacity items. It returns ErrFilterExists if the key exists.
+func (s *Store) CFReserve(key string, capacity uint64) error {
+	if err := checkCuckooParams(capacity); err != nil {
+		return err
+	}
+	_, _, err := s.modify(key, func(_ string, exists bool) (string, bool, error) {
+		if exists {
+			return "", false, ErrFilterExists
+		}
+		return newCuckooFilter(capacity).encode(), true, nil
+	})
+	return err
+}
+
+// CFAdd adds item to the cuckoo filter at key, creating it with the default
+// capacity if the key does not exist. Items can be added more than once.
+func (s *Store) CFAdd(key, item string) error {
+	_, err := s.cuckooUpdate(key, true, func(f *cuckooFilter) (bool, error) {
+		return true, f.add(item)
+	})
+	return err
+}
+
+// CFDel removes one copy of item from the cuckoo filter at key and reports
+// whether it was found.
+func (s *Store) CFDel(key, item string) (bool, error) {
+	return s.cuckooUpdate(key, false, func(f *cuckooFilter) (bool, error) {
+		return f.del(item), nil
+	})
+}
+
+// CFExists reports for each item whether it may be in the cuckoo filter at
+// key. A missing key holds no items.
+func (s *Store) CFExists(key string, items ...string) ([]bool, error) {
+	found := make([]bool, len(items))
+	v, ok := s.Get(key)
+	if !ok {
+		return found, nil
+	}
+	f, err := decodeCuckoo(v)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		found[i] = f.exists(item)
+	}
+	return found, nil
+}
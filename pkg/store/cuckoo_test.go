@@ -0,0 +1,98 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCFReserve(t *testing.T) {
+	s := New()
+	tests := []struct {
+		key      string
+		capacity uint64
+		err      error
+	}{
+		{key: "a", capacity: 100},
+		{key: "a", capacity: 100, err: ErrFilterExists},
+		{key: "b", capacity: 0, err: ErrFilterParams},
+		{key: "b", capacity: filterMaxBytes + 1, err: ErrFilterTooLarge},
+		{key: "b", capacity: filterMaxBytes},
+	}
+	for _, tt := range tests {
+		if err := s.CFReserve(tt.key, tt.capacity); err != tt.err {
+			t.Errorf("CFReserve(%s, %d) = %v, want %v", tt.key, tt.capacity, err, tt.err)
+		}
+	}
+}
+
+func TestCuckooFilter(t *testing.T) {
+	s := New()
+	s.Set("plain", "v")
+	// one bucket, so an item fits four times
+	if err := s.CFReserve("f", 4); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		op   string // ADD, DEL or EXISTS
+		key  string
+		item string
+		want bool // DEL: found; EXISTS: may be present
+		err  error
+	}{
+		{op: "EXISTS", key: "f", item: "x"},
+		{op: "DEL", key: "f", item: "x"},
+		{op: "ADD", key: "f", item: "x"},
+		{op: "ADD", key: "f", item: "x"},
+		{op: "ADD", key: "f", item: "x"},
+		{op: "ADD", key: "f", item: "x"},
+		{op: "ADD", key: "f", item: "x", err: ErrFilterFull},
+		{op: "EXISTS", key: "f", item: "x", want: true},
+		{op: "DEL", key: "f", item: "x", want: true},
+		{op: "DEL", key: "f", item: "x", want: true},
+		{op: "DEL", key: "f", item: "x", want: true},
+		{op: "EXISTS", key: "f", item: "x", want: true},
+		{op: "DEL", key: "f", item: "x", want: true},
+		{op: "DEL", key: "f", item: "x"},
+		{op: "EXISTS", key: "f", item: "x"},
+		{op: "DEL", key: "missing", item: "x"},
+		{op: "ADD", key: "g", item: "y"},
+		{op: "EXISTS", key: "g", item: "y", want: true},
+		{op: "ADD", key: "plain", item: "x", err: ErrWrongType},
+		{op: "DEL", key: "plain", item: "x", err: ErrWrongType},
+		{op: "EXISTS", key: "plain", item: "x", err: ErrWrongType},
+	}
+	for _, tt := range tests {
+		var got bool
+		var err error
+		switch tt.op {
+		case "ADD":
+			err = s.CFAdd(tt.key, tt.item)
+		case "DEL":
+			got, err = s.CFDel(tt.key, tt.item)
+		case "EXISTS":
+			var found []bool
+			if found, err = s.CFExists(tt.key, tt.item); err == nil {
+				got = found[0]
+			}
+		}
+		if got != tt.want || err != tt.err {
+			t.Errorf("%s %s %s = %v, %v; want %v, %v", tt.op, tt.key, tt.item, got, err, tt.want, tt.err)
+		}
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Error("CF.DEL created a missing key")
+	}
+}
+
+func TestCFExistsMany(t *testing.T) {
+	s := New()
+	for _, item := range []string{"a", "b"} {
+		if err := s.CFAdd("f", item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, err := s.CFExists("f", "a", "b", "zzz")
+	if err != nil || !slices.Equal(found, []bool{true, true, false}) {
+		t.Fatalf("CFExists = %v, %v", found, err)
+	}
+}
@@ -0,0 +1,100 @@
+package store
+
+// Go maps never shrink: deleting keys frees the entries but keeps the
+// buckets, so after a mass deletion the data map holds on to memory sized
+// for its peak. Defrag rebuilds the map once the live keys fall below a
+// percentage of that peak. The rebuild copies every key under the write
+// lock, which is why it waits for a map to be mostly empty and skips
+// small ones. FLUSHALL already starts from a fresh map.
+
+const (
+	defaultDefragThreshold = 25 // percent of the peak key count
+	defragMinKeys          = 1024
+
+	// Bucket layout of map[string]*Entry: 8 slots, each with a tophash
+	// byte, a string header and a pointer, plus an overflow pointer.
+	mapBucketSlots = 8
+	mapBucketBytes = mapBucketSlots*(1+16+8) + 8
+)
+
+// DefragStats reports the map rebuilds done by Defrag.
+type DefragStats struct {
+	Threshold      int   // percent; 0 means off
+	PeakKeys       int   // largest key count since the map was last built
+	Runs           int64 // rebuilds so far
+	ReclaimedBytes int64 // approximate bucket memory released by them
+}
+
+// SetDefragThreshold makes Defrag rebuild the data map once the key count
+// falls below pct percent of its peak. 0 turns rebuilding off, except for
+// forced ones.
+func (s *Store) SetDefragThreshold(pct int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defrag.threshold = min(pct, 100)
+}
+
+// DefragStats returns the current defrag counters.
+func (s *Store) DefragStats() DefragStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return DefragStats{
+		Threshold:      s.defrag.threshold,
+		PeakKeys:       s.defrag.peak,
+		Runs:           s.defrag.runs,
+		ReclaimedBytes: s.defrag.reclaimed,
+	}
+}
+
+// Defrag rebuilds the data map if it has become sparse, or whenever it
+// has shrunk when force is set, and returns the approximate number of
+// bytes released.
+func (s *Store) Defrag(force bool) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	live, peak := len(s.data), s.defrag.peak
+	if live >= peak {
+		return 0
+	}
+	if !force {
+		if s.defrag.threshold <= 0 || peak-live < defragMinKeys || live*100 >= peak*s.defrag.threshold {
+			return 0
+		}
+	}
+	data := make(map[string]*Entry, live)
+	for k, e := range s.data {
+		data[k] = e
+	}
+	s.data = data
+	s.defrag.peak = live
+	n := mapBytes(peak) - mapBytes(live)
+	s.defrag.runs++
+	s.defrag.reclaimed += n
+	return n
+}
+
+// defragState tracks how sparse the data map is. Fields are guarded by
+// Store.mu.
+type defragState struct {
+	threshold int
+	peak      int
+	runs      int64
+	reclaimed int64
+}
+
+// grew records the key count after an insert. Callers hold the write lock.
+func (d *defragState) grew(n int) {
+	if n > d.peak {
+		d.peak = n
+	}
+}
+
+// mapBytes estimates the bucket memory of a map grown to n keys: Go
+// doubles the bucket array once the average load passes 6.5 keys.
+func mapBytes(n int) int64 {
+	buckets := 1
+	for n*2 > buckets*13 {
+		buckets *= 2
+	}
+	return int64(buckets) * mapBucketBytes
+}
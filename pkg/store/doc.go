@@ -0,0 +1,27 @@
+// Package store is RediGo's in-memory key/value dataset: string values with
+// optional expiry, key and memory limits, and Redis-style eviction policies
+// (see policy.go). It is safe for concurrent use and has no dependency on
+// the network server, so Go programs can embed it directly.
+//
+// The exported API follows semantic versioning: exported names keep their
+// signatures and behaviour within a major version. Entry fields are a
+// read-only view; entries returned by Inspect and Snapshot are copies, and
+// changing them has no effect on the store.
+//
+// Writes go through Set, Setwithttl, IncrBy, CompareAndSwap, JSONSet,
+// JSONDel, Del, Expires and Reset; Update runs reads and writes to several
+// keys as one step. Set and friends return ErrOOM when the
+// dataset is full and the eviction policy cannot make room, and
+// ErrKeyTooLong or ErrValueTooLarge for writes over the size limits
+// (SetMaxKeyLength, SetMaxValueSize), or ErrQuotaExceeded when a namespace
+// is over its quota (SetQuota). JSON documents
+// are string values in a canonical encoding, updated in place by path (see
+// json.go). Snapshot gives a consistent view of the whole dataset for
+// iteration without blocking writers, Iterate walks such a view a few keys
+// per call with a cursor, and the optional key index (SetKeyIndex) makes
+// prefix lookups proportional to their result. OnSet, OnDelete, OnExpire,
+// OnEvict and OnFlush register hooks that are told about mutations
+// asynchronously, and OnGap about events they missed;
+// TrackRemovals instead queues evicted and expired keys for the caller to
+// collect in order with its own writes (TakeRemoved).
+package store
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Probabilistic structures (bloom.go, cuckoo.go, cms.go, topk.go) are
+// stored as ordinary string values: a type prefix followed by their binary
+// encoding in base64, which keeps the value free of whitespace for the AOF
+// and snapshots.
+
+// ErrWrongType is returned when a command expects a structure the value at
+// key does not hold.
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// ValueType names the kind of value v holds, from its encoding: "bloom",
+// "cuckoo", "cms", "topk", "timeseries", or "string" for everything else
+// (JSON documents included).
+func ValueType(v string) string {
+	switch {
+	case strings.HasPrefix(v, bloomPrefix):
+		return "bloom"
+	case strings.HasPrefix(v, cuckooPrefix):
+		return "cuckoo"
+	case strings.HasPrefix(v, cmsPrefix):
+		return "cms"
+	case strings.HasPrefix(v, topkPrefix):
+		return "topk"
+	case strings.HasPrefix(v, tsPrefix):
+		return "timeseries"
+	}
+	return "string"
+}
+
+// CheckValue reports whether v, a value read back from an AOF or a
+// snapshot, decodes as the structure its type prefix names. Plain strings
+// always pass.
+func CheckValue(v string) error {
+	var err error
+	typ := ValueType(v)
+	switch typ {
+	case "bloom":
+		_, err = decodeBloom(v)
+	case "cuckoo":
+		_, err = decodeCuckoo(v)
+	case "cms":
+		_, err = decodeCMS(v)
+	case "topk":
+		_, err = decodeTopK(v)
+	case "timeseries":
+		_, err = decodeSeries(v)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("corrupt %s encoding", typ)
+	}
+	return nil
+}
+
+// encodeTagged returns the stored form of the binary encoding b.
+func encodeTagged(prefix string, b []byte) string {
+	return prefix + base64.StdEncoding.EncodeToString(b)
+}
+
+// decodeTagged returns the binary encoding stored in v, or ErrWrongType if
+// v does not start with prefix.
+func decodeTagged(v, prefix string) (*binReader, error) {
+	if !strings.HasPrefix(v, prefix) {
+		return nil, ErrWrongType
+	}
+	b, err := base64.StdEncoding.DecodeString(v[len(prefix):])
+	if err != nil {
+		return nil, ErrWrongType
+	}
+	return &binReader{b: b}, nil
+}
+
+// binReader reads the fields of a binary encoding in order. Reads past the
+// end return zero values and set bad, which callers check once at the end.
+type binReader struct {
+	b   []byte
+	bad bool
+}
+
+func (r *binReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.bad = true
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *binReader) uint64() uint64 {
+	if len(r.b) < 8 {
+		r.bad = true
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+// bytes returns the next n bytes, copied so the result can be modified.
+func (r *binReader) bytes(n uint64) []byte {
+	if uint64(len(r.b)) < n {
+		r.bad = true
+		return nil
+	}
+	v := append([]byte(nil), r.b[:n]...)
+	r.b = r.b[n:]
+	return v
+}
+
+// err reports ErrWrongType if the encoding was truncated or had bytes left.
+func (r *binReader) err() error {
+	if r.bad || len(r.b) != 0 {
+		return ErrWrongType
+	}
+	return nil
+}
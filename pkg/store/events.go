@@ -0,0 +1,200 @@
+package store
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventQueueSize bounds the events waiting for delivery. When the queue is
+// full new events are dropped (and counted) rather than stalling writers,
+// and the hooks are told about the gap with an EventGap.
+const eventQueueSize = 4096
+
+// EventType identifies the mutation an Event reports.
+type EventType uint8
+
+const (
+	EventSet    EventType = iota + 1 // a key was written (SET, SETEX, INCR...)
+	EventDelete                      // a key was deleted (DEL, JSON.DEL...)
+	EventExpire                      // an expired key was removed
+	EventEvict                       // a key was evicted to respect a limit
+	EventFlush                       // every key was removed (Reset)
+	EventGap                         // events were dropped; see Event.Dropped
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	case EventFlush:
+		return "flush"
+	case EventGap:
+		return "gap"
+	}
+	return "unknown"
+}
+
+// Event describes one mutation. Value and ExpiresAt are the new entry's for
+// EventSet and the removed entry's otherwise; EventFlush and EventGap carry
+// no key. Dropped is the number of events an EventGap stands for.
+type Event struct {
+	Type      EventType
+	Key       string
+	Value     string
+	ExpiresAt int64
+	Time      time.Time
+	Dropped   int64
+}
+
+// eventBus queues events emitted under the store lock and delivers them to
+// the registered hooks on a single goroutine, in the order they happened.
+type eventBus struct {
+	active  atomic.Bool // set once any hook is registered
+	dropped atomic.Int64
+	once    sync.Once
+	queue   chan Event
+
+	mu    sync.RWMutex
+	hooks map[EventType][]func(Event)
+
+	// queued and handled count the events accepted by emit and those the
+	// hooks have finished with, so FlushEvents can wait for the backlog.
+	queued     atomic.Int64
+	handledMu  sync.Mutex
+	handled    int64
+	handledCnd *sync.Cond
+
+	// lost counts the events dropped since the last EventGap was delivered;
+	// gapQueued is set while an EventGap is waiting in the queue.
+	lost      atomic.Int64
+	gapQueued atomic.Bool
+}
+
+// OnSet registers fn to be called after a key is written.
+func (s *Store) OnSet(fn func(Event)) { s.events.subscribe(EventSet, fn) }
+
+// OnDelete registers fn to be called after a key is deleted.
+func (s *Store) OnDelete(fn func(Event)) { s.events.subscribe(EventDelete, fn) }
+
+// OnExpire registers fn to be called after an expired key is removed. Keys
+// expire lazily: reads stop returning them at once, but the event fires
+// when the key is actually cleaned up.
+func (s *Store) OnExpire(fn func(Event)) { s.events.subscribe(EventExpire, fn) }
+
+// OnEvict registers fn to be called after a key is evicted.
+func (s *Store) OnEvict(fn func(Event)) { s.events.subscribe(EventEvict, fn) }
+
+// OnFlush registers fn to be called after Reset removed every key. Reset
+// reports this one event instead of a delete per key.
+func (s *Store) OnFlush(fn func(Event)) { s.events.subscribe(EventFlush, fn) }
+
+// OnGap registers fn to be called when events had to be dropped. The gap
+// is reported in order, before any later event, and Dropped says how many
+// were lost; a hook that mirrors the store should resync from a Snapshot.
+func (s *Store) OnGap(fn func(Event)) { s.events.subscribe(EventGap, fn) }
+
+// Hooks run on a dedicated goroutine after the mutation has been applied,
+// one event at a time, and may call back into the store. A slow hook delays
+// later events; if more than eventQueueSize events are waiting, new ones are
+// dropped, counted in Stats.EventsDropped and reported as an EventGap.
+func (b *eventBus) subscribe(t EventType, fn func(Event)) {
+	b.mu.Lock()
+	if b.hooks == nil {
+		b.hooks = make(map[EventType][]func(Event))
+	}
+	b.hooks[t] = append(b.hooks[t], fn)
+	b.mu.Unlock()
+
+	b.once.Do(func() {
+		// One slot more than eventQueueSize is reserved for the EventGap.
+		b.queue = make(chan Event, eventQueueSize+1)
+		b.handledCnd = sync.NewCond(&b.handledMu)
+		go b.run()
+	})
+	b.active.Store(true)
+}
+
+// emit queues an event for e. It is called with the store's write lock
+// held and never blocks.
+func (b *eventBus) emit(t EventType, key string, e *Entry) {
+	if !b.active.Load() {
+		return
+	}
+	b.push(Event{Type: t, Key: key, Value: e.raw(), ExpiresAt: e.ExpiresAt, Time: time.Now()})
+}
+
+// emitFlush queues the EventFlush for Reset, under the same conditions as
+// emit.
+func (b *eventBus) emitFlush() {
+	if !b.active.Load() {
+		return
+	}
+	b.push(Event{Type: EventFlush, Time: time.Now()})
+}
+
+// push queues ev, or drops it when eventQueueSize events are waiting. The
+// first drop also queues an EventGap in the reserved slot; pushes are
+// serialised by the store lock and run only takes from the queue, so that
+// slot is free whenever gapQueued is unset.
+func (b *eventBus) push(ev Event) {
+	if len(b.queue) < eventQueueSize {
+		b.queue <- ev
+		b.queued.Add(1)
+		return
+	}
+	b.dropped.Add(1)
+	b.lost.Add(1)
+	if b.gapQueued.CompareAndSwap(false, true) {
+		b.queue <- Event{Type: EventGap, Time: ev.Time}
+		b.queued.Add(1)
+	}
+}
+
+func (b *eventBus) run() {
+	for ev := range b.queue {
+		if ev.Type == EventGap {
+			// Drops up to now are covered by this gap, including those
+			// after it was queued: they are older than anything behind it.
+			b.gapQueued.Store(false)
+			ev.Dropped = b.lost.Swap(0)
+		}
+		var hooks []func(Event)
+		if ev.Type != EventGap || ev.Dropped > 0 { // 0: an earlier gap took them
+			b.mu.RLock()
+			hooks = b.hooks[ev.Type]
+			b.mu.RUnlock()
+		}
+		for _, fn := range hooks {
+			fn(ev)
+		}
+		b.handledMu.Lock()
+		b.handled++
+		b.handledCnd.Broadcast()
+		b.handledMu.Unlock()
+	}
+}
+
+// FlushEvents blocks until the hooks have been called for every event of
+// the mutations made so far, e.g. before shutting down an exporter. It
+// must not be called from a hook.
+func (s *Store) FlushEvents() {
+	b := &s.events
+	if !b.active.Load() {
+		return
+	}
+	s.mu.Lock() // no emit is half way through
+	target := b.queued.Load()
+	s.mu.Unlock()
+	b.handledMu.Lock()
+	defer b.handledMu.Unlock()
+	for b.handled < target {
+		b.handledCnd.Wait()
+	}
+}
@@ -0,0 +1,57 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/redigotest"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// Every way of reading a key agrees on when it expires: it is still there
+// in the second its TTL ends in, and gone the second after.
+func TestExpiryBoundary(t *testing.T) {
+	clock := redigotest.NewClock(time.Unix(1_000_000, 0))
+	s := store.New()
+	s.SetClock(clock)
+	s.Setwithttl("k", "v", 2)
+
+	tests := []struct {
+		advance time.Duration
+		live    bool
+		ttl     int64
+	}{
+		{advance: time.Second, live: true, ttl: 1},
+		{advance: time.Second, live: true, ttl: 0},
+		{advance: time.Second, live: false, ttl: -2},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		now := clock.Now().Unix()
+		if _, ok := s.Get("k"); ok != tt.live {
+			t.Errorf("%d: Get = %v, want %v", now, ok, tt.live)
+		}
+		if ok := s.Exists("k"); ok != tt.live {
+			t.Errorf("%d: Exists = %v, want %v", now, ok, tt.live)
+		}
+		if ttl := s.TTL("k"); ttl != tt.ttl {
+			t.Errorf("%d: TTL = %d, want %d", now, ttl, tt.ttl)
+		}
+		s.Update(func(tx *store.Txn) error {
+			if _, ok := tx.Get("k"); ok != tt.live {
+				t.Errorf("%d: Txn.Get = %v, want %v", now, ok, tt.live)
+			}
+			return nil
+		})
+		snap := s.Snapshot()
+		seen := false
+		snap.ForEach(func(key string, _ store.Entry) error {
+			seen = seen || key == "k"
+			return nil
+		})
+		snap.Close()
+		if seen != tt.live {
+			t.Errorf("%d: in snapshot = %v, want %v", now, seen, tt.live)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package store
+
+// makeRoom is called before key is written with entry e. It rejects keys
+// and values over the size limits or their namespace's quota, enforces
+// maxKeys and maxMemory by evicting keys according to the eviction policy,
+// and returns ErrOOM if the write cannot fit.
+func (s *Store) makeRoom(key string, e *Entry) error {
+	if err := s.checkSize(key, e.Value); err != nil {
+		return err
+	}
+	// Limits apply to the value as written, memory to what is stored.
+	s.compress(e)
+	newSize := entrySize(key, e)
+	if err := s.checkQuota(key, newSize); err != nil {
+		return err
+	}
+	// Eviction decisions need up-to-date recency and frequency data.
+	s.applyAccesses()
+	old, exists := s.data[key]
+
+	// If key is new, enforce capacity
+	if s.maxKeys > 0 && !exists {
+		for len(s.data) >= s.maxKeys {
+			if !s.evictOne(key) {
+				return ErrOOM
+			}
+		}
+	}
+
+	if s.maxMemory > 0 {
+		var oldSize int64
+		if exists {
+			oldSize = entrySize(key, old)
+		}
+		for s.usedBytes-oldSize+newSize > s.maxMemory {
+			if !s.evictOne(key) {
+				return ErrOOM
+			}
+		}
+	}
+	return nil
+}
+
+// ensureMemory evicts keys until the dataset fits in maxMemory again, or
+// nothing more can be evicted. It is used when the limit is lowered.
+func (s *Store) ensureMemory() {
+	if s.maxMemory <= 0 {
+		return
+	}
+	s.applyAccesses()
+	for s.usedBytes > s.maxMemory {
+		if !s.evictOne("") {
+			return
+		}
+	}
+}
+
+// evictOne removes one key other than skip, chosen by the eviction policy.
+// It returns false if the policy found nothing to evict.
+func (s *Store) evictOne(skip string) bool {
+	var victim *Entry
+
+	switch s.policy {
+	case PolicyNoEviction:
+		return false
+
+	case PolicyAllKeysLRU, PolicyVolatileLRU:
+		// Walk from the least recently used end; for allkeys-lru this
+		// stops at the tail (or its neighbour when the tail is skip).
+		for e := s.lru.tail; e != nil; e = e.prev {
+			if e.key == skip {
+				continue
+			}
+			if s.policy == PolicyVolatileLRU && e.ExpiresAt == 0 {
+				continue
+			}
+			victim = e
+			break
+		}
+
+	case PolicyAllKeysRandom:
+		// Map iteration order is randomized, so the first candidate will do.
+		for k, e := range s.data {
+			if k != skip {
+				victim = e
+				break
+			}
+		}
+
+	case PolicyVolatileTTL:
+		for k, e := range s.data {
+			if k == skip || e.ExpiresAt == 0 {
+				continue
+			}
+			if victim == nil || e.ExpiresAt < victim.ExpiresAt {
+				victim = e
+			}
+		}
+
+	case PolicyAllKeysLFU:
+		// Lowest decayed frequency wins; ties go to the least recently used.
+		nowMin := lfuMinutes(s.now())
+		var victimFreq uint8
+		for k, e := range s.data {
+			if k == skip {
+				continue
+			}
+			freq := s.lfuDecayed(e, nowMin)
+			if victim == nil || freq < victimFreq ||
+				(freq == victimFreq && e.LastAccess < victim.LastAccess) {
+				victim, victimFreq = e, freq
+			}
+		}
+	}
+
+	if victim == nil {
+		return false
+	}
+	s.remove(victim.key)
+	s.evictions++
+	s.removals.note(victim.key)
+	s.events.emit(EventEvict, victim.key, victim)
+	return true
+}
+
+// modify runs fn on key's current value under the write lock and stores
+// the value it returns, keeping the key's TTL. exists is false for a
+// missing or expired key; fn returns false to leave the key untouched.
+func (s *Store) modify(key string, fn func(old string, exists bool) (string, bool, error)) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().Unix()
+	var old string
+	var exp int64
+	e, exists := s.data[key]
+	if exists && !e.expired(now) {
+		old, exp = e.raw(), e.ExpiresAt
+	} else {
+		exists = false
+	}
+	val, ok, err := fn(old, exists)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	e = &Entry{Value: val, ExpiresAt: exp, LastAccess: now, Freq: lfuInitVal, freqUpdatedAt: now / 60}
+	if err := s.makeRoom(key, e); err != nil {
+		return "", false, err
+	}
+	s.put(key, e)
+	s.writes++
+	return val, true, nil
+}
+
+// put stores e under key, as the most recently used entry, and keeps
+// usedBytes, the count of keys with a TTL and the LRU list in sync.
+func (s *Store) put(key string, e *Entry) {
+	s.preserve(key)
+	s.remove(key)
+	e.key = key
+	s.data[key] = e
+	s.defrag.grew(len(s.data))
+	if s.index != nil {
+		s.index.insert(key)
+	}
+	s.lru.pushFront(e)
+	s.usedBytes += entrySize(key, e)
+	if e.ExpiresAt != 0 {
+		s.expires++
+	}
+	s.trackQuota(key, e, 1)
+	s.trackCompression(e, 1)
+	s.events.emit(EventSet, key, e)
+}
+
+// remove deletes key and keeps usedBytes, the count of keys with a TTL and
+// the LRU list in sync.
+func (s *Store) remove(key string) {
+	if old, ok := s.data[key]; ok {
+		s.preserve(key)
+		s.usedBytes -= entrySize(key, old)
+		if old.ExpiresAt != 0 {
+			s.expires--
+		}
+		s.trackQuota(key, old, -1)
+		s.trackCompression(old, -1)
+		s.lru.unlink(old)
+		delete(s.data, key)
+		if s.index != nil {
+			s.index.delete(key)
+		}
+	}
+}
+
+// setExpiresAt changes the expiry time of the stored entry e, 0 for none,
+// keeping the count of keys with a TTL in sync. Callers hold the write
+// lock.
+func (s *Store) setExpiresAt(e *Entry, at int64) {
+	switch {
+	case e.ExpiresAt == 0 && at != 0:
+		s.expires++
+	case e.ExpiresAt != 0 && at == 0:
+		s.expires--
+	}
+	e.ExpiresAt = at
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// KeyStat is one key of a HotKeys or BigKeys report.
+type KeyStat struct {
+	Key   string
+	Freq  uint8 // LFU access frequency (logarithmic, 0-255), see lfu.go
+	Bytes int64 // estimated size, as reported by MemoryUsage
+}
+
+// HotKeys returns up to n keys with the highest access frequency, hottest
+// first. Frequencies are the LFU counters every entry keeps whatever the
+// eviction policy, decayed to the current time.
+func (s *Store) HotKeys(n int) []KeyStat {
+	return s.topKeys(n, func(a, b KeyStat) bool {
+		return a.Freq < b.Freq || (a.Freq == b.Freq && a.Bytes < b.Bytes)
+	})
+}
+
+// BigKeys returns up to n keys with the largest estimated size, largest
+// first.
+func (s *Store) BigKeys(n int) []KeyStat {
+	return s.topKeys(n, func(a, b KeyStat) bool {
+		return a.Bytes < b.Bytes || (a.Bytes == b.Bytes && a.Freq < b.Freq)
+	})
+}
+
+// topKeys walks a snapshot, so writers are only blocked between batches,
+// and keeps the n greatest keys by less in a min-heap.
+func (s *Store) topKeys(n int, less func(a, b KeyStat) bool) []KeyStat {
+	if n <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	s.applyAccesses()
+	decayTime := s.lfuDecayTime
+	s.mu.Unlock()
+
+	snap := s.Snapshot()
+	defer snap.Close()
+	nowMin := lfuMinutes(snap.Time())
+	h := &keyStatHeap{less: less}
+	snap.ForEach(func(k string, e Entry) error {
+		st := KeyStat{Key: k, Freq: lfuDecay(e.Freq, e.freqUpdatedAt, nowMin, decayTime), Bytes: entrySize(k, &e)}
+		if h.Len() < n {
+			heap.Push(h, st)
+		} else if less(h.items[0], st) {
+			h.items[0] = st
+			heap.Fix(h, 0)
+		}
+		return nil
+	})
+	sort.Slice(h.items, func(i, j int) bool { return less(h.items[j], h.items[i]) })
+	return h.items
+}
+
+type keyStatHeap struct {
+	items []KeyStat
+	less  func(a, b KeyStat) bool
+}
+
+func (h *keyStatHeap) Len() int           { return len(h.items) }
+func (h *keyStatHeap) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h *keyStatHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *keyStatHeap) Push(x any)         { h.items = append(h.items, x.(KeyStat)) }
+func (h *keyStatHeap) Pop() any {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInvalidCursor is returned by Iterate for a cursor it did not hand out,
+// that was already used, or that expired.
+var ErrInvalidCursor = errors.New("ERR invalid or expired cursor")
+
+// DefaultIterateCount is how many keys Iterate visits when count is not
+// positive.
+const DefaultIterateCount = 10
+
+const (
+	// maxCursors bounds the snapshots kept open for Iterate; beyond it the
+	// least recently used cursor is dropped.
+	maxCursors = 64
+	// cursorIdleTimeout drops cursors that callers abandoned, so writers
+	// stop paying the snapshot's copy-on-write cost.
+	cursorIdleTimeout = 5 * time.Minute
+)
+
+// KeyEntry is a key with a copy of its entry.
+type KeyEntry struct {
+	Key   string
+	Entry Entry
+}
+
+// cursorTable holds the snapshots of iterations in progress.
+type cursorTable struct {
+	mu   sync.Mutex
+	last uint64
+	open map[uint64]*iteration
+}
+
+type iteration struct {
+	snap *Snapshot
+	pos  int // index of the next snapshot key to visit
+	used time.Time
+}
+
+// Iterate walks a consistent view of the dataset a few keys per call.
+// Cursor 0 starts a new iteration at the current state of the store; pass
+// the returned cursor to continue, until it is 0 again. Each call visits
+// up to count keys and returns the live ones filter accepts (nil accepts
+// all), so a call may return fewer than count entries, or none, before
+// the walk is done.
+//
+// Every key that existed when the iteration started is returned exactly
+// once with its value at that time, whatever is written meanwhile; the
+// lock is only held while a batch is copied. A cursor can be used once,
+// and cursors left unused for a few minutes expire with ErrInvalidCursor.
+func (s *Store) Iterate(cursor uint64, count int, filter func(key string, e Entry) bool) (uint64, []KeyEntry, error) {
+	return s.IteratePrefix(cursor, "", count, filter)
+}
+
+// IteratePrefix is Iterate over the keys starting with prefix, which only
+// visits those keys when the key index is on (see SetKeyIndex). The prefix
+// is fixed when the iteration starts and ignored for later cursors.
+func (s *Store) IteratePrefix(cursor uint64, prefix string, count int, filter func(key string, e Entry) bool) (uint64, []KeyEntry, error) {
+	if count <= 0 {
+		count = DefaultIterateCount
+	}
+	var it *iteration
+	if cursor == 0 {
+		it = &iteration{snap: s.snapshotPrefix(prefix)}
+	} else if it = s.cursors.take(cursor); it == nil {
+		return 0, nil, ErrInvalidCursor
+	}
+
+	end := it.pos + count
+	batch := it.snap.read(nil, it.pos, end)
+	res := batch[:0]
+	for _, ke := range batch {
+		if filter == nil || filter(ke.Key, ke.Entry) {
+			res = append(res, ke)
+		}
+	}
+	if end >= it.snap.Len() {
+		it.snap.Close()
+		return 0, res, nil
+	}
+	it.pos = end
+	return s.cursors.put(it), res, nil
+}
+
+// take removes the iteration behind cursor from the table, so concurrent
+// callers cannot advance it twice.
+func (t *cursorTable) take(cursor uint64) *iteration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	it := t.open[cursor]
+	delete(t.open, cursor)
+	if it != nil && time.Since(it.used) > cursorIdleTimeout {
+		it.snap.Close()
+		return nil
+	}
+	return it
+}
+
+// put stores it under a fresh cursor, closing expired iterations and, when
+// the table is full, the least recently used one.
+func (t *cursorTable) put(it *iteration) uint64 {
+	now := time.Now()
+	it.used = now
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.open == nil {
+		t.open = make(map[uint64]*iteration)
+	}
+	var oldest uint64
+	for c, o := range t.open {
+		if now.Sub(o.used) > cursorIdleTimeout {
+			o.snap.Close()
+			delete(t.open, c)
+		} else if oldest == 0 || o.used.Before(t.open[oldest].used) {
+			oldest = c
+		}
+	}
+	if len(t.open) >= maxCursors {
+		t.open[oldest].snap.Close()
+		delete(t.open, oldest)
+	}
+	t.last++
+	t.open[t.last] = it
+	return t.last
+}
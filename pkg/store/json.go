@@ -0,0 +1,353 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// JSON documents are stored as ordinary string values in a canonical
+// encoding: compact, object keys sorted, and spaces inside strings written
+// as \u0020. The result never contains whitespace, so it round-trips
+// through the inline protocol, the AOF and snapshots like any other value.
+
+var (
+	// ErrNotJSON is returned when a key used as a JSON document holds a
+	// value that is not valid JSON.
+	ErrNotJSON = errors.New("ERR existing value is not a JSON document")
+	// ErrJSONPath is returned for a malformed path.
+	ErrJSONPath = errors.New("ERR invalid JSON path")
+	// ErrJSONValue is returned when the value passed to JSONSet is not
+	// valid JSON.
+	ErrJSONValue = errors.New("ERR value is not valid JSON")
+	// ErrJSONNewRoot is returned when JSONSet creates a key at a path
+	// other than the root.
+	ErrJSONNewRoot = errors.New("ERR new documents must be created at the root path")
+)
+
+// JSONSetMode restricts when JSONSet writes.
+type JSONSetMode int
+
+const (
+	JSONSetAlways JSONSetMode = iota
+	JSONSetNX                 // only if the path does not exist yet (NX)
+	JSONSetXX                 // only if the path already exists (XX)
+)
+
+// jsonStep is one element of a parsed path: an object member or an array
+// index (negative indexes count from the end).
+type jsonStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath parses a JSONPath-style path: "$" or "." for the root,
+// followed by ".member", "['member']" / "[\"member\"]" and "[index]"
+// steps, e.g. "$.users[0].name". The legacy form without a leading "$"
+// or "." ("users[0].name") is accepted too. Wildcards, slices and
+// recursive descent are not supported.
+func parseJSONPath(p string) ([]jsonStep, error) {
+	switch {
+	case strings.HasPrefix(p, "$"):
+		p = p[1:]
+	case p != "" && p[0] != '.' && p[0] != '[':
+		p = "." + p
+	}
+	if p == "." {
+		return nil, nil
+	}
+	var steps []jsonStep
+	for p != "" {
+		switch p[0] {
+		case '.':
+			end := strings.IndexAny(p[1:], ".[") + 1
+			if end == 0 {
+				end = len(p)
+			}
+			if end == 1 {
+				return nil, ErrJSONPath
+			}
+			steps = append(steps, jsonStep{key: p[1:end]})
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, ErrJSONPath
+			}
+			inner := p[1:end]
+			if n := len(inner); n >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[n-1] == inner[0] {
+				steps = append(steps, jsonStep{key: inner[1 : n-1]})
+			} else {
+				i, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, ErrJSONPath
+				}
+				steps = append(steps, jsonStep{index: i, isIndex: true})
+			}
+			p = p[end+1:]
+		default:
+			return nil, ErrJSONPath
+		}
+	}
+	return steps, nil
+}
+
+// decodeJSON parses s keeping numbers as json.Number, so integers beyond
+// float64 precision survive a read-modify-write.
+func decodeJSON(s string) (any, error) {
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, errors.New("trailing data")
+	}
+	return v, nil
+}
+
+// encodeJSON returns the canonical encoding of v (see the top of this file).
+func encodeJSON(v any) (string, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	// Outside strings the compact form has no spaces; inside them any
+	// other whitespace is already escaped by the encoder.
+	return strings.ReplaceAll(strings.TrimSuffix(b.String(), "\n"), " ", `\u0020`), nil
+}
+
+// jsonChild returns the member or element of node addressed by st.
+func jsonChild(node any, st jsonStep) (any, bool) {
+	switch n := node.(type) {
+	case map[string]any:
+		if st.isIndex {
+			return nil, false
+		}
+		v, ok := n[st.key]
+		return v, ok
+	case []any:
+		if i, ok := st.resolve(len(n)); ok {
+			return n[i], true
+		}
+	}
+	return nil, false
+}
+
+// resolve turns a possibly negative index into a position in an array of
+// length n.
+func (st jsonStep) resolve(n int) (int, bool) {
+	if !st.isIndex {
+		return 0, false
+	}
+	i := st.index
+	if i < 0 {
+		i += n
+	}
+	return i, i >= 0 && i < n
+}
+
+// jsonLookup returns the value at steps below node.
+func jsonLookup(node any, steps []jsonStep) (any, bool) {
+	for _, st := range steps {
+		var ok bool
+		if node, ok = jsonChild(node, st); !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// jsonSet sets the value at steps below node and returns the new node. The
+// parent of the target must exist; an object member is created if missing,
+// an array element must already exist.
+func jsonSet(node any, steps []jsonStep, v any, mode JSONSetMode) (any, bool) {
+	st := steps[0]
+	if len(steps) > 1 {
+		child, ok := jsonChild(node, st)
+		if !ok {
+			return node, false
+		}
+		if child, ok = jsonSet(child, steps[1:], v, mode); !ok {
+			return node, false
+		}
+		return jsonReplace(node, st, child), true
+	}
+	_, exists := jsonChild(node, st)
+	if (mode == JSONSetNX && exists) || (mode == JSONSetXX && !exists) {
+		return node, false
+	}
+	switch n := node.(type) {
+	case map[string]any:
+		if st.isIndex {
+			return node, false
+		}
+		n[st.key] = v
+		return n, true
+	case []any:
+		if !exists {
+			return node, false
+		}
+		return jsonReplace(n, st, v), true
+	}
+	return node, false
+}
+
+// jsonReplace stores v as the existing child st of node.
+func jsonReplace(node any, st jsonStep, v any) any {
+	switch n := node.(type) {
+	case map[string]any:
+		n[st.key] = v
+	case []any:
+		i, _ := st.resolve(len(n))
+		n[i] = v
+	}
+	return node
+}
+
+// jsonDelete removes the value at steps below node and returns the new node.
+func jsonDelete(node any, steps []jsonStep) (any, bool) {
+	st := steps[0]
+	child, ok := jsonChild(node, st)
+	if !ok {
+		return node, false
+	}
+	if len(steps) > 1 {
+		if child, ok = jsonDelete(child, steps[1:]); !ok {
+			return node, false
+		}
+		return jsonReplace(node, st, child), true
+	}
+	switch n := node.(type) {
+	case map[string]any:
+		delete(n, st.key)
+	case []any:
+		i, _ := st.resolve(len(n))
+		node = append(n[:i], n[i+1:]...)
+	}
+	return node, true
+}
+
+// jsonDoc returns the decoded document at key, or false if the key is
+// missing or expired. Callers hold the lock.
+func (s *Store) jsonDoc(key string, now int64) (*Entry, any, bool, error) {
+	e, ok := s.data[key]
+	if !ok || e.expired(now) {
+		return nil, nil, false, nil
+	}
+	doc, err := decodeJSON(e.raw())
+	if err != nil {
+		return nil, nil, false, ErrNotJSON
+	}
+	return e, doc, true, nil
+}
+
+// JSONGet returns the canonical encoding of the value at path in the JSON
+// document stored at key. It reports false if the key or the path does
+// not exist. Like Get, it counts as an access.
+func (s *Store) JSONGet(key, path string) (string, bool, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := s.Get(key)
+	if !ok {
+		return "", false, nil
+	}
+	doc, err := decodeJSON(value)
+	if err != nil {
+		return "", false, ErrNotJSON
+	}
+	v, ok := jsonLookup(doc, steps)
+	if !ok {
+		return "", false, nil
+	}
+	out, err := encodeJSON(v)
+	return out, err == nil, err
+}
+
+// JSONSet sets the value at path in the JSON document stored at key to the
+// JSON text value, creating the key if path is the root. An existing key
+// keeps its TTL. It returns the whole updated document, or false if
+// nothing was written because of mode or because the parent of path does
+// not exist.
+func (s *Store) JSONSet(key, path, value string, mode JSONSetMode) (string, bool, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return "", false, err
+	}
+	v, err := decodeJSON(value)
+	if err != nil {
+		return "", false, ErrJSONValue
+	}
+
+	return s.modify(key, func(old string, exists bool) (string, bool, error) {
+		var doc any
+		switch {
+		case !exists && len(steps) > 0:
+			return "", false, ErrJSONNewRoot
+		case !exists:
+			if mode == JSONSetXX {
+				return "", false, nil
+			}
+			doc = v
+		case len(steps) == 0:
+			if mode == JSONSetNX {
+				return "", false, nil
+			}
+			doc = v
+		default:
+			if doc, err = decodeJSON(old); err != nil {
+				return "", false, ErrNotJSON
+			}
+			var ok bool
+			if doc, ok = jsonSet(doc, steps, v, mode); !ok {
+				return "", false, nil
+			}
+		}
+		out, err := encodeJSON(doc)
+		return out, err == nil, err
+	})
+}
+
+// JSONDel deletes the value at path in the JSON document stored at key;
+// deleting the root removes the key. It returns the updated document
+// (empty when the key was removed) and whether anything was deleted.
+func (s *Store) JSONDel(key, path string) (string, bool, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return "", false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().Unix()
+	old, doc, exists, err := s.jsonDoc(key, now)
+	if err != nil || !exists {
+		return "", false, err
+	}
+	if len(steps) == 0 {
+		s.remove(key)
+		s.writes++
+		s.events.emit(EventDelete, key, old)
+		return "", true, nil
+	}
+	if doc, exists = jsonDelete(doc, steps); !exists {
+		return "", false, nil
+	}
+
+	out, err := encodeJSON(doc)
+	if err != nil {
+		return "", false, err
+	}
+	// the document only shrinks, so there is no need to make room
+	s.put(key, &Entry{Value: out, ExpiresAt: old.ExpiresAt, LastAccess: now, Freq: old.Freq, freqUpdatedAt: old.freqUpdatedAt})
+	s.writes++
+	return out, true, nil
+}
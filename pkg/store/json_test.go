@@ -0,0 +1,117 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []jsonStep
+		err  bool
+	}{
+		{path: "$"},
+		{path: "."},
+		{path: ""},
+		{path: "$.a", want: []jsonStep{{key: "a"}}},
+		{path: ".a.b", want: []jsonStep{{key: "a"}, {key: "b"}}},
+		{path: "a.b", want: []jsonStep{{key: "a"}, {key: "b"}}},
+		{path: "$.users[0].name", want: []jsonStep{{key: "users"}, {index: 0, isIndex: true}, {key: "name"}}},
+		{path: "$[-1]", want: []jsonStep{{index: -1, isIndex: true}}},
+		{path: "$['a.b']", want: []jsonStep{{key: "a.b"}}},
+		{path: `$["x"][2]`, want: []jsonStep{{key: "x"}, {index: 2, isIndex: true}}},
+		{path: "$..a", err: true},
+		{path: "$.a.", err: true},
+		{path: "$[0", err: true},
+		{path: "$[x]", err: true},
+		{path: "$['a\"]", err: true},
+		{path: "$*", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseJSONPath(tt.path)
+		if tt.err {
+			if err != ErrJSONPath {
+				t.Errorf("parseJSONPath(%q) = %v, %v; want ErrJSONPath", tt.path, got, err)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("parseJSONPath(%q) = %v, %v; want %v", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestJSONPaths(t *testing.T) {
+	s := New()
+	if _, _, err := s.JSONSet("doc", "$", `{"users":[{"name":"ann"},{"name":"bob"}],"n":1}`, JSONSetAlways); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		op    string // GET, SET, SETNX, SETXX or DEL
+		path  string
+		value string
+		want  string // GET: the value; otherwise the document afterwards
+		ok    bool
+		err   error
+	}{
+		{op: "GET", path: "$.users[1].name", want: `"bob"`, ok: true},
+		{op: "GET", path: "$.users[-2].name", want: `"ann"`, ok: true},
+		{op: "GET", path: "$.users[2]"},
+		{op: "GET", path: "$.users.name"},
+		{op: "GET", path: "$.n[0]"},
+		{op: "GET", path: "$[", err: ErrJSONPath},
+		{op: "SET", path: "$.users[0].age", value: "30",
+			want: `{"n":1,"users":[{"age":30,"name":"ann"},{"name":"bob"}]}`, ok: true},
+		{op: "SET", path: "$.users[5]", value: "1"},
+		{op: "SET", path: "$.missing.x", value: "1"},
+		{op: "SET", path: "$.n", value: "{", err: ErrJSONValue},
+		{op: "SETNX", path: "$.n", value: "2"},
+		{op: "SETXX", path: "$.m", value: "2"},
+		// spaces are escaped in the stored encoding
+		{op: "SETXX", path: "$.n", value: `"a b"`,
+			want: `{"n":"a\u0020b","users":[{"age":30,"name":"ann"},{"name":"bob"}]}`, ok: true},
+		{op: "GET", path: "$.n", want: `"a\u0020b"`, ok: true},
+		{op: "DEL", path: "$.users[0]", want: `{"n":"a\u0020b","users":[{"name":"bob"}]}`, ok: true},
+		{op: "DEL", path: "$.users[3]"},
+		{op: "DEL", path: "$.users[-1].name", want: `{"n":"a\u0020b","users":[{}]}`, ok: true},
+		{op: "DEL", path: "$", ok: true},
+		{op: "GET", path: "$"},
+		{op: "SET", path: "$.a", value: "1", err: ErrJSONNewRoot},
+	}
+	for _, tt := range tests {
+		var got string
+		var ok bool
+		var err error
+		switch tt.op {
+		case "GET":
+			got, ok, err = s.JSONGet("doc", tt.path)
+		case "SET":
+			got, ok, err = s.JSONSet("doc", tt.path, tt.value, JSONSetAlways)
+		case "SETNX":
+			got, ok, err = s.JSONSet("doc", tt.path, tt.value, JSONSetNX)
+		case "SETXX":
+			got, ok, err = s.JSONSet("doc", tt.path, tt.value, JSONSetXX)
+		case "DEL":
+			got, ok, err = s.JSONDel("doc", tt.path)
+		}
+		if got != tt.want || ok != tt.ok || err != tt.err {
+			t.Errorf("%s %s %s = %s, %v, %v; want %s, %v, %v", tt.op, tt.path, tt.value, got, ok, err, tt.want, tt.ok, tt.err)
+		}
+	}
+}
+
+func TestJSONNotADocument(t *testing.T) {
+	s := New()
+	s.Set("k", "plain")
+	if _, _, err := s.JSONGet("k", "$"); err != ErrNotJSON {
+		t.Errorf("JSONGet: err = %v, want ErrNotJSON", err)
+	}
+	if _, _, err := s.JSONSet("k", "$.a", "1", JSONSetAlways); err != ErrNotJSON {
+		t.Errorf("JSONSet: err = %v, want ErrNotJSON", err)
+	}
+	if _, _, err := s.JSONDel("k", "$.a"); err != ErrNotJSON {
+		t.Errorf("JSONDel: err = %v, want ErrNotJSON", err)
+	}
+}
@@ -0,0 +1,54 @@
+package store
+
+import "strings"
+
+// SetKeyIndex turns the sorted key index on or off. With the index on,
+// KeysWithPrefix and IteratePrefix visit only the keys under the prefix,
+// in lexicographic order, at the cost of some memory and a little work on
+// every key creation and removal. Turning it on indexes the current keys
+// under the write lock.
+func (s *Store) SetKeyIndex(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case !on:
+		s.index = nil
+	case s.index == nil:
+		s.index = &radixTree{}
+		for k := range s.data {
+			s.index.insert(k)
+		}
+	}
+}
+
+// KeysWithPrefix returns the live keys starting with prefix; the empty
+// prefix returns every key. The keys are sorted when the key index is on.
+func (s *Store) KeysWithPrefix(prefix string) []string {
+	now := s.now().Unix()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var res []string
+	s.walkPrefix(prefix, func(k string, e *Entry) {
+		if !e.expired(now) {
+			res = append(res, k)
+		}
+	})
+	return res
+}
+
+// walkPrefix calls fn for every key starting with prefix, using the index
+// when there is one. It must be called with the lock held.
+func (s *Store) walkPrefix(prefix string, fn func(k string, e *Entry)) {
+	if s.index != nil {
+		s.index.walkPrefix(prefix, func(k string) bool {
+			fn(k, s.data[k])
+			return true
+		})
+		return
+	}
+	for k, e := range s.data {
+		if strings.HasPrefix(k, prefix) {
+			fn(k, e)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package store
+
+import "time"
+
+// TTLHistogramBounds are the upper bounds of the TTL histogram buckets in
+// a KeyspaceSample; a last bucket holds longer TTLs.
+var TTLHistogramBounds = []time.Duration{time.Minute, 10 * time.Minute, time.Hour, 24 * time.Hour, 7 * 24 * time.Hour}
+
+// KeyspaceSample describes a sample of the keyspace.
+type KeyspaceSample struct {
+	Sampled    int
+	Volatile   int // sampled keys with a TTL
+	Persistent int // sampled keys without one
+	// TTLHistogram counts volatile keys by remaining TTL: entry i holds
+	// TTLs up to TTLHistogramBounds[i], the last entry longer ones.
+	TTLHistogram []int
+	Types        map[string]TypeStats // by ValueType
+}
+
+// AvgBytes is the average estimated size of a key of this type.
+func (t TypeStats) AvgBytes() int64 {
+	if t.Keys == 0 {
+		return 0
+	}
+	return t.Bytes / int64(t.Keys)
+}
+
+// SampleKeyspace inspects up to n live keys instead of walking the whole
+// dataset, so it is cheap enough for every INFO. They are the first n keys
+// of a map iteration: Go starts each iteration at a random position, so
+// successive calls look at different keys, but the keys of one call come
+// from neighbouring hash buckets and are not a uniform random sample.
+func (s *Store) SampleKeyspace(n int) KeyspaceSample {
+	ks := KeyspaceSample{
+		TTLHistogram: make([]int, len(TTLHistogramBounds)+1),
+		Types:        make(map[string]TypeStats),
+	}
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for k, e := range s.data {
+		if ks.Sampled >= n {
+			break
+		}
+		if e.expired(now.Unix()) {
+			continue
+		}
+		ks.Sampled++
+		t := ks.Types[e.valueType()]
+		t.Keys++
+		t.Bytes += entrySize(k, e)
+		ks.Types[e.valueType()] = t
+
+		if e.ExpiresAt == 0 {
+			ks.Persistent++
+			continue
+		}
+		ks.Volatile++
+		ttl := time.Unix(e.ExpiresAt, 0).Sub(now)
+		i := 0
+		for i < len(TTLHistogramBounds) && ttl > TTLHistogramBounds[i] {
+			i++
+		}
+		ks.TTLHistogram[i]++
+	}
+	return ks
+}
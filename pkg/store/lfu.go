@@ -0,0 +1,80 @@
+package store
+
+import (
+	"math/rand"
+	"time"
+)
+
+// LFU frequency tracking follows Redis: each entry keeps an 8-bit
+// logarithmic counter that is incremented probabilistically on access (the
+// higher it is, the less likely an increment) and decays by one for every
+// decay period the key goes unaccessed.
+const (
+	lfuInitVal          = 5  // counter given to new keys so they are not evicted at once
+	defaultLFULogFactor = 10 // higher means slower counter growth
+	defaultLFUDecayTime = 1  // minutes per decrement; 0 disables decay
+)
+
+// lfuLogIncr returns counter incremented with probability 1/((c-init)*factor+1).
+func lfuLogIncr(counter uint8, logFactor int) uint8 {
+	if counter == 255 {
+		return 255
+	}
+	base := float64(counter) - lfuInitVal
+	if base < 0 {
+		base = 0
+	}
+	p := 1.0 / (base*float64(logFactor) + 1)
+	if rand.Float64() < p {
+		counter++
+	}
+	return counter
+}
+
+// lfuMinutes is the clock used for decay periods.
+func lfuMinutes(now time.Time) int64 {
+	return now.Unix() / 60
+}
+
+// lfuDecayed returns e's counter after applying decay for the periods elapsed
+// since its last access, without modifying e.
+func (s *Store) lfuDecayed(e *Entry, nowMin int64) uint8 {
+	return lfuDecay(e.Freq, e.freqUpdatedAt, nowMin, s.lfuDecayTime)
+}
+
+// lfuDecay returns freq, last updated at minute updatedAt, after decaying
+// it by one per decayTime minutes until nowMin.
+func lfuDecay(freq uint8, updatedAt, nowMin int64, decayTime int) uint8 {
+	if decayTime <= 0 {
+		return freq
+	}
+	periods := (nowMin - updatedAt) / int64(decayTime)
+	if periods <= 0 {
+		return freq
+	}
+	if periods >= int64(freq) {
+		return 0
+	}
+	return freq - uint8(periods)
+}
+
+// lfuTouch decays and then increments e's counter for an access.
+func (s *Store) lfuTouch(e *Entry, now time.Time) {
+	nowMin := lfuMinutes(now)
+	e.Freq = lfuLogIncr(s.lfuDecayed(e, nowMin), s.lfuLogFactor)
+	e.freqUpdatedAt = nowMin
+}
+
+// SetLFULogFactor sets how quickly LFU counters saturate (higher is slower).
+func (s *Store) SetLFULogFactor(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lfuLogFactor = n
+}
+
+// SetLFUDecayTime sets the minutes per LFU counter decrement; 0 disables decay.
+func (s *Store) SetLFUDecayTime(minutes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lfuDecayTime = minutes
+}
@@ -0,0 +1,38 @@
+package store
+
+import "errors"
+
+// Errors returned by writes that exceed the key and value size limits.
+var (
+	ErrKeyTooLong    = errors.New("ERR key is longer than 'max-key-length'")
+	ErrValueTooLarge = errors.New("ERR value is larger than 'max-value-size'")
+)
+
+// SetMaxKeyLength limits the length of keys in bytes. 0 means no limit.
+// Existing keys are kept, but writes to longer keys fail with ErrKeyTooLong.
+func (s *Store) SetMaxKeyLength(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxKeyLen = n
+}
+
+// SetMaxValueSize limits the size of values in bytes. 0 means no limit.
+// Existing values are kept, but writes of larger values fail with
+// ErrValueTooLarge.
+func (s *Store) SetMaxValueSize(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxValueSize = n
+}
+
+// checkSize rejects a write of value under key that exceeds the limits.
+// It must be called with the write lock held.
+func (s *Store) checkSize(key, value string) error {
+	if s.maxKeyLen > 0 && len(key) > s.maxKeyLen {
+		return ErrKeyTooLong
+	}
+	if s.maxValueSize > 0 && int64(len(value)) > s.maxValueSize {
+		return ErrValueTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Loader fetches a key missing from the store from a backing source, such
+// as a database. found is false if the source doesn't have the key either.
+type Loader func(ctx context.Context, key string) (value string, found bool, err error)
+
+// loaderState is the read-through configuration and the loads in flight.
+// Concurrent misses on one key share a single call to the loader, so a hot
+// missing key doesn't stampede the backing source.
+type loaderState struct {
+	mu    sync.Mutex
+	fn    Loader
+	ttl   time.Duration
+	calls map[string]*loadCall
+}
+
+// loadCall is one loader call, waited on by every reader missing its key.
+type loadCall struct {
+	done  chan struct{}
+	value string
+	found bool
+	err   error
+}
+
+// SetLoader makes GetOrLoad fall back to fn on a miss. Loaded values are
+// cached for ttl (0 means no expiry). A nil fn turns read-through off.
+func (s *Store) SetLoader(fn Loader, ttl time.Duration) {
+	s.loader.mu.Lock()
+	defer s.loader.mu.Unlock()
+	s.loader.fn = fn
+	s.loader.ttl = ttl
+}
+
+// HasLoader reports whether a loader is set.
+func (s *Store) HasLoader() bool {
+	s.loader.mu.Lock()
+	defer s.loader.mu.Unlock()
+	return s.loader.fn != nil
+}
+
+// GetOrLoad is Get, but on a miss it asks the loader set by SetLoader,
+// caches what it returns and returns it. Without a loader it is Get. If the
+// loaded value can't be cached (it's over a size limit, say) it is still
+// returned. A key written while the load was running keeps the written
+// value, which is returned instead.
+func (s *Store) GetOrLoad(ctx context.Context, key string) (string, bool, error) {
+	if v, ok := s.Get(key); ok {
+		return v, true, nil
+	}
+
+	s.loader.mu.Lock()
+	fn, ttl := s.loader.fn, s.loader.ttl
+	if fn == nil {
+		s.loader.mu.Unlock()
+		return "", false, nil
+	}
+	c, ok := s.loader.calls[key]
+	if !ok {
+		c = &loadCall{done: make(chan struct{})}
+		if s.loader.calls == nil {
+			s.loader.calls = make(map[string]*loadCall)
+		}
+		s.loader.calls[key] = c
+		go s.load(c, fn, ttl, key)
+	}
+	s.loader.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.value, c.found, c.err
+	case <-ctx.Done():
+		return "", false, ctx.Err()
+	}
+}
+
+// load runs fn for key on behalf of every waiter on c. It gets its own
+// context: a reader that gives up must not cancel the load for the others.
+func (s *Store) load(c *loadCall, fn Loader, ttl time.Duration, key string) {
+	defer func() {
+		s.loader.mu.Lock()
+		delete(s.loader.calls, key)
+		s.loader.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.value, c.found, c.err = fn(context.Background(), key)
+	if c.err != nil || !c.found {
+		return
+	}
+	c.value = s.fill(key, c.value, ttl)
+}
+
+// fill caches a loaded value unless key was written in the meantime, and
+// returns the value the key now has.
+func (s *Store) fill(key, value string, ttl time.Duration) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if e, ok := s.data[key]; ok && !e.expired(now.Unix()) {
+		return e.raw()
+	}
+	var exp int64
+	if ttl > 0 {
+		// Round up so a sub-second TTL doesn't mean no expiry.
+		exp = now.Add(ttl + time.Second - 1).Unix()
+	}
+	e := &Entry{Value: value, ExpiresAt: exp, LastAccess: now.Unix(), Freq: lfuInitVal, freqUpdatedAt: now.Unix() / 60}
+	if err := s.makeRoom(key, e); err != nil {
+		return value
+	}
+	s.put(key, e)
+	s.writes++
+	return value
+}
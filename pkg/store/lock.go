@@ -0,0 +1,68 @@
+package store
+
+// Locks are ordinary keys whose value is the owner's token, so GET and TTL
+// show who holds a lock and for how long. What Lock, Unlock and ExtendLock
+// add over SET NX and DEL is the token check, done atomically under the
+// store lock.
+
+// Lock acquires the lock at key for token, expiring after ttlSeconds. It
+// reports whether token now holds the lock: acquiring a lock token
+// already holds succeeds and resets its TTL, so a retried LOCK is safe.
+func (s *Store) Lock(key, token string, ttlSeconds int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().Unix()
+	if e, ok := s.data[key]; ok && !e.expired(now) && e.raw() != token {
+		return false, nil
+	}
+	e := &Entry{Value: token, ExpiresAt: now + ttlSeconds, LastAccess: now, Freq: lfuInitVal, freqUpdatedAt: now / 60}
+	if err := s.makeRoom(key, e); err != nil {
+		return false, err
+	}
+	s.put(key, e)
+	s.writes++
+	return true, nil
+}
+
+// lockHeld returns the entry of the lock at key if token holds it.
+// Callers hold the write lock.
+func (s *Store) lockHeld(key, token string) (*Entry, bool) {
+	e, ok := s.data[key]
+	if !ok || e.raw() != token || e.expired(s.now().Unix()) {
+		return nil, false
+	}
+	return e, true
+}
+
+// Unlock releases the lock at key if token holds it, and reports whether
+// it did. A lock that expired or was taken over is left alone.
+func (s *Store) Unlock(key, token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lockHeld(key, token)
+	if !ok {
+		return false
+	}
+	s.remove(key)
+	s.writes++
+	s.events.emit(EventDelete, key, e)
+	return true
+}
+
+// ExtendLock resets the TTL of the lock at key to ttlSeconds if token
+// holds it, and reports whether it did.
+func (s *Store) ExtendLock(key, token string, ttlSeconds int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.lockHeld(key, token)
+	if !ok {
+		return false
+	}
+	s.preserve(key)
+	s.setExpiresAt(e, s.now().Unix()+ttlSeconds)
+	s.writes++
+	return true
+}
@@ -0,0 +1,46 @@
+package store
+
+// lruList is an intrusive doubly-linked list of entries ordered by recency:
+// the most recently used entry is at the front, the eviction candidate at
+// the back. All operations are O(1).
+type lruList struct {
+	head *Entry
+	tail *Entry
+}
+
+// pushFront links e in as the most recently used entry.
+func (l *lruList) pushFront(e *Entry) {
+	e.prev = nil
+	e.next = l.head
+	if l.head != nil {
+		l.head.prev = e
+	}
+	l.head = e
+	if l.tail == nil {
+		l.tail = e
+	}
+}
+
+// unlink removes e from the list.
+func (l *lruList) unlink(e *Entry) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		l.head = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		l.tail = e.prev
+	}
+	e.prev, e.next = nil, nil
+}
+
+// moveToFront marks e as the most recently used entry.
+func (l *lruList) moveToFront(e *Entry) {
+	if l.head == e {
+		return
+	}
+	l.unlink(e)
+	l.pushFront(e)
+}
@@ -0,0 +1,83 @@
+package store
+
+// entryOverhead approximates the fixed cost of one key in the store: the map
+// bucket slot, the key string header and the Entry struct with its LRU links.
+const entryOverhead = 96
+
+// MemoryStats is an approximate breakdown of the memory held by the dataset.
+type MemoryStats struct {
+	Keys          int                  `json:"keys"`
+	DatasetBytes  int64                `json:"dataset_bytes"`  // key and value payloads
+	OverheadBytes int64                `json:"overhead_bytes"` // per-entry bookkeeping
+	Types         map[string]TypeStats `json:"types"`
+}
+
+// TypeStats is the memory breakdown for one value type.
+type TypeStats struct {
+	Keys  int   `json:"keys"`
+	Bytes int64 `json:"bytes"`
+}
+
+// TotalBytes is the estimated total size of the dataset.
+func (m MemoryStats) TotalBytes() int64 {
+	return m.DatasetBytes + m.OverheadBytes
+}
+
+// entrySize estimates the memory used by key and its entry, counting
+// compressed values at their stored size.
+func entrySize(key string, e *Entry) int64 {
+	n := len(e.Value)
+	if e.storedLen != 0 {
+		n = e.storedLen
+	}
+	return int64(len(key)) + int64(n) + entryOverhead
+}
+
+// KeyMemory is the approximate memory used by one key.
+type KeyMemory struct {
+	Bytes      int64 // as stored
+	RawBytes   int64 // with the value uncompressed
+	Compressed bool
+}
+
+// MemoryUsage returns the approximate number of bytes used by key.
+func (s *Store) MemoryUsage(key string) (int64, bool) {
+	m, ok := s.KeyMemory(key)
+	return m.Bytes, ok
+}
+
+// KeyMemory returns the memory used by key, before and after compression.
+func (s *Store) KeyMemory(key string) (KeyMemory, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.data[key]
+	if !ok || e.expired(s.now().Unix()) {
+		return KeyMemory{}, false
+	}
+	m := KeyMemory{Bytes: entrySize(key, e), Compressed: e.rawLen != 0}
+	m.RawBytes = m.Bytes
+	if m.Compressed {
+		m.RawBytes += int64(e.rawLen - len(e.Value))
+	}
+	return m, true
+}
+
+// MemoryStats walks a snapshot of the dataset and returns its approximate
+// memory breakdown, with types named by ValueType.
+func (s *Store) MemoryStats() MemoryStats {
+	snap := s.Snapshot()
+	defer snap.Close()
+	st := MemoryStats{Types: map[string]TypeStats{"string": {}}}
+	snap.ForEach(func(k string, e Entry) error {
+		size := entrySize(k, &e)
+		st.Keys++
+		st.DatasetBytes += size - entryOverhead
+		st.OverheadBytes += entryOverhead
+		t := st.Types[ValueType(e.Value)]
+		t.Keys++
+		t.Bytes += size
+		st.Types[ValueType(e.Value)] = t
+		return nil
+	})
+	return st
+}
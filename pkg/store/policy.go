@@ -0,0 +1,44 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOOM is returned by writes that cannot fit within maxkeys/maxmemory,
+// either because the policy is noeviction or nothing is evictable.
+var ErrOOM = errors.New("OOM command not allowed when used memory > 'maxmemory'")
+
+// EvictionPolicy selects which keys are evicted when a limit is reached.
+type EvictionPolicy string
+
+const (
+	PolicyNoEviction    EvictionPolicy = "noeviction"
+	PolicyAllKeysLRU    EvictionPolicy = "allkeys-lru"
+	PolicyVolatileLRU   EvictionPolicy = "volatile-lru"
+	PolicyAllKeysRandom EvictionPolicy = "allkeys-random"
+	PolicyVolatileTTL   EvictionPolicy = "volatile-ttl"
+	PolicyAllKeysLFU    EvictionPolicy = "allkeys-lfu"
+)
+
+// ParseEvictionPolicy validates a maxmemory-policy name.
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	switch p := EvictionPolicy(name); p {
+	case PolicyNoEviction, PolicyAllKeysLRU, PolicyVolatileLRU,
+		PolicyAllKeysRandom, PolicyVolatileTTL, PolicyAllKeysLFU:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown eviction policy %q", name)
+}
+
+// volatileOnly reports whether the policy only evicts keys with a TTL.
+func (p EvictionPolicy) volatileOnly() bool {
+	return p == PolicyVolatileLRU || p == PolicyVolatileTTL
+}
+
+// SetEvictionPolicy changes the policy used when maxkeys/maxmemory is reached.
+func (s *Store) SetEvictionPolicy(p EvictionPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = p
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// NamespaceSeparator ends the namespace part of a key: the key
+// "billing:invoice:42" is in namespace "billing". Keys without it are in
+// no namespace and never count against a quota.
+const NamespaceSeparator = ":"
+
+// ErrQuotaExceeded is returned by writes that would take a namespace over
+// its quota. Unlike ErrOOM it never causes other keys to be evicted.
+var ErrQuotaExceeded = errors.New("QUOTA namespace is over its key or memory quota")
+
+// Quota limits the keys and approximate bytes of one namespace; a zero
+// field means no limit.
+type Quota struct {
+	MaxKeys   int
+	MaxMemory int64
+}
+
+// QuotaUsage is a namespace's quota and current usage, as reported by
+// Quotas.
+type QuotaUsage struct {
+	Namespace string
+	Quota
+	Keys  int
+	Bytes int64
+}
+
+// nsUsage is the usage tracked for a namespace with a quota.
+type nsUsage struct {
+	quota Quota
+	keys  int
+	bytes int64
+}
+
+// Namespace returns the namespace of key, or "" if it has none.
+func Namespace(key string) string {
+	ns, _, ok := strings.Cut(key, NamespaceSeparator)
+	if !ok {
+		return ""
+	}
+	return ns
+}
+
+// SetQuota limits the keys and bytes of namespace ns; a zero Quota
+// removes the limit. The namespace's usage is counted from the current keys
+// under the write lock. Keys already over a lowered quota are kept, but new
+// writes to the namespace fail with ErrQuotaExceeded until it shrinks.
+func (s *Store) SetQuota(ns string, q Quota) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q == (Quota{}) {
+		delete(s.quotas, ns)
+		return
+	}
+	if u, ok := s.quotas[ns]; ok {
+		u.quota = q
+		return
+	}
+	u := &nsUsage{quota: q}
+	for k, e := range s.data {
+		if Namespace(k) == ns {
+			u.keys++
+			u.bytes += entrySize(k, e)
+		}
+	}
+	if s.quotas == nil {
+		s.quotas = make(map[string]*nsUsage)
+	}
+	s.quotas[ns] = u
+}
+
+// Quotas returns every namespace with a quota and its usage, sorted by
+// namespace.
+func (s *Store) Quotas() []QuotaUsage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]QuotaUsage, 0, len(s.quotas))
+	for ns, u := range s.quotas {
+		res = append(res, QuotaUsage{Namespace: ns, Quota: u.quota, Keys: u.keys, Bytes: u.bytes})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Namespace < res[j].Namespace })
+	return res
+}
+
+// checkQuota rejects writing an entry of newSize bytes under key if that
+// would take its namespace over quota. It must be called with the write
+// lock held, before any eviction, so a tenant at its quota cannot push out
+// other tenants' keys.
+func (s *Store) checkQuota(key string, newSize int64) error {
+	u := s.quotas[Namespace(key)]
+	if u == nil {
+		return nil
+	}
+	old, exists := s.data[key]
+	if u.quota.MaxKeys > 0 && !exists && u.keys >= u.quota.MaxKeys {
+		return ErrQuotaExceeded
+	}
+	if u.quota.MaxMemory > 0 {
+		var oldSize int64
+		if exists {
+			oldSize = entrySize(key, old)
+		}
+		if newSize > oldSize && u.bytes-oldSize+newSize > u.quota.MaxMemory {
+			return ErrQuotaExceeded
+		}
+	}
+	return nil
+}
+
+// trackQuota adds (delta 1) or removes (delta -1) e from the usage of its
+// namespace, if that has a quota.
+func (s *Store) trackQuota(key string, e *Entry, delta int) {
+	if u := s.quotas[Namespace(key)]; u != nil {
+		u.keys += delta
+		u.bytes += int64(delta) * entrySize(key, e)
+	}
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"slices"
+	"strings"
+)
+
+// radixTree is a compressed trie of keys. When the key index is on it is
+// kept alongside Store.data, so prefix lookups visit only the matching keys,
+// in lexicographic order, instead of the whole keyspace.
+type radixTree struct {
+	root radixNode
+	size int
+}
+
+type radixNode struct {
+	prefix   string       // edge label from the parent
+	leaf     bool         // a key ends at this node
+	children []*radixNode // sorted by first byte of prefix
+}
+
+// child returns the child whose prefix starts with b, or nil and the index
+// where it would be inserted.
+func (n *radixNode) child(b byte) (int, *radixNode) {
+	i, ok := slices.BinarySearchFunc(n.children, b, func(c *radixNode, b byte) int {
+		return int(c.prefix[0]) - int(b)
+	})
+	if !ok {
+		return i, nil
+	}
+	return i, n.children[i]
+}
+
+// mergeChild folds n's only child into n.
+func (n *radixNode) mergeChild() {
+	c := n.children[0]
+	n.prefix += c.prefix
+	n.leaf = c.leaf
+	n.children = c.children
+}
+
+func (t *radixTree) insert(key string) {
+	n := &t.root
+	for key != "" {
+		i, c := n.child(key[0])
+		if c == nil {
+			n.children = slices.Insert(n.children, i, &radixNode{prefix: key, leaf: true})
+			t.size++
+			return
+		}
+		l := 0
+		for l < len(key) && l < len(c.prefix) && key[l] == c.prefix[l] {
+			l++
+		}
+		if l < len(c.prefix) {
+			// key diverges inside the edge: split it
+			split := &radixNode{prefix: c.prefix[:l], children: []*radixNode{c}}
+			c.prefix = c.prefix[l:]
+			n.children[i] = split
+			c = split
+		}
+		key = key[l:]
+		n = c
+	}
+	if !n.leaf {
+		n.leaf = true
+		t.size++
+	}
+}
+
+func (t *radixTree) delete(key string) {
+	var parent *radixNode
+	n, idx := &t.root, 0
+	for key != "" {
+		i, c := n.child(key[0])
+		if c == nil || !strings.HasPrefix(key, c.prefix) {
+			return
+		}
+		parent, n, idx = n, c, i
+		key = key[len(c.prefix):]
+	}
+	if !n.leaf {
+		return
+	}
+	n.leaf = false
+	t.size--
+	switch {
+	case parent == nil:
+		// the empty key, stored at the root
+	case len(n.children) == 0:
+		parent.children = slices.Delete(parent.children, idx, idx+1)
+		if parent != &t.root && !parent.leaf && len(parent.children) == 1 {
+			parent.mergeChild()
+		}
+	case len(n.children) == 1:
+		n.mergeChild()
+	}
+}
+
+// walkPrefix calls fn, in order, for every key starting with prefix until
+// fn returns false.
+func (t *radixTree) walkPrefix(prefix string, fn func(key string) bool) {
+	n, base := &t.root, ""
+	for prefix != "" {
+		_, c := n.child(prefix[0])
+		switch {
+		case c == nil:
+			return
+		case strings.HasPrefix(prefix, c.prefix):
+			prefix = prefix[len(c.prefix):]
+		case strings.HasPrefix(c.prefix, prefix):
+			prefix = ""
+		default:
+			return
+		}
+		base += c.prefix
+		n = c
+	}
+	n.walk(base, fn)
+}
+
+func (n *radixNode) walk(key string, fn func(key string) bool) bool {
+	if n.leaf && !fn(key) {
+		return false
+	}
+	for _, c := range n.children {
+		if !c.walk(key+c.prefix, fn) {
+			return false
+		}
+	}
+	return true
+}
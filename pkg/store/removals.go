@@ -0,0 +1,53 @@
+package store
+
+import "sync/atomic"
+
+// Keys the store drops by itself, evicted to respect maxkeys or maxmemory
+// or cleaned up once expired, go under the lock of some unrelated write or
+// of the background cleanup. A server logging writes by their effect has
+// to log those removals too, ahead of later writes to the same keys, so
+// with tracking on the store queues them until TakeRemoved.
+type removalLog struct {
+	on      bool
+	pending atomic.Bool // keys are queued; read without the lock
+	keys    []string
+}
+
+// TrackRemovals turns queueing of evicted and expired keys on or off.
+// Turning it off drops the queued keys.
+func (s *Store) TrackRemovals(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removals.on = on
+	if !on {
+		s.removals.keys = nil
+		s.removals.pending.Store(false)
+	}
+}
+
+// HasRemoved reports whether TakeRemoved has keys to return. It doesn't
+// lock, so writers can check it on every command.
+func (s *Store) HasRemoved() bool { return s.removals.pending.Load() }
+
+// TakeRemoved appends the keys evicted or expired since the last call to
+// dst, oldest first, and forgets them.
+func (s *Store) TakeRemoved(dst []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := &s.removals
+	dst = append(dst, r.keys...)
+	clear(r.keys)
+	r.keys = r.keys[:0]
+	r.pending.Store(false)
+	return dst
+}
+
+// note queues key, which the store has just dropped. It is called with
+// the write lock held.
+func (r *removalLog) note(key string) {
+	if !r.on {
+		return
+	}
+	r.keys = append(r.keys, key)
+	r.pending.Store(true)
+}
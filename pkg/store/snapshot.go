@@ -0,0 +1,154 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// snapshotBatch is how many keys a snapshot reads per read-lock acquisition,
+// letting writers in between batches.
+const snapshotBatch = 256
+
+// Snapshot is a point-in-time view of the dataset that can be iterated
+// without holding the store lock for the whole walk. While a snapshot is
+// open, a writer about to change or remove a key first copies the key's
+// current entry into every open snapshot (copy-on-write), so iteration
+// always sees the dataset as it was when the snapshot was taken.
+type Snapshot struct {
+	s     *Store
+	at    time.Time
+	keys  []string          // keys present when the snapshot was taken
+	saved map[string]*Entry // pre-images of keys changed since; nil = absent
+}
+
+// Snapshot freezes the current generation of the dataset. The caller must
+// Close it when done, or writers keep paying the copy-on-write cost.
+func (s *Store) Snapshot() *Snapshot {
+	return s.snapshotPrefix("")
+}
+
+// snapshotPrefix is Snapshot restricted to the keys starting with prefix.
+func (s *Store) snapshotPrefix(prefix string) *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := &Snapshot{
+		s:     s,
+		at:    s.now(),
+		saved: make(map[string]*Entry),
+	}
+	if prefix == "" {
+		snap.keys = make([]string, 0, len(s.data))
+	}
+	s.walkPrefix(prefix, func(k string, _ *Entry) {
+		snap.keys = append(snap.keys, k)
+	})
+	if s.snapshots == nil {
+		s.snapshots = make(map[*Snapshot]struct{})
+	}
+	s.snapshots[snap] = struct{}{}
+	return snap
+}
+
+// Time is when the snapshot was taken.
+func (snap *Snapshot) Time() time.Time {
+	return snap.at
+}
+
+// Len is the number of keys the snapshot will visit (expired keys included).
+func (snap *Snapshot) Len() int {
+	return len(snap.keys)
+}
+
+// ForEach calls fn for every key that existed and had not expired when the
+// snapshot was taken, stopping at the first error fn returns.
+func (snap *Snapshot) ForEach(fn func(key string, e Entry) error) error {
+	var batch []KeyEntry
+	for start := 0; start < len(snap.keys); start += snapshotBatch {
+		batch = snap.read(batch[:0], start, start+snapshotBatch)
+		for _, it := range batch {
+			if err := fn(it.Key, it.Entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// read appends copies of the live entries of keys[start:end] to dst under
+// a single read lock.
+func (snap *Snapshot) read(dst []KeyEntry, start, end int) []KeyEntry {
+	end = min(end, len(snap.keys))
+	now := snap.at.Unix()
+
+	// Writers preserve pre-images under the write lock, so under the read
+	// lock a key is either saved or still unchanged in data.
+	snap.s.mu.RLock()
+	defer snap.s.mu.RUnlock()
+	for _, k := range snap.keys[start:end] {
+		e, ok := snap.saved[k]
+		if !ok {
+			e = snap.s.data[k]
+		}
+		if e == nil {
+			continue // created and removed again, or absent
+		}
+		if e.expired(now) {
+			continue
+		}
+		dst = append(dst, KeyEntry{Key: k, Entry: e.copy()})
+	}
+	return dst
+}
+
+// Close releases the snapshot.
+func (snap *Snapshot) Close() {
+	snap.s.mu.Lock()
+	defer snap.s.mu.Unlock()
+	delete(snap.s.snapshots, snap)
+	snap.saved = nil
+}
+
+// preserve copies key's current state into every open snapshot that has not
+// saved it yet. It must be called with the write lock held, before the key
+// is modified or removed.
+func (s *Store) preserve(key string) {
+	if len(s.snapshots) == 0 {
+		return
+	}
+	var pre *Entry
+	if e, ok := s.data[key]; ok {
+		c := e.copy()
+		pre = &c
+	}
+	for snap := range s.snapshots {
+		if _, ok := snap.saved[key]; !ok {
+			snap.saved[key] = pre
+		}
+	}
+}
+
+// DumpCommands returns a slice of text commands that reconstruct the DB.
+// This is similar to AOF contents, but generated from a snapshot, so writers
+// are only blocked briefly while it is built.
+func (s *Store) DumpCommands() []string {
+	snap := s.Snapshot()
+	defer snap.Close()
+
+	cmds := []string{}
+	snap.ForEach(func(k string, e Entry) error {
+		cmds = append(cmds, DumpEntry(k, e)...)
+		return nil
+	})
+	return cmds
+}
+
+// DumpEntry renders one live entry as the commands that recreate it: a SET,
+// then an EXPIREAT if it has a TTL. The expiry is absolute, so it doesn't
+// move however late the commands are applied.
+func DumpEntry(key string, e Entry) []string {
+	cmds := []string{fmt.Sprintf("SET %s %s", key, e.Value)}
+	if e.ExpiresAt != 0 {
+		cmds = append(cmds, fmt.Sprintf("EXPIREAT %s %d", key, e.ExpiresAt))
+	}
+	return cmds
+}
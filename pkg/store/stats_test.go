@@ -0,0 +1,42 @@
+package store
+
+import "testing"
+
+// Stats.Expires is kept as a running count; it must match a walk of the
+// keyspace after every kind of write that sets or clears a TTL.
+func TestStatsExpires(t *testing.T) {
+	s := New()
+	steps := []struct {
+		name string
+		do   func()
+		want int
+	}{
+		{"set", func() { s.Set("a", "1") }, 0},
+		{"set with ttl", func() { s.Setwithttl("b", "1", 100) }, 1},
+		{"expire", func() { s.Expires("a", 100) }, 2},
+		{"expire again", func() { s.Expires("a", 200) }, 2},
+		{"persist", func() { s.Persist("a") }, 1},
+		{"overwrite without ttl", func() { s.Set("b", "2") }, 0},
+		{"lock", func() { s.Lock("l", "tok", 100) }, 1},
+		{"overwrite lock without ttl", func() { s.Set("l", "tok") }, 0},
+		{"extend lock", func() { s.ExtendLock("l", "tok", 100) }, 1},
+		{"expire in the past", func() { s.ExpireAt("l", 1) }, 0},
+		{"cas with ttl", func() { s.CompareAndSwap("a", "1", "2", 100) }, 1},
+		{"incr keeps ttl", func() { s.IncrBy("a", 1) }, 1},
+		{"del", func() { s.Del("a") }, 0},
+		{"throttle", func() { s.Throttle("t", 1, 1, 1e9, 1) }, 1},
+		{"reset", func() { s.Reset() }, 0},
+	}
+	for _, st := range steps {
+		st.do()
+		walked := 0
+		for _, e := range s.data {
+			if e.ExpiresAt != 0 {
+				walked++
+			}
+		}
+		if got := s.Stats().Expires; got != st.want || got != walked {
+			t.Fatalf("after %s: Stats().Expires = %d, want %d (%d keys have a TTL)", st.name, got, st.want, walked)
+		}
+	}
+}
@@ -0,0 +1,637 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotInteger is returned by IncrBy when the value is not a base-10 64-bit
+// integer or the result would overflow.
+var ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+
+// Entry is a stored value with its expiry and access metadata.
+type Entry struct {
+	Value     string
+	ExpiresAt int64
+	LastAccess int64
+	Freq       uint8 // logarithmic access frequency for allkeys-lfu, see lfu.go
+	freqUpdatedAt int64 // minute of the last Freq update, for decay
+
+	// Compression, see compress.go. rawLen is the length of the original
+	// value when Value is stored deflated; copies handed out by the store
+	// hold the original value and remember the stored size in storedLen.
+	rawLen    int
+	storedLen int
+
+	// Intrusive LRU links, owned by Store.lru.
+	key        string
+	prev, next *Entry
+}
+
+// Store is a concurrency-safe key/value dataset. Create one with New.
+type Store struct {
+	mu   sync.RWMutex
+	data map[string]*Entry
+	lru  lruList // every entry in data, most recently used first
+	maxKeys int // 0 means no limit
+	maxMemory int64 // bytes; 0 means no limit
+	maxKeyLen int // bytes; 0 means no limit, see limits.go
+	maxValueSize int64 // bytes; 0 means no limit
+	compressThreshold int64 // bytes; 0 means no compression, see compress.go
+	compressedKeys, compressedRaw, compressedBytes int64
+	usedBytes int64 // approximate size of the dataset, see entrySize
+	expires int // entries with a TTL, expired or not; see setExpiresAt
+	policy EvictionPolicy
+	lfuLogFactor int
+	lfuDecayTime int // minutes
+	evictions int64 // ccount for evicated keys
+	reads  atomic.Int64 // updated under the read lock by Get
+	misses atomic.Int64 // reads of missing or expired keys
+	access accessLog    // reads not yet applied to lru / LFU counters
+	writes int64
+	writesBefore int64 // writes counted before the last ResetStats, see Changes
+	snapshots map[*Snapshot]struct{} // open snapshots, see snapshot.go
+	cursors cursorTable // snapshots being walked by Iterate
+	index *radixTree // sorted key index, nil when off; see keyindex.go
+	quotas map[string]*nsUsage // namespace quotas, see quota.go
+	events eventBus // mutation hooks, see events.go
+	removals removalLog // evicted and expired keys, see removals.go
+	clock Clock // see clock.go
+	loader loaderState // read-through loader, see loader.go
+	defrag defragState // map rebuilds, see defrag.go
+}
+
+// Stats holds dataset counters and limits, as reported by INFO.
+type Stats struct {
+	Keys      int   `json:"keys"`
+	Expires   int   `json:"expires"`
+	MaxKeys   int   `json:"max_keys"`
+	UsedMemory int64 `json:"used_memory"`
+	MaxMemory  int64 `json:"max_memory"`
+	MaxKeyLength int `json:"max_key_length"`
+	MaxValueSize int64 `json:"max_value_size"`
+	CompressThreshold int64 `json:"compress_threshold"`
+	CompressedKeys int64 `json:"compressed_keys"`
+	CompressedRawBytes int64 `json:"compressed_raw_bytes"` // original size of the compressed values
+	CompressedBytes int64 `json:"compressed_bytes"` // their stored size
+	Policy     EvictionPolicy `json:"maxmemory_policy"`
+	LFULogFactor int `json:"lfu_log_factor"`
+	LFUDecayTime int `json:"lfu_decay_time"`
+	KeyIndex  bool  `json:"key_index"`
+	Evictions int64 `json:"evictions"`
+	Reads     int64 `json:"reads"`
+	Hits      int64 `json:"keyspace_hits"`
+	Misses    int64 `json:"keyspace_misses"`
+	Writes    int64 `json:"writes"`
+	EventsDropped int64 `json:"events_dropped"`
+}
+
+// New returns an empty store with no limits and the allkeys-lru policy.
+func New() *Store {
+	return &Store{
+		data: make(map[string]*Entry),
+		maxKeys: 0, // no limit by default; we'll control via command
+		policy: PolicyAllKeysLRU,
+		clock: realClock{},
+		lfuLogFactor: defaultLFULogFactor,
+		lfuDecayTime: defaultLFUDecayTime,
+		defrag: defragState{threshold: defaultDefragThreshold},
+	}
+}
+
+// SetMaxKeys sets a soft limit on number of keys. 0 means no limit.
+func (s *Store) SetMaxKeys(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxKeys = n
+}
+
+// SetMaxMemory sets a limit on the approximate dataset size in bytes.
+// 0 means no limit. Lowering the limit evicts keys right away.
+func (s *Store) SetMaxMemory(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxMemory = n
+	s.ensureMemory()
+}
+
+// Limited reports whether maxkeys or maxmemory is set. Writes may then
+// evict other keys, and which ones depends on the order of the writes.
+func (s *Store) Limited() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.maxKeys > 0 || s.maxMemory > 0
+}
+
+// Stats returns the current counters and limits.
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	misses := s.misses.Load()
+	reads := s.reads.Load()
+	return Stats{
+		Keys:      len(s.data),
+		Expires:   s.expires,
+		MaxKeys:   s.maxKeys,
+		UsedMemory: s.usedBytes,
+		MaxMemory:  s.maxMemory,
+		MaxKeyLength: s.maxKeyLen,
+		MaxValueSize: s.maxValueSize,
+		CompressThreshold: s.compressThreshold,
+		CompressedKeys: s.compressedKeys,
+		CompressedRawBytes: s.compressedRaw,
+		CompressedBytes: s.compressedBytes,
+		Policy:     s.policy,
+		LFULogFactor: s.lfuLogFactor,
+		LFUDecayTime: s.lfuDecayTime,
+		KeyIndex:  s.index != nil,
+		Evictions: s.evictions,
+		Reads:     reads,
+		Hits:      reads - misses,
+		Misses:    misses,
+		Writes:    s.writes,
+		EventsDropped: s.events.dropped.Load(),
+	}
+}
+
+// ResetStats zeroes the read, write and eviction counters.
+func (s *Store) ResetStats() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictions = 0
+	s.reads.Store(0)
+	s.misses.Store(0)
+	s.writesBefore += s.writes
+	s.writes = 0
+}
+
+// Changes returns the number of writes since the store was created. Unlike
+// Stats.Writes it is not zeroed by ResetStats, so callers can count the
+// changes made since some earlier point, such as the last snapshot.
+func (s *Store) Changes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.writesBefore + s.writes
+}
+
+// Set stores a value without a TTL (no expiry).
+// It returns ErrOOM if the key does not fit and nothing can be evicted.
+func (s *Store) Set(key, value string) error {
+	return s.Setwithttl(key, value, 0)
+}
+
+// Setwithttl stores a value that expires after ttlSeconds; a ttl of 0 or
+// less means no expiry.
+func (s *Store) Setwithttl(key, value string, ttlSeconds int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setLocked(key, value, ttlSeconds)
+}
+
+// setLocked is Setwithttl for callers holding the write lock.
+func (s *Store) setLocked(key, value string, ttlSeconds int64) error {
+	now := s.now().Unix()
+
+	var exp int64 = 0
+	if ttlSeconds > 0 {
+		exp = s.now().Unix() + ttlSeconds
+	}
+	e := &Entry{Value: value, ExpiresAt: exp,LastAccess: now, Freq: lfuInitVal, freqUpdatedAt: now / 60}
+	if err := s.makeRoom(key, e); err != nil {
+		return err
+	}
+	s.put(key, e)
+	s.writes++
+	return nil
+}
+
+// Get returns a value if present and not expired.
+// Get only takes the read lock: the hit is queued in the access log and
+// applied to the LRU list and LFU counter later (see access.go).
+func (s *Store) Get(key string) (string, bool) {
+	now := s.now()
+	s.mu.RLock()
+	e, ok := s.data[key]
+	if !ok {
+		s.mu.RUnlock()
+		s.reads.Add(1)
+		s.misses.Add(1)
+		return "", false
+	}
+
+	// Check if expired
+	if e.expired(now.Unix()) {
+		s.mu.RUnlock()
+		s.reads.Add(1)
+		s.misses.Add(1)
+		return "", false
+	}
+	value := e.raw()
+	full := s.access.record(e, now)
+	s.mu.RUnlock()
+
+	s.reads.Add(1)
+	if full {
+		s.flushAccesses()
+	}
+	return value, true
+}
+
+// copy returns the fields of e, detached from the LRU list, with the
+// value decompressed.
+func (e *Entry) copy() Entry {
+	c := Entry{Value: e.raw(), ExpiresAt: e.ExpiresAt, LastAccess: e.LastAccess, Freq: e.Freq, freqUpdatedAt: e.freqUpdatedAt}
+	if e.rawLen != 0 {
+		c.storedLen = len(e.Value)
+	}
+	return c
+}
+
+// expired reports whether e has a TTL that ended before now, in Unix
+// seconds. A key lives through the second its TTL ends in, for which TTL
+// reports 0; every read and every snapshot decides expiry with this.
+func (e *Entry) expired(now int64) bool {
+	return e.ExpiresAt != 0 && e.ExpiresAt < now
+}
+
+// Inspect returns the raw entry for key, including expired entries that have
+// not been cleaned up yet. It does not count as an access.
+func (s *Store) Inspect(key string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyAccesses()
+	e, ok := s.data[key]
+	if !ok {
+		return Entry{}, false
+	}
+	c := e.copy()
+	c.Freq = s.lfuDecayed(e, lfuMinutes(s.now()))
+	return c, true
+}
+
+// Stall holds the store's write lock for d, blocking every other reader and
+// writer. It exists for DEBUG SLEEP.
+func (s *Store) Stall(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	time.Sleep(d)
+}
+
+// Del removes key if it exists and reports whether it was removed.
+func (s *Store) Del(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delLocked(key)
+}
+
+// delLocked is Del for callers holding the write lock.
+func (s *Store) delLocked(key string) bool {
+	if e, ok := s.data[key]; ok {
+		s.remove(key)
+		s.writes++
+		s.events.emit(EventDelete, key, e)
+		return true
+	}
+	return false
+}
+
+// Expires sets a TTL in seconds on key and reports whether the key
+// exists. As in Redis, a TTL of 0 or less deletes the key.
+func (s *Store) Expires(key string, ttlSeconds int64) bool {
+	return s.ExpireAt(key, s.expiryAt(ttlSeconds))
+}
+
+// expiryAt returns the unix time ttlSeconds from now.
+func (s *Store) expiryAt(ttlSeconds int64) int64 {
+	now := s.now().Unix()
+	at := now + ttlSeconds
+	if ttlSeconds > 0 && at < now {
+		at = math.MaxInt64 // overflow: never in practice
+	}
+	return at
+}
+
+// ExpireAt makes key expire at the unix time at, in seconds, and reports
+// whether the key exists. A time that isn't in the future deletes the key.
+func (s *Store) ExpireAt(key string, at int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.expireAtLocked(key, at)
+}
+
+// expireAtLocked is ExpireAt for callers holding the write lock.
+func (s *Store) expireAtLocked(key string, at int64) bool {
+	e, ok := s.data[key]
+	now := s.now().Unix()
+	if !ok || e.expired(now) {
+		return false
+	}
+	if at <= now {
+		s.remove(key)
+		s.writes++
+		s.events.emit(EventDelete, key, e)
+		return true
+	}
+	s.preserve(key)
+	s.setExpiresAt(e, at)
+	s.writes++
+	return true
+}
+
+// Persist removes the TTL of key. It reports whether there was one: false
+// if the key is missing or never expires.
+func (s *Store) Persist(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.persistLocked(key)
+}
+
+// persistLocked is Persist for callers holding the write lock.
+func (s *Store) persistLocked(key string) bool {
+	e, ok := s.data[key]
+	if !ok || e.ExpiresAt == 0 || e.expired(s.now().Unix()) {
+		return false
+	}
+	s.preserve(key)
+	s.setExpiresAt(e, 0)
+	s.writes++
+	return true
+}
+
+// Exists reports whether key is present and not expired. Unlike Get it
+// does not count as an access.
+func (s *Store) Exists(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.existsLocked(key)
+}
+
+// existsLocked is Exists for callers holding the lock.
+func (s *Store) existsLocked(key string) bool {
+	e, ok := s.data[key]
+	return ok && !e.expired(s.now().Unix())
+}
+
+// Len returns the number of keys, including expired keys not cleaned up yet.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.data)
+}
+
+// IncrBy adds delta to the integer stored at key and returns the result. A
+// missing or expired key counts as 0; an existing key keeps its TTL. It
+// returns ErrNotInteger if the value is not an integer or would overflow.
+func (s *Store) IncrBy(key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.incrByLocked(key, delta)
+}
+
+// incrByLocked is IncrBy for callers holding the write lock.
+func (s *Store) incrByLocked(key string, delta int64) (int64, error) {
+	now := s.now().Unix()
+	var cur, exp int64
+	if e, ok := s.data[key]; ok && !e.expired(now) {
+		n, err := strconv.ParseInt(e.raw(), 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+		cur, exp = n, e.ExpiresAt
+	}
+	if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) {
+		return 0, ErrNotInteger
+	}
+	cur += delta
+
+	e := &Entry{Value: strconv.FormatInt(cur, 10), ExpiresAt: exp, LastAccess: now, Freq: lfuInitVal, freqUpdatedAt: now / 60}
+	if err := s.makeRoom(key, e); err != nil {
+		return 0, err
+	}
+	s.put(key, e)
+	s.writes++
+	return cur, nil
+}
+
+// Reset removes every key. Limits, policy and counters are kept.
+func (s *Store) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.snapshots) > 0 {
+		for k := range s.data {
+			s.preserve(k)
+		}
+	}
+	s.events.emitFlush()
+	s.access.take()
+	s.data = make(map[string]*Entry)
+	s.defrag.peak = 0
+	s.lru = lruList{}
+	if s.index != nil {
+		s.index = &radixTree{}
+	}
+	s.usedBytes = 0
+	s.expires = 0
+	for _, u := range s.quotas {
+		u.keys, u.bytes = 0, 0
+	}
+	s.writes++
+}
+
+// TTL returns remaining time-to-live in seconds.
+// -1 if key exists and has no TTL
+// -2 if key does not exist or is expired
+func (s *Store) TTL(key string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ttlLocked(key)
+}
+
+// ttlLocked is TTL for callers holding the lock.
+func (s *Store) ttlLocked(key string) int64 {
+	e, ok := s.data[key]
+	if !ok {
+		return -2
+	}
+	if e.ExpiresAt == 0 {
+		return -1
+	}
+	if e.expired(s.now().Unix()) {
+		return -2
+	}
+	return e.ExpiresAt - s.now().Unix()
+}
+
+// ExpireTime returns the unix time in seconds at which key expires, as
+// TTL does: -1 if key exists and has no TTL, -2 if it does not exist or is
+// expired.
+func (s *Store) ExpireTime(key string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.data[key]
+	if !ok {
+		return -2
+	}
+	if e.ExpiresAt == 0 {
+		return -1
+	}
+	if e.expired(s.now().Unix()) {
+		return -2
+	}
+	return e.ExpiresAt
+}
+
+// CleanupExpired removes expired keys and returns how many were removed.
+func (s *Store) CleanupExpired() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for i, e := range s.data {
+		if e.expired(s.now().Unix()) {
+			s.remove(i)
+			removed++
+			s.evictions++
+			s.removals.note(i)
+			s.events.emit(EventExpire, i, e)
+		}
+	}
+	return removed
+}
+
+// Keys returns every key, including expired keys not cleaned up yet.
+func (s *Store) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]string, 0, len(s.data))
+	for k := range s.data {
+		res = append(res, k)
+	}
+	return res
+}
+
+// HelpText returns a small help message for the client.
+func HelpText() string {
+	lines := []string{
+		"Supported commands (simple text protocol):",
+		"  SET key value           - set value for key (no TTL)",
+		"  SETEX key ttl value     - set value with TTL in seconds",
+		"  GET key                 - get value for key",
+		"  WAITKEY key timeout [GET] - block until key is written (timeout in seconds, 0 = forever)",
+		"  DEL key                 - delete key",
+		"  FLUSHALL / FLUSHDB      - delete every key",
+		"  FCALL name numkeys [key ...] [arg ...] - run a server-side function atomically",
+		"  FUNCTION LIST           - names of the server-side functions",
+		"  EXISTS key              - check if key exists",
+		"  TTL key                 - get remaining TTL (seconds)",
+		"  EXPIRE key ttl          - set TTL in seconds (0 or less deletes the key), reply 1 or 0 if missing",
+		"  EXPIREAT key timestamp  - expire at a unix time in seconds (past deletes the key)",
+		"  PERSIST key             - remove the TTL, reply 1 or 0 if missing or without TTL",
+		"  INCR key                - increment integer value (init 0 if missing)",
+		"  DECR key                - decrement integer value (init 0 if missing)",
+		"  CONFIG SET name value   - change a runtime parameter, e.g.:",
+		"      maxkeys n           - max allowed keys (0 = unlimited)",
+		"      maxmemory bytes     - evict keys when the dataset exceeds bytes (e.g. 100mb, 0 = unlimited)",
+		"      max-key-length n | max-value-size bytes - reject larger keys / values (0 = unlimited)",
+		"      compress-threshold bytes - store string values of at least bytes compressed (0 = off)",
+		"      maxmemory-policy p  - noeviction, allkeys-lru, volatile-lru, allkeys-random, volatile-ttl, allkeys-lfu",
+		"      lfu-log-factor n | lfu-decay-time minutes - tune allkeys-lfu counters",
+		"      slowlog-log-slower-than usec (-1 = off) | slowlog-max-len n - tune the slowlog",
+		"      active-defrag-threshold percent - rebuild the key map below this share of its peak (0 = off)",
+		"      timeout secs        - close clients idle for secs seconds (0 = never)",
+		"      appendfsync policy  - always, everysec or no",
+		"      save \"secs changes ...\" - BGSAVE after secs if at least changes writes happened (\"\" = never)",
+		"      auto-aof-rewrite-percentage n | auto-aof-rewrite-min-size bytes - BGREWRITEAOF once the AOF grows by n% (0 = never)",
+		"      active-expire yes|no - background removal of expired keys",
+		"  CONFIG GET pattern      - show configuration parameters matching a glob",
+		"  CONFIG REWRITE          - save runtime changes to the config file",
+		"  CONFIG RESETSTAT        - zero all statistics counters",
+		"  CLIENT LIST|ID          - list connected clients / show own id",
+		"  CLIENT SETNAME|GETNAME  - set or get the connection name",
+		"  CLIENT KILL ID id|ADDR a - close another client connection",
+		"  CLIENT PAUSE ms [WRITE|ALL] - hold commands (or only writes) for ms",
+		"  CLIENT UNPAUSE          - resume command processing",
+		"  CLIENT FRAMING ON|OFF   - precede each reply with a *N line giving its line count",
+		"  MONITOR                 - stream every command processed by the server",
+		"  SLOWLOG GET [count]|LEN|RESET - commands slower than slowlog-log-slower-than, newest first",
+		"  LATENCY PERCENTILES [command...] - p50/p99/p99.9 and max latency per command, in usec",
+		"  INFO [section]          - show server stats (server, clients, memory, persistence, stats, replication, keyspace, commandstats, all, json)",
+		"  HEALTHCHECK             - check the store and AOF (ok, degraded or failed)",
+		"  MEMORY USAGE key [DETAIL] - approximate bytes used by key (DETAIL: stored and uncompressed)",
+		"  MEMORY STATS            - approximate dataset memory breakdown",
+		"  MEMORY PURGE            - rebuild the key map and release free memory, reply bytes reclaimed",
+		"  QUOTA SET ns maxkeys maxmemory | DEL ns | LIST - per-namespace quotas (namespace = key part before ':')",
+		"  SAVE                    - write a snapshot to disk and wait for it",
+		"  BGSAVE                  - write a snapshot to disk in the background",
+		"  BGREWRITEAOF            - write the dataset as a new AOF base file and drop the older AOF parts",
+		"  LASTSAVE                - unix time of the last successful snapshot",
+		"  LCS k1 k2 [LEN] [IDX] [MINMATCHLEN n] [WITHMATCHLEN] - longest common subsequence of two values",
+		"  JSON.SET key path json [NX|XX] - set a value in a JSON document ($ is the root)",
+		"  JSON.GET key [path]     - get a value from a JSON document",
+		"  JSON.DEL key [path]     - delete a value from a JSON document",
+		"  BF.RESERVE key error_rate capacity - create a bloom filter",
+		"  BF.ADD/BF.MADD key item [item ...] - add to a bloom filter (created if missing)",
+		"  BF.EXISTS/BF.MEXISTS key item [item ...] - check bloom filter membership",
+		"  CF.RESERVE key capacity - create a cuckoo filter",
+		"  CF.ADD/CF.DEL key item  - add to or remove from a cuckoo filter",
+		"  CF.EXISTS/CF.MEXISTS key item [item ...] - check cuckoo filter membership",
+		"  CMS.INITBYDIM key width depth | CMS.INITBYPROB key error prob - create a count-min sketch",
+		"  CMS.INCRBY key item n [item n ...] - count items in a count-min sketch",
+		"  CMS.QUERY key item [item ...] - estimated counts from a count-min sketch",
+		"  TOPK.RESERVE key k [width depth decay] - create a Top-K list",
+		"  TOPK.ADD key item [item ...] - count items, replying with any item pushed out",
+		"  TOPK.LIST key [WITHCOUNT] | TOPK.QUERY key item [item ...] - read a Top-K list",
+		"  TS.CREATE key [RETENTION ms] - create a time series",
+		"  TS.ADD key ts|* value [RETENTION ms] - append a sample (series created if missing)",
+		"  TS.GET key              - newest sample of a time series",
+		"  TS.RANGE key from|- to|+ [AGGREGATION avg|sum|min|max|count bucket_ms] - query samples",
+		"  THROTTLE key max_burst count period [quantity] - GCRA rate limit; replies limited, limit, remaining, retry-after, reset-after",
+		"  LOCK key token ttl      - acquire a lock for token (:1) unless another token holds it (:0)",
+		"  UNLOCK key token        - release a lock only if token holds it",
+		"  EXTEND key token ttl    - reset a lock's ttl only if token holds it",
+		"  CAS key expected new [EX ttl] - set key to new only if it holds expected, else reply the current value",
+		"  HOTKEYS [FREQ|SIZE] [COUNT n] - most accessed (default) or largest keys",
+		"  KEYS [pattern]          - list all keys, or those matching a glob pattern",
+		"  SCAN cursor [MATCH pattern] [COUNT n] [TYPE type] - iterate over the keys, starting at cursor 0",
+		"  COMMAND [COUNT|INFO|DOCS] [name...] - describe registered commands",
+		"  DEBUG SLEEP|OBJECT|SET-ACTIVE-EXPIRE|JMAP - testing helpers",
+		"  DEBUG FAULT LIST|SET name value|CLEAR - inject AOF and replication faults (enable-debug-fault yes)",
+		"  PING [msg]              - ping or echo message",
+		"  HELP                    - show this help",
+		"  QUIT                    - close connection",
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CompareAndSwap sets key to value only if it currently holds expected,
+// and reports whether it did. Otherwise it returns the current value
+// (exists is false if the key is missing or expired). A ttlSeconds of 0 or
+// less keeps the key's TTL; a positive one replaces it.
+func (s *Store) CompareAndSwap(key, expected, value string, ttlSeconds int64) (current string, exists, swapped bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().Unix()
+	e, ok := s.data[key]
+	if !ok || e.expired(now) {
+		return "", false, false, nil
+	}
+	if cur := e.raw(); cur != expected {
+		return cur, true, false, nil
+	}
+	exp := e.ExpiresAt
+	if ttlSeconds > 0 {
+		exp = now + ttlSeconds
+	}
+	n := &Entry{Value: value, ExpiresAt: exp, LastAccess: now, Freq: lfuInitVal, freqUpdatedAt: now / 60}
+	if err := s.makeRoom(key, n); err != nil {
+		return "", true, false, err
+	}
+	s.put(key, n)
+	s.writes++
+	return value, true, true, nil
+}
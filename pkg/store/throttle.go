@@ -0,0 +1,85 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ErrThrottleParams is returned by Throttle for a non-positive count or
+// period, or a negative burst or quantity.
+var ErrThrottleParams = errors.New("ERR invalid rate limit parameters")
+
+// ThrottleResult is the outcome of a Throttle call, matching the reply of
+// redis-cell's CL.THROTTLE.
+type ThrottleResult struct {
+	Limited    bool
+	Limit      int64         // max_burst + 1
+	Remaining  int64         // requests still allowed right now
+	RetryAfter time.Duration // until the request would be allowed; -1 if allowed
+	ResetAfter time.Duration // until the limit is back to its full burst
+	// TAT is the stored theoretical arrival time, in Unix nanoseconds.
+	TAT int64
+}
+
+// Throttle applies the generic cell rate algorithm (GCRA) to key: it
+// allows count requests per period with bursts of up to maxBurst extra
+// requests, and charges quantity requests to the limit. The key holds the
+// theoretical arrival time in Unix nanoseconds and expires once the limit
+// has fully reset; a limited request does not change it.
+func (s *Store) Throttle(key string, maxBurst, count int64, period time.Duration, quantity int64) (ThrottleResult, error) {
+	if count <= 0 || period <= 0 || maxBurst < 0 || quantity < 0 || maxBurst >= math.MaxInt32 {
+		return ThrottleResult{}, ErrThrottleParams
+	}
+	interval := period / time.Duration(count)
+	if interval <= 0 {
+		return ThrottleResult{}, ErrThrottleParams
+	}
+	tolerance := interval * time.Duration(maxBurst+1)
+	increment := interval * time.Duration(quantity)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	tat := now.UnixNano()
+	if e, ok := s.data[key]; ok && !e.expired(now.Unix()) {
+		n, err := strconv.ParseInt(e.raw(), 10, 64)
+		if err != nil {
+			return ThrottleResult{}, ErrWrongType
+		}
+		tat = max(tat, n)
+	}
+
+	res := ThrottleResult{Limit: maxBurst + 1, RetryAfter: -1}
+	newTAT := tat + int64(increment)
+	allowAt := newTAT - int64(tolerance)
+	if diff := allowAt - now.UnixNano(); diff > 0 {
+		res.Limited = true
+		res.RetryAfter = time.Duration(diff)
+		if increment > tolerance {
+			res.RetryAfter = -1 // can never fit, e.g. quantity > max_burst+1
+		}
+		newTAT = tat
+	}
+	res.TAT = newTAT
+	res.ResetAfter = time.Duration(newTAT - now.UnixNano())
+	res.Remaining = max(0, int64((tolerance-res.ResetAfter)/interval))
+	if res.Limited {
+		return res, nil
+	}
+
+	ttl := int64(math.Ceil(res.ResetAfter.Seconds()))
+	e := &Entry{Value: strconv.FormatInt(newTAT, 10), ExpiresAt: now.Unix() + ttl, LastAccess: now.Unix(), Freq: lfuInitVal, freqUpdatedAt: now.Unix() / 60}
+	if ttl <= 0 {
+		// quantity 0 on an idle key: nothing to remember
+		return res, nil
+	}
+	if err := s.makeRoom(key, e); err != nil {
+		return ThrottleResult{}, err
+	}
+	s.put(key, e)
+	s.writes++
+	return res, nil
+}
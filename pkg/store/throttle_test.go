@@ -0,0 +1,73 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DakshBaxi/RediGo/pkg/redigotest"
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+func TestThrottleParams(t *testing.T) {
+	s := store.New()
+	tests := []struct {
+		maxBurst, count int64
+		period          time.Duration
+		quantity        int64
+	}{
+		{maxBurst: 1, count: 0, period: time.Second, quantity: 1},
+		{maxBurst: 1, count: 1, period: 0, quantity: 1},
+		{maxBurst: -1, count: 1, period: time.Second, quantity: 1},
+		{maxBurst: 1, count: 1, period: time.Second, quantity: -1},
+		{maxBurst: 1 << 31, count: 1, period: time.Second, quantity: 1},
+		// an interval shorter than a nanosecond
+		{maxBurst: 1, count: 10, period: time.Nanosecond, quantity: 1},
+	}
+	for _, tt := range tests {
+		if _, err := s.Throttle("k", tt.maxBurst, tt.count, tt.period, tt.quantity); err != store.ErrThrottleParams {
+			t.Errorf("Throttle(%d, %d, %v, %d) = %v, want ErrThrottleParams", tt.maxBurst, tt.count, tt.period, tt.quantity, err)
+		}
+	}
+	s.Set("plain", "v")
+	if _, err := s.Throttle("plain", 1, 1, time.Second, 1); err != store.ErrWrongType {
+		t.Errorf("Throttle on a string = %v, want ErrWrongType", err)
+	}
+}
+
+// One request per second, with bursts of up to 2 more.
+func TestThrottle(t *testing.T) {
+	clock := redigotest.NewClock(time.Unix(1_000_000, 0))
+	s := store.New()
+	s.SetClock(clock)
+
+	tests := []struct {
+		advance  time.Duration
+		key      string
+		quantity int64
+		want     store.ThrottleResult // TAT is not compared
+	}{
+		{key: "k", quantity: 1, want: store.ThrottleResult{Limit: 3, Remaining: 2, RetryAfter: -1, ResetAfter: time.Second}},
+		{key: "k", quantity: 1, want: store.ThrottleResult{Limit: 3, Remaining: 1, RetryAfter: -1, ResetAfter: 2 * time.Second}},
+		{key: "k", quantity: 1, want: store.ThrottleResult{Limit: 3, Remaining: 0, RetryAfter: -1, ResetAfter: 3 * time.Second}},
+		{key: "k", quantity: 1, want: store.ThrottleResult{Limited: true, Limit: 3, Remaining: 0, RetryAfter: time.Second, ResetAfter: 3 * time.Second}},
+		{advance: 500 * time.Millisecond, key: "k", quantity: 1,
+			want: store.ThrottleResult{Limited: true, Limit: 3, Remaining: 0, RetryAfter: 500 * time.Millisecond, ResetAfter: 2500 * time.Millisecond}},
+		{advance: 500 * time.Millisecond, key: "k", quantity: 1, want: store.ThrottleResult{Limit: 3, Remaining: 0, RetryAfter: -1, ResetAfter: 3 * time.Second}},
+		// more than the burst can ever hold
+		{key: "k", quantity: 4, want: store.ThrottleResult{Limited: true, Limit: 3, Remaining: 0, RetryAfter: -1, ResetAfter: 3 * time.Second}},
+		// the limit has fully reset and the key expired
+		{advance: 10 * time.Second, key: "k", quantity: 1, want: store.ThrottleResult{Limit: 3, Remaining: 2, RetryAfter: -1, ResetAfter: time.Second}},
+		{key: "idle", quantity: 0, want: store.ThrottleResult{Limit: 3, Remaining: 3, RetryAfter: -1}},
+	}
+	for i, tt := range tests {
+		clock.Advance(tt.advance)
+		got, err := s.Throttle(tt.key, 2, 1, time.Second, tt.quantity)
+		got.TAT = 0
+		if err != nil || got != tt.want {
+			t.Errorf("step %d: Throttle(%s, %d) = %+v, %v; want %+v", i, tt.key, tt.quantity, got, err, tt.want)
+		}
+	}
+	if _, ok := s.Get("idle"); ok {
+		t.Error("a request of quantity 0 stored its key")
+	}
+}
@@ -0,0 +1,290 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+)
+
+// A time series is stored as "ts:" followed by fixed-width records: 15
+// bytes (a 7-byte big-endian unsigned integer and an 8-byte float64),
+// which base64 encodes to exactly 20 characters. The first record holds
+// the retention in milliseconds, the rest are samples in timestamp order.
+// Appending a sample is a string concatenation and lookups binary search
+// the records, so nothing is decoded beyond the samples a command reads.
+
+const (
+	tsPrefix       = "ts:"
+	tsRecordLen    = 20 // base64 characters per record
+	tsRecordBytes  = 15
+	MaxTSTimestamp = 1<<56 - 1
+)
+
+var (
+	// ErrTSDuplicate is returned when adding a sample at a timestamp the
+	// series already has.
+	ErrTSDuplicate = errors.New("ERR a sample with this timestamp already exists")
+	// ErrTSTooOld is returned when adding a sample that falls outside the
+	// retention window of the series.
+	ErrTSTooOld = errors.New("ERR timestamp is older than the retention period")
+	// ErrTSTimestamp is returned for timestamps outside [0, MaxTSTimestamp].
+	ErrTSTimestamp = errors.New("ERR invalid timestamp")
+)
+
+// Sample is a time series data point; timestamps are in milliseconds.
+type Sample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// Aggregation downsamples a range into one sample per time bucket.
+type Aggregation int
+
+const (
+	AggNone Aggregation = iota
+	AggAvg
+	AggSum
+	AggMin
+	AggMax
+	AggCount
+)
+
+// ParseAggregation parses an aggregation name as used by TS.RANGE.
+func ParseAggregation(name string) (Aggregation, bool) {
+	switch strings.ToLower(name) {
+	case "avg":
+		return AggAvg, true
+	case "sum":
+		return AggSum, true
+	case "min":
+		return AggMin, true
+	case "max":
+		return AggMax, true
+	case "count":
+		return AggCount, true
+	}
+	return AggNone, false
+}
+
+func tsRecord(a uint64, f float64) string {
+	var b [tsRecordBytes]byte
+	for i := 6; i >= 0; i-- {
+		b[i] = byte(a)
+		a >>= 8
+	}
+	bits := math.Float64bits(f)
+	for i := 14; i >= 7; i-- {
+		b[i] = byte(bits)
+		bits >>= 8
+	}
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// tsSeries is a view of a stored series value.
+type tsSeries string
+
+func decodeSeries(v string) (tsSeries, error) {
+	if !strings.HasPrefix(v, tsPrefix) || (len(v)-len(tsPrefix))%tsRecordLen != 0 || len(v) == len(tsPrefix) {
+		return "", ErrWrongType
+	}
+	return tsSeries(v), nil
+}
+
+func newSeries(retention int64) tsSeries {
+	return tsSeries(tsPrefix + tsRecord(uint64(retention), 0))
+}
+
+// record decodes record i, where 0 is the header.
+func (t tsSeries) record(i int) (uint64, float64) {
+	off := len(tsPrefix) + i*tsRecordLen
+	var b [tsRecordBytes]byte
+	base64.StdEncoding.Decode(b[:], []byte(t[off:off+tsRecordLen]))
+	var a, bits uint64
+	for _, c := range b[:7] {
+		a = a<<8 | uint64(c)
+	}
+	for _, c := range b[7:] {
+		bits = bits<<8 | uint64(c)
+	}
+	return a, math.Float64frombits(bits)
+}
+
+func (t tsSeries) retention() int64 {
+	r, _ := t.record(0)
+	return int64(r)
+}
+
+func (t tsSeries) len() int {
+	return (len(t)-len(tsPrefix))/tsRecordLen - 1
+}
+
+func (t tsSeries) sample(i int) Sample {
+	ts, v := t.record(i + 1)
+	return Sample{int64(ts), v}
+}
+
+func (t tsSeries) offset(i int) int {
+	return len(tsPrefix) + (i+1)*tsRecordLen
+}
+
+// search returns the index of the first sample at or after ts.
+func (t tsSeries) search(ts int64) int {
+	return sort.Search(t.len(), func(i int) bool { return t.sample(i).Timestamp >= ts })
+}
+
+// add inserts a sample and drops the samples that fall out of the
+// retention window.
+func (t tsSeries) add(ts int64, v float64) (tsSeries, error) {
+	n := t.len()
+	newest := ts
+	if n > 0 {
+		newest = max(newest, t.sample(n-1).Timestamp)
+	}
+	ret := t.retention()
+	if ret > 0 && ts < newest-ret {
+		return "", ErrTSTooOld
+	}
+
+	i := n
+	if n > 0 && ts <= t.sample(n-1).Timestamp {
+		i = t.search(ts)
+		if t.sample(i).Timestamp == ts {
+			return "", ErrTSDuplicate
+		}
+	}
+	rec := tsRecord(uint64(ts), v)
+	if i == n {
+		t += tsSeries(rec)
+	} else {
+		t = t[:t.offset(i)] + tsSeries(rec) + t[t.offset(i):]
+	}
+	if ret > 0 {
+		if j := t.search(newest - ret); j > 0 {
+			t = t[:t.offset(0)] + t[t.offset(j):]
+		}
+	}
+	return t, nil
+}
+
+// TSCreate creates an empty time series at key that keeps samples up to
+// retention milliseconds older than its newest one (0 keeps everything).
+// It returns ErrFilterExists if the key exists.
+func (s *Store) TSCreate(key string, retention int64) error {
+	if retention < 0 || retention > MaxTSTimestamp {
+		return ErrTSTimestamp
+	}
+	_, _, err := s.modify(key, func(_ string, exists bool) (string, bool, error) {
+		if exists {
+			return "", false, ErrFilterExists
+		}
+		return string(newSeries(retention)), true, nil
+	})
+	return err
+}
+
+// TSAdd adds a sample to the time series at key, creating the series with
+// the given retention if the key does not exist. Adding an existing
+// timestamp fails with ErrTSDuplicate, which makes TS.ADD safe to replay.
+func (s *Store) TSAdd(key string, ts int64, v float64, retention int64) error {
+	if ts < 0 || ts > MaxTSTimestamp || retention < 0 || retention > MaxTSTimestamp {
+		return ErrTSTimestamp
+	}
+	_, _, err := s.modify(key, func(old string, exists bool) (string, bool, error) {
+		t := newSeries(retention)
+		if exists {
+			var err error
+			if t, err = decodeSeries(old); err != nil {
+				return "", false, err
+			}
+		}
+		t, err := t.add(ts, v)
+		return string(t), err == nil, err
+	})
+	return err
+}
+
+func (s *Store) getSeries(key string) (tsSeries, error) {
+	v, ok := s.Get(key)
+	if !ok {
+		return "", ErrNoSuchKey
+	}
+	return decodeSeries(v)
+}
+
+// TSGet returns the newest sample of the time series at key, or false if
+// it has none.
+func (s *Store) TSGet(key string) (Sample, bool, error) {
+	t, err := s.getSeries(key)
+	if err != nil || t.len() == 0 {
+		return Sample{}, false, err
+	}
+	return t.sample(t.len() - 1), true, nil
+}
+
+// TSRange returns the samples of the time series at key with timestamps in
+// [from, to]. With an aggregation, it returns one sample per bucket of
+// bucket milliseconds instead, timestamped with the start of the bucket.
+func (s *Store) TSRange(key string, from, to int64, agg Aggregation, bucket int64) ([]Sample, error) {
+	t, err := s.getSeries(key)
+	if err != nil {
+		return nil, err
+	}
+	var out []Sample
+	var cur tsBucket
+	for i := t.search(from); i < t.len(); i++ {
+		smp := t.sample(i)
+		if smp.Timestamp > to {
+			break
+		}
+		if agg == AggNone || bucket <= 0 {
+			out = append(out, smp)
+			continue
+		}
+		start := smp.Timestamp - smp.Timestamp%bucket
+		if cur.count > 0 && cur.start != start {
+			out = append(out, cur.result(agg))
+			cur = tsBucket{}
+		}
+		cur.add(start, smp.Value)
+	}
+	if cur.count > 0 {
+		out = append(out, cur.result(agg))
+	}
+	return out, nil
+}
+
+// tsBucket accumulates the samples of one aggregation bucket.
+type tsBucket struct {
+	start         int64
+	count         int
+	sum, min, max float64
+}
+
+func (b *tsBucket) add(start int64, v float64) {
+	if b.count == 0 {
+		b.start, b.min, b.max = start, v, v
+	}
+	b.count++
+	b.sum += v
+	b.min = min(b.min, v)
+	b.max = max(b.max, v)
+}
+
+func (b *tsBucket) result(agg Aggregation) Sample {
+	r := Sample{Timestamp: b.start}
+	switch agg {
+	case AggAvg:
+		r.Value = b.sum / float64(b.count)
+	case AggSum:
+		r.Value = b.sum
+	case AggMin:
+		r.Value = b.min
+	case AggMax:
+		r.Value = b.max
+	case AggCount:
+		r.Value = float64(b.count)
+	}
+	return r
+}
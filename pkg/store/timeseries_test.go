@@ -0,0 +1,128 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseAggregation(t *testing.T) {
+	tests := []struct {
+		name string
+		want Aggregation
+		ok   bool
+	}{
+		{"avg", AggAvg, true},
+		{"SUM", AggSum, true},
+		{"Min", AggMin, true},
+		{"max", AggMax, true},
+		{"count", AggCount, true},
+		{"first", AggNone, false},
+		{"", AggNone, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseAggregation(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseAggregation(%q) = %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTSAdd(t *testing.T) {
+	s := New()
+	s.Set("plain", "v")
+	if err := s.TSCreate("r", 100); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key       string
+		ts        int64
+		retention int64
+		err       error
+	}{
+		{key: "s", ts: 10},
+		{key: "s", ts: 30},
+		{key: "s", ts: 20}, // out of order
+		{key: "s", ts: 20, err: ErrTSDuplicate},
+		{key: "s", ts: -1, err: ErrTSTimestamp},
+		{key: "s", ts: MaxTSTimestamp + 1, err: ErrTSTimestamp},
+		{key: "s", ts: 40, retention: -1, err: ErrTSTimestamp},
+		{key: "r", ts: 1000},
+		{key: "r", ts: 900},
+		{key: "r", ts: 899, err: ErrTSTooOld},
+		{key: "r", ts: MaxTSTimestamp},
+		{key: "plain", ts: 1, err: ErrWrongType},
+	}
+	for _, tt := range tests {
+		if err := s.TSAdd(tt.key, tt.ts, 1, tt.retention); err != tt.err {
+			t.Errorf("TSAdd(%s, %d) = %v, want %v", tt.key, tt.ts, err, tt.err)
+		}
+	}
+
+	got, _ := s.TSRange("s", 0, MaxTSTimestamp, AggNone, 0)
+	if want := []Sample{{10, 1}, {20, 1}, {30, 1}}; !slices.Equal(got, want) {
+		t.Errorf("s = %v, want %v", got, want)
+	}
+	// the newest sample pushed the others out of the retention window
+	got, _ = s.TSRange("r", 0, MaxTSTimestamp, AggNone, 0)
+	if want := []Sample{{MaxTSTimestamp, 1}}; !slices.Equal(got, want) {
+		t.Errorf("r = %v, want %v", got, want)
+	}
+	if err := s.TSCreate("r", 0); err != ErrFilterExists {
+		t.Errorf("TSCreate on an existing key = %v, want ErrFilterExists", err)
+	}
+	if err := s.TSCreate("x", -1); err != ErrTSTimestamp {
+		t.Errorf("TSCreate with a negative retention = %v, want ErrTSTimestamp", err)
+	}
+}
+
+func TestTSGet(t *testing.T) {
+	s := New()
+	if _, _, err := s.TSGet("s"); err != ErrNoSuchKey {
+		t.Errorf("TSGet(missing) = %v, want ErrNoSuchKey", err)
+	}
+	s.TSCreate("s", 0)
+	if _, ok, err := s.TSGet("s"); ok || err != nil {
+		t.Errorf("TSGet(empty) = %v, %v; want false, nil", ok, err)
+	}
+	s.TSAdd("s", 20, 2.5, 0)
+	s.TSAdd("s", 10, 1, 0)
+	if smp, ok, err := s.TSGet("s"); smp != (Sample{20, 2.5}) || !ok || err != nil {
+		t.Errorf("TSGet = %v, %v, %v; want {20 2.5}", smp, ok, err)
+	}
+}
+
+func TestTSRange(t *testing.T) {
+	s := New()
+	for _, smp := range []Sample{{0, 1}, {5, 3}, {10, 2}, {19, 6}, {25, -1}, {40, 4}} {
+		if err := s.TSAdd("s", smp.Timestamp, smp.Value, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		from, to int64
+		agg      Aggregation
+		bucket   int64
+		want     []Sample
+	}{
+		{from: 0, to: 100, want: []Sample{{0, 1}, {5, 3}, {10, 2}, {19, 6}, {25, -1}, {40, 4}}},
+		{from: 5, to: 19, want: []Sample{{5, 3}, {10, 2}, {19, 6}}},
+		{from: 6, to: 9},
+		{from: 41, to: 100},
+		{from: 0, to: 100, agg: AggAvg, bucket: 10, want: []Sample{{0, 2}, {10, 4}, {20, -1}, {40, 4}}},
+		{from: 0, to: 100, agg: AggSum, bucket: 10, want: []Sample{{0, 4}, {10, 8}, {20, -1}, {40, 4}}},
+		{from: 0, to: 100, agg: AggMin, bucket: 20, want: []Sample{{0, 1}, {20, -1}, {40, 4}}},
+		{from: 0, to: 100, agg: AggMax, bucket: 20, want: []Sample{{0, 6}, {20, -1}, {40, 4}}},
+		{from: 0, to: 100, agg: AggCount, bucket: 100, want: []Sample{{0, 6}}},
+		{from: 10, to: 30, agg: AggCount, bucket: 20, want: []Sample{{0, 2}, {20, 1}}},
+		// a bucket of zero returns the raw samples
+		{from: 0, to: 5, agg: AggSum, want: []Sample{{0, 1}, {5, 3}}},
+	}
+	for _, tt := range tests {
+		got, err := s.TSRange("s", tt.from, tt.to, tt.agg, tt.bucket)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("TSRange(%d, %d, %v, %d) = %v, %v; want %v", tt.from, tt.to, tt.agg, tt.bucket, got, err, tt.want)
+		}
+	}
+	if _, err := s.TSRange("missing", 0, 100, AggNone, 0); err != ErrNoSuchKey {
+		t.Errorf("TSRange(missing) = %v, want ErrNoSuchKey", err)
+	}
+}
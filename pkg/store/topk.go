@@ -0,0 +1,212 @@
+package store
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// Top-K tracks the k most frequent items with HeavyKeeper, like RedisBloom:
+// a width x depth array of (fingerprint, count) buckets where colliding
+// items decay each other's counts with probability decay^count, plus the
+// current top k items with their estimated counts. The decay draws from
+// stateRand, seeded by a count of adds kept with the list, so adds are
+// deterministic and are logged as commands.
+
+const (
+	topkPrefix          = "topk:"
+	TopKDefaultWidth    = 8
+	TopKDefaultDepth    = 7
+	TopKDefaultDecay    = 0.9
+	maxTopK             = 1 << 16
+	topkMaxDecayLookups = 256
+)
+
+// TopKItem is an item in a Top-K list with its estimated count.
+type TopKItem struct {
+	Item  string
+	Count uint64
+}
+
+type topkBucket struct {
+	fp    uint32
+	count uint64
+}
+
+type topK struct {
+	k, width, depth uint64
+	decay           float64
+	buckets         []topkBucket // row-major
+	top             []TopKItem   // at most k, unordered
+	adds            uint64       // items added so far, seeding the decay
+}
+
+func validTopKParams(k, width, depth uint64, decay float64) bool {
+	return k > 0 && k <= maxTopK && validSketchDims(width, depth) && decay > 0 && decay <= 1
+}
+
+// add counts item and returns the item it pushed out of the top k, or "".
+func (t *topK) add(item string) string {
+	h1, h2 := itemHashes(item)
+	fp := uint32(h2 >> 32)
+	t.adds++
+	seed := t.adds
+	var est uint64
+	for row := uint64(0); row < t.depth; row++ {
+		b := &t.buckets[row*t.width+(h1+row*h2)%t.width]
+		switch {
+		case b.count == 0:
+			b.fp, b.count = fp, 1
+		case b.fp == fp:
+			b.count++
+		// decay^count underflows to 0 long before count gets large, so
+		// heavy hitters are effectively never decayed
+		case b.count < topkMaxDecayLookups && float64(stateRand(&seed)>>11)/(1<<53) < math.Pow(t.decay, float64(b.count)):
+			if b.count--; b.count == 0 {
+				b.fp, b.count = fp, 1
+			}
+		}
+		if b.fp == fp {
+			est = max(est, b.count)
+		}
+	}
+
+	minIdx := -1
+	for i := range t.top {
+		if t.top[i].Item == item {
+			t.top[i].Count = max(t.top[i].Count, est)
+			return ""
+		}
+		if minIdx < 0 || t.top[i].Count < t.top[minIdx].Count {
+			minIdx = i
+		}
+	}
+	if uint64(len(t.top)) < t.k {
+		t.top = append(t.top, TopKItem{item, est})
+		return ""
+	}
+	if est > t.top[minIdx].Count {
+		expelled := t.top[minIdx].Item
+		t.top[minIdx] = TopKItem{item, est}
+		return expelled
+	}
+	return ""
+}
+
+func (t *topK) encode() string {
+	b := binary.AppendUvarint(nil, t.k)
+	b = binary.AppendUvarint(b, t.width)
+	b = binary.AppendUvarint(b, t.depth)
+	b = binary.BigEndian.AppendUint64(b, math.Float64bits(t.decay))
+	for _, bk := range t.buckets {
+		b = binary.AppendUvarint(b, uint64(bk.fp))
+		b = binary.AppendUvarint(b, bk.count)
+	}
+	b = binary.AppendUvarint(b, uint64(len(t.top)))
+	for _, it := range t.top {
+		b = binary.AppendUvarint(b, uint64(len(it.Item)))
+		b = append(b, it.Item...)
+		b = binary.AppendUvarint(b, it.Count)
+	}
+	b = binary.AppendUvarint(b, t.adds)
+	return encodeTagged(topkPrefix, b)
+}
+
+func decodeTopK(v string) (*topK, error) {
+	r, err := decodeTagged(v, topkPrefix)
+	if err != nil {
+		return nil, err
+	}
+	t := &topK{k: r.uvarint(), width: r.uvarint(), depth: r.uvarint(), decay: math.Float64frombits(r.uint64())}
+	if r.bad || !validTopKParams(t.k, t.width, t.depth, t.decay) {
+		return nil, ErrWrongType
+	}
+	t.buckets = make([]topkBucket, t.width*t.depth)
+	for i := range t.buckets {
+		t.buckets[i] = topkBucket{fp: uint32(r.uvarint()), count: r.uvarint()}
+	}
+	n := r.uvarint()
+	for i := uint64(0); i < n && !r.bad; i++ {
+		item := string(r.bytes(r.uvarint()))
+		t.top = append(t.top, TopKItem{item, r.uvarint()})
+	}
+	if len(r.b) > 0 { // absent from lists written before it was kept
+		t.adds = r.uvarint()
+	}
+	return t, r.err()
+}
+
+// TopKReserve creates an empty Top-K list at key tracking the k most
+// frequent items. It returns ErrFilterExists if the key exists.
+func (s *Store) TopKReserve(key string, k, width, depth uint64, decay float64) error {
+	if !validTopKParams(k, width, depth, decay) {
+		return ErrSketchParams
+	}
+	_, _, err := s.modify(key, func(_ string, exists bool) (string, bool, error) {
+		if exists {
+			return "", false, ErrFilterExists
+		}
+		t := &topK{k: k, width: width, depth: depth, decay: decay, buckets: make([]topkBucket, width*depth)}
+		return t.encode(), true, nil
+	})
+	return err
+}
+
+// TopKAdd counts items in the Top-K list at key and returns, for each, the
+// item it pushed out of the list or "" if none. The list must exist.
+func (s *Store) TopKAdd(key string, items ...string) ([]string, error) {
+	expelled := make([]string, len(items))
+	_, _, err := s.modify(key, func(old string, exists bool) (string, bool, error) {
+		if !exists {
+			return "", false, ErrNoSuchKey
+		}
+		t, err := decodeTopK(old)
+		if err != nil {
+			return "", false, err
+		}
+		for i, item := range items {
+			expelled[i] = t.add(item)
+		}
+		return t.encode(), true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expelled, nil
+}
+
+// TopKList returns the items in the Top-K list at key, most frequent first.
+func (s *Store) TopKList(key string) ([]TopKItem, error) {
+	t, err := s.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(t.top, func(i, j int) bool { return t.top[i].Count > t.top[j].Count })
+	return t.top, nil
+}
+
+// TopKQuery reports for each item whether it is in the Top-K list at key.
+func (s *Store) TopKQuery(key string, items ...string) ([]bool, error) {
+	t, err := s.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]bool, len(items))
+	for i, item := range items {
+		for _, it := range t.top {
+			if it.Item == item {
+				found[i] = true
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+func (s *Store) getTopK(key string) (*topK, error) {
+	v, ok := s.Get(key)
+	if !ok {
+		return nil, ErrNoSuchKey
+	}
+	return decodeTopK(v)
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestTopKReserve(t *testing.T) {
+	s := New()
+	tests := []struct {
+		key             string
+		k, width, depth uint64
+		decay           float64
+		err             error
+	}{
+		{key: "a", k: 3, width: 8, depth: 7, decay: 0.9},
+		{key: "a", k: 3, width: 8, depth: 7, decay: 0.9, err: ErrFilterExists},
+		{key: "b", k: 0, width: 8, depth: 7, decay: 0.9, err: ErrSketchParams},
+		{key: "b", k: maxTopK + 1, width: 8, depth: 7, decay: 0.9, err: ErrSketchParams},
+		{key: "b", k: 3, width: 0, depth: 7, decay: 0.9, err: ErrSketchParams},
+		{key: "b", k: 3, width: 8, depth: 7, decay: 0, err: ErrSketchParams},
+		{key: "b", k: 3, width: 8, depth: 7, decay: 1.5, err: ErrSketchParams},
+		{key: "b", k: 3, width: 8, depth: 7, decay: 1},
+	}
+	for _, tt := range tests {
+		if err := s.TopKReserve(tt.key, tt.k, tt.width, tt.depth, tt.decay); err != tt.err {
+			t.Errorf("TopKReserve(%s, %d, %d, %d, %g) = %v, want %v", tt.key, tt.k, tt.width, tt.depth, tt.decay, err, tt.err)
+		}
+	}
+}
+
+func TestTopKAdd(t *testing.T) {
+	s := New()
+	s.Set("plain", "v")
+	if err := s.TopKReserve("t", 1, 100, 3, 0.9); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key      string
+		items    []string
+		expelled []string
+		err      error
+	}{
+		{key: "t", items: []string{"a"}, expelled: []string{""}},
+		// b has to be seen more often than a to take its place
+		{key: "t", items: []string{"b"}, expelled: []string{""}},
+		{key: "t", items: []string{"b", "b"}, expelled: []string{"a", ""}},
+		{key: "t", items: []string{"a"}, expelled: []string{""}},
+		{key: "missing", items: []string{"a"}, err: ErrNoSuchKey},
+		{key: "plain", items: []string{"a"}, err: ErrWrongType},
+	}
+	for _, tt := range tests {
+		got, err := s.TopKAdd(tt.key, tt.items...)
+		if err != tt.err || err == nil && !slices.Equal(got, tt.expelled) {
+			t.Errorf("TopKAdd(%s, %v) = %q, %v; want %q, %v", tt.key, tt.items, got, err, tt.expelled, tt.err)
+		}
+	}
+	if found, err := s.TopKQuery("t", "a", "b"); err != nil || !slices.Equal(found, []bool{false, true}) {
+		t.Errorf("TopKQuery = %v, %v; want [false true]", found, err)
+	}
+	if _, err := s.TopKList("missing"); err != ErrNoSuchKey {
+		t.Errorf("TopKList(missing) = %v, want ErrNoSuchKey", err)
+	}
+}
+
+// Heavy hitters come out on top, most frequent first, among many items
+// seen once.
+func TestTopKHeavyHitters(t *testing.T) {
+	s := New()
+	if err := s.TopKReserve("t", 3, TopKDefaultWidth, TopKDefaultDepth, TopKDefaultDecay); err != nil {
+		t.Fatal(err)
+	}
+	var items []string
+	for i := 0; i < 50; i++ {
+		items = append(items, "a", fmt.Sprint("x", i))
+		if i < 30 {
+			items = append(items, "b")
+		}
+		if i < 20 {
+			items = append(items, "c")
+		}
+	}
+	if _, err := s.TopKAdd("t", items...); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.TopKList("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, it := range list {
+		got = append(got, it.Item)
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("TopKList = %v, want %v", list, want)
+	}
+	if list[0].Count > 50 {
+		t.Errorf("count of a = %d, more than it was added", list[0].Count)
+	}
+}
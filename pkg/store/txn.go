@@ -0,0 +1,142 @@
+package store
+
+// Update runs fn with the store's write lock held, so its reads and writes
+// to any number of keys happen as one step: no other client sees them part
+// way through. fn works on the store through tx only; calling the Store's
+// own methods from fn deadlocks, and tx must not be used once fn returns.
+//
+// Update returns fn's error. Writes made before fn fails are kept: like a
+// Redis script, there is no rollback. Callers that persist or replicate
+// writes themselves collect tx.Changes before fn returns.
+func (s *Store) Update(fn func(tx *Txn) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyAccesses()
+	return fn(&Txn{s: s})
+}
+
+// Change is the state a key was left in by an Update: its value and
+// expiry (0 for none), or Deleted if the key no longer exists.
+type Change struct {
+	Key       string
+	Value     string
+	ExpiresAt int64
+	Deleted   bool
+}
+
+// Txn reads and writes the store inside Update. Its methods behave as the
+// Store methods of the same name.
+type Txn struct {
+	s       *Store
+	changed []string
+	seen    map[string]bool
+}
+
+// Get returns the value of key if present and not expired.
+func (tx *Txn) Get(key string) (string, bool) {
+	s := tx.s
+	now := s.now()
+	s.reads.Add(1)
+	e, ok := s.data[key]
+	if !ok || e.expired(now.Unix()) {
+		s.misses.Add(1)
+		return "", false
+	}
+	// Holding the write lock, the access is applied at once rather than
+	// queued as Get does.
+	e.LastAccess = now.Unix()
+	s.lfuTouch(e, now)
+	s.lru.moveToFront(e)
+	return e.raw(), true
+}
+
+// Exists reports whether key is present and not expired. It does not
+// count as an access.
+func (tx *Txn) Exists(key string) bool {
+	return tx.s.existsLocked(key)
+}
+
+// Set stores value at key, expiring after ttlSeconds; 0 or less means no
+// expiry.
+func (tx *Txn) Set(key, value string, ttlSeconds int64) error {
+	if err := tx.s.setLocked(key, value, ttlSeconds); err != nil {
+		return err
+	}
+	tx.change(key)
+	return nil
+}
+
+// Del removes key and reports whether it existed.
+func (tx *Txn) Del(key string) bool {
+	if !tx.s.delLocked(key) {
+		return false
+	}
+	tx.change(key)
+	return true
+}
+
+// IncrBy adds delta to the integer at key and returns the result.
+func (tx *Txn) IncrBy(key string, delta int64) (int64, error) {
+	n, err := tx.s.incrByLocked(key, delta)
+	if err != nil {
+		return 0, err
+	}
+	tx.change(key)
+	return n, nil
+}
+
+// Expire sets a TTL in seconds on key and reports whether the key exists.
+func (tx *Txn) Expire(key string, ttlSeconds int64) bool {
+	if !tx.s.expireAtLocked(key, tx.s.expiryAt(ttlSeconds)) {
+		return false
+	}
+	tx.change(key)
+	return true
+}
+
+// Persist removes the TTL of key and reports whether there was one.
+func (tx *Txn) Persist(key string) bool {
+	if !tx.s.persistLocked(key) {
+		return false
+	}
+	tx.change(key)
+	return true
+}
+
+// TTL returns the remaining time to live of key in seconds, -1 for a key
+// without one and -2 for a missing key.
+func (tx *Txn) TTL(key string) int64 {
+	return tx.s.ttlLocked(key)
+}
+
+// change notes that key was changed.
+func (tx *Txn) change(key string) {
+	if tx.seen[key] {
+		return
+	}
+	if tx.seen == nil {
+		tx.seen = make(map[string]bool)
+	}
+	tx.seen[key] = true
+	tx.changed = append(tx.changed, key)
+}
+
+// Changes returns the current state of every key changed so far, in the
+// order the keys were first changed.
+func (tx *Txn) Changes() []Change {
+	if len(tx.changed) == 0 {
+		return nil
+	}
+	s := tx.s
+	now := s.now().Unix()
+	res := make([]Change, len(tx.changed))
+	for i, key := range tx.changed {
+		res[i].Key = key
+		if e, ok := s.data[key]; ok && !e.expired(now) {
+			res[i].Value, res[i].ExpiresAt = e.raw(), e.ExpiresAt
+		} else {
+			res[i].Deleted = true
+		}
+	}
+	return res
+}
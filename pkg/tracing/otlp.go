@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otlpExporter posts span batches as OTLP/HTTP JSON.
+type otlpExporter struct {
+	url      string
+	resource otlpResource
+	client   *http.Client
+}
+
+func newOTLPExporter(endpoint, service string) *otlpExporter {
+	return &otlpExporter{
+		url: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		resource: otlpResource{Attributes: []otlpKeyValue{
+			{Key: "service.name", Value: otlpValue{StringValue: &service}},
+		}},
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// export sends spans; failures are logged and the batch is dropped.
+func (e *otlpExporter) export(spans []*Span) {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		slog.Error("tracing: encoding spans failed", "err", err)
+		return
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("tracing: exporting spans failed", "url", e.url, "spans", len(spans), "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		slog.Warn("tracing: collector rejected spans", "url", e.url, "spans", len(spans), "status", resp.Status)
+	}
+}
+
+func (e *otlpExporter) request(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, sp := range spans {
+		o := otlpSpan{
+			TraceID:           hex.EncodeToString(sp.traceID[:]),
+			SpanID:            hex.EncodeToString(sp.spanID[:]),
+			Name:              sp.name,
+			Kind:              sp.kind,
+			StartTimeUnixNano: strconv.FormatInt(sp.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(sp.end.UnixNano(), 10),
+		}
+		if sp.parent != [8]byte{} {
+			o.ParentSpanID = hex.EncodeToString(sp.parent[:])
+		}
+		for _, a := range sp.attrs {
+			o.Attributes = append(o.Attributes, otlpKeyValue{Key: a.key, Value: toValue(a.value)})
+		}
+		if sp.failed {
+			o.Status = &otlpStatus{Code: 2, Message: sp.err}
+		}
+		out = append(out, o)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: e.resource,
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/DakshBaxi/RediGo"},
+			Spans: out,
+		}},
+	}}}
+}
+
+func toValue(v any) otlpValue {
+	switch v := v.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	}
+	s := fmt.Sprint(v)
+	return otlpValue{StringValue: &s}
+}
+
+// The types below mirror the OTLP/JSON trace request. Ids are hex encoded
+// and 64-bit integers are strings, as the protobuf JSON mapping requires.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
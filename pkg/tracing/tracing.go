@@ -0,0 +1,218 @@
+// Package tracing records spans for RediGo operations and exports them to
+// an OpenTelemetry collector over OTLP/HTTP (JSON encoding), without
+// depending on the OpenTelemetry SDK.
+//
+// Spans are queued and sent in batches by a background goroutine; when the
+// queue is full new spans are dropped rather than slowing the caller.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	mrand "math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Span kinds, as defined by OTLP.
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// Config configures a Tracer.
+type Config struct {
+	// Endpoint is the collector's OTLP/HTTP base URL, e.g.
+	// "http://localhost:4318"; spans are posted to Endpoint+"/v1/traces".
+	Endpoint string
+
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string
+
+	// SampleRatio is the fraction of spans recorded, in [0, 1]. Zero means
+	// record everything.
+	SampleRatio float64
+
+	// BatchSize and FlushInterval control how often spans are sent.
+	// Defaults: 512 spans, 5s.
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// Tracer creates spans and hands finished ones to the exporter.
+type Tracer struct {
+	cfg      Config
+	exporter *otlpExporter
+	queue    chan *Span
+	dropped  atomic.Int64
+
+	done     chan struct{}
+	stopOnce sync.Once
+	flushed  chan struct{}
+}
+
+// New starts a tracer exporting to cfg.Endpoint.
+func New(cfg Config) *Tracer {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "redigo"
+	}
+	if cfg.SampleRatio <= 0 || cfg.SampleRatio > 1 {
+		cfg.SampleRatio = 1
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	t := &Tracer{
+		cfg:      cfg,
+		exporter: newOTLPExporter(cfg.Endpoint, cfg.ServiceName),
+		queue:    make(chan *Span, cfg.BatchSize*4),
+		done:     make(chan struct{}),
+		flushed:  make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Span is one timed operation. A nil *Span (returned when the operation is
+// not sampled) ignores every method call, so callers need no checks.
+type Span struct {
+	t       *Tracer
+	traceID [16]byte
+	spanID  [8]byte
+	parent  [8]byte
+	name    string
+	kind    int
+	start   time.Time
+	end     time.Time
+	attrs   []attribute
+	err     string
+	failed  bool
+}
+
+type attribute struct {
+	key   string
+	value any // string, int64, float64 or bool
+}
+
+// Start begins a root span, or returns nil if it is not sampled.
+func (t *Tracer) Start(name string, kind int) *Span {
+	if t == nil {
+		return nil
+	}
+	if t.cfg.SampleRatio < 1 && mrand.Float64() >= t.cfg.SampleRatio {
+		return nil
+	}
+	sp := &Span{t: t, name: name, kind: kind, start: time.Now()}
+	rand.Read(sp.traceID[:])
+	rand.Read(sp.spanID[:])
+	return sp
+}
+
+// StartChild begins a span in the same trace as sp.
+func (sp *Span) StartChild(name string, kind int) *Span {
+	if sp == nil {
+		return nil
+	}
+	c := &Span{t: sp.t, traceID: sp.traceID, parent: sp.spanID, name: name, kind: kind, start: time.Now()}
+	rand.Read(c.spanID[:])
+	return c
+}
+
+// SetString adds a string attribute to the span.
+func (sp *Span) SetString(key, v string) {
+	if sp != nil {
+		sp.attrs = append(sp.attrs, attribute{key, v})
+	}
+}
+
+// SetInt adds an integer attribute to the span.
+func (sp *Span) SetInt(key string, v int64) {
+	if sp != nil {
+		sp.attrs = append(sp.attrs, attribute{key, v})
+	}
+}
+
+// SetBool adds a boolean attribute to the span.
+func (sp *Span) SetBool(key string, v bool) {
+	if sp != nil {
+		sp.attrs = append(sp.attrs, attribute{key, v})
+	}
+}
+
+// SetError marks the span as failed with msg.
+func (sp *Span) SetError(msg string) {
+	if sp != nil {
+		sp.failed = true
+		sp.err = msg
+	}
+}
+
+// End finishes the span and queues it for export.
+func (sp *Span) End() {
+	if sp == nil {
+		return
+	}
+	sp.end = time.Now()
+	select {
+	case sp.t.queue <- sp:
+	default:
+		sp.t.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many spans were discarded because the queue was full.
+func (t *Tracer) Dropped() int64 {
+	return t.dropped.Load()
+}
+
+// Shutdown exports queued spans and stops the tracer. Spans ended after
+// Shutdown are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.stopOnce.Do(func() { close(t.done) })
+	select {
+	case <-t.flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.flushed)
+	ticker := time.NewTicker(t.cfg.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, t.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		t.exporter.export(batch)
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case sp := <-t.queue:
+			batch = append(batch, sp)
+			if len(batch) >= t.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.done:
+			for {
+				select {
+				case sp := <-t.queue:
+					batch = append(batch, sp)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package redigo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/DakshBaxi/RediGo/pkg/store"
+)
+
+// Values are stored as strings; the typed accessors below do the encoding
+// so every caller handles it, and its errors, the same way: integers as
+// base-10 text (as INCR and GET see them) and structured values as JSON.
+
+// GetInt64 returns the integer stored at key and whether the key exists.
+// A value that isn't a base-10 64-bit integer fails with
+// store.ErrNotInteger, like IncrBy.
+func (e *Embedded) GetInt64(key string) (int64, bool, error) {
+	v, ok := e.s.Get(key)
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, true, store.ErrNotInteger
+	}
+	return n, true, nil
+}
+
+// SetInt64 stores n under key without a TTL.
+func (e *Embedded) SetInt64(key string, n int64) error {
+	return e.Set(key, strconv.FormatInt(n, 10))
+}
+
+// GetJSON decodes the JSON value stored at key into a T and reports
+// whether the key exists. It is a function rather than a method because
+// methods can't have type parameters.
+func GetJSON[T any](e *Embedded, key string) (T, bool, error) {
+	var v T
+	s, ok := e.s.Get(key)
+	if !ok {
+		return v, false, nil
+	}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return v, true, fmt.Errorf("redigo: decode %s: %w", key, err)
+	}
+	return v, true, nil
+}
+
+// SetJSON stores v as a JSON document under key, in the encoding JSON.SET
+// uses, so it can be read with GetJSON or with the JSON commands. Like
+// JSON.SET, it keeps the TTL of an existing key.
+func (e *Embedded) SetJSON(key string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("redigo: encode %s: %w", key, err)
+	}
+	return e.write(func() error {
+		doc, _, err := e.s.JSONSet(key, "$", string(b), store.JSONSetAlways)
+		if err != nil {
+			return err
+		}
+		e.propagateValue(key, doc)
+		return nil
+	})
+}